@@ -470,6 +470,12 @@ func (fb *filterBackend) HeaderByNumber(ctx context.Context, block rpc.BlockNumb
 	if block == rpc.LatestBlockNumber {
 		return fb.bc.CurrentHeader(), nil
 	}
+	if block == rpc.FinalizedBlockNumber {
+		if finalized := fb.bc.CurrentFinalizedBlock(); finalized != nil {
+			return finalized.Header(), nil
+		}
+		return nil, nil
+	}
 	return fb.bc.GetHeaderByNumber(uint64(block.Int64())), nil
 }
 
@@ -510,6 +516,9 @@ func (fb *filterBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.
 func (fb *filterBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return fb.bc.SubscribeChainEvent(ch)
 }
+func (fb *filterBackend) SubscribeFinalizedHeadEvent(ch chan<- core.FinalizedHeadEvent) event.Subscription {
+	return fb.bc.SubscribeFinalizedHeadEvent(ch)
+}
 func (fb *filterBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return fb.bc.SubscribeRemovedLogsEvent(ch)
 }
@@ -2,6 +2,8 @@ package blocksigner
 
 import (
 	"math/rand"
+	"sort"
+	"sync"
 
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 )
@@ -9,18 +11,53 @@ import (
 const defaultTestSignerNumber = 12
 
 var (
-	Signers map[common.Address]struct{}
+	// Signers are the block signers before the first arbiters rotation of the ela chain
+	Signers        map[common.Address]struct{}
 	SelfIsProducer bool
+
+	signerSets = make(map[uint64]map[common.Address]struct{}) // working ela height -> signers
+	heights    []uint64                                       // sorted working ela heights
+	mu         sync.RWMutex
 )
 
+// SetBlockSigners records the block signers working from the ela height.
+func SetBlockSigners(elaHeight uint64, signers []common.Address) {
+	set := make(map[common.Address]struct{}, len(signers))
+	for _, signer := range signers {
+		set[signer] = struct{}{}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := signerSets[elaHeight]; !ok {
+		heights = append(heights, elaHeight)
+		sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	}
+	signerSets[elaHeight] = set
+}
+
+// ResetBlockSigners forgets the signers recorded by SetBlockSigners.
+func ResetBlockSigners() {
+	mu.Lock()
+	defer mu.Unlock()
+	signerSets = make(map[uint64]map[common.Address]struct{})
+	heights = nil
+}
+
+// GetBlockSignerMaps returns the block signers working at the ela height.
 func GetBlockSignerMaps(elaHeight uint64) *map[common.Address]struct{} {
-	// TODO get from ELA
-	return &Signers
+	mu.RLock()
+	defer mu.RUnlock()
+	index := sort.Search(len(heights), func(i int) bool { return heights[i] > elaHeight })
+	if index == 0 {
+		return &Signers
+	}
+	signers := signerSets[heights[index-1]]
+	return &signers
 }
 
-func GetBlockSignersCount() int {
-	// TODO get from ELA
-	return len(Signers)
+// GetBlockSignersCount returns the number of block signers working at the ela height.
+func GetBlockSignersCount(elaHeight uint64) int {
+	return len(*GetBlockSignerMaps(elaHeight))
 }
 
 func ValidateSigner(elaHeight uint64, addr common.Address) bool {
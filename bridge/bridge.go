@@ -0,0 +1,311 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bridge implements the rules of the system contracts bridging the side
+// chain to the ELA main chain: the recharge address crediting the deposits of
+// the main chain and the black contract burning the withdrawals.
+package bridge
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common/hexutil"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
+)
+
+var (
+	// ErrMainTxHashPresence is returned if the main chain transaction has been recharged.
+	ErrMainTxHashPresence = errors.New("main txhash presence")
+
+	// ErrElaToEthAddress is returned if the recharged Ethereum address is incorrect.
+	ErrElaToEthAddress = errors.New("Ethereum address is incorrect ")
+
+	// ErrRechargeFee is returned if the fee of the deposits can't pay for the
+	// gas of the recharge transaction.
+	ErrRechargeFee = errors.New("recharge fee is not enough")
+)
+
+// RechargeAddress is the system address the recharge transactions are sent to,
+// the recharged main chain transactions are recorded in its storage.
+var RechargeAddress = common.Address{}
+
+// StateReader is the state the recharged main chain transactions are read from.
+type StateReader interface {
+	GetState(common.Address, common.Hash) common.Hash
+}
+
+// StateDB is the state the recharged main chain transactions are recorded in.
+type StateDB interface {
+	StateReader
+	SetState(common.Address, common.Hash, common.Hash)
+	GetNonce(common.Address) uint64
+	SetNonce(common.Address, uint64)
+}
+
+// Deposits is the source of the deposits of the main chain credited by the
+// recharges, it is implemented by the spv service following the main chain.
+type Deposits interface {
+	FindOutputFeeAndaddressByTxHash(transactionHash string) (*big.Int, common.Address, *big.Int)
+	FindRechargeOutputs(transactionHash string) []*spv.RechargeOutput
+	UpTransactionIndex(transactionHash string)
+}
+
+// Registry is the registry of the bridge system contracts of a chain.
+type Registry struct {
+	config   *params.ChainConfig
+	deposits Deposits
+}
+
+// NewRegistry returns the bridge system contracts of the chain configuration
+// crediting the deposits, no deposit is found if deposits is nil.
+func NewRegistry(config *params.ChainConfig, deposits Deposits) *Registry {
+	return &Registry{config: config, deposits: deposits}
+}
+
+// BlackContract returns the address of the black contract the withdrawals are
+// sent to, false if the chain has none configured.
+func (r *Registry) BlackContract() (common.Address, bool) {
+	if !common.IsHexAddress(r.config.BlackContractAddr) {
+		return common.Address{}, false
+	}
+	return common.HexToAddress(r.config.BlackContractAddr), true
+}
+
+// IsBlackContract returns whether the address is the black contract.
+func (r *Registry) IsBlackContract(addr common.Address) bool {
+	// The configured checksummed address is matched as it always has been by
+	// consensus, a differently cased configuration matches no contract.
+	return r.config.BlackContractAddr != "" && addr.String() == r.config.BlackContractAddr
+}
+
+// IsBlackContractCreation returns whether the account deploys the black contract
+// with its transaction of the nonce.
+func (r *Registry) IsBlackContractCreation(from common.Address, nonce uint64) bool {
+	return r.IsBlackContract(crypto.CreateAddress(from, nonce))
+}
+
+// PassBalance returns the balance credited to the sender of a system transaction
+// for the duration of the transaction, it must be left untouched by the transaction.
+func (r *Registry) PassBalance() *big.Int {
+	return new(big.Int).SetUint64(r.config.PassBalance)
+}
+
+// RechargeOutputs returns the outputs of the deposit of the main chain
+// transaction to the side chain addresses.
+func (r *Registry) RechargeOutputs(txHash string) []*spv.RechargeOutput {
+	if r.deposits == nil {
+		return nil
+	}
+	return r.deposits.FindRechargeOutputs(txHash)
+}
+
+// RequeueRecharge queues the deposit of the main chain transaction for
+// recharge again, its recharge transaction was dropped before minted.
+func (r *Registry) RequeueRecharge(txHash string) {
+	if r.deposits == nil {
+		return
+	}
+	r.deposits.UpTransactionIndex(txHash)
+}
+
+// rechargeFee returns the fee of the outputs of the deposit which can be credited.
+func (r *Registry) rechargeFee(txHash string) *big.Int {
+	fee, _, _ := r.rechargeTotal(txHash)
+	return fee
+}
+
+// rechargeTotal returns the total fee and output of the outputs of the deposit
+// which can be credited and the address of the first of them.
+func (r *Registry) rechargeTotal(txHash string) (*big.Int, common.Address, *big.Int) {
+	var (
+		fee, output = new(big.Int), new(big.Int)
+		first       common.Address
+	)
+	for _, o := range r.RechargeOutputs(txHash) {
+		if o.Output.Cmp(o.Fee) <= 0 {
+			continue
+		}
+		if output.Sign() == 0 {
+			first = o.Address
+		}
+		fee.Add(fee, o.Fee)
+		output.Add(output, o.Output)
+	}
+	return fee, first, output
+}
+
+// Rules returns the bridge rules active at the block number.
+func (r *Registry) Rules(num *big.Int) Rules {
+	return Rules{
+		Registry:              r,
+		IsMultiOutputRecharge: r.config.IsMultiOutputRecharge(num),
+		IsBatchRecharge:       r.config.IsBatchRecharge(num),
+		IsMainChainData:       r.config.IsMainChainData(num),
+	}
+}
+
+// Rules wraps the bridge system contracts with the versions of the bridge rules
+// activated by the forks of the chain at a block.
+type Rules struct {
+	*Registry
+	IsMultiOutputRecharge bool // every output of a deposit is credited instead of the first one
+	IsBatchRecharge       bool // a recharge transaction may credit a batch of deposits
+	IsMainChainData       bool // the ela heights of the blocks recharging the deposits are recorded for the contracts
+}
+
+// IsRecharge returns whether a transaction to the address with the data recharges
+// main chain transactions, a single one or a batch of them.
+func IsRecharge(to *common.Address, data []byte) bool {
+	if to == nil || *to != RechargeAddress {
+		return false
+	}
+	return len(data) == common.HashLength || spv.IsBatchRecharge(data)
+}
+
+// IsRecharge returns whether a transaction to the address with the data recharges
+// main chain transactions under the rules, a batch of them only from the batch
+// recharge fork.
+func (r Rules) IsRecharge(to *common.Address, data []byte) bool {
+	if to == nil || *to != RechargeAddress {
+		return false
+	}
+	return len(data) == common.HashLength || (r.IsBatchRecharge && spv.IsBatchRecharge(data))
+}
+
+// Recharge is a recharge transaction checked against the deposits of the main chain.
+type Recharge struct {
+	Batch  bool
+	Hashes []string // main chain transactions credited by the recharge
+
+	Fee    *big.Int       // fee of the deposits paying for the gas of the recharge
+	To     common.Address // credited address of a single recharge
+	Output *big.Int       // credited outputs of a single recharge
+}
+
+// CoversGas returns whether the fee of the deposits pays for the gas at the price.
+func (rc *Recharge) CoversGas(gas uint64, gasPrice *big.Int) bool {
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(gas), gasPrice)
+	return rc.Fee.Sign() > 0 && rc.Fee.Cmp(cost) >= 0
+}
+
+// FindRecharge returns the fee, address and output of the recharge of the main
+// chain transaction, the outputs which can be credited are summed after the
+// multi-output fork like in a batch recharge.
+func (r Rules) FindRecharge(txHash string) (*big.Int, common.Address, *big.Int) {
+	if r.IsMultiOutputRecharge {
+		return r.rechargeTotal(txHash)
+	}
+	if r.deposits == nil {
+		return new(big.Int), common.Address{}, new(big.Int)
+	}
+	return r.deposits.FindOutputFeeAndaddressByTxHash(txHash)
+}
+
+// CheckRecharge checks a transaction to the address with the data against the
+// deposits of the main chain and the recharges recorded in the state. It returns
+// nil if the transaction is not a recharge, this is the validation shared by
+// the transaction pool and the state transition.
+func (r Rules) CheckRecharge(state StateReader, to *common.Address, data []byte) (*Recharge, error) {
+	if to == nil || *to != RechargeAddress {
+		return nil, nil
+	}
+	if r.IsBatchRecharge && spv.IsBatchRecharge(data) {
+		pending, fee := r.BatchRecharges(state, data)
+		if len(pending) == 0 {
+			return nil, ErrMainTxHashPresence
+		}
+		return &Recharge{Batch: true, Hashes: pending, Fee: fee}, nil
+	}
+	if len(data) != common.HashLength {
+		return nil, nil
+	}
+	txHash := hexutil.Encode(data)
+	fee, addr, output := r.FindRecharge(txHash)
+	if addr == RechargeAddress {
+		return nil, ErrElaToEthAddress
+	}
+	if state.GetState(RechargeAddress, common.HexToHash(txHash)) != (common.Hash{}) || output.Cmp(fee) <= 0 {
+		return nil, ErrMainTxHashPresence
+	}
+	return &Recharge{Hashes: []string{txHash}, Fee: fee, To: addr, Output: output}, nil
+}
+
+// CompleteRecharge records the main chain transactions of the recharge as
+// recharged by the transaction, in a block sealed at the ela height.
+func (r Rules) CompleteRecharge(state StateDB, rc *Recharge, tx common.Hash, elaHeight uint64) {
+	for _, txHash := range rc.Hashes {
+		state.SetState(RechargeAddress, common.HexToHash(txHash), tx)
+		state.SetNonce(RechargeAddress, state.GetNonce(RechargeAddress)+1)
+		if r.IsMainChainData && elaHeight > 0 {
+			state.SetState(RechargeAddress, RechargedElaHeightKey(common.HexToHash(txHash)), common.BigToHash(new(big.Int).SetUint64(elaHeight)))
+		}
+		if rc.Batch || r.IsMultiOutputRecharge {
+			for _, o := range r.RechargeOutputs(txHash) {
+				if o.Output.Cmp(o.Fee) > 0 {
+					state.SetState(RechargeAddress, spv.RechargeOutputKey(txHash, o.Index), tx)
+				}
+			}
+		}
+	}
+}
+
+// RechargedElaHeightKey returns the state key of the recharge address recording
+// the ela height of the block recharging the main chain transaction.
+func RechargedElaHeightKey(hash common.Hash) common.Hash {
+	return crypto.Keccak256Hash(hash.Bytes(), []byte("height"))
+}
+
+// RechargedElaHeight returns the ela height of the block recharging the main
+// chain transaction recorded in the state, the transaction is recharged by that
+// ela height. It is not the main chain height including the transaction, which
+// is not committed to the side chain. 0 is returned if the transaction is not
+// recharged, was recharged before the main chain data fork or in a block
+// without a validated ela height.
+func RechargedElaHeight(state StateReader, hash common.Hash) uint64 {
+	return state.GetState(RechargeAddress, RechargedElaHeightKey(hash)).Big().Uint64()
+}
+
+// BatchRecharges returns the main chain transactions of the batch recharge data
+// which have not been recharged and have outputs to credit, together with
+// their total fee. A transaction listed twice is recharged once.
+func (r *Registry) BatchRecharges(state StateReader, data []byte) ([]string, *big.Int) {
+	var (
+		pending []string
+		fee     = new(big.Int)
+		seen    = make(map[common.Hash]bool)
+	)
+	hashes, ok := spv.DecodeBatchRecharge(data)
+	if !ok {
+		return nil, fee
+	}
+	for _, hash := range hashes {
+		if seen[hash] || state.GetState(RechargeAddress, hash) != (common.Hash{}) {
+			continue
+		}
+		seen[hash] = true
+		txFee := r.rechargeFee(hash.Hex())
+		if txFee.Sign() <= 0 {
+			continue
+		}
+		pending = append(pending, hash.Hex())
+		fee.Add(fee, txFee)
+	}
+	return pending, fee
+}
@@ -0,0 +1,229 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package bridge
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb/memorydb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
+)
+
+type testState struct {
+	storage map[common.Hash]common.Hash
+	nonce   uint64
+}
+
+func newTestState() *testState {
+	return &testState{storage: make(map[common.Hash]common.Hash)}
+}
+
+func (s *testState) GetState(addr common.Address, key common.Hash) common.Hash {
+	if addr != RechargeAddress {
+		return common.Hash{}
+	}
+	return s.storage[key]
+}
+
+func (s *testState) SetState(addr common.Address, key common.Hash, value common.Hash) {
+	if addr == RechargeAddress {
+		s.storage[key] = value
+	}
+}
+
+func (s *testState) GetNonce(common.Address) uint64          { return s.nonce }
+func (s *testState) SetNonce(_ common.Address, nonce uint64) { s.nonce = nonce }
+
+func TestRules(t *testing.T) {
+	config := *params.TestChainConfig
+	config.MultiOutputRechargeBlock = big.NewInt(10)
+	config.BatchRechargeBlock = big.NewInt(20)
+	config.PassBalance = 1000
+	registry := NewRegistry(&config, nil)
+
+	for _, tt := range []struct {
+		number             int64
+		multiOutput, batch bool
+	}{
+		{0, false, false},
+		{10, true, false},
+		{19, true, false},
+		{20, true, true},
+	} {
+		rules := registry.Rules(big.NewInt(tt.number))
+		if rules.IsMultiOutputRecharge != tt.multiOutput || rules.IsBatchRecharge != tt.batch {
+			t.Errorf("rules of block %d mismatch: have %v/%v, want %v/%v", tt.number, rules.IsMultiOutputRecharge, rules.IsBatchRecharge, tt.multiOutput, tt.batch)
+		}
+	}
+	if have := registry.PassBalance(); have.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("pass balance mismatch: have %v, want 1000", have)
+	}
+}
+
+func TestRulesIsRecharge(t *testing.T) {
+	var (
+		recharge = RechargeAddress
+		other    = common.Address{1}
+		single   = common.HexToHash("0x01").Bytes()
+		batch    = spv.EncodeBatchRecharge([]common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")})
+	)
+	for i, tt := range []struct {
+		rules Rules
+		to    *common.Address
+		data  []byte
+		want  bool
+	}{
+		{Rules{}, &recharge, single, true},
+		{Rules{}, &recharge, batch, false},
+		{Rules{IsBatchRecharge: true}, &recharge, batch, true},
+		{Rules{IsBatchRecharge: true}, &other, batch, false},
+		{Rules{IsBatchRecharge: true}, nil, single, false},
+		{Rules{IsBatchRecharge: true}, &recharge, []byte{1}, false},
+	} {
+		if have := tt.rules.IsRecharge(tt.to, tt.data); have != tt.want {
+			t.Errorf("test %d: recharge mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+func TestBlackContract(t *testing.T) {
+	deployer := common.Address{0x01}
+	contract := crypto.CreateAddress(deployer, 3)
+
+	config := *params.TestChainConfig
+	registry := NewRegistry(&config, nil)
+	if _, ok := registry.BlackContract(); ok || registry.IsBlackContract(common.Address{}) {
+		t.Fatalf("black contract found without configuration")
+	}
+	config.BlackContractAddr = contract.String()
+	if have, ok := registry.BlackContract(); !ok || have != contract {
+		t.Errorf("black contract mismatch: have %x, want %x", have, contract)
+	}
+	if !registry.IsBlackContract(contract) || !registry.IsBlackContractCreation(deployer, 3) {
+		t.Errorf("black contract not matched")
+	}
+	if registry.IsBlackContractCreation(deployer, 4) {
+		t.Errorf("black contract matched by another deployment")
+	}
+	// consensus matches the configured checksummed address only
+	config.BlackContractAddr = strings.ToLower(contract.String())
+	if registry.IsBlackContract(contract) {
+		t.Errorf("black contract matched by a differently cased address")
+	}
+}
+
+func TestCheckRecharge(t *testing.T) {
+	spvdb := memorydb.New()
+
+	var (
+		single, multi, invalid, unknown = common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03"), common.HexToHash("0x04")
+		addr1, addr2                    = common.Address{1}, common.Address{2}
+	)
+	for hash, outputs := range map[common.Hash][]spv.DepositOutput{
+		single:  {{Address: addr1.String(), Amount: 100000000, Fee: 10000}},
+		multi:   {{Address: addr1.String(), Amount: 100000000, Fee: 10000}, {Address: addr2.String(), Amount: 200000000, Fee: 10000}, {Address: addr2.String(), Amount: 10000, Fee: 10000}},
+		invalid: {{Address: "ELA", Amount: 100000000, Fee: 10000}},
+	} {
+		if err := spv.WriteDeposit(spvdb, &spv.DepositRecord{MainTxHash: hash, Outputs: outputs, Fee: 10000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := *params.TestChainConfig
+	config.MultiOutputRechargeBlock = big.NewInt(1)
+	config.BatchRechargeBlock = big.NewInt(2)
+	config.MainChainDataBlock = big.NewInt(3)
+	registry := NewRegistry(&config, spv.New(&spv.Config{}, spvdb, nil, nil))
+	state := newTestState()
+
+	// transactions to other addresses or with other data are not recharges
+	other := common.Address{0xff}
+	if rc, err := registry.Rules(common.Big0).CheckRecharge(state, &other, single.Bytes()); rc != nil || err != nil {
+		t.Errorf("recharge to another address: have %v, %v", rc, err)
+	}
+	batch := spv.EncodeBatchRecharge([]common.Hash{single, multi})
+	if rc, err := registry.Rules(common.Big1).CheckRecharge(state, &RechargeAddress, batch); rc != nil || err != nil {
+		t.Errorf("batch recharge before the fork: have %v, %v", rc, err)
+	}
+
+	for _, tt := range []struct {
+		number *big.Int
+		hash   common.Hash
+		err    error
+		fee    *big.Int
+		output *big.Int
+	}{
+		{common.Big0, single, nil, spv.SelaToWei(10000), spv.SelaToWei(100000000)},
+		{common.Big0, multi, nil, spv.SelaToWei(10000), spv.SelaToWei(100000000)},
+		{common.Big1, multi, nil, spv.SelaToWei(20000), spv.SelaToWei(300000000)},
+		{common.Big1, invalid, ErrElaToEthAddress, nil, nil},
+		{common.Big1, unknown, ErrElaToEthAddress, nil, nil},
+	} {
+		rc, err := registry.Rules(tt.number).CheckRecharge(state, &RechargeAddress, tt.hash.Bytes())
+		if err != tt.err {
+			t.Errorf("recharge of %x at %v: have error %v, want %v", tt.hash, tt.number, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if rc.Batch || rc.To != addr1 || rc.Fee.Cmp(tt.fee) != 0 || rc.Output.Cmp(tt.output) != 0 {
+			t.Errorf("recharge of %x at %v mismatch: have %+v", tt.hash, tt.number, rc)
+		}
+	}
+
+	rules := registry.Rules(big.NewInt(2))
+	rc, err := rules.CheckRecharge(state, &RechargeAddress, batch)
+	if err != nil || !rc.Batch || len(rc.Hashes) != 2 || rc.Fee.Cmp(spv.SelaToWei(30000)) != 0 {
+		t.Fatalf("batch recharge mismatch: have %+v, %v", rc, err)
+	}
+	if !rc.CoversGas(30000, spv.SelaToWei(1)) || rc.CoversGas(30001, spv.SelaToWei(1)) {
+		t.Errorf("batch recharge fee covers the wrong gas")
+	}
+
+	// completed recharges are rejected, the ela heights of the blocks recharging
+	// them are recorded from the main chain data fork
+	tx := common.Hash{0xaa}
+	rules.CompleteRecharge(state, rc, tx, 100)
+	if state.nonce != 2 || state.GetState(RechargeAddress, spv.RechargeOutputKey(multi.Hex(), 1)) != tx {
+		t.Errorf("recharge completion not recorded")
+	}
+	if state.GetState(RechargeAddress, spv.RechargeOutputKey(multi.Hex(), 2)) != (common.Hash{}) {
+		t.Errorf("recharge completion recorded for an output not covering its fee")
+	}
+	if height := RechargedElaHeight(state, single); height != 0 {
+		t.Errorf("ela height recorded before the fork: have %d", height)
+	}
+	registry.Rules(big.NewInt(3)).CompleteRecharge(state, &Recharge{Hashes: []string{single.Hex()}}, tx, 100)
+	if height := RechargedElaHeight(state, single); height != 100 {
+		t.Errorf("ela height mismatch: have %d, want 100", height)
+	}
+	registry.Rules(big.NewInt(3)).CompleteRecharge(state, &Recharge{Hashes: []string{unknown.Hex()}}, tx, 0)
+	if height := RechargedElaHeight(state, unknown); height != 0 {
+		t.Errorf("ela height of a block without one: have %d", height)
+	}
+	if _, err := rules.CheckRecharge(state, &RechargeAddress, single.Bytes()); err != ErrMainTxHashPresence {
+		t.Errorf("completed recharge: have error %v, want %v", err, ErrMainTxHashPresence)
+	}
+	if _, err := rules.CheckRecharge(state, &RechargeAddress, batch); err != ErrMainTxHashPresence {
+		t.Errorf("completed batch recharge: have error %v, want %v", err, ErrMainTxHashPresence)
+	}
+}
@@ -87,6 +87,10 @@ var (
 		Value: filepath.Join(node.DefaultDataDir(), "keystore"),
 		Usage: "Directory for the keystore",
 	}
+	dposKeystoreFlag = cli.StringFlag{
+		Name:  "dpos.keystore",
+		Usage: "ELA keystore of a side chain producer key to serve on the dpos namespace",
+	}
 	configdirFlag = cli.StringFlag{
 		Name:  "configdir",
 		Value: DefaultConfigDir(),
@@ -202,6 +206,7 @@ func init() {
 	app.Flags = []cli.Flag{
 		logLevelFlag,
 		keystoreFlag,
+		dposKeystoreFlag,
 		configdirFlag,
 		chainIdFlag,
 		utils.LightKDFFlag,
@@ -534,13 +539,27 @@ func signer(c *cli.Context) error {
 			Service:   api,
 			Version:   "1.0"},
 	}
+	modules := []string{"account"}
+	if dposKeystore := c.GlobalString(dposKeystoreFlag.Name); dposKeystore != "" {
+		dposAPI, err := core.NewDposSignerAPI(dposKeystore, ui, pwStorage)
+		if err != nil {
+			utils.Fatalf("Could not open producer keystore: %v", err)
+		}
+		rpcAPI = append(rpcAPI, rpc.API{
+			Namespace: "dpos",
+			Public:    true,
+			Service:   dposAPI,
+			Version:   "1.0"})
+		modules = append(modules, "dpos")
+		log.Info("Producer keystore loaded", "keystore", dposKeystore)
+	}
 	if c.GlobalBool(utils.RPCEnabledFlag.Name) {
 		vhosts := splitAndTrim(c.GlobalString(utils.RPCVirtualHostsFlag.Name))
 		cors := splitAndTrim(c.GlobalString(utils.RPCCORSDomainFlag.Name))
 
 		// start http server
 		httpEndpoint := fmt.Sprintf("%s:%d", c.GlobalString(utils.RPCListenAddrFlag.Name), c.Int(rpcPortFlag.Name))
-		listener, _, err := rpc.StartHTTPEndpoint(httpEndpoint, rpcAPI, []string{"account"}, cors, vhosts, rpc.DefaultHTTPTimeouts)
+		listener, _, err := rpc.StartHTTPEndpoint(httpEndpoint, rpcAPI, modules, cors, vhosts, rpc.DefaultHTTPTimeouts)
 		if err != nil {
 			utils.Fatalf("Could not start RPC api: %v", err)
 		}
@@ -237,6 +237,11 @@ func importChain(ctx *cli.Context) error {
 	chain, db := utils.MakeChain(ctx, stack)
 	defer db.Close()
 
+	// The imported recharges credit the deposits of the spv database
+	deposits := utils.MakeSpvService(ctx)
+	defer deposits.Close()
+	chain.SetDeposits(deposits)
+
 	// Start periodically gathering memory profiles
 	var peakMemAlloc, peakMemSys uint64
 	go func() {
@@ -400,6 +405,9 @@ func copyDb(ctx *cli.Context) error {
 	defer stack.Close()
 
 	chain, chainDb := utils.MakeChain(ctx, stack)
+	deposits := utils.MakeSpvService(ctx)
+	defer deposits.Close()
+	chain.SetDeposits(deposits)
 	syncMode := *utils.GlobalTextMarshaler(ctx, utils.SyncModeFlag.Name).(*downloader.SyncMode)
 
 	var syncBloom *trie.SyncBloom
@@ -26,15 +26,14 @@ import (
 	"unicode"
 
 	cli "gopkg.in/urfave/cli.v1"
-	"path/filepath"
 
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/cmd/utils"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/dashboard"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/eth"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/node"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/relayer"
 	whisper "github.com/elastos/Elastos.ELA.SideChain.ETH/whisper/whisperv6"
-	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
 	"github.com/naoina/toml"
 )
 
@@ -82,6 +81,7 @@ type gethConfig struct {
 	Node      node.Config
 	Ethstats  ethstatsConfig
 	Dashboard dashboard.Config
+	Relayer   relayer.Config
 }
 
 func loadConfig(file string, cfg *gethConfig) error {
@@ -116,6 +116,7 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 		Shh:       whisper.DefaultConfig,
 		Node:      defaultNodeConfig(),
 		Dashboard: dashboard.DefaultConfig,
+		Relayer:   relayer.DefaultConfig,
 	}
 
 	// Load config file.
@@ -137,6 +138,7 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	}
 	utils.SetShhConfig(ctx, stack, &cfg.Shh)
 	utils.SetDashboardConfig(ctx, &cfg.Dashboard)
+	utils.SetRelayerConfig(ctx, &cfg.Relayer)
 
 	return stack, cfg
 }
@@ -154,25 +156,10 @@ func enableWhisper(ctx *cli.Context) bool {
 func makeFullNode(ctx *cli.Context) *node.Node {
 	stack, cfg := makeConfigNode(ctx)
 
-	var SpvDbDir string
-	switch {
-	case ctx.GlobalIsSet(utils.DataDirFlag.Name):
-		SpvDbDir = ctx.GlobalString(utils.DataDirFlag.Name)
-	case ctx.GlobalBool(utils.DeveloperFlag.Name):
-		SpvDbDir = "" // unless explicitly requested, use memory databases
-	case ctx.GlobalBool(utils.TestnetFlag.Name):
-		SpvDbDir = filepath.Join(node.DefaultDataDir(), "testnet")
-	case ctx.GlobalBool(utils.RinkebyFlag.Name):
-		SpvDbDir = filepath.Join(node.DefaultDataDir(), "rinkeby")
-	default:
-		SpvDbDir = node.DefaultDataDir()
-	}
-	spv.SpvDbInit(SpvDbDir)
-
 	if ctx.GlobalIsSet(utils.OverrideIstanbulFlag.Name) {
 		cfg.Eth.OverrideIstanbul = new(big.Int).SetUint64(ctx.GlobalUint64(utils.OverrideIstanbulFlag.Name))
 	}
-	utils.RegisterEthService(stack, &cfg.Eth)
+	utils.RegisterEthService(ctx, stack, &cfg.Eth)
 
 	if ctx.GlobalBool(utils.DashboardEnabledFlag.Name) {
 		utils.RegisterDashboardService(stack, &cfg.Dashboard, gitCommit)
@@ -196,6 +183,10 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	if ctx.GlobalIsSet(utils.GraphQLEnabledFlag.Name) {
 		utils.RegisterGraphQLService(stack, cfg.Node.GraphQLEndpoint(), cfg.Node.GraphQLCors, cfg.Node.GraphQLVirtualHosts, cfg.Node.HTTPTimeouts)
 	}
+	// Add the withdrawal relayer if requested.
+	if ctx.GlobalBool(utils.RelayerEnabledFlag.Name) {
+		utils.RegisterRelayerService(stack, &cfg.Relayer)
+	}
 	// Add the Ethereum Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
@@ -0,0 +1,395 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of Elastos.ELA.SideChain.ETH.
+//
+// Elastos.ELA.SideChain.ETH is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elastos.ELA.SideChain.ETH is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elastos.ELA.SideChain.ETH. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/cmd/utils"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/state"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/vm"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/relayer"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var crosschainCommand = cli.Command{
+	Name:      "crosschain",
+	Usage:     "Manage the cross chain transactions",
+	ArgsUsage: "",
+	Category:  "BLOCKCHAIN COMMANDS",
+	Subcommands: []cli.Command{
+		{
+			Name:   "audit",
+			Usage:  "Reconcile the recharges and withdrawals with the SPV deposit records",
+			Action: utils.MigrateFlags(auditCrossChain),
+			Flags: []cli.Flag{
+				utils.DataDirFlag,
+				utils.CacheFlag,
+				utils.SyncModeFlag,
+				utils.AuditFromFlag,
+				utils.AuditToFlag,
+				utils.AuditRangeFlag,
+			},
+			Description: `
+    geth crosschain audit --audit.from 0 --audit.to 100000
+
+Walks the blocks and sums the recharges credited to the side chain, the
+withdrawals burned at the black contract and the pass balance credits, per
+range of --audit.range blocks. The recharges are checked against the SPV
+deposit records and the replay markers of the current state, the withdrawals
+are traced by the payload logs of the black contract and the deposit records
+of the audited main chain heights left uncredited are listed. The report is
+printed as JSON with the discrepancies listed per transaction.`,
+		},
+	},
+}
+
+// weiAmount is an amount of wei printed as a decimal string.
+type weiAmount struct{ big.Int }
+
+func (a weiAmount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Int.String())
+}
+
+// auditRange is the sum of the cross chain transactions of a block range.
+type auditRange struct {
+	From               uint64    `json:"from"`
+	To                 uint64    `json:"to"`
+	Recharges          int       `json:"recharges"`          // main chain transactions recharged
+	Recharged          weiAmount `json:"recharged"`          // credited to the side chain addresses
+	Expected           weiAmount `json:"expected"`           // expected by the SPV deposit records
+	RechargeFees       weiAmount `json:"rechargeFees"`       // deposit fees of the recharges
+	Withdrawals        int       `json:"withdrawals"`        // withdraw transactions
+	Withdrawn          weiAmount `json:"withdrawn"`          // burned at the black contract
+	PassBalanceCredits weiAmount `json:"passBalanceCredits"` // credited to pay the gas of the system transactions
+}
+
+func (r *auditRange) add(other *auditRange) {
+	r.Recharges += other.Recharges
+	r.Recharged.Add(&r.Recharged.Int, &other.Recharged.Int)
+	r.Expected.Add(&r.Expected.Int, &other.Expected.Int)
+	r.RechargeFees.Add(&r.RechargeFees.Int, &other.RechargeFees.Int)
+	r.Withdrawals += other.Withdrawals
+	r.Withdrawn.Add(&r.Withdrawn.Int, &other.Withdrawn.Int)
+	r.PassBalanceCredits.Add(&r.PassBalanceCredits.Int, &other.PassBalanceCredits.Int)
+}
+
+// auditDiscrepancy is a cross chain transaction not matching the records.
+type auditDiscrepancy struct {
+	Block    uint64       `json:"block,omitempty"`
+	Tx       *common.Hash `json:"tx,omitempty"`
+	MainTx   *common.Hash `json:"mainTx,omitempty"`
+	Reason   string       `json:"reason"`
+	Expected string       `json:"expected,omitempty"`
+	Actual   string       `json:"actual,omitempty"`
+}
+
+// auditReport is the reconciliation of the cross chain transactions of the audited blocks.
+type auditReport struct {
+	From          uint64              `json:"from"`
+	To            uint64              `json:"to"`
+	ReplayMarkers bool                `json:"replayMarkersChecked"`
+	Ranges        []*auditRange       `json:"ranges"`
+	Total         *auditRange         `json:"total"`
+	Discrepancies []*auditDiscrepancy `json:"discrepancies"`
+}
+
+// crossChainAuditor reconciles the blocks with the SPV deposit records.
+type crossChainAuditor struct {
+	config        *params.ChainConfig
+	blackContract common.Address
+	markers       *state.StateDB // state holding the replay markers, nil if not available
+	deposits      *spv.Service   // spv service holding the deposits of the main chain
+	recharged     map[common.Hash]common.Hash
+	rolledBack    map[common.Hash]bool // minted deposits rolled back on the main chain
+	elaFrom       uint64               // lowest main chain height of the audited blocks, 0 if none
+	elaTo         uint64               // highest main chain height of the audited blocks
+	report        *auditReport
+}
+
+func newCrossChainAuditor(config *params.ChainConfig, markers *state.StateDB, deposits *spv.Service, from, to uint64) *crossChainAuditor {
+	a := &crossChainAuditor{
+		config:     config,
+		markers:    markers,
+		deposits:   deposits,
+		recharged:  make(map[common.Hash]common.Hash),
+		rolledBack: make(map[common.Hash]bool),
+		report: &auditReport{
+			From:          from,
+			To:            to,
+			ReplayMarkers: markers != nil,
+			Total:         &auditRange{From: from, To: to},
+			Discrepancies: []*auditDiscrepancy{},
+		},
+	}
+	a.blackContract, _ = bridge.NewRegistry(config, nil).BlackContract()
+	return a
+}
+
+func (a *crossChainAuditor) discrepancy(block uint64, tx common.Hash, mainTx *common.Hash, reason string, expected, actual *big.Int) {
+	d := &auditDiscrepancy{Block: block, MainTx: mainTx, Reason: reason}
+	if tx != (common.Hash{}) {
+		d.Tx = &tx
+	}
+	if expected != nil {
+		d.Expected = expected.String()
+	}
+	if actual != nil {
+		d.Actual = actual.String()
+	}
+	a.report.Discrepancies = append(a.report.Discrepancies, d)
+}
+
+// addBlock sums the cross chain transactions of the block into the range.
+func (a *crossChainAuditor) addBlock(block *types.Block, receipts types.Receipts, r *auditRange) {
+	if height := core.ElaHeight(a.config, block.Header()); height > 0 {
+		if a.elaFrom == 0 || height < a.elaFrom {
+			a.elaFrom = height
+		}
+		if height > a.elaTo {
+			a.elaTo = height
+		}
+	}
+	passBalance := bridge.NewRegistry(a.config, nil).PassBalance()
+	for i, tx := range block.Transactions() {
+		if i >= len(receipts) {
+			a.discrepancy(block.NumberU64(), tx.Hash(), nil, "receipt not found", nil, nil)
+			continue
+		}
+		receipt := receipts[i]
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+		switch {
+		case core.IsRechargeTx(tx):
+			if a.addRecharge(block, tx, receipt, r) {
+				r.PassBalanceCredits.Add(&r.PassBalanceCredits.Int, passBalance)
+			}
+		case tx.To() == nil && a.blackContract != (common.Address{}) && receipt.ContractAddress == a.blackContract:
+			r.PassBalanceCredits.Add(&r.PassBalanceCredits.Int, passBalance)
+		case a.blackContract != (common.Address{}):
+			a.addWithdrawal(block, tx, receipt, r)
+		}
+	}
+}
+
+// addRecharge checks the outputs credited by the recharge transaction against
+// the deposit records, it returns false if nothing is credited.
+func (a *crossChainAuditor) addRecharge(block *types.Block, tx *types.Transaction, receipt *types.Receipt, r *auditRange) bool {
+	var (
+		credits = make(map[common.Hash]*big.Int)
+		order   []common.Hash
+	)
+	for _, l := range receipt.Logs {
+		if l.Address != (common.Address{}) || len(l.Topics) != 5 || l.Topics[0] != vm.RechargeTopic {
+			continue
+		}
+		mainTx := l.Topics[2]
+		if credits[mainTx] == nil {
+			credits[mainTx] = new(big.Int)
+			order = append(order, mainTx)
+		}
+		credits[mainTx].Add(credits[mainTx], l.Topics[4].Big())
+	}
+	number := block.NumberU64()
+	for _, mainTx := range order {
+		mainTx := mainTx
+		credit := credits[mainTx]
+		r.Recharges++
+		r.Recharged.Add(&r.Recharged.Int, credit)
+
+		if first, ok := a.recharged[mainTx]; ok {
+			a.discrepancy(number, tx.Hash(), &mainTx, "duplicate recharge, first recharged by "+first.Hex(), nil, credit)
+		} else {
+			a.recharged[mainTx] = tx.Hash()
+		}
+		if a.markers != nil {
+			if marker := a.markers.GetState(common.Address{}, mainTx); marker != a.recharged[mainTx] {
+				a.discrepancy(number, tx.Hash(), &mainTx, "replay marker is "+marker.Hex(), nil, nil)
+			}
+		}
+		record := a.deposits.GetDeposit(mainTx)
+		if record == nil {
+			a.discrepancy(number, tx.Hash(), &mainTx, "deposit not found", nil, credit)
+			continue
+		}
+		if record.Status == spv.DepositRolledBack || a.rolledBack[mainTx] {
+			a.discrepancy(number, tx.Hash(), &mainTx, "deposit rolled back", nil, credit)
+		}
+		expected, fee := expectedRecharge(record, a.config.IsMultiOutputRecharge(block.Number()))
+		r.Expected.Add(&r.Expected.Int, expected)
+		r.RechargeFees.Add(&r.RechargeFees.Int, fee)
+		if expected.Cmp(credit) != 0 {
+			a.discrepancy(number, tx.Hash(), &mainTx, "credit mismatch", expected, credit)
+		}
+	}
+	return len(order) > 0
+}
+
+// addWithdrawal sums the value the transaction burned at the black contract,
+// traced by the payload logs of the contract if it is called by another
+// contract. The value of a direct call is checked against the announced assets.
+func (a *crossChainAuditor) addWithdrawal(block *types.Block, tx *types.Transaction, receipt *types.Receipt, r *auditRange) {
+	var (
+		withdrawals = relayer.DecodeWithdrawals(a.blackContract, receipt.Logs)
+		direct      = tx.To() != nil && *tx.To() == a.blackContract && tx.Value().Sign() > 0
+		announced   = new(big.Int)
+	)
+	if !direct && len(withdrawals) == 0 {
+		return
+	}
+	for _, withdrawal := range withdrawals {
+		for _, asset := range withdrawal.Assets {
+			announced.Add(announced, asset.Amount)
+		}
+	}
+	burned := announced
+	if direct {
+		burned = tx.Value()
+		if announced.Cmp(burned) != 0 {
+			a.discrepancy(block.NumberU64(), tx.Hash(), nil, "withdraw mismatch", burned, announced)
+		}
+	}
+	r.Withdrawals++
+	r.Withdrawn.Add(&r.Withdrawn.Int, burned)
+}
+
+// addUncredited lists the deposit records of the audited main chain heights
+// credited neither by the audited blocks nor by the replay markers. All the
+// records are checked if the blocks carry no main chain height.
+func (a *crossChainAuditor) addUncredited(records []*spv.DepositRecord) {
+	multiOutput := a.config.IsMultiOutputRecharge(new(big.Int).SetUint64(a.report.To))
+	for _, record := range records {
+		mainTx := record.MainTxHash
+		if _, ok := a.recharged[mainTx]; ok || record.Status == spv.DepositRolledBack {
+			continue
+		}
+		if a.elaFrom > 0 && (uint64(record.MainHeight) < a.elaFrom || uint64(record.MainHeight) > a.elaTo) {
+			continue
+		}
+		if a.markers != nil && a.markers.GetState(common.Address{}, mainTx) != (common.Hash{}) {
+			continue
+		}
+		if expected, _ := expectedRecharge(record, multiOutput); expected.Sign() > 0 {
+			a.discrepancy(0, common.Hash{}, &mainTx, "deposit not credited", expected, nil)
+		}
+	}
+}
+
+// expectedRecharge returns the credit and the fee of the deposit record, only
+// the first output is credited before the multi-output recharge fork.
+func expectedRecharge(record *spv.DepositRecord, multiOutput bool) (*big.Int, *big.Int) {
+	credit, fee := new(big.Int), new(big.Int)
+	for _, o := range record.Outputs {
+		if common.IsHexAddress(o.Address) && o.Amount > o.Fee {
+			credit.Add(credit, spv.SelaToWei(o.Amount-o.Fee))
+			fee.Add(fee, spv.SelaToWei(o.Fee))
+		}
+		if !multiOutput {
+			break
+		}
+	}
+	return credit, fee
+}
+
+// auditChain reconciles the blocks from the first to the last, summed per range of size blocks.
+func auditChain(chain *core.BlockChain, deposits *spv.Service, from, to, size uint64) *auditReport {
+	markers, err := chain.State()
+	if err != nil {
+		log.Warn("Replay markers are not checked", "err", err)
+		markers = nil
+	}
+	var (
+		a      = newCrossChainAuditor(chain.Config(), markers, deposits, from, to)
+		r      *auditRange
+		logged = time.Now()
+	)
+	for _, record := range deposits.GetRollbackRecords() {
+		a.rolledBack[common.HexToHash(record.ElaTx)] = true
+	}
+	for number := from; number <= to; number++ {
+		if r == nil || number > r.To {
+			r = &auditRange{From: number, To: number + size - 1}
+			if r.To > to || r.To < number {
+				r.To = to
+			}
+			a.report.Ranges = append(a.report.Ranges, r)
+		}
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			utils.Fatalf("Block %d not found", number)
+		}
+		a.addBlock(block, chain.GetReceiptsByHash(block.Hash()), r)
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Auditing cross chain transactions", "number", number, "to", to)
+			logged = time.Now()
+		}
+		if number == to {
+			break
+		}
+	}
+	if db := deposits.GetDatabase(); db != nil {
+		a.addUncredited(spv.ReadAllDeposits(db))
+	}
+	for _, r := range a.report.Ranges {
+		a.report.Total.add(r)
+	}
+	return a.report
+}
+
+func auditCrossChain(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	defer stack.Close()
+	deposits := utils.MakeSpvService(ctx)
+	defer deposits.Close()
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+	chain.SetDeposits(deposits)
+
+	var (
+		head = chain.CurrentBlock().NumberU64()
+		from = ctx.Uint64(utils.AuditFromFlag.Name)
+		to   = ctx.Uint64(utils.AuditToFlag.Name)
+		size = ctx.Uint64(utils.AuditRangeFlag.Name)
+	)
+	if to == 0 || to > head {
+		to = head
+	}
+	if from > to {
+		utils.Fatalf("Audit range is empty: from %d to %d", from, to)
+	}
+	if size == 0 {
+		size = to - from + 1
+	}
+	start := time.Now()
+	report := auditChain(chain, deposits, from, to, size)
+	log.Info("Cross chain audit done", "blocks", to-from+1, "discrepancies", len(report.Discrepancies), "elapsed", common.PrettyDuration(time.Since(start)))
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
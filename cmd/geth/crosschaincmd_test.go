@@ -0,0 +1,205 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of Elastos.ELA.SideChain.ETH.
+//
+// Elastos.ELA.SideChain.ETH is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Elastos.ELA.SideChain.ETH is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Elastos.ELA.SideChain.ETH. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/accounts/abi"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus/ethash"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/vm"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb/memorydb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rlp"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
+)
+
+// Tests that the audit sums the recharges per range and reports the recharges
+// not matching the deposit records.
+func TestAuditCrossChain(t *testing.T) {
+	spvdb := memorydb.New()
+	deposits := spv.New(&spv.Config{}, spvdb, nil, nil)
+
+	var (
+		first, second          = common.HexToHash("0x01"), common.HexToHash("0x02")
+		uncredited, rolledBack = common.HexToHash("0x03"), common.HexToHash("0x04")
+		records                = []*spv.DepositRecord{
+			{MainTxHash: first, Outputs: []spv.DepositOutput{{Address: common.Address{1}.String(), Amount: 100000000, Fee: 10000}}, Fee: 10000},
+			{MainTxHash: second, Outputs: []spv.DepositOutput{{Address: common.Address{2}.String(), Amount: 200000000, Fee: 10000}}, Fee: 10000},
+			{MainTxHash: uncredited, Outputs: []spv.DepositOutput{{Address: common.Address{3}.String(), Amount: 300000000, Fee: 10000}}, Fee: 10000},
+			{MainTxHash: rolledBack, Outputs: []spv.DepositOutput{{Address: common.Address{4}.String(), Amount: 400000000, Fee: 10000}}, Fee: 10000, Status: spv.DepositRolledBack},
+		}
+	)
+	for _, record := range records {
+		if err := spv.WriteDeposit(spvdb, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := *params.TestChainConfig
+	config.MultiOutputRechargeBlock = big.NewInt(0)
+	config.BatchRechargeBlock = big.NewInt(0)
+	config.PassBalance = 1000
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		gspec  = &core.Genesis{
+			Config: &config,
+			Alloc:  core.GenesisAlloc{sender: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(config.GetChainIDByHeight(big.NewInt(0)))
+	)
+	chain, err := core.NewBlockChain(db, nil, &config, ethash.NewFaker(), ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	chain.SetDeposits(deposits)
+	blocks, _ := core.GenerateChain(&config, genesis, ethash.NewFaker(), db, 3, func(i int, block *core.BlockGen) {
+		if i == 1 {
+			return
+		}
+		data := spv.EncodeBatchRecharge([]common.Hash{records[i/2].MainTxHash})
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(sender), common.Address{}, common.Big0, 100000, big.NewInt(1000000000), data), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTxWithChain(chain, tx)
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second deposit was recorded with a different amount and the first one
+	// was rolled back on the main chain after being minted
+	records[1].Outputs[0].Amount = 300000000
+	if err := spv.WriteDeposit(spvdb, records[1]); err != nil {
+		t.Fatal(err)
+	}
+	rollback, _ := rlp.EncodeToBytes(&spv.RollbackRecord{ElaTx: strings.TrimPrefix(first.Hex(), "0x"), Height: 10, EthTx: common.Hash{1}})
+	if err := spvdb.Put([]byte(spv.RollbackMinted+strings.TrimPrefix(first.Hex(), "0x")), rollback); err != nil {
+		t.Fatal(err)
+	}
+	report := auditChain(chain, deposits, 1, 3, 2)
+	if len(report.Ranges) != 2 || report.Ranges[0].To != 2 || report.Ranges[1].From != 3 || report.Ranges[1].To != 3 {
+		t.Fatalf("ranges mismatch: have %+v", report.Ranges)
+	}
+	if !report.ReplayMarkers {
+		t.Errorf("replay markers not checked")
+	}
+	want := new(big.Int).Add(spv.SelaToWei(100000000-10000), spv.SelaToWei(200000000-10000))
+	if report.Total.Recharges != 2 || report.Total.Recharged.Cmp(want) != 0 {
+		t.Errorf("recharged mismatch: have %d %v, want 2 %v", report.Total.Recharges, &report.Total.Recharged.Int, want)
+	}
+	if have := report.Ranges[0].PassBalanceCredits.Uint64(); have != 1000 {
+		t.Errorf("pass balance credits mismatch: have %d, want 1000", have)
+	}
+	if len(report.Discrepancies) != 3 {
+		t.Fatalf("discrepancies mismatch: have %d, want 3", len(report.Discrepancies))
+	}
+	if d := report.Discrepancies[0]; d.Block != 1 || *d.MainTx != first || d.Reason != "deposit rolled back" {
+		t.Errorf("rollback discrepancy mismatch: have %+v", d)
+	}
+	if d := report.Discrepancies[1]; d.Block != 3 || *d.MainTx != second || d.Reason != "credit mismatch" || d.Expected != spv.SelaToWei(300000000-10000).String() {
+		t.Errorf("discrepancy mismatch: have %+v", d)
+	}
+	// the deposit never recharged is listed, the rolled back one is not
+	if d := report.Discrepancies[2]; d.Tx != nil || *d.MainTx != uncredited || d.Reason != "deposit not credited" || d.Expected != spv.SelaToWei(300000000-10000).String() {
+		t.Errorf("uncredited discrepancy mismatch: have %+v", d)
+	}
+}
+
+// Tests that the audit traces the withdrawals by the payload logs of the black
+// contract, whether it is called directly or by another contract.
+func TestAuditWithdrawals(t *testing.T) {
+	deposits := spv.New(&spv.Config{}, memorydb.New(), nil, nil)
+
+	parsed, err := abi.JSON(strings.NewReader(`[{"anonymous":false,"inputs":[{"indexed":false,"name":"_addr","type":"string"},{"indexed":false,"name":"_amount","type":"uint256"},{"indexed":false,"name":"_crosschainamount","type":"uint256"},{"indexed":true,"name":"_sender","type":"address"}],"name":"PayloadReceived","type":"event"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := parsed.Events["PayloadReceived"]
+	var (
+		black     = common.HexToAddress("0x0000000000000000000000000000000000000bbb")
+		forwarder = common.HexToAddress("0x0000000000000000000000000000000000000fff")
+		amount    = new(big.Int).Mul(big.NewInt(100000000), big.NewInt(1e10))
+		fee       = big.NewInt(1e14)
+	)
+	payload, err := event.Inputs.NonIndexed().Pack("EKsSQae7goc5oGGxwvgbUxkMsiQhC9ZfJ3", amount, new(big.Int).Sub(amount, fee))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the black contract logs the call data, the forwarder calls it with the value and the call data
+	blackCode := append(append([]byte{0x36, 0x60, 0x00, 0x60, 0x00, 0x37, 0x33, 0x7f}, event.ID().Bytes()...), 0x36, 0x60, 0x00, 0xa2, 0x00)
+	forwarderCode := append(append([]byte{0x36, 0x60, 0x00, 0x60, 0x00, 0x37, 0x60, 0x00, 0x60, 0x00, 0x36, 0x60, 0x00, 0x34, 0x73}, black.Bytes()...), 0x5a, 0xf1, 0x50, 0x00)
+
+	config := *params.TestChainConfig
+	config.BlackContractAddr = black.String()
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		gspec  = &core.Genesis{
+			Config: &config,
+			Alloc: core.GenesisAlloc{
+				sender:    {Balance: new(big.Int).Mul(amount, big.NewInt(10))},
+				black:     {Balance: common.Big0, Code: blackCode},
+				forwarder: {Balance: common.Big0, Code: forwarderCode},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(config.GetChainIDByHeight(big.NewInt(0)))
+	)
+	blocks, _ := core.GenerateChain(&config, genesis, ethash.NewFaker(), db, 2, func(i int, block *core.BlockGen) {
+		to := black
+		if i == 1 {
+			to = forwarder
+		}
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(sender), to, amount, 200000, big.NewInt(1000000000), payload), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTx(tx)
+	})
+	chain, err := core.NewBlockChain(db, nil, &config, ethash.NewFaker(), ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	report := auditChain(chain, deposits, 1, 2, 1)
+	for i, r := range report.Ranges {
+		if r.Withdrawals != 1 || r.Withdrawn.Cmp(amount) != 0 {
+			t.Errorf("range %d: withdrawn mismatch: have %d %v, want 1 %v", i, r.Withdrawals, &r.Withdrawn.Int, amount)
+		}
+	}
+	if len(report.Discrepancies) != 0 {
+		t.Errorf("discrepancies found: %+v", report.Discrepancies[0])
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"os"
 	"runtime"
 	godebug "runtime/debug"
@@ -54,7 +55,6 @@ import (
 	"github.com/elastic/gosigar"
 	"golang.org/x/crypto/ripemd160"
 	"gopkg.in/urfave/cli.v1"
-	"path/filepath"
 )
 
 const (
@@ -164,9 +164,13 @@ var (
 		utils.SpvMonitoringAddrFlag,
 		utils.PassBalance,
 		utils.BlackContractAddr,
+		utils.RelayerEnabledFlag,
+		utils.RelayerAddrFlag,
+		utils.RelayerPortFlag,
 		utils.PreConnectOffset,
 		utils.PbftKeyStore,
 		utils.PbftKeystorePassWord,
+		utils.PbftSigner,
 		utils.PbftIPAddress,
 		utils.PbftDposPort,
 	}
@@ -229,6 +233,8 @@ func init() {
 		removedbCommand,
 		dumpCommand,
 		inspectCommand,
+		// See crosschaincmd.go:
+		crosschainCommand,
 		// See accountcmd.go:
 		accountCommand,
 		walletCommand,
@@ -384,24 +390,8 @@ func calculateGenesisAddress(genesisBlockHash string) (string, error) {
 
 func startSpv(ctx *cli.Context, stack *node.Node) {
 
-	var SpvDataDir string
-	switch {
-	case ctx.GlobalIsSet(utils.DataDirFlag.Name):
-		SpvDataDir = ctx.GlobalString(utils.DataDirFlag.Name)
-	case ctx.GlobalBool(utils.DeveloperFlag.Name):
-		SpvDataDir = "" // unless explicitly requested, use memory databases
-	case ctx.GlobalBool(utils.TestnetFlag.Name):
-		SpvDataDir = filepath.Join(node.DefaultDataDir(), "testnet")
-	case ctx.GlobalBool(utils.RinkebyFlag.Name):
-		SpvDataDir = filepath.Join(node.DefaultDataDir(), "rinkeby")
-	case  ctx.GlobalBool(utils.GoerliFlag.Name):
-		SpvDataDir = filepath.Join(node.DefaultDataDir(), "goerli")
-	default:
-		SpvDataDir = node.DefaultDataDir()
-	}
-
 	var spvCfg = &spv.Config{
-		DataDir: SpvDataDir,
+		DataDir: utils.SpvDataDir(ctx),
 	}
 	// prepare the SPV service config parameters
 	switch {
@@ -453,7 +443,7 @@ func startSpv(ctx *cli.Context, stack *node.Node) {
 		}
 	}
 
-	spv.GetDefaultSingerAddr = func() (common.Address) {
+	spvCfg.Signer = func() (common.Address) {
 		var addr common.Address
 		if wallets := stack.AccountManager().Wallets(); len(wallets) > 0 {
 			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
@@ -464,18 +454,34 @@ func startSpv(ctx *cli.Context, stack *node.Node) {
 		return addr
 	}
 
+	// the recharges are batched once the next block is after the batch recharge fork
+	var fullnode *eth.Ethereum
+	if err := stack.Service(&fullnode); err != nil {
+		log.Info("SPV service is not started, the node is not a full node")
+		return
+	}
+	spvCfg.BatchRecharge = func() bool {
+		chain := fullnode.BlockChain()
+		return chain.Config().IsBatchRecharge(new(big.Int).Add(chain.CurrentBlock().Number(), big.NewInt(1)))
+	}
+	if pbftConfig := fullnode.BlockChain().Config().Pbft; pbftConfig != nil {
+		spvCfg.ArbiterSigners = pbftConfig.ArbiterSigners
+	}
+
 	client, err := stack.Attach()
 	if err != nil {
 		log.Error("Attach client: ", "err", err)
 	}
 
-	if spvService, err := spv.NewService(spvCfg,client); err != nil {
+	spvService := fullnode.Spv()
+	if err := spvService.Init(spvCfg, client); err != nil {
 		utils.Fatalf("SPV service init error: %v", err)
-	} else {
-		MinedBlockSub := stack.EventMux().Subscribe(events.MinedBlockEvent{})
-		OnDutySub := stack.EventMux().Subscribe(events.OnDutyEvent{})
-		go spv.MinedBroadcastLoop(MinedBlockSub, OnDutySub)
-		spvService.Start()
+	}
+	MinedBlockSub := stack.EventMux().Subscribe(events.MinedBlockEvent{})
+	OnDutySub := stack.EventMux().Subscribe(events.OnDutyEvent{})
+	go spvService.MinedBroadcastLoop(MinedBlockSub, OnDutySub)
+	if err := spvService.Start(); err != nil {
+		utils.Fatalf("SPV service start error: %v", err)
 	}
 }
 
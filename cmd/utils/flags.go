@@ -62,7 +62,9 @@ import (
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/p2p/nat"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/p2p/netutil"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/relayer"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/rpc"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
 	whisper "github.com/elastos/Elastos.ELA.SideChain.ETH/whisper/whisperv6"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	cli "gopkg.in/urfave/cli.v1"
@@ -773,6 +775,35 @@ var (
 		Value: 1000000000000000000,
 	}
 
+	RelayerEnabledFlag = cli.BoolFlag{
+		Name:  "relayer",
+		Usage: "Enable the withdrawal relayer serving the arbiters",
+	}
+	RelayerAddrFlag = cli.StringFlag{
+		Name:  "relayer.addr",
+		Usage: "Withdrawal relayer listening interface",
+		Value: relayer.DefaultConfig.Host,
+	}
+	RelayerPortFlag = cli.IntFlag{
+		Name:  "relayer.port",
+		Usage: "Withdrawal relayer listening port",
+		Value: relayer.DefaultConfig.Port,
+	}
+
+	AuditFromFlag = cli.Uint64Flag{
+		Name:  "audit.from",
+		Usage: "First block of the cross chain audit",
+	}
+	AuditToFlag = cli.Uint64Flag{
+		Name:  "audit.to",
+		Usage: "Last block of the cross chain audit (0 = current head)",
+	}
+	AuditRangeFlag = cli.Uint64Flag{
+		Name:  "audit.range",
+		Usage: "Number of blocks summed in each range of the cross chain audit",
+		Value: 100000,
+	}
+
 	PbftKeyStore = cli.StringFlag{
 		Name:  "pbft.keystore",
 		Usage: "configue pbft consensus account",
@@ -788,6 +819,11 @@ var (
 		Usage: "pbft keystore password",
 		Value: "",
 	}
+	PbftSigner = cli.StringFlag{
+		Name:  "pbft.signer",
+		Usage: "External signer of the pbft consensus account (clef:<endpoint> or hsm:<endpoint>#<key>)",
+		Value: "",
+	}
 	PbftIPAddress = cli.StringFlag{
 		Name: "pbft.net.address",
 		Usage: "connect dpos direct net ip",
@@ -1551,6 +1587,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	cfg.PreConnectOffset = ctx.GlobalUint64(PreConnectOffset.Name)
 	cfg.PbftKeyStore = ctx.GlobalString(PbftKeyStore.Name)
 	cfg.PbftKeyStorePassWord = MakeDposPasswordList(ctx)
+	cfg.PbftSigner = ctx.GlobalString(PbftSigner.Name)
 	cfg.PbftIPAddress = ctx.GlobalString(PbftIPAddress.Name)
 	cfg.PbftDPosPort = uint16(ctx.GlobalUint(PbftDposPort.Name))
 	// Override any default configs for hard coded networks.
@@ -1618,16 +1655,33 @@ func SetDashboardConfig(ctx *cli.Context, cfg *dashboard.Config) {
 	cfg.Refresh = ctx.GlobalDuration(DashboardRefreshFlag.Name)
 }
 
+// SetRelayerConfig applies withdrawal relayer related command line flags to the config.
+func SetRelayerConfig(ctx *cli.Context, cfg *relayer.Config) {
+	cfg.Host = ctx.GlobalString(RelayerAddrFlag.Name)
+	cfg.Port = ctx.GlobalInt(RelayerPortFlag.Name)
+}
+
 // RegisterEthService adds an Ethereum client to the stack.
-func RegisterEthService(stack *node.Node, cfg *eth.Config) {
+func RegisterEthService(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	var err error
 	if cfg.SyncMode == downloader.LightSync {
 		err = stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
 			return les.New(ctx, cfg, stack)
 		})
 	} else {
+		spvDataDir := SpvDataDir(ctx)
 		err = stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+			// The full node credits the recharges from the deposits of the spv database
+			spvService, err := spv.NewService(spvDataDir)
+			if err != nil {
+				return nil, fmt.Errorf("open spv database: %v", err)
+			}
+			cfg.Spv = spvService
 			fullNode, err := eth.New(ctx, cfg, stack)
+			if err != nil {
+				spvService.Close()
+				return nil, err
+			}
 			if fullNode != nil && cfg.LightServ > 0 {
 				ls, _ := les.NewLesServer(fullNode, cfg)
 				fullNode.AddLesServer(ls)
@@ -1674,6 +1728,27 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// RegisterRelayerService configures the withdrawal relayer and adds it to the given node.
+func RegisterRelayerService(stack *node.Node, cfg *relayer.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		ctx.Service(&ethServ)
+
+		db, err := ctx.OpenDatabase("relayer", 16, 16, "eth/db/relayer/")
+		if err != nil {
+			return nil, err
+		}
+		service, err := relayer.New(cfg, ethServ, db)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return service, nil
+	}); err != nil {
+		Fatalf("Failed to register the withdrawal relayer service: %v", err)
+	}
+}
+
 // RegisterGraphQLService is a utility function to construct a new service and register it against a node.
 func RegisterGraphQLService(stack *node.Node, endpoint string, cors, vhosts []string, timeouts rpc.HTTPTimeouts) {
 	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
@@ -1774,7 +1849,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	}
 	var engine consensus.Engine
 	if config.Pbft != nil {
-		engine = pbft.New(config.Pbft, config.PbftKeyStore, []byte(config.PbftKeyStorePassWord), stack.ResolvePath(""), config.PBFTBlock.Uint64())
+		engine = pbft.New(config.Pbft, config.PbftKeyStore, []byte(config.PbftKeyStorePassWord), config.PbftSigner, stack.ResolvePath(""), config.PBFTBlock.Uint64())
 	} else if config.Clique != nil {
 		engine = clique.New(config.Clique, chainDb)
 	} else {
@@ -1814,6 +1889,33 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	return chain, chainDb
 }
 
+// SpvDataDir returns the directory of the spv databases of the selected network.
+func SpvDataDir(ctx *cli.Context) string {
+	switch {
+	case ctx.GlobalIsSet(DataDirFlag.Name):
+		return ctx.GlobalString(DataDirFlag.Name)
+	case ctx.GlobalBool(DeveloperFlag.Name):
+		return "" // unless explicitly requested, use memory databases
+	case ctx.GlobalBool(TestnetFlag.Name):
+		return filepath.Join(node.DefaultDataDir(), "testnet")
+	case ctx.GlobalBool(RinkebyFlag.Name):
+		return filepath.Join(node.DefaultDataDir(), "rinkeby")
+	case ctx.GlobalBool(GoerliFlag.Name):
+		return filepath.Join(node.DefaultDataDir(), "goerli")
+	}
+	return node.DefaultDataDir()
+}
+
+// MakeSpvService opens the spv database of the selected network, the deposits of
+// the main chain the recharges credit are read from the returned service.
+func MakeSpvService(ctx *cli.Context) *spv.Service {
+	service, err := spv.NewService(SpvDataDir(ctx))
+	if err != nil {
+		Fatalf("Failed to open spv database: %v", err)
+	}
+	return service
+}
+
 // MakeConsolePreloads retrieves the absolute paths for the console JavaScript
 // scripts to preload before starting.
 func MakeConsolePreloads(ctx *cli.Context) []string {
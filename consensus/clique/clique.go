@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/accounts"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/blocksigner"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common/hexutil"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus"
@@ -59,7 +60,7 @@ var (
 
 	extraVanity = 32                     // Fixed number of extra-data prefix bytes reserved for signer vanity
 	extraSeal   = crypto.SignatureLength // Fixed number of extra-data suffix bytes reserved for signer seal
-	extraElaHeight = 8
+	extraElaHeight = 8 // Fixed number of extra-data bytes before the seal reserved for the big endian ela height
 	nonceAuthVote = hexutil.MustDecode("0xffffffffffffffff") // Magic nonce number to vote on adding a new signer
 	nonceDropVote = hexutil.MustDecode("0x0000000000000000") // Magic nonce number to vote on removing a signer.
 
@@ -137,6 +138,18 @@ var (
 	// errRecentlySigned is returned if a header is signed by an authorized entity
 	// that already signed a header recently, thus is temporarily not allowed to.
 	errRecentlySigned = errors.New("recently signed")
+
+	// errMissingElaHeight is returned if a block's extra-data section doesn't seem
+	// to contain the ela height from the validated clique ela height fork.
+	errMissingElaHeight = errors.New("extra-data ela height missing")
+
+	// errInvalidElaHeight is returned if the ela height of a block is lower than
+	// the one of its parent.
+	errInvalidElaHeight = errors.New("invalid ela height")
+
+	// errUnknownElaHeight is returned if a block is prepared while the spv module
+	// is behind the ela height of its parent.
+	errUnknownElaHeight = errors.New("unknown ela height")
 )
 
 // SignerFn is a signer callback function to request a header to be signed by a
@@ -187,6 +200,8 @@ type Clique struct {
 	fakeDiff bool // Skip difficulty verifications
 
 	signersCount int // record signersCount
+
+	spv *spv.Service // Main chain synced by the spv module, nil if the node doesn't follow it
 }
 
 // New creates a Clique proof-of-authority consensus engine with the initial
@@ -279,6 +294,8 @@ func (c *Clique) verifyHeader(chain consensus.ChainReader, header *types.Header,
 	signersBytes := len(header.Extra) - extraVanity - extraSeal
 	if signersBytes%common.AddressLength == extraElaHeight {
 		signersBytes -= extraElaHeight
+	} else if chain.Config().IsCliqueElaHeight(header.Number) {
+		return errMissingElaHeight
 	}
 	if !checkpoint && signersBytes != 0 {
 		return errExtraSigners
@@ -331,6 +348,17 @@ func (c *Clique) verifyCascadingFields(chain consensus.ChainReader, header *type
 	if parent.Time+c.config.Period > header.Time {
 		return ErrInvalidTimestamp
 	}
+	// Ensure that the ela height follows the parent and the synced main chain
+	if chain.Config().IsCliqueElaHeight(header.Number) {
+		elaHeight := c.elaHeight(chain.Config(), header)
+		if elaHeight < c.elaHeight(chain.Config(), parent) {
+			return errInvalidElaHeight
+		}
+		if spvHeight, ok := c.spv.BestHeight(); ok && elaHeight > spvHeight {
+			log.Warn("block ela height is higher than spv height", "number", number, "elaHeight", elaHeight, "spvHeight", spvHeight)
+			return consensus.ErrFutureBlock
+		}
+	}
 	// Retrieve the snapshot needed to verify this header and cache it
 	snap, err := c.snapshot(chain, number-1, header.ParentHash, parents)
 	if err != nil {
@@ -487,6 +515,10 @@ func (c *Clique) verifySeal(chain consensus.ChainReader, header *types.Header, p
 	if _, ok := snap.Signers[signer]; !ok {
 		return errUnauthorizedSigner
 	}
+	// Ensure that the signer was a block signer at the ela height of the block
+	if chain.Config().IsCliqueElaHeight(header.Number) && !blocksigner.ValidateSigner(c.elaHeight(chain.Config(), header), signer) {
+		return errUnauthorizedSigner
+	}
 	beforeChangeEngine := c.isBeforeChangeEngine(chain, header)
 	log.Info("verifySeal beforeChangeEngine", "beforeChangeEngine", beforeChangeEngine, "headerNumber", header.Number.Uint64(), "header.Difficulty:", header.Difficulty)
 	for seen, recent := range snap.Recents {
@@ -563,17 +595,26 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 			header.Extra = append(header.Extra, signer[:]...)
 		}
 	}
-	header.Extra = append(header.Extra, make([]byte, extraElaHeight)...)
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	// Stamp the synced main chain height from the validated ela height fork
+	elaHeight := make([]byte, extraElaHeight)
+	if chain.Config().IsCliqueElaHeight(header.Number) {
+		height, _ := c.spv.BestHeight()
+		if height < c.elaHeight(chain.Config(), parent) {
+			return errUnknownElaHeight
+		}
+		binary.BigEndian.PutUint64(elaHeight, height)
+	}
+	header.Extra = append(header.Extra, elaHeight...)
 	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
 
 	// Mix digest is reserved for now, set to empty
 	header.MixDigest = common.Hash{}
 
 	// Ensure the timestamp has the correct delay
-	parent := chain.GetHeader(header.ParentHash, number-1)
-	if parent == nil {
-		return consensus.ErrUnknownAncestor
-	}
 	header.Time = parent.Time + c.config.Period
 	if header.Time < uint64(time.Now().Unix()) {
 		header.Time = uint64(time.Now().Unix())
@@ -611,6 +652,19 @@ func (c *Clique) Authorize(signer common.Address, signFn SignerFn) {
 	c.signFn = signFn
 }
 
+// elaHeight returns the ela height carried in the extra-data of the header, 0
+// before the validated clique ela height fork.
+func (c *Clique) elaHeight(config *params.ChainConfig, header *types.Header) uint64 {
+	if !config.IsCliqueElaHeight(header.Number) {
+		return 0
+	}
+	signersBytes := len(header.Extra) - extraVanity - extraSeal
+	if signersBytes < extraElaHeight || signersBytes%common.AddressLength != extraElaHeight {
+		return 0
+	}
+	return binary.BigEndian.Uint64(header.Extra[len(header.Extra)-extraSeal-extraElaHeight:])
+}
+
 func (c *Clique) isBeforeChangeEngine(chain consensus.ChainReader,
 	header *types.Header) bool {
 	if chain.Config().PBFTBlock == nil {
@@ -686,11 +740,6 @@ func (c *Clique) Seal(chain consensus.ChainReader, block *types.Block, results c
 	}
 
 	length := len(header.Extra)
-	in := new(bytes.Buffer)
-	elaHeight := spv.GetElaHeight()
-	if err := binary.Write(in, binary.BigEndian, elaHeight); err == nil {
-		copy(header.Extra[length-extraElaHeight-extraSeal:length-extraSeal], in.Bytes())
-	}
 
 	// Sign all the things!
 	sighash, err := signFn(accounts.Account{Address: signer}, accounts.MimetypeClique, CliqueRLP(header))
@@ -761,6 +810,12 @@ func (c *Clique) SetFakeDiff(v bool) {
 	c.fakeDiff = v
 }
 
+// SetSpv sets the spv service following the main chain, the ela height of the
+// blocks is verified against and stamped from the main chain it synced.
+func (c *Clique) SetSpv(service *spv.Service) {
+	c.spv = service
+}
+
 // APIs implements consensus.Engine, returning the user facing RPC API to allow
 // controlling the signer voting.
 func (c *Clique) APIs(chain consensus.ChainReader) []rpc.API {
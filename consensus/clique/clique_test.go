@@ -17,9 +17,11 @@
 package clique
 
 import (
+	"encoding/binary"
 	"math/big"
 	"testing"
 
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/blocksigner"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
@@ -111,3 +113,68 @@ func TestReimportMirroredState(t *testing.T) {
 		t.Fatalf("chain head mismatch: have %d, want %d", head, 3)
 	}
 }
+
+// Tests that the ela height carried by the blocks after the validated clique ela
+// height fork never decreases and authorizes the signer against the block
+// signers working at that height.
+func TestElaHeight(t *testing.T) {
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		config = *params.AllCliqueProtocolChanges
+	)
+	config.CliqueElaHeightBlock = big.NewInt(1)
+	engine := New(config.Clique, db)
+
+	genspec := &core.Genesis{
+		Config:    &config,
+		ExtraData: make([]byte, extraVanity+common.AddressLength+extraSeal+extraElaHeight),
+	}
+	copy(genspec.ExtraData[extraVanity:], addr[:])
+	genesis := genspec.MustCommit(db)
+
+	blocks, _ := core.GenerateChain(&config, genesis, engine, db, 3, func(i int, block *core.BlockGen) {
+		block.SetDifficulty(diffInTurn)
+	})
+	seal := func(elaHeights ...uint64) []*types.Block {
+		sealed := make([]*types.Block, len(elaHeights))
+		for i, elaHeight := range elaHeights {
+			header := blocks[i].Header()
+			if i > 0 {
+				header.ParentHash = sealed[i-1].Hash()
+			}
+			header.Extra = make([]byte, extraVanity+extraSeal+extraElaHeight)
+			binary.BigEndian.PutUint64(header.Extra[extraVanity:], elaHeight)
+			header.Difficulty = diffInTurn
+
+			sig, _ := crypto.Sign(SealHash(header).Bytes(), key)
+			copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+			sealed[i] = blocks[i].WithSeal(header)
+		}
+		return sealed
+	}
+	insert := func(blocks []*types.Block) error {
+		db := rawdb.NewMemoryDatabase()
+		genspec.MustCommit(db)
+		engine := New(config.Clique, db)
+		chain, _ := core.NewBlockChain(db, nil, &config, engine, engine, vm.Config{}, nil)
+		defer chain.Stop()
+		_, err := chain.InsertChain(blocks)
+		return err
+	}
+	defer func(signers map[common.Address]struct{}) { blocksigner.Signers = signers }(blocksigner.Signers)
+	blocksigner.Signers = map[common.Address]struct{}{addr: {}}
+	defer blocksigner.ResetBlockSigners()
+
+	if err := insert(seal(5, 5, 7)); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	if err := insert(seal(5, 4)); err != errInvalidElaHeight {
+		t.Fatalf("decreasing ela height: have %v, want %v", err, errInvalidElaHeight)
+	}
+	blocksigner.SetBlockSigners(7, []common.Address{{0x01}})
+	if err := insert(seal(5, 5, 7)); err != errUnauthorizedSigner {
+		t.Fatalf("rotated out signer: have %v, want %v", err, errUnauthorizedSigner)
+	}
+}
@@ -6,8 +6,15 @@
 package pbft
 
 import (
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rpc"
+
 	daccount "github.com/elastos/Elastos.ELA/dpos/account"
 )
 
@@ -29,6 +36,153 @@ func (a *API) GetAtbiterPeersInfo() []peerInfo {
 	return a.pbft.GetAtbiterPeersInfo()
 }
 
+// GetIllegalEvidence returns the evidences of producers who signed conflicting
+// proposals or votes.
+func (a *API) GetIllegalEvidence() []illegalEvidence {
+	return a.pbft.GetIllegalEvidences()
+}
+
+// GetConfirmByHeight returns the decoded confirm of the canonical block at the
+// given height.
+func (a *API) GetConfirmByHeight(height uint64) *confirmInfo {
+	return a.pbft.GetConfirmByHeight(height)
+}
+
+// GetConfirmByHash returns the decoded confirm of the block with the given hash.
+func (a *API) GetConfirmByHash(hash common.Hash) *confirmInfo {
+	return a.pbft.GetConfirmByHash(hash)
+}
+
+// GetVotersByHeight returns the public keys of the producers who accepted the
+// canonical block at the given height.
+func (a *API) GetVotersByHeight(height uint64) []string {
+	return a.pbft.GetVotersByHeight(height)
+}
+
+// GetProducers returns the public keys of the current producers.
+func (a *API) GetProducers() []string {
+	return a.pbft.GetProducers()
+}
+
+// GetOnDutyProducer returns the public key of the producer on duty in the
+// current view.
+func (a *API) GetOnDutyProducer() string {
+	return a.pbft.GetOnDutyProducer()
+}
+
+// GetViewInfo returns the view offset, start and change time of the current
+// consensus view.
+func (a *API) GetViewInfo() *viewInfo {
+	return a.pbft.GetViewInfo()
+}
+
+// GetProcessingProposal returns the proposal which is being voted on.
+func (a *API) GetProcessingProposal() *proposalInfo {
+	return a.pbft.GetProcessingProposal()
+}
+
+// GetVoteTally returns the signers who accepted or rejected the processing
+// proposal.
+func (a *API) GetVoteTally() *voteTally {
+	return a.pbft.GetVoteTally()
+}
+
+// GetRecoveryStatus returns the state of the abnormal consensus recovery.
+func (a *API) GetRecoveryStatus() *recoveryStatus {
+	return a.pbft.GetRecoveryStatus()
+}
+
+// GetDutySchedule returns the producers on duty for the next count heights
+// if no view change happens.
+func (a *API) GetDutySchedule(count uint64) []dutyInfo {
+	return a.pbft.GetDutySchedule(count)
+}
+
+// ViewChanges creates a subscription that is triggered each time the
+// consensus view changes.
+func (a *API) ViewChanges(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan ViewChangeEvent, 10)
+		sub := a.pbft.SubscribeViewChange(events)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				notifier.Notify(rpcSub.ID, ev)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// ProposalResults creates a subscription that is triggered each time a
+// proposal is confirmed or rejected by the majority of producers.
+func (a *API) ProposalResults(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan ProposalResultEvent, 10)
+		sub := a.pbft.SubscribeProposalResult(events)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				notifier.Notify(rpcSub.ID, ev)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// AdminAPI is the RPC API managing the producer signer, it is registered in
+// the admin namespace.
+type AdminAPI struct {
+	pbft *Pbft
+}
+
+// RotateSigner replaces the producer signer with the one of the url without
+// restarting the node, see dpos.OpenSigner for the format of the url. The
+// password of a keystore signer is read from the password file. It returns
+// the public key of the new signer.
+func (a *AdminAPI) RotateSigner(url string, passwordFile *string) (string, error) {
+	var password []byte
+	if passwordFile != nil && *passwordFile != "" {
+		text, err := ioutil.ReadFile(*passwordFile)
+		if err != nil {
+			return "", err
+		}
+		password = []byte(strings.TrimRight(string(text), "\r\n"))
+	}
+	signer, err := dpos.OpenSigner(url, "", password)
+	if err != nil {
+		return "", err
+	}
+	if err := a.pbft.RotateSigner(signer); err != nil {
+		return "", err
+	}
+	return common.Bytes2Hex(signer.PublicKeyBytes()), nil
+}
+
 func (a *API) Dispatcher() *dpos.Dispatcher {
 	return a.pbft.dispatcher
 }
@@ -37,6 +191,6 @@ func (a *API) Account() daccount.Account {
 	return a.pbft.account
 }
 
-func (a *API) Network() *dpos.Network {
-	return a.pbft.network
+func (a *API) Network() dpos.DPOSNetwork {
+	return a.pbft.getNetwork()
 }
@@ -0,0 +1,168 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"errors"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+)
+
+// proposalInfo is the RPC representation of a pbft proposal.
+type proposalInfo struct {
+	Hash       string `json:"hash"`
+	Sponsor    string `json:"sponsor"`
+	SealHash   string `json:"sealhash"`
+	ViewOffset uint32 `json:"viewoffset"`
+	Sign       string `json:"sign"`
+}
+
+// voteInfo is the RPC representation of a vote of a pbft proposal.
+type voteInfo struct {
+	Hash   string `json:"hash"`
+	Signer string `json:"signer"`
+	Accept bool   `json:"accept"`
+	Sign   string `json:"sign"`
+}
+
+// confirmInfo is the RPC representation of the confirm of a block.
+type confirmInfo struct {
+	Height    uint64       `json:"height"`
+	BlockHash string       `json:"blockhash"`
+	Proposal  proposalInfo `json:"proposal"`
+	Votes     []voteInfo   `json:"votes"`
+}
+
+// errInvalidConfirmEncoding is returned if the confirm in the header extra is
+// not in the encoding of the fork active at the header.
+var errInvalidConfirmEncoding = errors.New("invalid confirm encoding")
+
+// encodeConfirm serializes the confirm into the extra data of the header, the
+// compact encoding is used after the compact confirm fork.
+func (p *Pbft) encodeConfirm(chain consensus.ChainReader, header *types.Header, confirm *payload.Confirm) ([]byte, error) {
+	if !chain.Config().IsPBFTCompactConfirm(header.Number) {
+		return dpos.EncodeConfirm(confirm, nil, false)
+	}
+	producers, err := p.producersAt(chain, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	return dpos.EncodeConfirm(confirm, producers, true)
+}
+
+// decodeConfirm deserializes the confirm of the header, a compact confirm is
+// expanded with the producer set active at the header.
+func (p *Pbft) decodeConfirm(chain consensus.ChainReader, header *types.Header, parents []*types.Header, data []byte) (*payload.Confirm, error) {
+	compact := chain.Config().IsPBFTCompactConfirm(header.Number)
+	if compact != dpos.IsCompactConfirm(data) {
+		return nil, errInvalidConfirmEncoding
+	}
+	if !compact {
+		return dpos.DecodeConfirm(data, nil)
+	}
+	producers, err := p.producersAt(chain, header, parents)
+	if err != nil {
+		return nil, err
+	}
+	return dpos.DecodeConfirm(data, producers)
+}
+
+// getConfirm returns the confirm of the block, blocks written before the
+// confirms were stored separately fall back to the confirm in the header.
+func (p *Pbft) getConfirm(header *types.Header) *payload.Confirm {
+	if p.chain == nil || !p.chain.Config().IsPBFTFork(header.Number) {
+		return nil
+	}
+	hash, number := header.Hash(), header.Number.Uint64()
+	data := rawdb.ReadPbftConfirm(p.chain.GetDatabase(), hash, number)
+	if len(data) == 0 {
+		data = header.Extra
+	}
+	confirm, err := p.decodeConfirm(p.chain, header, nil, data)
+	if err != nil {
+		return nil
+	}
+	return confirm
+}
+
+func newConfirmInfo(header *types.Header, confirm *payload.Confirm) *confirmInfo {
+	info := &confirmInfo{
+		Height:    header.Number.Uint64(),
+		BlockHash: header.Hash().String(),
+		Proposal:  newProposalInfo(&confirm.Proposal),
+		Votes:     make([]voteInfo, 0, len(confirm.Votes)),
+	}
+	for _, vote := range confirm.Votes {
+		info.Votes = append(info.Votes, voteInfo{
+			Hash:   vote.Hash().String(),
+			Signer: common.Bytes2Hex(vote.Signer),
+			Accept: vote.Accept,
+			Sign:   common.Bytes2Hex(vote.Sign),
+		})
+	}
+	return info
+}
+
+// GetConfirmByHeight returns the confirm of the canonical block at the height.
+func (p *Pbft) GetConfirmByHeight(height uint64) *confirmInfo {
+	if p.chain == nil {
+		return nil
+	}
+	header := p.chain.GetHeaderByNumber(height)
+	if header == nil {
+		return nil
+	}
+	confirm := p.getConfirm(header)
+	if confirm == nil {
+		return nil
+	}
+	return newConfirmInfo(header, confirm)
+}
+
+// GetConfirmByHash returns the confirm of the block with the given hash.
+func (p *Pbft) GetConfirmByHash(hash common.Hash) *confirmInfo {
+	if p.chain == nil {
+		return nil
+	}
+	header := p.chain.GetHeaderByHash(hash)
+	if header == nil {
+		return nil
+	}
+	confirm := p.getConfirm(header)
+	if confirm == nil {
+		return nil
+	}
+	return newConfirmInfo(header, confirm)
+}
+
+// GetVotersByHeight returns the public keys of the producers who accepted the
+// proposal of the canonical block at the height.
+func (p *Pbft) GetVotersByHeight(height uint64) []string {
+	if p.chain == nil {
+		return nil
+	}
+	header := p.chain.GetHeaderByNumber(height)
+	if header == nil {
+		return nil
+	}
+	confirm := p.getConfirm(header)
+	if confirm == nil {
+		return nil
+	}
+	voters := make([]string, 0, len(confirm.Votes))
+	for _, vote := range confirm.Votes {
+		if vote.Accept {
+			voters = append(voters, common.Bytes2Hex(vote.Signer))
+		}
+	}
+	return voters
+}
@@ -0,0 +1,740 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"math/big"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/vm"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
+	emsg "github.com/elastos/Elastos.ELA.SideChain.ETH/dpos/msg"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rlp"
+
+	ecom "github.com/elastos/Elastos.ELA/common"
+	elatypes "github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	elacrypto "github.com/elastos/Elastos.ELA/crypto"
+	daccount "github.com/elastos/Elastos.ELA/dpos/account"
+	"github.com/elastos/Elastos.ELA/dpos/p2p"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/msg"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
+	elap2p "github.com/elastos/Elastos.ELA/p2p"
+)
+
+// simSeed is the seed of the simulations, a failed simulation is reproduced
+// by running it again with the logged seed.
+var simSeed = flag.Int64("pbft.seed", 1, "seed of the pbft simulations")
+
+const (
+	// simPeriod is the block period of the simulated producers.
+	simPeriod = 1
+
+	// simTolerance is the view duration of the simulated producers.
+	simTolerance = 3 * time.Second
+)
+
+// simAccount is a producer account signing with an in-memory key.
+type simAccount struct {
+	key       *ecdsa.PrivateKey
+	publicKey *elacrypto.PublicKey
+	pubKey    []byte
+}
+
+func newSimAccount() *simAccount {
+	key, err := ecdsa.GenerateKey(elacrypto.DefaultCurve, crand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return newSimAccountFromKey(key)
+}
+
+// newSimAccountFromRand creates the account of a key derived from the random
+// source, the producers of a simulation are the same for the same seed.
+func newSimAccountFromRand(r *rand.Rand) *simAccount {
+	curve := elacrypto.DefaultCurve
+	d := new(big.Int).Rand(r, new(big.Int).Sub(curve.Params().N, big.NewInt(1)))
+	d.Add(d, big.NewInt(1))
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(d.Bytes())
+	return newSimAccountFromKey(key)
+}
+
+func newSimAccountFromKey(key *ecdsa.PrivateKey) *simAccount {
+	publicKey := &elacrypto.PublicKey{X: key.X, Y: key.Y}
+	pubKey, err := publicKey.EncodePoint(true)
+	if err != nil {
+		panic(err)
+	}
+	return &simAccount{key: key, publicKey: publicKey, pubKey: pubKey}
+}
+
+func (a *simAccount) PublicKey() *elacrypto.PublicKey {
+	return a.publicKey
+}
+
+func (a *simAccount) PublicKeyBytes() []byte {
+	return a.pubKey
+}
+
+func (a *simAccount) SignProposal(proposal *payload.DPOSProposal) ([]byte, error) {
+	return a.Sign(proposal.Data()), nil
+}
+
+func (a *simAccount) SignVote(vote *payload.DPOSProposalVote) ([]byte, error) {
+	return a.Sign(vote.Data()), nil
+}
+
+func (a *simAccount) Sign(data []byte) []byte {
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(crand.Reader, a.key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	signature := make([]byte, elacrypto.SignatureLength)
+	copy(signature[elacrypto.SignerLength-len(r.Bytes()):], r.Bytes())
+	copy(signature[elacrypto.SignatureLength-len(s.Bytes()):], s.Bytes())
+	return signature
+}
+
+func (a *simAccount) SignTx(tx *elatypes.Transaction) ([]byte, error) {
+	return nil, errors.New("not supported")
+}
+
+func (a *simAccount) DecryptAddr(cipher []byte) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (a *simAccount) pid() peer.PID {
+	var pid peer.PID
+	copy(pid[:], a.pubKey)
+	return pid
+}
+
+// simClock is a time source with a fixed skew from the local clock.
+type simClock struct {
+	skew time.Duration
+}
+
+func (c *simClock) AdjustedTime() time.Time {
+	return time.Now().Add(c.skew)
+}
+
+func (c *simClock) AddTimeSample(id string, timeVal time.Time) {}
+
+func (c *simClock) Offset() time.Duration {
+	return c.skew
+}
+
+// simPeer is an active peer of the simulated network.
+type simPeer struct {
+	pid peer.PID
+}
+
+func (p *simPeer) PID() peer.PID {
+	return p.pid
+}
+
+func (p *simPeer) ToPeer() *peer.Peer {
+	return nil
+}
+
+// simMessage is a message delivered to a simulated network.
+type simMessage struct {
+	from peer.PID
+	msg  elap2p.Message
+}
+
+// simHub connects the simulated networks and injects the network faults.
+type simHub struct {
+	rand      *rand.Rand
+	networks  []*simNetwork
+	minDelay  time.Duration
+	maxDelay  time.Duration
+	dropRate  float64
+	partition map[peer.PID]int
+
+	mu sync.Mutex
+}
+
+func newSimHub(seed int64) *simHub {
+	return &simHub{
+		rand:      rand.New(rand.NewSource(seed)),
+		partition: make(map[peer.PID]int),
+	}
+}
+
+// setDelay delays every message by a random duration in [min, max].
+func (h *simHub) setDelay(min, max time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.minDelay, h.maxDelay = min, max
+}
+
+// setDropRate drops messages with the given probability.
+func (h *simHub) setDropRate(rate float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dropRate = rate
+}
+
+// split isolates the networks of the given indexes from the others.
+func (h *simHub) split(indexes ...int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, i := range indexes {
+		h.partition[h.networks[i].pid] = 1
+	}
+}
+
+// heal removes the partition.
+func (h *simHub) heal() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.partition = make(map[peer.PID]int)
+}
+
+func (h *simHub) connected(from, to *simNetwork) bool {
+	return from != to && to.isRunning() && h.partition[from.pid] == h.partition[to.pid]
+}
+
+func (h *simHub) peers(from *simNetwork) []*simNetwork {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	peers := make([]*simNetwork, 0, len(h.networks))
+	for _, n := range h.networks {
+		if n != from {
+			peers = append(peers, n)
+		}
+	}
+	return peers
+}
+
+func (h *simHub) activePeers(from *simNetwork) []p2p.Peer {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	peers := make([]p2p.Peer, 0, len(h.networks))
+	for _, n := range h.networks {
+		if h.connected(from, n) {
+			peers = append(peers, &simPeer{pid: n.pid})
+		}
+	}
+	return peers
+}
+
+func (h *simHub) send(from *simNetwork, to *simNetwork, m elap2p.Message) {
+	h.mu.Lock()
+	if !h.connected(from, to) || h.rand.Float64() < h.dropRate {
+		h.mu.Unlock()
+		return
+	}
+	delay := h.minDelay
+	if h.maxDelay > h.minDelay {
+		delay += time.Duration(h.rand.Int63n(int64(h.maxDelay - h.minDelay)))
+	}
+	h.mu.Unlock()
+
+	m, err := copyMessage(m)
+	if err != nil {
+		panic(err)
+	}
+	time.AfterFunc(delay, func() {
+		to.deliver(from.pid, m)
+	})
+}
+
+// copyMessage sends the message through its wire encoding so the receiver
+// never shares memory with the sender.
+func copyMessage(m elap2p.Message) (elap2p.Message, error) {
+	buf := new(bytes.Buffer)
+	if err := m.Serialize(buf); err != nil {
+		return nil, err
+	}
+	c, err := dpos.MakeEmptyMessage(m.CMD())
+	if err != nil {
+		return m, nil
+	}
+	if err := c.Deserialize(buf); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// simNetwork is an in-memory DPOSNetwork of a simulated producer.
+type simNetwork struct {
+	hub      *simHub
+	pid      peer.PID
+	account  *simAccount
+	engine   *Pbft
+	listener dpos.NetworkEventListener
+
+	// byzantine makes the producer propose and vote for two conflicting
+	// blocks at the same height.
+	byzantine bool
+	twins     map[ecom.Uint256]*types.Block
+
+	messages   chan *simMessage
+	changeView chan struct{}
+	recover    chan struct{}
+	quit       chan struct{}
+	running    bool
+
+	mu sync.Mutex
+}
+
+func newSimNetwork(hub *simHub, account *simAccount) *simNetwork {
+	n := &simNetwork{
+		hub:        hub,
+		pid:        account.pid(),
+		account:    account,
+		twins:      make(map[ecom.Uint256]*types.Block),
+		messages:   make(chan *simMessage, 1024),
+		changeView: make(chan struct{}),
+		recover:    make(chan struct{}),
+		quit:       make(chan struct{}),
+	}
+	hub.mu.Lock()
+	hub.networks = append(hub.networks, n)
+	hub.mu.Unlock()
+	return n
+}
+
+func (n *simNetwork) isRunning() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.running
+}
+
+func (n *simNetwork) Start() {
+	n.mu.Lock()
+	n.running = true
+	n.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case m := <-n.messages:
+				dpos.DispatchMessage(n.listener, m.from, m.msg)
+			case <-n.changeView:
+				n.listener.OnChangeView()
+			case <-n.recover:
+				n.listener.OnRecover()
+			case <-n.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (n *simNetwork) Stop() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.running {
+		n.running = false
+		close(n.quit)
+	}
+	return nil
+}
+
+func (n *simNetwork) deliver(from peer.PID, m elap2p.Message) {
+	select {
+	case n.messages <- &simMessage{from: from, msg: m}:
+	case <-n.quit:
+	}
+}
+
+func (n *simNetwork) SendMessageToPeer(id peer.PID, m elap2p.Message) error {
+	for _, to := range n.hub.peers(n) {
+		if to.pid == id {
+			n.hub.send(n, to, m)
+			return nil
+		}
+	}
+	return errors.New("unknown peer")
+}
+
+func (n *simNetwork) BroadcastMessage(m elap2p.Message) {
+	if n.byzantine && n.broadcastConflict(m) {
+		return
+	}
+	n.broadcast(m)
+}
+
+func (n *simNetwork) broadcast(m elap2p.Message) {
+	for _, to := range n.hub.peers(n) {
+		n.hub.send(n, to, m)
+	}
+}
+
+// broadcastConflict sends conflicting blocks, proposals and votes to the
+// halves of the peers, it returns false if the message is sent unchanged.
+func (n *simNetwork) broadcastConflict(m elap2p.Message) bool {
+	peers := n.hub.peers(n)
+	split := func(honest, twin elap2p.Message) {
+		for i, to := range peers {
+			if i%2 == 0 {
+				n.hub.send(n, to, honest)
+			} else {
+				n.hub.send(n, to, twin)
+			}
+		}
+	}
+	switch m := m.(type) {
+	case *emsg.BlockMsg:
+		block := new(types.Block)
+		if err := rlp.DecodeBytes(m.GetData(), block); err != nil || len(block.Extra()) > extraVanity {
+			return false
+		}
+		header := block.Header()
+		header.Coinbase = common.Address{0xff}
+		twin := types.NewBlockWithHeader(header).WithBody(block.Transactions(), block.Uncles())
+		data, err := rlp.EncodeToBytes(twin)
+		if err != nil {
+			return false
+		}
+		sealHash := SealHash(block.Header())
+		hash, _ := ecom.Uint256FromBytes(sealHash.Bytes())
+		n.mu.Lock()
+		n.twins[*hash] = twin
+		n.mu.Unlock()
+		n.engine.blockPool.AppendDposBlock(twin)
+		split(m, emsg.NewBlockMsg(data))
+		return true
+
+	case *msg.Proposal:
+		n.mu.Lock()
+		twin, ok := n.twins[m.Proposal.BlockHash]
+		n.mu.Unlock()
+		if !ok {
+			return false
+		}
+		sealHash := SealHash(twin.Header())
+		hash, _ := ecom.Uint256FromBytes(sealHash.Bytes())
+		proposal, err := dpos.StartProposal(n.account, *hash, m.Proposal.ViewOffset)
+		if err != nil {
+			return false
+		}
+		split(m, &msg.Proposal{Proposal: *proposal})
+		n.broadcastVote(proposal.Hash(), true)
+		return true
+
+	case *msg.Vote:
+		n.broadcast(m)
+		n.broadcastVote(m.Vote.ProposalHash, !m.Vote.Accept)
+		return true
+	}
+	return false
+}
+
+func (n *simNetwork) broadcastVote(proposal ecom.Uint256, accept bool) {
+	vote, err := dpos.StartVote(&proposal, accept, n.account)
+	if err != nil {
+		return
+	}
+	if accept {
+		n.broadcast(&msg.Vote{Command: msg.CmdAcceptVote, Vote: *vote})
+	} else {
+		n.broadcast(&msg.Vote{Command: msg.CmdRejectVote, Vote: *vote})
+	}
+}
+
+func (n *simNetwork) UpdatePeers(peers []peer.PID) {}
+
+func (n *simNetwork) GetActivePeers() []p2p.Peer {
+	return n.hub.activePeers(n)
+}
+
+func (n *simNetwork) AddDirectLinkAddr(pid peer.PID, addr string) {}
+
+func (n *simNetwork) DumpPeersInfo() []*p2p.PeerInfo {
+	n.hub.mu.Lock()
+	defer n.hub.mu.Unlock()
+	infos := make([]*p2p.PeerInfo, 0, len(n.hub.networks))
+	for _, p := range n.hub.networks {
+		if p == n {
+			continue
+		}
+		info := &p2p.PeerInfo{PID: p.pid, State: p2p.CSNoneConnection}
+		if n.hub.connected(n, p) {
+			info.State = p2p.CS2WayConnection
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func (n *simNetwork) PeerScore(pid peer.PID) dpos.PeerScoreInfo {
+	return dpos.PeerScoreInfo{}
+}
+
+func (n *simNetwork) PostChangeViewTask() {
+	select {
+	case n.changeView <- struct{}{}:
+	case <-n.quit:
+	}
+}
+
+func (n *simNetwork) PostRecoverTask() {
+	select {
+	case n.recover <- struct{}{}:
+	case <-n.quit:
+	}
+}
+
+// simNode is a simulated producer running a pbft engine on its own chain.
+type simNode struct {
+	index   int
+	account *simAccount
+	clock   *simClock
+	network *simNetwork
+	engine  *Pbft
+	chain   *core.BlockChain
+
+	mine    chan struct{}
+	results chan *types.Block
+	stop    chan struct{}
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// simulation runs producers connected by a faulty in-memory network.
+type simulation struct {
+	t      *testing.T
+	seed   int64
+	dir    string
+	hub    *simHub
+	config *params.ChainConfig
+	nodes  []*simNode
+}
+
+// newSimulation creates the producers, the first byzantine ones propose and
+// vote for conflicting blocks. The keys of the producers and the faults of the
+// network are derived from the -pbft.seed flag.
+func newSimulation(t *testing.T, count int, byzantine int) *simulation {
+	if testing.Short() {
+		t.Skip("skipping pbft simulation in short mode")
+	}
+	seed := *simSeed
+	t.Logf("simulation seed %d", seed)
+
+	dir, err := ioutil.TempDir("", "pbft-simulation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dpos.InitLog(4, 0, 0, filepath.Join(dir, "logs"))
+
+	keys := rand.New(rand.NewSource(seed))
+	accounts := make([]*simAccount, count)
+	producers := make([]string, count)
+	for i := range accounts {
+		accounts[i] = newSimAccountFromRand(keys)
+		producers[i] = common.Bytes2Hex(accounts[i].pubKey)
+	}
+	cfg := &params.PbftConfig{Producers: producers}
+	config := *params.TestChainConfig
+	config.PBFTBlock = big.NewInt(0)
+	config.PBFTElaHeightBlock = big.NewInt(0)
+	config.Ethash = nil
+	config.Pbft = cfg
+	genesis := &core.Genesis{
+		Config:     &config,
+		Timestamp:  uint64(time.Now().Unix()) - simPeriod,
+		GasLimit:   params.GenesisGasLimit,
+		Difficulty: big.NewInt(1),
+	}
+
+	s := &simulation{t: t, seed: seed, dir: dir, hub: newSimHub(seed), config: &config}
+	for i, account := range accounts {
+		node := &simNode{
+			index:   i,
+			account: account,
+			clock:   new(simClock),
+			network: newSimNetwork(s.hub, account),
+			mine:    make(chan struct{}, 1),
+			results: make(chan *types.Block, 1),
+			stop:    make(chan struct{}),
+			quit:    make(chan struct{}),
+		}
+		node.network.byzantine = i < byzantine
+		node.engine = newPbft(cfg, account, dir, filepath.Join(dir, "wal", producers[i]), 1,
+			node.clock, simTolerance, func(pbft *Pbft, _ daccount.Account) (dpos.DPOSNetwork, error) {
+				node.network.listener = pbft
+				return node.network, nil
+			})
+		node.engine.period = simPeriod
+		node.network.engine = node.engine
+
+		db := rawdb.NewMemoryDatabase()
+		genesis.MustCommit(db)
+		node.chain, err = core.NewBlockChain(db, nil, &config, node.engine, node.engine, vm.Config{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node.engine.SetBlockChain(node.chain)
+		node.engine.IsCurrent = func() bool { return true }
+		node.engine.StartMine = node.startMine
+		node.engine.OnDuty = node.startMine
+		s.nodes = append(s.nodes, node)
+	}
+	return s
+}
+
+func (s *simulation) start() {
+	for _, node := range s.nodes {
+		node.start()
+	}
+}
+
+func (s *simulation) stop() {
+	for _, node := range s.nodes {
+		node.close()
+	}
+	os.RemoveAll(s.dir)
+}
+
+// honest returns the producers which are not byzantine.
+func (s *simulation) honest() []*simNode {
+	nodes := make([]*simNode, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		if !node.network.byzantine {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// waitHeight waits until all the given producers reach the height.
+func (s *simulation) waitHeight(height uint64, timeout time.Duration, nodes ...*simNode) {
+	deadline := time.Now().Add(timeout)
+	for _, node := range nodes {
+		for node.height() < height {
+			if time.Now().After(deadline) {
+				s.t.Fatalf("seed %d: producer %d stuck at height %d, want %d",
+					s.seed, node.index, node.height(), height)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
+
+// checkSafety checks the given producers agree on every common height.
+func (s *simulation) checkSafety(nodes ...*simNode) {
+	min := nodes[0].height()
+	for _, node := range nodes[1:] {
+		if height := node.height(); height < min {
+			min = height
+		}
+	}
+	for number := uint64(1); number <= min; number++ {
+		want := nodes[0].chain.GetHeaderByNumber(number).Hash()
+		for _, node := range nodes[1:] {
+			if have := node.chain.GetHeaderByNumber(number).Hash(); have != want {
+				s.t.Fatalf("seed %d: producers 0 and %d diverged at height %d: %x != %x",
+					s.seed, node.index, number, want, have)
+			}
+		}
+	}
+}
+
+func (n *simNode) height() uint64 {
+	return n.chain.CurrentHeader().Number.Uint64()
+}
+
+func (n *simNode) startMine() {
+	select {
+	case n.mine <- struct{}{}:
+	default:
+	}
+}
+
+func (n *simNode) start() {
+	heads := make(chan core.ChainHeadEvent, 64)
+	sub := n.chain.SubscribeChainHeadEvent(heads)
+
+	n.wg.Add(2)
+	go func() {
+		defer n.wg.Done()
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case <-n.mine:
+				n.commit()
+			case ev := <-heads:
+				n.engine.OnInsertBlock(ev.Block)
+				n.engine.AccessFutureBlock(ev.Block)
+				n.commit()
+			case <-n.quit:
+				return
+			}
+		}
+	}()
+	go func() {
+		defer n.wg.Done()
+		for {
+			select {
+			case block := <-n.results:
+				n.chain.InsertChain(types.Blocks{block})
+			case <-n.quit:
+				return
+			}
+		}
+	}()
+	go n.engine.StartServer()
+}
+
+// commit assembles an empty block on the chain head and seals it, it does
+// the same as the miner with the pbft engine.
+func (n *simNode) commit() {
+	parent := n.chain.CurrentBlock()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   parent.GasLimit(),
+		Coinbase:   common.BytesToAddress(n.account.pubKey),
+	}
+	if err := n.engine.Prepare(n.chain, header); err != nil {
+		return
+	}
+	state, err := n.chain.StateAt(parent.Root())
+	if err != nil {
+		return
+	}
+	block, err := n.engine.FinalizeAndAssemble(n.chain, header, state, nil, nil, nil)
+	if err != nil {
+		return
+	}
+	close(n.stop)
+	n.stop = make(chan struct{})
+	stop := n.stop
+	go n.engine.Seal(n.chain, block, n.results, stop)
+}
+
+func (n *simNode) close() {
+	close(n.quit)
+	n.wg.Wait()
+	close(n.stop)
+	n.engine.StopServer()
+	n.engine.Close()
+	n.chain.Stop()
+}
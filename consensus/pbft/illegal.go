@@ -0,0 +1,251 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
+	dmsg "github.com/elastos/Elastos.ELA.SideChain.ETH/dpos/msg"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rlp"
+
+	elacom "github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/msg"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
+)
+
+var (
+	// errInvalidEvidenceHeader is returned if the block header carried by an
+	// illegal evidence does not match its proposal.
+	errInvalidEvidenceHeader = errors.New("invalid evidence block header")
+
+	// errEvidenceNotProducer is returned if the signer of an illegal evidence
+	// was not a producer at the evidence height.
+	errEvidenceNotProducer = errors.New("evidence signer is not a producer")
+)
+
+// illegalEvidence is the RPC representation of a stored illegal evidence.
+type illegalEvidence struct {
+	Hash   string `json:"hash"`
+	Type   string `json:"type"`
+	Height uint64 `json:"height"`
+	Signer string `json:"signer"`
+	Data   string `json:"data"`
+}
+
+// getEvidenceHeader returns the serialized header and the height of the block
+// in the pool with the given seal hash.
+func (p *Pbft) getEvidenceHeader(sealHash elacom.Uint256) ([]byte, uint32, bool) {
+	b, ok := p.blockPool.GetBlock(sealHash)
+	if !ok {
+		return nil, 0, false
+	}
+	block, ok := b.(*types.Block)
+	if !ok {
+		return nil, 0, false
+	}
+	header, err := rlp.EncodeToBytes(block.Header())
+	if err != nil {
+		return nil, 0, false
+	}
+	return header, uint32(block.NumberU64()), true
+}
+
+// verifyProposalEvidence checks the block header of the evidence matches its
+// proposal and the signers were producers at that height.
+func (p *Pbft) verifyProposalEvidence(evidence *payload.ProposalEvidence, signers ...[]byte) error {
+	header := new(types.Header)
+	if err := rlp.DecodeBytes(evidence.BlockHeader, header); err != nil {
+		return err
+	}
+	sealHash := p.SealHash(header)
+	if !bytes.Equal(sealHash.Bytes(), evidence.Proposal.BlockHash.Bytes()) ||
+		header.Number.Uint64() != uint64(evidence.BlockHeight) {
+		return errInvalidEvidenceHeader
+	}
+	active, err := p.producersAt(p.chain, header, nil)
+	if err != nil {
+		return err
+	}
+	producers := dpos.NewProducers(active, 0)
+	for _, signer := range signers {
+		if !producers.IsProducers(signer) {
+			return errEvidenceNotProducer
+		}
+	}
+	return nil
+}
+
+// storeIllegalEvidence persists the evidence, it returns false if the evidence
+// is already known.
+func (p *Pbft) storeIllegalEvidence(evidence payload.DPOSIllegalData, signer []byte, data []byte) bool {
+	if p.chain == nil {
+		return false
+	}
+	db := p.chain.GetDatabase()
+	hash := common.BytesToHash(evidence.Hash().Bytes())
+	if rawdb.HasPbftIllegalEvidence(db, hash) {
+		return false
+	}
+	rawdb.WritePbftIllegalEvidence(db, hash, &rawdb.PbftIllegalEvidence{
+		Type:   uint8(evidence.Type()),
+		Height: uint64(evidence.GetBlockHeight()),
+		Signer: signer,
+		Data:   data,
+	})
+	log.Warn("store illegal evidence", "type", evidence.Type(), "height",
+		evidence.GetBlockHeight(), "signer", common.Bytes2Hex(signer))
+	return true
+}
+
+// submitIllegalEvidence submits the evidence to the main chain if this node is
+// a producer.
+func (p *Pbft) submitIllegalEvidence(evidence *payload.SidechainIllegalData) {
+	if !p.IsProducer() {
+		return
+	}
+	if err := p.spv.SubmitEvidence(evidence); err != nil {
+		log.Warn("submit illegal evidence failed", "height", evidence.Height, "err", err)
+	}
+}
+
+func (p *Pbft) onIllegalProposals(evidence *payload.DPOSIllegalProposals) {
+	sponsor := evidence.Evidence.Proposal.Sponsor
+	if !p.storeIllegalEvidence(evidence, sponsor, evidence.Data(payload.IllegalProposalVersion)) {
+		return
+	}
+	go p.submitIllegalEvidence(p.spv.NewIllegalData(payload.SidechainIllegalProposal,
+		uint64(evidence.GetBlockHeight()), sponsor, evidence.Evidence.Proposal.Hash(),
+		evidence.CompareEvidence.Proposal.Hash()))
+	if network := p.getNetwork(); network != nil {
+		network.BroadcastMessage(&msg.IllegalProposals{Proposals: *evidence})
+	}
+}
+
+func (p *Pbft) onIllegalVotes(evidence *payload.DPOSIllegalVotes) {
+	signer := evidence.Evidence.Vote.Signer
+	if !p.storeIllegalEvidence(evidence, signer, evidence.Data(payload.IllegalVoteVersion)) {
+		return
+	}
+	go p.submitIllegalEvidence(p.spv.NewIllegalData(payload.SidechainIllegalVote,
+		uint64(evidence.GetBlockHeight()), signer, evidence.Evidence.Vote.Hash(),
+		evidence.CompareEvidence.Vote.Hash()))
+	if network := p.getNetwork(); network != nil {
+		network.BroadcastMessage(&msg.IllegalVotes{Votes: *evidence})
+	}
+}
+
+func (p *Pbft) OnIllegalProposalReceived(id peer.PID, proposals *payload.DPOSIllegalProposals) {
+	log.Info("[OnIllegalProposalReceived]", "height", proposals.GetBlockHeight())
+	if p.chain == nil || rawdb.HasPbftIllegalEvidence(p.chain.GetDatabase(),
+		common.BytesToHash(proposals.Hash().Bytes())) {
+		return
+	}
+	if err := dpos.CheckIllegalProposals(proposals); err != nil {
+		log.Warn("[OnIllegalProposalReceived] invalid evidence", "err", err)
+		return
+	}
+	sponsor := proposals.Evidence.Proposal.Sponsor
+	for _, e := range []*payload.ProposalEvidence{&proposals.Evidence, &proposals.CompareEvidence} {
+		if err := p.verifyProposalEvidence(e, sponsor); err != nil {
+			log.Warn("[OnIllegalProposalReceived] invalid evidence", "err", err)
+			return
+		}
+	}
+	p.onIllegalProposals(proposals)
+}
+
+func (p *Pbft) OnIllegalVotesReceived(id peer.PID, votes *payload.DPOSIllegalVotes) {
+	log.Info("[OnIllegalVotesReceived]", "height", votes.GetBlockHeight())
+	if p.chain == nil || rawdb.HasPbftIllegalEvidence(p.chain.GetDatabase(),
+		common.BytesToHash(votes.Hash().Bytes())) {
+		return
+	}
+	if err := dpos.CheckIllegalVotes(votes); err != nil {
+		log.Warn("[OnIllegalVotesReceived] invalid evidence", "err", err)
+		return
+	}
+	signer := votes.Evidence.Vote.Signer
+	for _, e := range []*payload.VoteEvidence{&votes.Evidence, &votes.CompareEvidence} {
+		if err := p.verifyProposalEvidence(&e.ProposalEvidence, e.Proposal.Sponsor, signer); err != nil {
+			log.Warn("[OnIllegalVotesReceived] invalid evidence", "err", err)
+			return
+		}
+	}
+	p.onIllegalVotes(votes)
+}
+
+// broadcastEvidence sends the main chain evidence with the signs collected by
+// this node to the other producers.
+func (p *Pbft) broadcastEvidence(evidence *payload.SidechainIllegalData) {
+	if network := p.getNetwork(); network != nil {
+		network.BroadcastMessage(&dmsg.SidechainIllegalData{Data: *evidence})
+	}
+}
+
+// evidenceSigner returns the producer who made the sign of the unsigned
+// evidence data, nil if none of the current producers made it.
+func (p *Pbft) evidenceSigner(data, sign []byte) []byte {
+	for _, producer := range p.dispatcher.GetConsensusView().GetProducers() {
+		publicKey, err := crypto.DecodePoint(producer)
+		if err != nil {
+			continue
+		}
+		if crypto.Verify(*publicKey, data, sign) == nil {
+			return producer
+		}
+	}
+	return nil
+}
+
+func (p *Pbft) OnSidechainIllegalDataReceived(id peer.PID, data *payload.SidechainIllegalData) {
+	log.Info("[OnSidechainIllegalDataReceived]", "height", data.Height, "signs", len(data.Signs))
+	if !p.IsProducer() {
+		return
+	}
+	if err := p.spv.AddEvidenceSigns(data.Hash(), data.Signs, p.evidenceSigner); err != nil {
+		log.Warn("[OnSidechainIllegalDataReceived] add evidence signs failed", "err", err)
+	}
+}
+
+// GetIllegalEvidences returns the stored illegal evidences ordered by height.
+func (p *Pbft) GetIllegalEvidences() []illegalEvidence {
+	if p.chain == nil {
+		return nil
+	}
+	result := make([]illegalEvidence, 0)
+	for hash, e := range rawdb.ReadAllPbftIllegalEvidences(p.chain.GetDatabase()) {
+		evidenceType := "unknown"
+		switch payload.IllegalDataType(e.Type) {
+		case payload.IllegalProposal:
+			evidenceType = "proposal"
+		case payload.IllegalVote:
+			evidenceType = "vote"
+		}
+		result = append(result, illegalEvidence{
+			Hash:   hash.String(),
+			Type:   evidenceType,
+			Height: e.Height,
+			Signer: common.Bytes2Hex(e.Signer),
+			Data:   common.Bytes2Hex(e.Data),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Height != result[j].Height {
+			return result[i].Height < result[j].Height
+		}
+		return result[i].Hash < result[j].Hash
+	})
+	return result
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb/memorydb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
+
+	elacom "github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/dpos/dtime"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectSidechainIllegalData(t *testing.T) {
+	accounts := make([]*simAccount, 4)
+	producers := make([][]byte, 3)
+	for i := range accounts {
+		accounts[i] = newSimAccount()
+		if i < len(producers) {
+			producers[i] = accounts[i].PublicKeyBytes()
+		}
+	}
+	p := &Pbft{account: accounts[0]}
+	p.dispatcher = dpos.NewDispatcher(producers, nil, nil, 10*time.Second,
+		producers[0], dtime.NewMedianTime(), nil, 0)
+
+	chain := spv.NewFakeMainChain()
+	db := memorydb.New()
+	assert.NoError(t, spv.WriteArbiters(db, 0, producers))
+	p.SetSpv(spv.New(&spv.Config{}, db, chain, nil))
+
+	evidence := p.spv.NewIllegalData(payload.SidechainIllegalVote, 10, producers[2],
+		elacom.Uint256{1}, elacom.Uint256{2})
+	assert.NoError(t, p.spv.SubmitEvidence(evidence))
+	unsigned := new(bytes.Buffer)
+	assert.NoError(t, evidence.SerializeUnsigned(unsigned, payload.SidechainIllegalDataVersion))
+	assert.Equal(t, producers[0], p.evidenceSigner(unsigned.Bytes(), evidence.Signs[0]))
+
+	// the signs of the producer already signed and of the others are not counted
+	received := *evidence
+	received.Signs = [][]byte{accounts[0].Sign(unsigned.Bytes()), accounts[3].Sign(unsigned.Bytes())}
+	assert.Nil(t, p.evidenceSigner(unsigned.Bytes(), received.Signs[1]))
+	p.OnSidechainIllegalDataReceived(peer.PID{}, &received)
+	assert.False(t, p.spv.IsEvidenceSubmitted(evidence.Hash()))
+
+	// the evidence signed by more than 2/3 of the producers is submitted
+	received.Signs = [][]byte{accounts[1].Sign(unsigned.Bytes()), accounts[2].Sign(unsigned.Bytes())}
+	p.OnSidechainIllegalDataReceived(peer.PID{}, &received)
+	assert.True(t, p.spv.IsEvidenceSubmitted(evidence.Hash()))
+	submitted := chain.Submitted()
+	if assert.Equal(t, 1, len(submitted)) {
+		data := submitted[0].Payload.(*payload.SidechainIllegalData)
+		assert.Equal(t, producers[2], data.IllegalSigner)
+		assert.Equal(t, 3, len(data.Signs))
+	}
+}
@@ -50,13 +50,13 @@ func (p *Pbft) StartProposal(block *types.Block) error {
 		Proposal: *proposal,
 	}
 	log.Info("[StartProposal] send proposal message", "proposal", msg.GetMessageHash(m))
-	p.network.BroadcastMessage(m)
+	p.getNetwork().BroadcastMessage(m)
 
 	// Broadcast vote
 	voteMsg := p.dispatcher.AcceptProposal(proposal, p.account)
 	if voteMsg != nil {
 		go p.OnVoteAccepted(id, &voteMsg.Vote)
-		p.network.BroadcastMessage(voteMsg)
+		p.getNetwork().BroadcastMessage(voteMsg)
 	}
 	return nil
 }
@@ -66,6 +66,8 @@ type peerInfo struct {
 	NodePublicKey  string `json:"nodepublickey"`
 	IP             string `json:"ip"`
 	ConnState      string `json:"connstate"`
+
+	Score dpos.PeerScoreInfo `json:"score"`
 }
 
 func (p *Pbft) GetAtbiterPeersInfo() []peerInfo {
@@ -73,7 +75,7 @@ func (p *Pbft) GetAtbiterPeersInfo() []peerInfo {
 		return nil
 	}
 
-	peers :=  p.network.DumpPeersInfo()
+	peers :=  p.getNetwork().DumpPeersInfo()
 
 	result := make([]peerInfo, 0)
 	for _, peer := range peers {
@@ -86,6 +88,7 @@ func (p *Pbft) GetAtbiterPeersInfo() []peerInfo {
 			NodePublicKey: common.Bytes2Hex(pid),
 			IP:       peer.Addr,
 			ConnState: peer.State.String(),
+			Score:     p.getNetwork().PeerScore(peer.PID),
 		})
 	}
 	return result
@@ -111,7 +114,7 @@ func (p *Pbft) BroadBlockMsg(block *types.Block) error {
 		return err
 	}
 	msg := dmsg.NewBlockMsg(buffer.Bytes())
-	p.network.BroadcastMessage(msg)
+	p.getNetwork().BroadcastMessage(msg)
 	p.blockPool.AppendDposBlock(block)
 	return nil
 }
@@ -120,7 +123,7 @@ func (p *Pbft) RequestAbnormalRecovering() {
 	height := p.chain.CurrentHeader().Height()
 	msgItem := &dmsg.RequestConsensus{Height: height}
 	log.Info("[RequestAbnormalRecovering]", "height", height)
-	p.network.BroadcastMessage(msgItem)
+	p.getNetwork().BroadcastMessage(msgItem)
 }
 
 func (p *Pbft) tryGetCurrentProposal(id peer.PID, v *payload.DPOSProposalVote) (elacom.Uint256, bool) {
@@ -128,7 +131,7 @@ func (p *Pbft) tryGetCurrentProposal(id peer.PID, v *payload.DPOSProposalVote) (
 	if currentProposal == nil {
 		if _, ok := p.requestedProposals[v.ProposalHash]; !ok {
 			requestProposal := &msg.RequestProposal{ProposalHash: v.ProposalHash}
-			go p.network.SendMessageToPeer(id, requestProposal)
+			go p.getNetwork().SendMessageToPeer(id, requestProposal)
 		}
 		return elacom.EmptyHash, false
 	}
@@ -186,7 +189,7 @@ func (p *Pbft) AccessFutureBlock(parent *types.Block) {
 	if p.blockPool.HandleParentBlock(parent) {
 		log.Info("----[Send RequestProposal]-----")
 		requestProposal := &msg.RequestProposal{ProposalHash: elacom.EmptyHash}
-		go p.network.BroadcastMessage(requestProposal)
+		go p.getNetwork().BroadcastMessage(requestProposal)
 	}
 }
 
@@ -205,7 +208,7 @@ func (p *Pbft) OnInv(id peer.PID, blockHash elacom.Uint256) {
 	log.Info("[ProcessInv] send getblock:", "hash", blockHash.String())
 	p.limitMap(p.requestedBlocks, maxRequestedBlocks)
 	p.requestedBlocks[hash] = struct{}{}
-	go p.network.SendMessageToPeer(id, msg.NewGetBlock(blockHash))
+	go p.getNetwork().SendMessageToPeer(id, msg.NewGetBlock(blockHash))
 }
 
 func (p *Pbft) OnGetBlock(id peer.PID, blockHash elacom.Uint256) {
@@ -217,19 +220,175 @@ func (p *Pbft) OnGetBlock(id peer.PID, blockHash elacom.Uint256) {
 				log.Error("[OnGetBlock] Encode Block Error")
 			}
 			log.Info("Send block to peer", "peer:", id, "height:", block.GetHeight())
-			go p.network.SendMessageToPeer(id, dmsg.NewBlockMsg(buffer.Bytes()))
+			go p.getNetwork().SendMessageToPeer(id, dmsg.NewBlockMsg(buffer.Bytes()))
 		} else {
 			log.Error("block is not ethereum block")
 		}
 	}
 }
 
+// requestBlocks asks the peer for the confirmed blocks between the local
+// chain head and the given height.
+func (p *Pbft) requestBlocks(id peer.PID, height uint64) {
+	start := p.chain.CurrentBlock().NumberU64() + 1
+	if height < start {
+		return
+	}
+	end := height
+	if end-start+1 > maxGetBlocksRange {
+		end = start + maxGetBlocksRange - 1
+	}
+
+	p.blocksRequestMu.Lock()
+	if r := p.blocksRequest; r != nil && time.Since(r.time) < getBlocksTimeout &&
+		r.start <= start && r.end >= end {
+		p.blocksRequestMu.Unlock()
+		return
+	}
+	p.blocksRequest = &blocksRequest{pid: id, start: start, end: end, time: time.Now()}
+	p.blocksRequestMu.Unlock()
+
+	log.Info("[requestBlocks] send getblocks", "start", start, "end", end)
+	go p.getNetwork().SendMessageToPeer(id, &msg.GetBlocks{
+		StartBlockHeight: uint32(start),
+		EndBlockHeight:   uint32(end),
+	})
+}
+
+// getBlockConfirm returns the confirmed block at the given height, from the
+// chain if it is already inserted or else from the block pool.
+func (p *Pbft) getBlockConfirm(height uint64) *dmsg.BlockConfirm {
+	var (
+		block   *types.Block
+		confirm *payload.Confirm
+	)
+	if block = p.chain.GetBlockByNumber(height); block == nil {
+		c, ok := p.blockPool.GetConfirmByHeight(height)
+		if !ok {
+			return nil
+		}
+		b, ok := p.blockPool.GetBlock(c.Proposal.BlockHash)
+		if !ok {
+			return nil
+		}
+		if block, ok = b.(*types.Block); !ok {
+			return nil
+		}
+		confirm = c
+	}
+	buffer := bytes.NewBuffer([]byte{})
+	if err := block.EncodeRLP(buffer); err != nil {
+		log.Error("[getBlockConfirm] encode block error", "height", height, "err", err)
+		return nil
+	}
+	return &dmsg.BlockConfirm{Block: dmsg.NewBlockMsg(buffer.Bytes()), Confirm: confirm}
+}
+
 func (p *Pbft) OnGetBlocks(id peer.PID, startBlockHeight, endBlockHeight uint32) {
-	fmt.Println("OnGetBlocks")
+	log.Info("[OnGetBlocks]", "start", startBlockHeight, "end", endBlockHeight)
+	if endBlockHeight < startBlockHeight {
+		return
+	}
+	start, end := uint64(startBlockHeight), uint64(endBlockHeight)
+	if end-start+1 > maxGetBlocksRange {
+		end = start + maxGetBlocksRange - 1
+	}
+
+	p.blocksRequestMu.Lock()
+	if r, ok := p.servedBlocksRequests[id]; ok && time.Since(r.time) < getBlocksTimeout &&
+		r.start == start && r.end == end {
+		p.blocksRequestMu.Unlock()
+		log.Info("[OnGetBlocks] duplicated request", "peer", id)
+		return
+	}
+	p.servedBlocksRequests[id] = &blocksRequest{pid: id, start: start, end: end, time: time.Now()}
+	p.blocksRequestMu.Unlock()
+
+	blockConfirms := make([]*dmsg.BlockConfirm, 0, end-start+1)
+	size := 0
+	for height := start; height <= end; height++ {
+		bc := p.getBlockConfirm(height)
+		if bc == nil {
+			break
+		}
+		size += len(bc.Block.GetData())
+		if size > int(dmsg.MaxResponseBlocksSize) && len(blockConfirms) > 0 {
+			break
+		}
+		blockConfirms = append(blockConfirms, bc)
+	}
+	if len(blockConfirms) == 0 {
+		return
+	}
+	log.Info("[OnGetBlocks] send blocks to peer", "peer", id, "count", len(blockConfirms))
+	go p.getNetwork().SendMessageToPeer(id, dmsg.NewResponseBlocks(blockConfirms))
 }
 
-func (p *Pbft) OnResponseBlocks(id peer.PID, blockConfirms []*dmsg.BlockMsg) {
-	fmt.Println("OnResponseBlocks")
+func (p *Pbft) OnResponseBlocks(id peer.PID, blockConfirms []*dmsg.BlockConfirm) {
+	log.Info("[OnResponseBlocks]", "count", len(blockConfirms))
+	p.blocksRequestMu.Lock()
+	request := p.blocksRequest
+	if request == nil || request.pid != id {
+		p.blocksRequestMu.Unlock()
+		log.Warn("[OnResponseBlocks] received unrequested blocks", "peer", id)
+		return
+	}
+	p.blocksRequest = nil
+	p.blocksRequestMu.Unlock()
+
+	blocks := make([]*types.Block, 0, len(blockConfirms))
+	confirms := make(map[uint64]*payload.Confirm, len(blockConfirms))
+	for _, bc := range blockConfirms {
+		block := &types.Block{}
+		err := block.DecodeRLP(rlp.NewStream(bytes.NewBuffer(bc.Block.GetData()), 0))
+		if err != nil {
+			log.Warn("[OnResponseBlocks] decode block error", "err", err)
+			return
+		}
+		if block.NumberU64() < request.start || block.NumberU64() > request.end {
+			log.Warn("[OnResponseBlocks] block is out of requested range", "height", block.NumberU64())
+			return
+		}
+		if bc.Confirm != nil {
+			sealHash := p.SealHash(block.Header())
+			if !bytes.Equal(bc.Confirm.Proposal.BlockHash.Bytes(), sealHash.Bytes()) {
+				log.Warn("[OnResponseBlocks] confirm is not match block", "height", block.NumberU64())
+				return
+			}
+			confirms[block.NumberU64()] = bc.Confirm
+		}
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].NumberU64() < blocks[j].NumberU64()
+	})
+
+	for _, block := range blocks {
+		if block.NumberU64() <= p.chain.CurrentBlock().NumberU64() {
+			continue
+		}
+		// the compact confirm is encoded against the producers of the
+		// parent, so the block is sealed after its parent is inserted
+		if confirm, ok := confirms[block.NumberU64()]; ok {
+			header := block.Header()
+			extra, err := p.encodeConfirm(p.chain, header, confirm)
+			if err != nil {
+				log.Warn("[OnResponseBlocks] encode confirm error", "height", block.NumberU64(), "err", err)
+				return
+			}
+			header.Extra = extra
+			block = block.WithSeal(header)
+		}
+		buffer := bytes.NewBuffer([]byte{})
+		if err := block.EncodeRLP(buffer); err != nil {
+			return
+		}
+		p.OnBlockReceived(id, dmsg.NewBlockMsg(buffer.Bytes()), true)
+		if block.NumberU64() != p.chain.CurrentBlock().NumberU64() {
+			log.Warn("[OnResponseBlocks] insert block failed", "height", block.NumberU64())
+			return
+		}
+	}
 }
 
 func (p *Pbft) OnRequestConsensus(id peer.PID, height uint64) {
@@ -242,7 +401,7 @@ func (p *Pbft) OnRequestConsensus(id peer.PID, height uint64) {
 	status := p.dispatcher.HelpToRecoverAbnormal(id, height, p.chain.CurrentHeader().Height())
 	if status != nil {
 		msg := &msg.ResponseConsensus{Consensus: *status}
-		go p.network.SendMessageToPeer(id, msg)
+		go p.getNetwork().SendMessageToPeer(id, msg)
 	}
 }
 
@@ -251,6 +410,8 @@ func (p *Pbft) OnResponseConsensus(id peer.PID, status *msg.ConsensusStatus) {
 	if !p.IsProducer() {
 		return
 	}
+	p.recoverMu.Lock()
+	defer p.recoverMu.Unlock()
 	if !p.recoverStarted {
 		return
 	}
@@ -264,18 +425,10 @@ func (p *Pbft) OnRequestProposal(id peer.PID, hash elacom.Uint256) {
 	currentProposal := p.dispatcher.GetProcessingProposal()
 	if currentProposal != nil {
 		responseProposal := &msg.Proposal{Proposal: *currentProposal}
-		go p.network.SendMessageToPeer(id, responseProposal)
+		go p.getNetwork().SendMessageToPeer(id, responseProposal)
 	}
 }
 
-func (p *Pbft) OnIllegalProposalReceived(id peer.PID, proposals *payload.DPOSIllegalProposals) {
-	fmt.Println("OnIllegalProposalReceived")
-}
-
-func (p *Pbft) OnIllegalVotesReceived(id peer.PID, votes *payload.DPOSIllegalVotes) {
-	fmt.Println("OnIllegalVotesReceived")
-}
-
 func (p *Pbft) OnProposalReceived(id peer.PID, proposal *payload.DPOSProposal) {
 	log.Info("OnProposalReceived", "hash:", proposal.Hash().String())
 	if _, ok := p.requestedProposals[proposal.Hash()]; ok {
@@ -340,7 +493,7 @@ func (p *Pbft) OnProposalReceived(id peer.PID, proposal *payload.DPOSProposal) {
 		p.notHandledProposal = make(map[string]struct{})
 	}
 	if voteMsg != nil && !p.dispatcher.GetProposalProcessFinished() {
-		p.network.BroadcastMessage(voteMsg)
+		p.getNetwork().BroadcastMessage(voteMsg)
 		p.dispatcher.SetProposalProcessFinished()
 	}
 }
@@ -404,30 +557,39 @@ func (p *Pbft) OnRecover() {
 }
 
 func (p *Pbft) recoverAbnormalState() bool {
+	p.recoverMu.Lock()
 	if p.recoverStarted {
+		p.recoverMu.Unlock()
 		return false
 	}
 	if producers := p.dispatcher.GetConsensusView().GetProducers(); len(producers) > 0 {
-		if peers := p.network.GetActivePeers(); len(peers) == 0 {
+		if peers := p.getNetwork().GetActivePeers(); len(peers) == 0 {
+			p.recoverMu.Unlock()
 			log.Error("[recoverAbnormalState] can not find active peer")
 			return false
 		}
 		p.recoverStarted = true
+		p.recoverMu.Unlock()
 		p.RequestAbnormalRecovering()
 		go func() {
 			<-time.NewTicker(time.Second * 2).C
 			p.OnRecoverTimeout()
+			p.recoverMu.Lock()
 			p.isRecoved = true
+			p.recoverMu.Unlock()
 			if p.chain.Engine() == p {
 				p.StartMine()
 			}
 		}()
 		return true
 	}
+	p.recoverMu.Unlock()
 	return false
 }
 
 func (p *Pbft) OnRecoverTimeout() {
+	p.recoverMu.Lock()
+	defer p.recoverMu.Unlock()
 	if p.recoverStarted == true {
 		if len(p.statusMap) != 0 {
 			p.DoRecover()
@@ -437,6 +599,8 @@ func (p *Pbft) OnRecoverTimeout() {
 	}
 }
 
+// DoRecover recovers the consensus from the statuses collected, the caller
+// holds recoverMu.
 func (p *Pbft) DoRecover() {
 	var maxCount int
 	var maxCountMaxViewOffset uint32
@@ -500,11 +664,12 @@ func (p *Pbft) OnBlockReceived(id peer.PID, b *dmsg.BlockMsg, confirmed bool) {
 
 	parent := p.chain.GetBlock(block.ParentHash(), block.NumberU64() - 1)
 	if parent == nil {//ErrUnknownAncestor
-		count := len(p.network.GetActivePeers())
+		count := len(p.getNetwork().GetActivePeers())
 		log.Warn("verify block error", "error", consensus.ErrUnknownAncestor, "activePeers", count)
 		if !p.dispatcher.GetConsensusView().HasProducerMajorityCount(count) {
 			go p.AnnounceDAddr()
 		}
+		p.requestBlocks(id, block.NumberU64())
 		return
 	}
 
@@ -513,6 +678,7 @@ func (p *Pbft) OnBlockReceived(id peer.PID, b *dmsg.BlockMsg, confirmed bool) {
 	log.Info("InsertChain", "height", block.GetHeight())
 	if block.NumberU64() - p.chain.CurrentBlock().NumberU64() > 1 {
 		log.Info("is bigger than local number")
+		p.requestBlocks(id, block.NumberU64())
 		return
 	}
 	if _, err := p.chain.InsertChain(blocks); err != nil {
@@ -534,6 +700,7 @@ func (p *Pbft) OnConfirmReceived(pid peer.PID, c *payload.Confirm, height uint64
 	
 	if height >  p.chain.CurrentHeader().Number.Uint64() + 1 {
 		log.Info("is future confirm")
+		p.requestBlocks(pid, height-1)
 		return
 	}
 	
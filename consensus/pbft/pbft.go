@@ -13,6 +13,7 @@ import (
 	"math/big"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
@@ -22,10 +23,12 @@ import (
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
 	emsg "github.com/elastos/Elastos.ELA.SideChain.ETH/dpos/msg"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/event"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/rlp"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/rpc"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
 	"github.com/elastos/Elastos.ELA/core/types/payload"
 
 	ecom "github.com/elastos/Elastos.ELA/common"
@@ -50,6 +53,14 @@ const (
 	// maxRequestedBlocks is the maximum number of requested block
 	// hashes to store in memory.
 	maxRequestedBlocks = msg.MaxInvPerMsg
+
+	// maxGetBlocksRange is the maximum number of heights requested by one
+	// GetBlocks message.
+	maxGetBlocksRange = emsg.MaxBlocksPerResponse
+
+	// getBlocksTimeout is the time to wait for the response of a GetBlocks
+	// message before the same heights can be requested again.
+	getBlocksTimeout = 10 * time.Second
 )
 
 var (
@@ -87,8 +98,32 @@ var (
 	errChainForkBlock = errors.New("chain fork block")
 
 	errDoubleSignBlock = errors.New("double sign block")
+
+	// errInvalidElaHeight is returned if the main chain height carried by a
+	// block is lower than its parent's.
+	errInvalidElaHeight = errors.New("invalid ela height")
+
+	// errUnknownElaHeight is returned if a proposed block carries a main chain
+	// height the local spv module has not synced yet.
+	errUnknownElaHeight = errors.New("unknown ela height")
+
+	// errSignerNotRotatable is returned if the node has no producer signer
+	// which can be rotated.
+	errSignerNotRotatable = errors.New("producer signer can not be rotated")
+
+	// errSignerNotProducer is returned if the rotated signer is neither in the
+	// current producers nor in the announced ones.
+	errSignerNotProducer = errors.New("signer is not a producer")
 )
 
+// blocksRequest is a GetBlocks message sent to or received from a peer.
+type blocksRequest struct {
+	pid   peer.PID
+	start uint64
+	end   uint64
+	time  time.Time
+}
+
 // Pbft is a consensus engine based on Byzantine fault-tolerant algorithm
 type Pbft struct {
 	datadir     string
@@ -97,48 +132,73 @@ type Pbft struct {
 	confirmCh   chan *payload.Confirm
 	unConfirmCh chan *payload.Confirm
 	account     daccount.Account
-	network     *dpos.Network
+	network     dpos.DPOSNetwork
+	networkMu   sync.RWMutex // guards network and the signer of account while they are rotated
+	newNetwork  func(pbft *Pbft, account daccount.Account) (dpos.DPOSNetwork, error)
 	blockPool   *dpos.BlockPool
 	chain       *core.BlockChain
 	timeSource  dtime.MedianTimeSource
+	producers   *producersHistory
+	wal         *dpos.ConsensusWAL
+	spv         *spv.Service // the main chain synced by the spv module, nil if the node doesn't follow it
+
+	viewFeed     event.Feed
+	proposalFeed event.Feed
+
+	dposStartHeight uint64
 
 	// IsCurrent returns whether BlockChain synced to best height.
 	IsCurrent func() bool
 	StartMine func()
 	OnDuty func()
 	OnInsertChainError func(id peer.PID, block *types.Block, err error)
+	// OnSignerRotated is invoked after the producer key changed.
+	OnSignerRotated func(account daccount.Account)
 
 	requestedBlocks    map[common.Hash]struct{}
 	requestedProposals map[ecom.Uint256]struct{}
 	statusMap          map[uint32]map[string]*dmsg.ConsensusStatus
 	notHandledProposal map[string]struct{}
 
+	blocksRequest        *blocksRequest
+	servedBlocksRequests map[peer.PID]*blocksRequest
+	blocksRequestMu      sync.Mutex
+
 	enableViewLoop bool
 	recoverStarted bool
 	isRecoved      bool
 	period         uint64
 	isSealOver     bool
 	isRecovering   bool
+	// recoverMu guards statusMap, recoverStarted, isRecoved and isRecovering,
+	// they are written by the network loop and the recover timers.
+	recoverMu sync.Mutex
 }
 
-func New(cfg *params.PbftConfig, pbftKeystore string, password []byte, dataDir string, dposStartHeight uint64) *Pbft {
+func New(cfg *params.PbftConfig, pbftKeystore string, password []byte, pbftSigner string, dataDir string, dposStartHeight uint64) *Pbft {
 	logpath := filepath.Join(dataDir, "/logs/dpos")
 	dposPath := filepath.Join(dataDir, "/network/dpos")
+	walPath := filepath.Join(dataDir, "/wal/dpos")
 	if strings.LastIndex(dataDir, "/") == len(dataDir)-1 {
 		dposPath = filepath.Join(dataDir, "network/dpos")
 		logpath = filepath.Join(dataDir, "logs/dpos")
+		walPath = filepath.Join(dataDir, "wal/dpos")
+	}
+	if dataDir == "" {
+		// without a datadir the wal would be written relative to the working
+		// directory, so the engine runs without one
+		walPath = ""
 	}
 	if cfg == nil {
 		dpos.InitLog(0, 0, 0, logpath)
 		return &Pbft{}
 	}
 	dpos.InitLog(cfg.PrintLevel, cfg.MaxPerLogSize, cfg.MaxLogsSize, logpath)
-	producers := make([][]byte, len(cfg.Producers))
-	for i, v := range cfg.Producers {
-		producers[i] = common.Hex2Bytes(v)
-	}
-	account, err := dpos.GetDposAccount(pbftKeystore, password)
-	if err != nil {
+	var account daccount.Account
+	signerAccount, err := dpos.OpenDposAccount(pbftSigner, pbftKeystore, password)
+	if err == nil {
+		account = signerAccount
+	} else {
 		if string(password) == "" {
 			fmt.Println("create dpos account error:", err.Error(), "pbftKeystore:", pbftKeystore, "password")
 		} else {
@@ -147,7 +207,52 @@ func New(cfg *params.PbftConfig, pbftKeystore string, password []byte, dataDir s
 		//can't return, because common node need verify use this engine
 	}
 	medianTimeSouce := dtime.NewMedianTime()
+	return newPbft(cfg, account, dataDir, walPath, dposStartHeight, medianTimeSouce, 10*time.Second,
+		func(pbft *Pbft, account daccount.Account) (dpos.DPOSNetwork, error) {
+			return dpos.NewNetwork(&dpos.NetworkConfig{
+				IPAddress:   cfg.IPAddress,
+				Magic:       cfg.Magic,
+				DefaultPort: cfg.DPoSPort,
+				Account:     account,
+				MedianTime:  medianTimeSouce,
+				Listener:    pbft,
+				DataPath:    dposPath,
+				PublicKey:   account.PublicKeyBytes(),
+				AnnounceAddr: func() {
+					events.Notify(dpos.ETAnnounceAddr, nil)
+				},
+				IsOnDuty: func(pubKey []byte, viewOffset uint32) bool {
+					if pbft.dispatcher == nil {
+						return true
+					}
+					return pbft.dispatcher.GetConsensusView().ProducerIsOnDutyAt(pubKey, viewOffset)
+				},
+				ViewOffset: func() uint32 {
+					if pbft.dispatcher == nil {
+						return 0
+					}
+					return pbft.dispatcher.GetConsensusView().GetViewOffset()
+				},
+				ProducersCount: func() int {
+					if pbft.dispatcher == nil {
+						return 0
+					}
+					return len(pbft.dispatcher.GetConsensusView().GetProducers())
+				},
+			})
+		})
+}
 
+// newPbft creates the engine with the given account, time source and view
+// tolerance, the network of a producer is created by newNetwork for its account.
+// The consensus wal is opened at walPath, an empty walPath disables it.
+func newPbft(cfg *params.PbftConfig, account daccount.Account, dataDir string, walPath string,
+	dposStartHeight uint64, timeSource dtime.MedianTimeSource, tolerance time.Duration,
+	newNetwork func(pbft *Pbft, account daccount.Account) (dpos.DPOSNetwork, error)) *Pbft {
+	producers := make([][]byte, len(cfg.Producers))
+	for i, v := range cfg.Producers {
+		producers[i] = common.Hex2Bytes(v)
+	}
 	pbft := &Pbft{
 		datadir:            dataDir,
 		cfg:                *cfg,
@@ -158,8 +263,11 @@ func New(cfg *params.PbftConfig, pbftKeystore string, password []byte, dataDir s
 		requestedProposals: make(map[ecom.Uint256]struct{}),
 		statusMap:          make(map[uint32]map[string]*dmsg.ConsensusStatus),
 		notHandledProposal: make(map[string]struct{}),
+		servedBlocksRequests: make(map[peer.PID]*blocksRequest),
 		period:             5,
-		timeSource:         medianTimeSouce,
+		timeSource:         timeSource,
+		producers:          newProducersHistory(producers),
+		dposStartHeight:    dposStartHeight,
 	}
 	blockPool := dpos.NewBlockPool(pbft.verifyConfirm, pbft.verifyBlock, DBlockSealHash)
 	pbft.blockPool = blockPool
@@ -167,28 +275,35 @@ func New(cfg *params.PbftConfig, pbftKeystore string, password []byte, dataDir s
 
 	if account != nil {
 		accpubkey = account.PublicKeyBytes()
-		network, err := dpos.NewNetwork(&dpos.NetworkConfig{
-			IPAddress:   cfg.IPAddress,
-			Magic:       cfg.Magic,
-			DefaultPort: cfg.DPoSPort,
-			Account:     account,
-			MedianTime:  medianTimeSouce,
-			Listener:    pbft,
-			DataPath:    dposPath,
-			PublicKey:   accpubkey,
-			AnnounceAddr: func() {
-				events.Notify(dpos.ETAnnounceAddr, nil)
-			},
-		})
+		network, err := newNetwork(pbft, account)
 		if err != nil {
 			dpos.Error("New dpos network error:", err.Error())
 			return nil
 		}
+		pbft.newNetwork = newNetwork
 		pbft.network = network
 		pbft.subscribeEvent()
 	}
 	pbft.dispatcher = dpos.NewDispatcher(producers, pbft.onConfirm, pbft.onUnConfirm,
-		10*time.Second, accpubkey, medianTimeSouce, pbft, dposStartHeight)
+		tolerance, accpubkey, timeSource, pbft, dposStartHeight)
+	pbft.dispatcher.SetIllegalMonitor(dpos.NewIllegalMonitor(pbft.getEvidenceHeader,
+		pbft.onIllegalProposals, pbft.onIllegalVotes))
+	if account != nil && walPath == "" {
+		dpos.Warn("No data dir, consensus wal is disabled")
+	} else if account != nil {
+		wal, err := dpos.OpenConsensusWAL(walPath)
+		if err != nil {
+			dpos.Error("Open consensus wal error:", err.Error())
+			return nil
+		}
+		pbft.wal = wal
+		pbft.dispatcher.SetWAL(wal)
+	}
+	events.Subscribe(func(e *events.Event) {
+		if e.Type == dpos.ETNextProducers {
+			pbft.onNextTurnDPOSInfo(e.Data.(*payload.NextTurnDPOSInfo))
+		}
+	})
 	return pbft
 }
 
@@ -196,9 +311,9 @@ func (p *Pbft) subscribeEvent() {
 	events.Subscribe(func(e *events.Event) {
 		switch e.Type {
 		case events.ETDirectPeersChanged:
-			go p.network.UpdatePeers(e.Data.([]peer.PID))
+			go p.getNetwork().UpdatePeers(e.Data.([]peer.PID))
 		case dpos.ETNewPeer:
-			count := len(p.network.GetActivePeers())
+			count := len(p.getNetwork().GetActivePeers())
 			log.Info("new peer accept", "active peer count", count)
 			height := p.chain.CurrentHeader().Number.Uint64()
 			cfg := p.chain.Config()
@@ -313,6 +428,10 @@ func (p *Pbft) verifyHeader(chain consensus.ChainReader, header *types.Header, p
 		return ErrInvalidTimestamp
 	}
 
+	if chain.Config().IsPBFTElaHeight(header.Number) && header.Nonce.Uint64() < p.elaHeight(chain.Config(), parent) {
+		return errInvalidElaHeight
+	}
+
 	if number > 0 {
 		if header.Difficulty == nil || (header.Difficulty.Cmp(diffInTurn) != 0) {
 			return errInvalidDifficulty
@@ -347,28 +466,30 @@ func (p *Pbft) verifySeal(chain consensus.ChainReader, header *types.Header, par
 	}
 
 	// Retrieve the confirm from the header extra-data
-	var confirm payload.Confirm
-	err := confirm.Deserialize(bytes.NewReader(header.Extra))
+	confirm, err := p.decodeConfirm(chain, header, parents, header.Extra)
+	if err != nil {
+		return err
+	}
+	producers, err := p.producersAt(chain, header, parents)
 	if err != nil {
 		return err
 	}
-	err = p.verifyConfirm(&confirm)
+	err = dpos.CheckConfirmWithProducers(confirm, producers)
 	if err != nil {
 		return err
 	}
 
 	if oldHeader := chain.GetHeaderByNumber(number); oldHeader != nil {
-		var oldConfirm payload.Confirm
-		err := oldConfirm.Deserialize(bytes.NewReader(oldHeader.Extra))
+		oldConfirm, err := dpos.DecodeConfirmProposal(oldHeader.Extra)
 		if err != nil {
 			return nil
 		}
 
-		log.Info("verify seal chain fork", "oldViewOffset", oldConfirm.Proposal.ViewOffset, "newViewOffset", confirm.Proposal.ViewOffset, "height", number)
-		if confirm.Proposal.ViewOffset < oldConfirm.Proposal.ViewOffset {
+		log.Info("verify seal chain fork", "oldViewOffset", oldConfirm.ViewOffset, "newViewOffset", confirm.Proposal.ViewOffset, "height", number)
+		if confirm.Proposal.ViewOffset < oldConfirm.ViewOffset {
 			return errChainForkBlock
 		}
-		if confirm.Proposal.ViewOffset == oldConfirm.Proposal.ViewOffset && oldHeader.Hash() != header.Hash() {
+		if confirm.Proposal.ViewOffset == oldConfirm.ViewOffset && oldHeader.Hash() != header.Hash() {
 			return errDoubleSignBlock
 		}
 	}
@@ -388,7 +509,7 @@ func (p *Pbft) Prepare(chain consensus.ChainReader, header *types.Header) error
 	if parent == nil {
 		return consensus.ErrUnknownAncestor
 	}
-	if !p.isRecoved {
+	if !p.recovered() {
 		return ErrWaitRecoverStatus
 	}
 	if p.dispatcher.GetConsensusView().IsRunning() && p.enableViewLoop {
@@ -406,6 +527,13 @@ func (p *Pbft) Prepare(chain consensus.ChainReader, header *types.Header) error
 		header.Time = nowTime
 		p.dispatcher.ResetView(nowTime)
 	}
+	if chain.Config().IsPBFTElaHeight(header.Number) {
+		elaHeight, _ := p.spvHeight()
+		if parentHeight := p.elaHeight(chain.Config(), parent); elaHeight < parentHeight {
+			elaHeight = parentHeight
+		}
+		header.Nonce = types.EncodeNonce(elaHeight)
+	}
 
 	return nil
 }
@@ -438,7 +566,7 @@ func (p *Pbft) Seal(chain consensus.ChainReader, block *types.Block, results cha
 		return errors.New("no signer inited")
 	}
 
-	if !p.isRecoved {
+	if !p.recovered() {
 		return ErrWaitRecoverStatus
 	}
 
@@ -471,7 +599,10 @@ func (p *Pbft) Seal(chain consensus.ChainReader, block *types.Block, results cha
 	select {
 	case confirm := <-p.confirmCh:
 		log.Info("Received confirmCh", "proposal", confirm.Proposal.Hash().String(), "block:", block.NumberU64())
-		p.addConfirmToBlock(header, confirm)
+		if err := p.addConfirmToBlock(chain, header, confirm); err != nil {
+			p.isSealOver = true
+			return err
+		}
 		p.isSealOver = true
 		break
 	case <-p.unConfirmCh:
@@ -501,14 +632,13 @@ func (p *Pbft) Seal(chain consensus.ChainReader, block *types.Block, results cha
 	return nil
 }
 
-func (p *Pbft) addConfirmToBlock(header *types.Header, confirm *payload.Confirm) error {
-	sealBuf := new(bytes.Buffer)
-	if err := confirm.Serialize(sealBuf); err != nil {
+func (p *Pbft) addConfirmToBlock(chain consensus.ChainReader, header *types.Header, confirm *payload.Confirm) error {
+	extra, err := p.encodeConfirm(chain, header, confirm)
+	if err != nil {
 		log.Error("confirm serialize error", "error", err)
 		return err
 	}
-	header.Extra = make([]byte, sealBuf.Len())
-	copy(header.Extra[:], sealBuf.Bytes()[:])
+	header.Extra = extra
 	sealHash := SealHash(header)
 	hash, _ := ecom.Uint256FromBytes(sealHash.Bytes())
 	p.dispatcher.FinishedProposal(header.Number.Uint64(), *hash, header.Time)
@@ -526,6 +656,7 @@ func (p *Pbft) onConfirm(confirm *payload.Confirm) error {
 		log.Error("Received confirm", "proposal", confirm.Proposal.Hash().String(), "err:", err)
 		return err
 	}
+	p.postProposalResult(confirm, true)
 	if p.IsOnduty() {
 		log.Info("on duty, set confirm block")
 		p.confirmCh <- confirm
@@ -541,6 +672,7 @@ func (p *Pbft) onUnConfirm(unconfirm *payload.Confirm) error {
 	if p.isSealOver {
 		return errors.New("seal block is over, can't unconfirm")
 	}
+	p.postProposalResult(unconfirm, false)
 	if p.IsOnduty() {
 		p.unConfirmCh <- unconfirm
 	}
@@ -551,6 +683,17 @@ func (p *Pbft) SealHash(header *types.Header) common.Hash {
 	return SealHash(header)
 }
 
+// IsConfirmedHeader returns whether the extra-data of the header carries the
+// confirm of the proposal of the header itself.
+func (p *Pbft) IsConfirmedHeader(header *types.Header) bool {
+	proposal, err := dpos.DecodeConfirmProposal(header.Extra)
+	if err != nil {
+		return false
+	}
+	sealHash := SealHash(header)
+	return bytes.Equal(proposal.BlockHash.Bytes(), sealHash.Bytes())
+}
+
 func (p *Pbft) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
 	dpos.Info("Pbft CalcDifficulty")
 	if p.IsOnduty() {
@@ -565,12 +708,20 @@ func (p *Pbft) APIs(chain consensus.ChainReader) []rpc.API {
 		Version:   "1.0",
 		Service:   &API{chain: chain, pbft: p},
 		Public:    false,
+	}, {
+		Namespace: "admin",
+		Version:   "1.0",
+		Service:   &AdminAPI{pbft: p},
+		Public:    false,
 	}}
 }
 
 func (p *Pbft) Close() error {
 	dpos.Info("Pbft Close")
 	p.enableViewLoop = false
+	if p.wal != nil {
+		return p.wal.Close()
+	}
 	return nil
 }
 
@@ -623,28 +774,28 @@ func encodeSigHeader(w io.Writer, header *types.Header) {
 }
 
 func (p *Pbft) AddDirectLinkPeer(pid peer.PID, addr string) {
-	if p.network != nil {
-		p.network.AddDirectLinkAddr(pid, addr)
+	if network := p.getNetwork(); network != nil {
+		network.AddDirectLinkAddr(pid, addr)
 	}
 }
 
 func (p *Pbft) GetActivePeersCount() int {
-	if p.network != nil {
-		return len(p.network.GetActivePeers())
+	if network := p.getNetwork(); network != nil {
+		return len(network.GetActivePeers())
 	}
 	return 0
 }
 
 func (p *Pbft) StartServer() {
-	if p.network != nil {
-		p.network.Start()
+	if network := p.getNetwork(); network != nil {
+		network.Start()
 		p.Recover()
 	}
 }
 
 func (p *Pbft) StopServer() {
-	if p.network != nil {
-		p.network.Stop()
+	if network := p.getNetwork(); network != nil {
+		network.Stop()
 	}
 	p.enableViewLoop = false
 }
@@ -657,6 +808,7 @@ func (p *Pbft) Start(headerTime uint64) {
 		p.enableViewLoop = true
 		p.dispatcher.GetConsensusView().SetChangViewTime(headerTime)
 		p.dispatcher.GetConsensusView().UpdateDutyIndex(p.chain.CurrentBlock().NumberU64())
+		p.dispatcher.ReplayWAL(p.chain.CurrentBlock().NumberU64())
 		go p.changeViewLoop()
 	} else {
 		p.dispatcher.ResetView(headerTime)
@@ -666,29 +818,45 @@ func (p *Pbft) Start(headerTime uint64) {
 
 func (p *Pbft) changeViewLoop() {
 	for p.enableViewLoop {
-		p.network.PostChangeViewTask()
+		p.getNetwork().PostChangeViewTask()
 		time.Sleep(1 * time.Second)
 	}
 }
 
 func (p *Pbft) Recover() {
-	if p.IsCurrent == nil || p.account == nil || p.isRecovering ||
+	if p.IsCurrent == nil || p.account == nil ||
 		!p.dispatcher.IsProducer(p.account.PublicKeyBytes()) {
 		return
 	}
+	p.recoverMu.Lock()
+	if p.isRecovering {
+		p.recoverMu.Unlock()
+		return
+	}
 	p.isRecovering = true
+	p.recoverMu.Unlock()
 	for {
-		if p.IsCurrent() && len(p.network.GetActivePeers()) > 0 &&
-			p.dispatcher.GetConsensusView().HasArbitersMinorityCount(len(p.network.GetActivePeers())) {
+		if p.IsCurrent() && len(p.getNetwork().GetActivePeers()) > 0 &&
+			p.dispatcher.GetConsensusView().HasArbitersMinorityCount(len(p.getNetwork().GetActivePeers())) {
 			log.Info("----- PostRecoverTask --------")
-			p.network.PostRecoverTask()
+			p.getNetwork().PostRecoverTask()
+			p.recoverMu.Lock()
 			p.isRecovering = false
+			p.recoverMu.Unlock()
 			return
 		}
 		time.Sleep(time.Second)
 	}
 }
 
+// recovered returns whether the consensus status has been recovered from the
+// other producers once.
+func (p *Pbft) recovered() bool {
+	p.recoverMu.Lock()
+	defer p.recoverMu.Unlock()
+	return p.isRecoved
+}
+
 func (p *Pbft) IsOnduty() bool {
 	if p.account == nil {
 		return false
@@ -705,11 +873,21 @@ func (p *Pbft) IsProducer() bool {
 
 func (p *Pbft) SetBlockChain(chain *core.BlockChain) {
 	p.chain = chain
+	p.loadProducersHistory()
+}
+
+// SetSpv sets the spv service following the main chain, the illegal evidences
+// found by the producer are signed with its key and submitted through it.
+func (p *Pbft) SetSpv(service *spv.Service) {
+	p.spv = service
+	if service != nil && p.account != nil {
+		service.SetEvidenceHandlers(p.account.Sign, p.broadcastEvidence)
+	}
 }
 
 func (p *Pbft) broadConfirmMsg(confirm *payload.Confirm, height uint64) {
 	msg := emsg.NewConfirmMsg(confirm, height)
-	p.network.BroadcastMessage(msg)
+	p.getNetwork().BroadcastMessage(msg)
 }
 
 func (p *Pbft) verifyConfirm(confirm *payload.Confirm) error {
@@ -726,6 +904,10 @@ func (p *Pbft) verifyBlock(block dpos.DBlock) error {
 		if err != nil {
 			return err
 		}
+		if spvHeight, ok := p.spvHeight(); ok && spvHeight > 0 && p.chain.Config().IsPBFTElaHeight(b.Number()) && b.Nonce() > spvHeight {
+			log.Warn("block ela height is higher than spv height", "elaHeight", b.Nonce(), "spvHeight", spvHeight)
+			return errUnknownElaHeight
+		}
 		err = p.chain.Validator().ValidateBody(b)
 		if err != nil {
 			log.Error("validateBody error", "height:", b.GetHeight())
@@ -751,6 +933,7 @@ func (p *Pbft) CleanFinalConfirmedBlock(height uint64) {
 }
 
 func (p *Pbft) OnViewChanged(isOnDuty bool, force bool) {
+	p.postViewChange(force)
 	if isOnDuty && p.OnDuty != nil {
 		p.OnDuty()
 	}
@@ -774,6 +957,71 @@ func (p *Pbft) GetTimeSource() dtime.MedianTimeSource {
 	return p.timeSource
 }
 
+// getNetwork returns the dpos network, it is replaced when the signer is rotated.
+func (p *Pbft) getNetwork() dpos.DPOSNetwork {
+	p.networkMu.RLock()
+	defer p.networkMu.RUnlock()
+	return p.network
+}
+
+// GetAccount returns the producer account, it is nil if the node is not a
+// producer.
+func (p *Pbft) GetAccount() daccount.Account {
+	return p.account
+}
+
+// RotateSigner replaces the signer of the producer key while the node is
+// running. If the public key changed it must be one of the current or
+// announced producers, and the dpos network is restarted with the new key.
+func (p *Pbft) RotateSigner(signer dpos.Signer) error {
+	account, ok := p.account.(*dpos.SignerAccount)
+	if !ok {
+		return errSignerNotRotatable
+	}
+	next, err := dpos.NewSignerAccount(signer)
+	if err != nil {
+		return err
+	}
+	oldKey, newKey := account.PublicKeyBytes(), next.PublicKeyBytes()
+	log.Info("rotate producer signer", "old", common.Bytes2Hex(oldKey), "new", common.Bytes2Hex(newKey))
+	if bytes.Equal(oldKey, newKey) {
+		return account.Rotate(signer)
+	}
+	if !p.dispatcher.GetConsensusView().IsProducers(newKey) && !p.producers.isAnnounced(newKey) {
+		return errSignerNotProducer
+	}
+	// The new network is created before anything is swapped so a failure
+	// leaves the engine on the old key.
+	var oldNetwork, newNetwork dpos.DPOSNetwork
+	if p.getNetwork() != nil && p.newNetwork != nil {
+		if newNetwork, err = p.newNetwork(p, next); err != nil {
+			return err
+		}
+	}
+	// The signer and the network are swapped under the lock, the old network
+	// is stopped after it is released as its loop may be reading the network.
+	p.networkMu.Lock()
+	if err := account.Rotate(signer); err != nil {
+		p.networkMu.Unlock()
+		return err
+	}
+	p.dispatcher.GetConsensusView().SetPublicKey(newKey)
+	if newNetwork != nil {
+		oldNetwork, p.network = p.network, newNetwork
+	}
+	p.networkMu.Unlock()
+
+	if newNetwork != nil {
+		oldNetwork.Stop()
+		newNetwork.Start()
+		go p.AnnounceDAddr()
+	}
+	if p.OnSignerRotated != nil {
+		p.OnSignerRotated(account)
+	}
+	return nil
+}
+
 func (p *Pbft) IsBadBlock(height uint64) bool {
 	blocks := p.chain.BadBlocks()
 	for _, block := range blocks {
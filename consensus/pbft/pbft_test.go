@@ -7,10 +7,12 @@ package pbft
 
 import (
 	"bytes"
+	"errors"
 
 	"github.com/stretchr/testify/assert"
 	"math/big"
 	"math/rand"
+	"reflect"
 	"testing"
 	"time"
 
@@ -36,12 +38,12 @@ func TestReimportMirroredState(t *testing.T) {
 			"03bfd8bd2b10e887ec785360f9b329c2ae567975c784daca2f223cb19840b51914",
 		},
 	}
-	PbftProtocolChanges := &params.ChainConfig{big.NewInt(1), big.NewInt(20), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, big.NewInt(0), nil, nil, cfg, "", 0, "", 1, "test/keystore.dat", "123"}
+	PbftProtocolChanges := &params.ChainConfig{big.NewInt(1), big.NewInt(20), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, nil, cfg, "", 0, "", 1, "test/keystore.dat", "123", ""}
 	var (
 		db     = rawdb.NewMemoryDatabase()
 		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr   = crypto.PubkeyToAddress(key.PublicKey)
-		engine = New(cfg, PbftProtocolChanges.PbftKeyStore, []byte(PbftProtocolChanges.PbftKeyStorePassWord), "", PbftProtocolChanges.PBFTBlock.Uint64())
+		engine = New(cfg, PbftProtocolChanges.PbftKeyStore, []byte(PbftProtocolChanges.PbftKeyStorePassWord), PbftProtocolChanges.PbftSigner, t.TempDir(), PbftProtocolChanges.PBFTBlock.Uint64())
 		signer = new(types.HomesteadSigner)
 	)
 	engine.IsCurrent = func() bool {
@@ -103,7 +105,7 @@ func TestReimportMirroredState(t *testing.T) {
 	// Simulate a crash by creating a new chain on top of the database, without
 	// flushing the dirty states out. Insert the last block, trigerring a sidechain
 	// reimport.
-	engine = New(cfg, PbftProtocolChanges.PbftKeyStore, []byte(PbftProtocolChanges.PbftKeyStorePassWord), "", PbftProtocolChanges.PBFTBlock.Uint64())
+	engine = New(cfg, PbftProtocolChanges.PbftKeyStore, []byte(PbftProtocolChanges.PbftKeyStorePassWord), PbftProtocolChanges.PbftSigner, t.TempDir(), PbftProtocolChanges.PBFTBlock.Uint64())
 	chain, _ = core.NewBlockChain(db, nil, PbftProtocolChanges, engine, engine, vm.Config{}, nil)
 	defer chain.Stop()
 
@@ -147,7 +149,7 @@ func TestChangeEngine(t *testing.T) {
 	}
 	cliqueCfg := &params.CliqueConfig{Period: 0, Epoch: 30000}
 	var (
-		PbftProtocolChanges = &params.ChainConfig{big.NewInt(1), big.NewInt(20), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, big.NewInt(10), nil, cliqueCfg, cfg, "", 0, "", 1, "test/keystore.dat", "123"}
+		PbftProtocolChanges = &params.ChainConfig{big.NewInt(1), big.NewInt(20), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, big.NewInt(10), nil, nil, nil, nil, nil, nil, nil, cliqueCfg, cfg, "", 0, "", 1, "test/keystore.dat", "123", ""}
 		db     = rawdb.NewMemoryDatabase()
 		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr   = crypto.PubkeyToAddress(key.PublicKey)
@@ -222,4 +224,67 @@ func TestChangeEngine(t *testing.T) {
 	assert.Equal(t, chain.CurrentHeader().Hash(), blocks2[len(blocks2) - 1].Hash())
 	assert.Equal(t, chain.CurrentHeader().Difficulty, diffInTurn)
 	assert.Equal(t, chain.CurrentHeader().Number.Uint64(), uint64(len(blocks2)))
-}
\ No newline at end of file
+}
+// simSigner is the dpos.Signer of a simulated producer.
+type simSigner struct {
+	*simAccount
+}
+
+func (s simSigner) Sign(data []byte) ([]byte, error) {
+	return s.simAccount.Sign(data), nil
+}
+
+func TestRotateSigner(t *testing.T) {
+	apis := (&Pbft{}).APIs(nil)
+	for _, api := range apis {
+		_, found := reflect.TypeOf(api.Service).MethodByName("RotateSigner")
+		assert.Equal(t, api.Namespace == "admin", found, api.Namespace)
+	}
+
+	oldSigner, newSigner := simSigner{newSimAccount()}, simSigner{newSimAccount()}
+	signerAccount, err := dpos.NewSignerAccount(oldSigner)
+	assert.NoError(t, err)
+	hub := newSimHub(1)
+	oldNetwork, newNetwork := newSimNetwork(hub, oldSigner.simAccount), newSimNetwork(hub, newSigner.simAccount)
+	p := &Pbft{account: signerAccount, network: oldNetwork}
+	networkErr := errors.New("network error")
+	p.newNetwork = func(_ *Pbft, next account.Account) (dpos.DPOSNetwork, error) {
+		assert.Equal(t, newSigner.PublicKeyBytes(), next.PublicKeyBytes())
+		return nil, networkErr
+	}
+	p.producers = newProducersHistory([][]byte{oldSigner.PublicKeyBytes()})
+	p.dispatcher = dpos.NewDispatcher([][]byte{oldSigner.PublicKeyBytes()}, nil, nil, 10*time.Second,
+		oldSigner.PublicKeyBytes(), nil, nil, 0)
+	oldNetwork.Start()
+
+	// a key which is not a producer is rejected
+	assert.Equal(t, errSignerNotProducer, p.RotateSigner(newSigner))
+	assert.Equal(t, oldSigner.PublicKeyBytes(), signerAccount.PublicKeyBytes())
+
+	// the engine keeps the old key if the new network can't be created
+	p.producers.pending[100] = [][]byte{newSigner.PublicKeyBytes()}
+	assert.Equal(t, networkErr, p.RotateSigner(newSigner))
+	assert.Equal(t, oldSigner.PublicKeyBytes(), signerAccount.PublicKeyBytes())
+	assert.Equal(t, dpos.DPOSNetwork(oldNetwork), p.getNetwork())
+	assert.True(t, oldNetwork.isRunning())
+
+	p.newNetwork = func(*Pbft, account.Account) (dpos.DPOSNetwork, error) {
+		return newNetwork, nil
+	}
+
+	// the network is read by the consensus while the signer is rotated
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			p.GetActivePeersCount()
+		}
+	}()
+	assert.NoError(t, p.RotateSigner(newSigner))
+	<-done
+	assert.Equal(t, newSigner.PublicKeyBytes(), signerAccount.PublicKeyBytes())
+	assert.Equal(t, dpos.DPOSNetwork(newNetwork), p.getNetwork())
+	assert.False(t, oldNetwork.isRunning())
+	assert.True(t, newNetwork.isRunning())
+	newNetwork.Stop()
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+)
+
+// producersHistory keeps every producer set used by the engine indexed by the
+// side chain height it became active at, together with the sets announced by
+// the main chain which are still waiting for their working height.
+//
+// A set announced for main chain working height W becomes active at the first
+// side chain block whose parent carries an ela height (header nonce) >= W.
+type producersHistory struct {
+	genesis [][]byte
+	heights []uint64            // sorted activation heights
+	sets    map[uint64][][]byte // activation height -> producers
+	pending map[uint64][][]byte // main chain working height -> producers
+
+	mu sync.RWMutex
+}
+
+func newProducersHistory(genesis [][]byte) *producersHistory {
+	return &producersHistory{
+		genesis: genesis,
+		sets:    make(map[uint64][][]byte),
+		pending: make(map[uint64][][]byte),
+	}
+}
+
+func (h *producersHistory) setActive(number uint64, producers [][]byte) {
+	if _, ok := h.sets[number]; !ok {
+		h.heights = append(h.heights, number)
+		sort.Slice(h.heights, func(i, j int) bool {
+			return h.heights[i] < h.heights[j]
+		})
+	}
+	h.sets[number] = producers
+}
+
+// active returns the last recorded set at or below number and the height it
+// was activated at.
+func (h *producersHistory) active(number uint64) ([][]byte, uint64) {
+	producers, activated := h.genesis, uint64(0)
+	for _, height := range h.heights {
+		if height > number {
+			break
+		}
+		producers, activated = h.sets[height], height
+	}
+	return producers, activated
+}
+
+// due returns the latest announced set whose working height has been reached
+// by the given main chain height.
+func (h *producersHistory) due(elaHeight uint64) ([][]byte, uint64, bool) {
+	var (
+		producers [][]byte
+		working   uint64
+		found     bool
+	)
+	for height, set := range h.pending {
+		if height <= elaHeight && (!found || height > working) {
+			producers, working, found = set, height, true
+		}
+	}
+	return producers, working, found
+}
+
+// isAnnounced returns whether the key is in a set announced by the main chain
+// which is still waiting for its working height.
+func (h *producersHistory) isAnnounced(key []byte) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, set := range h.pending {
+		for _, producer := range set {
+			if bytes.Equal(producer, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// producersAt returns the producer set for the block at number whose parent
+// carries parentElaHeight.
+func (h *producersHistory) producersAt(number uint64, parentElaHeight uint64) [][]byte {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	producers, activated := h.active(number)
+	if number > activated {
+		// The parent has not been processed by OnInsertBlock yet, the rotation
+		// it triggers is not part of the recorded history.
+		if next, _, ok := h.due(parentElaHeight); ok {
+			producers = next
+		}
+	}
+	return producers
+}
+
+// elaHeight returns the main chain height the proposer of a pbft block had
+// synced, it is carried in the header nonce from the pbft ela height fork.
+// The nonce of an earlier block, a clique block voting on a signer or a pbft
+// block of a producer not stamping it, is not an ela height.
+func (p *Pbft) elaHeight(config *params.ChainConfig, header *types.Header) uint64 {
+	if header == nil || header.Number.Uint64() < p.dposStartHeight || !config.IsPBFTElaHeight(header.Number) {
+		return 0
+	}
+	return header.Nonce.Uint64()
+}
+
+// spvHeight returns the best main chain height synced by the spv module, false
+// if the node doesn't follow the main chain.
+func (p *Pbft) spvHeight() (uint64, bool) {
+	return p.spv.BestHeight()
+}
+
+// producersAt returns the producer set which was active for the given header.
+// The set is derived from the arbiters announced by the main chain up to the
+// ela height of the parent, ErrFutureBlock is returned if the spv module has
+// not synced them yet so the header is retried instead of being rejected.
+func (p *Pbft) producersAt(chain consensus.ChainReader, header *types.Header, parents []*types.Header) ([][]byte, error) {
+	number := header.Number.Uint64()
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	parentElaHeight := p.elaHeight(chain.Config(), parent)
+	if height, ok := p.spvHeight(); ok && height < parentElaHeight {
+		p.producers.mu.RLock()
+		_, activated := p.producers.active(number)
+		p.producers.mu.RUnlock()
+		if number > activated {
+			log.Warn("producers of the block not synced", "height", number, "parentElaHeight", parentElaHeight, "spvHeight", height)
+			return nil, consensus.ErrFutureBlock
+		}
+	}
+	return p.producers.producersAt(number, parentElaHeight), nil
+}
+
+// loadProducersHistory restores the producer sets from the chain database and
+// switches the consensus view to the set active for the next block.
+func (p *Pbft) loadProducersHistory() {
+	if p.chain == nil || p.dispatcher == nil {
+		return
+	}
+	db := p.chain.GetDatabase()
+	p.producers.mu.Lock()
+	for height, producers := range rawdb.ReadAllPbftProducers(db) {
+		p.producers.setActive(height, producers)
+	}
+	for working, producers := range rawdb.ReadAllNextPbftProducers(db) {
+		p.producers.pending[working] = producers
+	}
+	producers, activated := p.producers.active(p.chain.CurrentBlock().NumberU64() + 1)
+	p.producers.mu.Unlock()
+
+	if activated > 0 {
+		log.Info("restore pbft producers", "activated", activated, "count", len(producers))
+		p.dispatcher.GetConsensusView().UpdateProducers(producers, activated)
+	}
+}
+
+// onNextTurnDPOSInfo records the arbiters announced by the main chain, they
+// replace the current producers once their working height is reached.
+func (p *Pbft) onNextTurnDPOSInfo(info *payload.NextTurnDPOSInfo) {
+	producers := make([][]byte, 0, len(info.CRPublicKeys)+len(info.DPOSPublicKeys))
+	for _, pk := range info.CRPublicKeys {
+		if len(pk) > 0 {
+			producers = append(producers, pk)
+		}
+	}
+	for _, pk := range info.DPOSPublicKeys {
+		if len(pk) > 0 {
+			producers = append(producers, pk)
+		}
+	}
+	if len(producers) == 0 {
+		log.Warn("ignore empty next turn producers", "workingHeight", info.WorkingHeight)
+		return
+	}
+	working := uint64(info.WorkingHeight)
+
+	p.producers.mu.Lock()
+	p.producers.pending[working] = producers
+	p.producers.mu.Unlock()
+	if p.chain == nil {
+		return
+	}
+	rawdb.WriteNextPbftProducers(p.chain.GetDatabase(), working, producers)
+	log.Info("received next turn producers", "workingHeight", working, "count", len(producers))
+
+	// The working height may already be covered by local blocks if the spv
+	// module was behind the side chain, apply the rotation at the block it
+	// was due.
+	current := p.chain.CurrentBlock()
+	if p.elaHeight(p.chain.Config(), current.Header()) < working {
+		return
+	}
+	header := current.Header()
+	for header.Number.Uint64() > p.dposStartHeight {
+		parent := p.chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+		if parent == nil || p.elaHeight(p.chain.Config(), parent) < working {
+			break
+		}
+		header = parent
+	}
+	p.applyProducers(header)
+}
+
+// OnInsertBlock applies the announced producer set which becomes active after
+// the given block.
+func (p *Pbft) OnInsertBlock(block *types.Block) {
+	p.applyProducers(block.Header())
+}
+
+func (p *Pbft) applyProducers(header *types.Header) {
+	if p.chain == nil || p.dispatcher == nil {
+		return
+	}
+	number := header.Number.Uint64() + 1
+	elaHeight := p.elaHeight(p.chain.Config(), header)
+
+	p.producers.mu.Lock()
+	producers, working, ok := p.producers.due(elaHeight)
+	if !ok {
+		p.producers.mu.Unlock()
+		return
+	}
+	if _, activated := p.producers.active(number); activated >= number {
+		p.producers.mu.Unlock()
+		return
+	}
+	db := p.chain.GetDatabase()
+	p.producers.setActive(number, producers)
+	rawdb.WritePbftProducers(db, number, producers)
+	for height := range p.producers.pending {
+		if height <= elaHeight {
+			delete(p.producers.pending, height)
+			rawdb.DeleteNextPbftProducers(db, height)
+		}
+	}
+	latest := p.producers.heights[len(p.producers.heights)-1] == number
+	p.producers.mu.Unlock()
+
+	log.Info("change pbft producers", "height", number, "workingHeight", working, "count", len(producers))
+	if latest {
+		p.dispatcher.GetConsensusView().UpdateProducers(producers, number)
+	}
+	for _, producer := range producers {
+		log.Info("pbft producer", "publicKey", common.Bytes2Hex(producer))
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProducersHistory(t *testing.T) {
+	genesis := [][]byte{{1}, {2}, {3}}
+	first := [][]byte{{4}, {5}, {6}}
+	second := [][]byte{{7}, {8}}
+
+	h := newProducersHistory(genesis)
+	assert.Equal(t, genesis, h.producersAt(10, 0))
+
+	// announced sets are only used once the parent reached the working height
+	h.pending[500] = first
+	assert.Equal(t, genesis, h.producersAt(10, 499))
+	assert.Equal(t, first, h.producersAt(10, 500))
+
+	// recorded history wins over pending sets for old blocks
+	h.setActive(11, first)
+	delete(h.pending, 500)
+	h.pending[600] = second
+	assert.Equal(t, genesis, h.producersAt(10, 0))
+	assert.Equal(t, first, h.producersAt(11, 500))
+	assert.Equal(t, first, h.producersAt(20, 599))
+	assert.Equal(t, second, h.producersAt(20, 600))
+
+	h.setActive(21, second)
+	delete(h.pending, 600)
+	producers, activated := h.active(30)
+	assert.Equal(t, second, producers)
+	assert.Equal(t, uint64(21), activated)
+	assert.Equal(t, first, h.producersAt(15, 700))
+}
+
+func TestElaHeight(t *testing.T) {
+	config := *params.TestChainConfig
+	config.PBFTBlock = big.NewInt(10)
+	config.PBFTElaHeightBlock = big.NewInt(20)
+	p := &Pbft{dposStartHeight: 10}
+	header := func(number int64, nonce uint64) *types.Header {
+		return &types.Header{Number: big.NewInt(number), Nonce: types.EncodeNonce(nonce)}
+	}
+
+	// the nonce of the clique block voting on a signer at the switch
+	assert.Equal(t, uint64(0), p.elaHeight(&config, header(9, math.MaxUint64)))
+	// the nonce of the pbft blocks before the ela height fork
+	assert.Equal(t, uint64(0), p.elaHeight(&config, header(19, 500)))
+	assert.Equal(t, uint64(500), p.elaHeight(&config, header(20, 500)))
+	assert.Equal(t, uint64(0), p.elaHeight(&config, nil))
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
+)
+
+func TestSimulationNormal(t *testing.T) {
+	s := newSimulation(t, 4, 0)
+	defer s.stop()
+	s.start()
+
+	s.waitHeight(8, time.Minute, s.nodes...)
+	s.checkSafety(s.nodes...)
+}
+
+func TestSimulationDelayAndDrop(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping long simulation in short mode")
+	}
+	s := newSimulation(t, 4, 0)
+	defer s.stop()
+	s.hub.setDelay(10*time.Millisecond, 200*time.Millisecond)
+	s.hub.setDropRate(0.05)
+	s.start()
+
+	s.waitHeight(8, 2*time.Minute, s.nodes...)
+	s.checkSafety(s.nodes...)
+}
+
+func TestSimulationPartition(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping long simulation in short mode")
+	}
+	s := newSimulation(t, 4, 0)
+	defer s.stop()
+	s.start()
+	s.waitHeight(2, time.Minute, s.nodes...)
+
+	// the majority keeps producing while a producer is isolated
+	s.hub.split(3)
+	height := s.nodes[3].height()
+	s.waitHeight(height+5, 2*time.Minute, s.nodes[:3]...)
+	s.checkSafety(s.nodes...)
+
+	// the isolated producer catches up after the partition is healed
+	s.hub.heal()
+	height = s.nodes[0].height()
+	s.waitHeight(height+3, 2*time.Minute, s.nodes...)
+	s.checkSafety(s.nodes...)
+}
+
+func TestSimulationClockSkew(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping long simulation in short mode")
+	}
+	s := newSimulation(t, 4, 0)
+	defer s.stop()
+	s.nodes[1].clock.skew = time.Second
+	s.nodes[2].clock.skew = -time.Second
+	s.start()
+
+	s.waitHeight(8, 2*time.Minute, s.nodes...)
+	s.checkSafety(s.nodes...)
+}
+
+func TestSimulationByzantine(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping long simulation in short mode")
+	}
+	s := newSimulation(t, 4, 1)
+	defer s.stop()
+	s.start()
+
+	honest := s.honest()
+	s.waitHeight(8, 2*time.Minute, honest...)
+	s.checkSafety(honest...)
+
+	// the double votes of the byzantine producer are recorded as evidence
+	byzantine := s.nodes[0].account.pubKey
+	for _, node := range honest {
+		for _, evidence := range rawdb.ReadAllPbftIllegalEvidences(node.chain.GetDatabase()) {
+			if bytes.Equal(evidence.Signer, byzantine) {
+				return
+			}
+		}
+	}
+	t.Fatalf("seed %d: no evidence of the byzantine producer", s.seed)
+}
+
+func TestSimulationCompactConfirm(t *testing.T) {
+	s := newSimulation(t, 4, 0)
+	defer s.stop()
+	s.config.PBFTCompactConfirmBlock = big.NewInt(4)
+	s.start()
+
+	s.waitHeight(8, time.Minute, s.nodes...)
+	s.checkSafety(s.nodes...)
+
+	// the blocks switch to the compact confirm at the fork and the rpc
+	// still returns the expanded confirm
+	node := s.nodes[0]
+	for number := uint64(1); number <= 8; number++ {
+		header := node.chain.GetHeaderByNumber(number)
+		if compact := dpos.IsCompactConfirm(header.Extra); compact != (number >= 4) {
+			t.Fatalf("seed %d: block %d compact confirm %v", s.seed, number, compact)
+		}
+		info := node.engine.GetConfirmByHeight(number)
+		if info == nil || len(info.Votes) < 3 {
+			t.Fatalf("seed %d: block %d has no expanded confirm", s.seed, number)
+		}
+	}
+}
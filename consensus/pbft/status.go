@@ -0,0 +1,249 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/event"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+)
+
+// maxDutySchedule is the max number of heights returned by GetDutySchedule.
+const maxDutySchedule = 1024
+
+// ViewChangeEvent is posted when the consensus view changes.
+type ViewChangeEvent struct {
+	Height         uint64 `json:"height"`
+	ViewOffset     uint32 `json:"viewoffset"`
+	ViewStartTime  uint64 `json:"viewstarttime"`
+	ViewChangeTime uint64 `json:"viewchangetime"`
+	OnDuty         string `json:"onduty"`
+	IsOnDuty       bool   `json:"isonduty"`
+	Force          bool   `json:"force"`
+}
+
+// ProposalResultEvent is posted when a proposal collected the majority of
+// accept or reject votes.
+type ProposalResultEvent struct {
+	Height   uint64       `json:"height"`
+	Proposal proposalInfo `json:"proposal"`
+	Accepted bool         `json:"accepted"`
+	Votes    int          `json:"votes"`
+}
+
+// viewInfo is the RPC representation of the current consensus view.
+type viewInfo struct {
+	Height         uint64 `json:"height"`
+	Running        bool   `json:"running"`
+	ViewOffset     uint32 `json:"viewoffset"`
+	ViewStartTime  uint64 `json:"viewstarttime"`
+	ViewChangeTime uint64 `json:"viewchangetime"`
+	OnDuty         string `json:"onduty"`
+	IsOnDuty       bool   `json:"isonduty"`
+}
+
+// voteTally is the RPC representation of the votes of the processing proposal.
+type voteTally struct {
+	Proposal      string   `json:"proposal"`
+	MajorityCount int      `json:"majoritycount"`
+	Accepts       []string `json:"accepts"`
+	Rejects       []string `json:"rejects"`
+}
+
+// recoveryStatus is the RPC representation of the abnormal recovery state.
+type recoveryStatus struct {
+	Recovering     bool `json:"recovering"`
+	RecoverStarted bool `json:"recoverstarted"`
+	Recovered      bool `json:"recovered"`
+	StatusCount    int  `json:"statuscount"`
+}
+
+// dutyInfo is the RPC representation of the producer on duty at a height.
+type dutyInfo struct {
+	Height   uint64 `json:"height"`
+	Producer string `json:"producer"`
+}
+
+func newProposalInfo(proposal *payload.DPOSProposal) proposalInfo {
+	return proposalInfo{
+		Hash:       proposal.Hash().String(),
+		Sponsor:    common.Bytes2Hex(proposal.Sponsor),
+		SealHash:   proposal.BlockHash.String(),
+		ViewOffset: proposal.ViewOffset,
+		Sign:       common.Bytes2Hex(proposal.Sign),
+	}
+}
+
+// nextHeight returns the height of the block under consensus.
+func (p *Pbft) nextHeight() uint64 {
+	if p.chain == nil {
+		return 0
+	}
+	return p.chain.CurrentHeader().Number.Uint64() + 1
+}
+
+// GetProducers returns the public keys of the current producers.
+func (p *Pbft) GetProducers() []string {
+	if p.dispatcher == nil {
+		return nil
+	}
+	producers := p.dispatcher.GetConsensusView().GetProducers()
+	result := make([]string, 0, len(producers))
+	for _, producer := range producers {
+		result = append(result, common.Bytes2Hex(producer))
+	}
+	return result
+}
+
+// GetOnDutyProducer returns the public key of the producer on duty in the
+// current view.
+func (p *Pbft) GetOnDutyProducer() string {
+	if p.dispatcher == nil {
+		return ""
+	}
+	return p.onDutyProducer(p.dispatcher.GetConsensusView())
+}
+
+func (p *Pbft) onDutyProducer(view *dpos.ConsensusView) string {
+	for _, producer := range view.GetProducers() {
+		if view.ProducerIsOnDuty(producer) {
+			return common.Bytes2Hex(producer)
+		}
+	}
+	return ""
+}
+
+// GetViewInfo returns the current consensus view.
+func (p *Pbft) GetViewInfo() *viewInfo {
+	if p.dispatcher == nil {
+		return nil
+	}
+	view := p.dispatcher.GetConsensusView()
+	return &viewInfo{
+		Height:         p.nextHeight(),
+		Running:        view.IsRunning(),
+		ViewOffset:     view.GetViewOffset(),
+		ViewStartTime:  uint64(view.GetViewStartTime().Unix()),
+		ViewChangeTime: uint64(view.GetChangeViewTime().Unix()),
+		OnDuty:         p.onDutyProducer(view),
+		IsOnDuty:       view.IsOnduty(),
+	}
+}
+
+// GetProcessingProposal returns the proposal which is being voted on.
+func (p *Pbft) GetProcessingProposal() *proposalInfo {
+	if p.dispatcher == nil {
+		return nil
+	}
+	proposal := p.dispatcher.GetProcessingProposal()
+	if proposal == nil {
+		return nil
+	}
+	info := newProposalInfo(proposal)
+	return &info
+}
+
+// GetVoteTally returns the votes collected for the processing proposal.
+func (p *Pbft) GetVoteTally() *voteTally {
+	if p.dispatcher == nil {
+		return nil
+	}
+	proposal := p.dispatcher.GetProcessingProposal()
+	if proposal == nil {
+		return nil
+	}
+	accepts, rejects := p.dispatcher.GetVotes()
+	tally := &voteTally{
+		Proposal:      proposal.Hash().String(),
+		MajorityCount: p.dispatcher.GetConsensusView().GetMajorityCount(),
+		Accepts:       make([]string, 0, len(accepts)),
+		Rejects:       make([]string, 0, len(rejects)),
+	}
+	for _, vote := range accepts {
+		tally.Accepts = append(tally.Accepts, common.Bytes2Hex(vote.Signer))
+	}
+	for _, vote := range rejects {
+		tally.Rejects = append(tally.Rejects, common.Bytes2Hex(vote.Signer))
+	}
+	return tally
+}
+
+// GetRecoveryStatus returns the state of the abnormal consensus recovery.
+func (p *Pbft) GetRecoveryStatus() *recoveryStatus {
+	p.recoverMu.Lock()
+	defer p.recoverMu.Unlock()
+	count := 0
+	for _, status := range p.statusMap {
+		count += len(status)
+	}
+	return &recoveryStatus{
+		Recovering:     p.isRecovering,
+		RecoverStarted: p.recoverStarted,
+		Recovered:      p.isRecoved,
+		StatusCount:    count,
+	}
+}
+
+// GetDutySchedule returns the producers on duty at view offset zero for the
+// next count heights.
+func (p *Pbft) GetDutySchedule(count uint64) []dutyInfo {
+	if p.dispatcher == nil {
+		return nil
+	}
+	if count > maxDutySchedule {
+		count = maxDutySchedule
+	}
+	view := p.dispatcher.GetConsensusView()
+	height := p.nextHeight()
+	schedule := make([]dutyInfo, 0, count)
+	for i := uint64(0); i < count; i++ {
+		schedule = append(schedule, dutyInfo{
+			Height:   height + i,
+			Producer: common.Bytes2Hex(view.GetDutyProducer(height + i)),
+		})
+	}
+	return schedule
+}
+
+// postViewChange notifies the subscribers of the current view.
+func (p *Pbft) postViewChange(force bool) {
+	view := p.dispatcher.GetConsensusView()
+	p.viewFeed.Send(ViewChangeEvent{
+		Height:         p.nextHeight(),
+		ViewOffset:     view.GetViewOffset(),
+		ViewStartTime:  uint64(view.GetViewStartTime().Unix()),
+		ViewChangeTime: uint64(view.GetChangeViewTime().Unix()),
+		OnDuty:         p.onDutyProducer(view),
+		IsOnDuty:       view.IsOnduty(),
+		Force:          force,
+	})
+}
+
+// postProposalResult notifies the subscribers of the outcome of a proposal.
+func (p *Pbft) postProposalResult(confirm *payload.Confirm, accepted bool) {
+	var height uint64
+	if block, ok := p.blockPool.GetBlock(confirm.Proposal.BlockHash); ok {
+		height = block.GetHeight()
+	}
+	p.proposalFeed.Send(ProposalResultEvent{
+		Height:   height,
+		Proposal: newProposalInfo(&confirm.Proposal),
+		Accepted: accepted,
+		Votes:    len(confirm.Votes),
+	})
+}
+
+// SubscribeViewChange registers a subscription of ViewChangeEvent.
+func (p *Pbft) SubscribeViewChange(ch chan<- ViewChangeEvent) event.Subscription {
+	return p.viewFeed.Subscribe(ch)
+}
+
+// SubscribeProposalResult registers a subscription of ProposalResultEvent.
+func (p *Pbft) SubscribeProposalResult(ch chan<- ProposalResultEvent) event.Subscription {
+	return p.proposalFeed.Subscribe(ch)
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/dpos/dtime"
+	dmsg "github.com/elastos/Elastos.ELA/dpos/p2p/msg"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsensusStatus(t *testing.T) {
+	producers := [][]byte{{1}, {2}, {3}}
+	p := &Pbft{}
+	p.dispatcher = dpos.NewDispatcher(producers, nil, nil, 10*time.Second,
+		producers[1], dtime.NewMedianTime(), nil, 0)
+
+	assert.Equal(t, []string{"01", "02", "03"}, p.GetProducers())
+	assert.Equal(t, "01", p.GetOnDutyProducer())
+	info := p.GetViewInfo()
+	assert.Equal(t, uint32(0), info.ViewOffset)
+	assert.Equal(t, "01", info.OnDuty)
+	assert.Nil(t, p.GetProcessingProposal())
+	assert.Nil(t, p.GetVoteTally())
+
+	schedule := p.GetDutySchedule(4)
+	if assert.Equal(t, 4, len(schedule)) {
+		for i, duty := range schedule {
+			assert.Equal(t, uint64(i), duty.Height)
+			assert.Equal(t, common.Bytes2Hex(producers[i%3]), duty.Producer)
+		}
+	}
+	assert.Equal(t, maxDutySchedule, len(p.GetDutySchedule(maxDutySchedule+1)))
+
+	events := make(chan ViewChangeEvent, 1)
+	sub := p.SubscribeViewChange(events)
+	defer sub.Unsubscribe()
+	p.postViewChange(true)
+	ev := <-events
+	assert.True(t, ev.Force)
+	assert.Equal(t, "01", ev.OnDuty)
+
+	p.blockPool = dpos.NewBlockPool(nil, nil, nil)
+	results := make(chan ProposalResultEvent, 1)
+	resultSub := p.SubscribeProposalResult(results)
+	defer resultSub.Unsubscribe()
+	proposal := payload.DPOSProposal{Sponsor: producers[0], ViewOffset: 1}
+	p.postProposalResult(&payload.Confirm{Proposal: proposal,
+		Votes: []payload.DPOSProposalVote{{Signer: producers[0], Accept: true}}}, true)
+	result := <-results
+	assert.True(t, result.Accepted)
+	assert.Equal(t, 1, result.Votes)
+	assert.Equal(t, proposal.Hash().String(), result.Proposal.Hash)
+}
+
+func TestRecoveryStatusConcurrent(t *testing.T) {
+	p := &Pbft{statusMap: make(map[uint32]map[string]*dmsg.ConsensusStatus)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			p.recoverMu.Lock()
+			p.recoverStarted = true
+			p.recoverMu.Unlock()
+			p.OnRecoverTimeout()
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		status := p.GetRecoveryStatus()
+		assert.Equal(t, 0, status.StatusCount)
+	}
+	<-done
+	assert.False(t, p.GetRecoveryStatus().RecoverStarted)
+}
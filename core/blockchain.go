@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/blocksigner"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common/mclock"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common/prque"
@@ -54,6 +55,7 @@ var (
 	headBlockGauge     = metrics.NewRegisteredGauge("chain/head/block", nil)
 	headHeaderGauge    = metrics.NewRegisteredGauge("chain/head/header", nil)
 	headFastBlockGauge = metrics.NewRegisteredGauge("chain/head/receipt", nil)
+	headFinalizedGauge = metrics.NewRegisteredGauge("chain/head/finalized", nil)
 
 	accountReadTimer   = metrics.NewRegisteredTimer("chain/account/reads", nil)
 	accountHashTimer   = metrics.NewRegisteredTimer("chain/account/hashes", nil)
@@ -148,6 +150,7 @@ type BlockChain struct {
 	chainFeed     event.Feed
 	chainSideFeed event.Feed
 	chainHeadFeed event.Feed
+	finalizedFeed event.Feed
 	logsFeed      event.Feed
 	dangerousFeed event.Feed
 	blockProcFeed event.Feed
@@ -159,6 +162,7 @@ type BlockChain struct {
 
 	currentBlock     atomic.Value // Current head of the block chain
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
+	currentFinalized atomic.Value // Current highest canonical block finalized by a pbft confirm (may be nil)
 
 	stateCache    state.Database // State database to reuse between imports (contains state cache)
 	bodyCache     *lru.Cache     // Cache for the most recent block bodies
@@ -181,6 +185,7 @@ type BlockChain struct {
 	prefetcher Prefetcher // Block state prefetcher interface
 	processor  Processor  // Block transaction processor interface
 	vmConfig   vm.Config
+	bridge     *bridge.Registry // Bridge system contracts crediting the deposits of the main chain
 
 	badBlocks       *lru.Cache                     // Bad block cache
 	shouldPreserve  func(*types.Block) bool        // Function used to determine whether should preserve the given block.
@@ -188,7 +193,7 @@ type BlockChain struct {
 
 	evilSigners *EvilSignersMap // EvilSigners contains evil signers
 	evilmu      sync.RWMutex    // evil signers lock
-	journal     *EvilJournal    // Journal of local  evilSingeerEvents to back up to disk
+	evilProofs  EvilProofSender // Reporter of the evil signers to the ela chain
 }
 
 // NewBlockChain returns a fully initialised block chain using information
@@ -226,8 +231,8 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		futureBlocks:   futureBlocks,
 		engine:         engine,
 		vmConfig:       vmConfig,
+		bridge:         bridge.NewRegistry(chainConfig, nil),
 		badBlocks:      badBlocks,
-		journal:        NewEvilJournal(chainConfig.EvilSignersJournalDir),
 		evilSigners:    &EvilSignersMap{},
 	}
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
@@ -247,6 +252,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	var nilBlock *types.Block
 	bc.currentBlock.Store(nilBlock)
 	bc.currentFastBlock.Store(nilBlock)
+	bc.currentFinalized.Store(nilBlock)
 
 	// Initialize the chain with ancient data if it isn't empty.
 	if bc.empty() {
@@ -314,17 +320,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		}
 	}
 
-	if bc.journal != nil {
-		if err := bc.journal.Load(bc.addEvilSingerEvents); err != nil {
-			log.Warn("Failed to load evil singer events journal", "err", err)
-		}
-		if err := bc.removeOldEvilSigners(bc.CurrentBlock().Number(), 0); err != nil {
-			log.Warn("Failed to remove old evil signers", "err", err)
-		}
-		if err := bc.journal.Rotate(bc.getEvilSignerEvents()); err != nil {
-			log.Warn("Failed to rotate evil singer events ournal", "err", err)
-		}
+	if err := MigrateEvilJournal(chainConfig.EvilSignersJournalDir, bc.db); err != nil {
+		log.Warn("Failed to migrate evil singer events journal", "err", err)
 	}
+	bc.loadEvilSigners()
 
 	// Take ownership of this particular state
 	go bc.update()
@@ -358,6 +357,11 @@ func (bc *BlockChain) GetVMConfig() *vm.Config {
 	return &bc.vmConfig
 }
 
+// GetDatabase returns the database backing the block chain.
+func (bc *BlockChain) GetDatabase() ethdb.Database {
+	return bc.db
+}
+
 // empty returns an indicator whether the blockchain is empty.
 // Note, it's a special case that we connect a non-empty ancient
 // database with an empty node, so that we can plugin the ancient
@@ -421,6 +425,17 @@ func (bc *BlockChain) loadLastState() error {
 			headFastBlockGauge.Update(int64(block.NumberU64()))
 		}
 	}
+	// Restore the last known finalized block, dropping it if it was rewound
+	var finalized *types.Block
+	if head := rawdb.ReadHeadFinalizedBlockHash(bc.db); head != (common.Hash{}) {
+		if block := bc.GetBlockByHash(head); block != nil && block.NumberU64() <= currentBlock.NumberU64() &&
+			rawdb.ReadCanonicalHash(bc.db, block.NumberU64()) == head {
+			finalized = block
+			headFinalizedGauge.Update(int64(block.NumberU64()))
+		}
+	}
+	bc.currentFinalized.Store(finalized)
+
 	// Issue a status log for the user
 	currentFastBlock := bc.CurrentFastBlock()
 
@@ -431,6 +446,9 @@ func (bc *BlockChain) loadLastState() error {
 	log.Info("Loaded most recent local header", "number", currentHeader.Number, "hash", currentHeader.Hash(), "td", headerTd, "age", common.PrettyAge(time.Unix(int64(currentHeader.Time), 0)))
 	log.Info("Loaded most recent local full block", "number", currentBlock.Number(), "hash", currentBlock.Hash(), "td", blockTd, "age", common.PrettyAge(time.Unix(int64(currentBlock.Time()), 0)))
 	log.Info("Loaded most recent local fast block", "number", currentFastBlock.Number(), "hash", currentFastBlock.Hash(), "td", fastTd, "age", common.PrettyAge(time.Unix(int64(currentFastBlock.Time()), 0)))
+	if finalized != nil {
+		log.Info("Loaded most recent finalized block", "number", finalized.Number(), "hash", finalized.Hash())
+	}
 
 	return nil
 }
@@ -495,6 +513,7 @@ func (bc *BlockChain) SetHead(head uint64) error {
 			rawdb.DeleteBody(db, hash, num)
 			rawdb.DeleteReceipts(db, hash, num)
 		}
+		rawdb.DeletePbftConfirm(db, hash, num)
 		// Todo(rjl493456442) txlookup, bloombits, etc
 	}
 	bc.hc.SetHead(head, updateFn, delFn)
@@ -548,6 +567,40 @@ func (bc *BlockChain) CurrentFastBlock() *types.Block {
 	return bc.currentFastBlock.Load().(*types.Block)
 }
 
+// CurrentFinalizedBlock retrieves the highest canonical block finalized by a
+// pbft confirm, it returns nil if no block has been finalized yet.
+func (bc *BlockChain) CurrentFinalizedBlock() *types.Block {
+	return bc.currentFinalized.Load().(*types.Block)
+}
+
+// confirmSealer is implemented by the pbft engine, the blocks it seals carry
+// the pbft confirm of their proposal in the header extra.
+type confirmSealer interface {
+	IsConfirmedHeader(header *types.Header) bool
+}
+
+// isConfirmedBlock returns whether the block carries a pbft confirm of itself.
+// The confirm has been verified by the engine before the block is written.
+// Only the blocks sealed by the pbft engine are decoded, the extra of another
+// engine is not a confirm.
+func (bc *BlockChain) isConfirmedBlock(block *types.Block) bool {
+	if !bc.chainConfig.IsPBFTFork(block.Number()) {
+		return false
+	}
+	sealer, ok := bc.pbftEngine.(confirmSealer)
+	return ok && sealer.IsConfirmedHeader(block.Header())
+}
+
+// writeFinalizedBlock marks the canonical block as the finalized head.
+func (bc *BlockChain) writeFinalizedBlock(block *types.Block) {
+	if finalized := bc.CurrentFinalizedBlock(); finalized != nil && finalized.NumberU64() >= block.NumberU64() {
+		return
+	}
+	rawdb.WriteHeadFinalizedBlockHash(bc.db, block.Hash())
+	bc.currentFinalized.Store(block)
+	headFinalizedGauge.Update(int64(block.NumberU64()))
+}
+
 // Validator returns the current validator.
 func (bc *BlockChain) Validator() Validator {
 	return bc.validator
@@ -896,9 +949,6 @@ func (bc *BlockChain) Stop() {
 			log.Error("Dangling trie nodes after full cleanup")
 		}
 	}
-	if bc.journal != nil {
-		bc.journal.Close()
-	}
 	log.Info("Blockchain manager stopped")
 }
 
@@ -1405,6 +1455,11 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	batch := bc.db.NewBatch()
 	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
 
+	confirmed := bc.isConfirmedBlock(block)
+	if confirmed {
+		rawdb.WritePbftConfirm(batch, block.Hash(), block.NumberU64(), block.Extra())
+	}
+
 	isToMany := bc.isToManyEvilSigners(block.Header())
 	if isToMany {
 		err = errors.New("too many evil signers on the chain")
@@ -1459,6 +1514,9 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	// Set new head.
 	if status == CanonStatTy {
 		bc.insert(block)
+		if confirmed {
+			bc.writeFinalizedBlock(block)
+		}
 	}
 	bc.futureBlocks.Remove(block.Hash())
 	go func() {
@@ -2068,6 +2126,12 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			return fmt.Errorf("invalid new chain")
 		}
 	}
+	// Blocks finalized by a pbft confirm must never be dropped
+	if finalized := bc.CurrentFinalizedBlock(); finalized != nil && commonBlock.NumberU64() < finalized.NumberU64() {
+		log.Warn("Refused reorg below finalized block", "number", commonBlock.Number(), "hash", commonBlock.Hash(),
+			"finalized", finalized.Number(), "drop", len(oldChain), "add", len(newChain))
+		return ErrReorgFinalized
+	}
 	// Ensure the user sees large reorgs
 	if len(oldChain) > 0 && len(newChain) > 0 {
 		logFn := log.Info
@@ -2084,9 +2148,10 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "newnum", newBlock.Number(), "newhash", newBlock.Hash())
 	}
 	//elastos is clique
-	if blocksigner.GetBlockSignersCount() > 6 && len(oldChain) > blocksigner.GetBlockSignersCount()/2 {
+	elaHeight := ElaHeight(bc.chainConfig, commonBlock.Header())
+	if signersCount := blocksigner.GetBlockSignersCount(elaHeight); signersCount > 6 && len(oldChain) > signersCount/2 {
 		msg := "danger chain detected, more than n/2 :"
-		log.Error(msg, "singerCount", blocksigner.GetBlockSignersCount()/2, "number", commonBlock.Number(), "hash", commonBlock.Hash(),
+		log.Error(msg, "singerCount", signersCount/2, "number", commonBlock.Number(), "hash", commonBlock.Hash(),
 			"drop", len(oldChain), "dropfrom", oldChain[0].Hash(), "add", len(newChain), "addfrom", newChain[0].Hash())
 		defer func() {
 			bc.dangerousFeed.Send(DangerousChainSideEvent{})
@@ -2161,6 +2226,9 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 
 		case ChainHeadEvent:
 			bc.chainHeadFeed.Send(ev)
+			if finalized := bc.CurrentFinalizedBlock(); finalized != nil && finalized.Hash() == ev.Block.Hash() {
+				bc.finalizedFeed.Send(FinalizedHeadEvent{Block: finalized})
+			}
 
 		case ChainSideEvent:
 			bc.chainSideFeed.Send(ev)
@@ -2355,6 +2423,21 @@ func (bc *BlockChain) Config() *params.ChainConfig { return bc.chainConfig }
 // Engine retrieves the blockchain's consensus engine.
 func (bc *BlockChain) Engine() consensus.Engine { return bc.engine }
 
+// Bridge retrieves the blockchain's bridge system contracts.
+func (bc *BlockChain) Bridge() *bridge.Registry { return bc.bridge }
+
+// SetDeposits sets the deposits of the main chain credited by the recharges,
+// it must be called before any block is processed by the chain.
+func (bc *BlockChain) SetDeposits(deposits bridge.Deposits) {
+	bc.bridge = bridge.NewRegistry(bc.chainConfig, deposits)
+}
+
+// SetEvilProofSender sets the reporter of the evil signers to the ela chain,
+// it must be called before any block is processed by the chain.
+func (bc *BlockChain) SetEvilProofSender(sender EvilProofSender) {
+	bc.evilProofs = sender
+}
+
 func (bc *BlockChain) SetEngine(engine consensus.Engine) {
 	if engine == nil {
 		log.Warn("---------[BlockChain SetEngine] is nil")
@@ -2382,6 +2465,11 @@ func (bc *BlockChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Su
 	return bc.scope.Track(bc.chainHeadFeed.Subscribe(ch))
 }
 
+// SubscribeFinalizedHeadEvent registers a subscription of FinalizedHeadEvent.
+func (bc *BlockChain) SubscribeFinalizedHeadEvent(ch chan<- FinalizedHeadEvent) event.Subscription {
+	return bc.scope.Track(bc.finalizedFeed.Subscribe(ch))
+}
+
 // SubscribeChainSideEvent registers a subscription of ChainSideEvent.
 func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Subscription {
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
@@ -2411,7 +2499,9 @@ func (bc *BlockChain) SubscribeBlockProcessingEvent(ch chan<- bool) event.Subscr
 func (bc *BlockChain) IsDangerChain() bool {
 	bc.evilmu.Lock()
 	defer bc.evilmu.Unlock()
-	return bc.evilSigners.IsDanger(bc.CurrentBlock().Number(), blocksigner.GetBlockSignersCount()*2/3)
+	current := bc.CurrentBlock()
+	elaHeight := ElaHeight(bc.chainConfig, current.Header())
+	return bc.evilSigners.IsDanger(current.Number(), elaHeight, blocksigner.GetBlockSignersCount(elaHeight)*2/3)
 }
 
 // whether the block was created by evil signer.
@@ -2429,29 +2519,31 @@ func (bc *BlockChain) isToManyEvilSigners(header *types.Header) bool {
 		//TODO dpos double sign verify
 		return false
 	}
-	return IsNeedStopChain(header, headerOld, bc.engine, bc.evilSigners, bc.journal)
+	return IsNeedStopChain(bc.chainConfig, header, headerOld, bc.engine, bc.evilSigners, bc.db, bc.evilProofs)
 }
 
-// remove old evilSigners who have created  different blocks, and difference between  the blocks height
-// and currentHeight should biger then rangeValue.
-func (bc *BlockChain) removeOldEvilSigners(currentHeight *big.Int, rangeValue int64) error {
+// loadEvilSigners restores the evil signers from the evidences stored in the
+// database, and removes the stored evidences which are out of date.
+func (bc *BlockChain) loadEvilSigners() {
+	bc.addEvilSingerEvents(ReadEvilSignerEvents(bc.db))
+
 	bc.evilmu.Lock()
 	defer bc.evilmu.Unlock()
-	if bc.evilSigners == nil {
-		return nil
-	}
-	//bc.evilSigners.RemoveOldEvilSigners(currentHeight, rangeValue)
-	return nil
+	current := bc.CurrentBlock()
+	elaHeight := ElaHeight(bc.chainConfig, current.Header())
+	bc.evilSigners.RemoveOldEvilSigners(current.Number(), int64(blocksigner.GetBlockSignersCount(elaHeight)))
+	bc.evilSigners.PruneEvidences(bc.db)
 }
 
-// getEvilSignerEvents return evilSignerEvents by now
-func (bc *BlockChain) getEvilSignerEvents() (res []*EvilSingerEvent) {
-	bc.evilmu.Lock()
-	defer bc.evilmu.Unlock()
-	if bc.evilSigners == nil {
-		bc.evilSigners = &EvilSignersMap{}
-	}
-	return bc.evilSigners.GetEvilSignerEvents()
+// EvilSigners returns the signers with stored evidences of conflicting blocks.
+func (bc *BlockChain) EvilSigners() map[common.Address]map[uint64][]rawdb.EvilSignerBlock {
+	return rawdb.ReadAllEvilSignerEvidences(bc.db)
+}
+
+// EvilSignerEvidence returns the conflicting blocks stored for the signer, keyed
+// by the height they were signed at.
+func (bc *BlockChain) EvilSignerEvidence(signer common.Address) map[uint64][]rawdb.EvilSignerBlock {
+	return rawdb.ReadEvilSignerEvidence(bc.db, signer)
 }
 
 // addEvilSignerEvents add evilSignerEvents of []*EvilSingerEvent.
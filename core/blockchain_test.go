@@ -28,6 +28,7 @@ import (
 
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus/clique"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus/ethash"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/state"
@@ -37,6 +38,7 @@ import (
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/blocksigner"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
 )
 
 // So we can deterministically seed different blockchains
@@ -440,6 +442,94 @@ func testReorg(t *testing.T, first, second []int64, td int64, full bool) {
 	}
 }
 
+// Tests that reorgs dropping a block finalized by a pbft confirm are refused,
+// while reorgs above it are still accepted.
+func TestReorgBelowFinalized(t *testing.T) {
+	db, blockchain, err := newCanonical(ethash.NewFaker(), 10, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	finalized := blockchain.GetBlockByNumber(5)
+	blockchain.writeFinalizedBlock(finalized)
+	if rawdb.ReadHeadFinalizedBlockHash(db) != finalized.Hash() {
+		t.Fatalf("finalized block hash mismatch")
+	}
+	head := blockchain.CurrentBlock()
+
+	fork := makeBlockChain(blockchain.GetBlockByNumber(3), 10, ethash.NewFaker(), db, forkSeed)
+	if _, err := blockchain.InsertChain(fork); err != ErrReorgFinalized {
+		t.Fatalf("reorg below finalized block error mismatch: have %v, want %v", err, ErrReorgFinalized)
+	}
+	if blockchain.CurrentBlock().Hash() != head.Hash() {
+		t.Fatalf("head block changed by refused reorg")
+	}
+
+	fork = makeBlockChain(finalized, 10, ethash.NewFaker(), db, forkSeed)
+	if _, err := blockchain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to reorg above finalized block: %v", err)
+	}
+	if blockchain.CurrentBlock().Hash() != fork[len(fork)-1].Hash() {
+		t.Fatalf("reorg above finalized block not applied")
+	}
+	if blockchain.CurrentFinalizedBlock().Hash() != finalized.Hash() {
+		t.Fatalf("finalized block changed by reorg")
+	}
+
+	// Rewinding the chain below the finalized block drops it
+	blockchain.SetHead(4)
+	if blockchain.CurrentFinalizedBlock() != nil {
+		t.Fatalf("finalized block kept after rewind")
+	}
+}
+
+// Tests that writing a clique block after the pbft fork on a chain without the
+// pbft engine doesn't decode its extra as a pbft confirm.
+func TestWriteCliqueBlockAfterPbftFork(t *testing.T) {
+	config := *params.AllCliqueProtocolChanges
+	config.PBFTBlock = big.NewInt(0)
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		genesis = (&Genesis{Config: &config, ExtraData: make([]byte, spv.ExtraVanity+common.AddressLength+spv.ExtraSeal+spv.ExtraElaHeight)}).MustCommit(db)
+		engine  = clique.New(config.Clique, db)
+	)
+	blockchain, err := NewBlockChain(db, nil, &config, engine, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	// The bytes of the seal read as the vote count of a legacy confirm are
+	// far more than the extra can hold.
+	extra := make([]byte, spv.ExtraVanity+spv.ExtraElaHeight+spv.ExtraSeal)
+	for i := len(extra) - spv.ExtraSeal; i < len(extra); i++ {
+		extra[i] = 0xff
+	}
+	block := types.NewBlockWithHeader(&types.Header{
+		ParentHash: genesis.Hash(),
+		Number:     big.NewInt(1),
+		Root:       genesis.Root(),
+		Difficulty: big.NewInt(2),
+		GasLimit:   genesis.GasLimit(),
+		Time:       genesis.Time() + 1,
+		Extra:      extra,
+	})
+	statedb, err := state.New(genesis.Root(), blockchain.stateCache)
+	if err != nil {
+		t.Fatalf("failed to open state: %v", err)
+	}
+	if _, err := blockchain.WriteBlockWithState(block, nil, statedb); err != nil {
+		t.Fatalf("failed to write clique block: %v", err)
+	}
+	if confirm := rawdb.ReadPbftConfirm(db, block.Hash(), 1); len(confirm) != 0 {
+		t.Errorf("confirm stored for a clique block: %x", confirm)
+	}
+	if finalized := blockchain.CurrentFinalizedBlock(); finalized != nil {
+		t.Errorf("clique block finalized: %x", finalized.Hash())
+	}
+}
+
 // Tests that the insertion functions detect banned hashes.
 func TestBadHeaderHashes(t *testing.T) { testBadHashes(t, false) }
 func TestBadBlockHashes(t *testing.T)  { testBadHashes(t, true) }
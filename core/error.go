@@ -16,7 +16,11 @@
 
 package core
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
+)
 
 var (
 	// ErrKnownBlock is returned when a block to import is already known locally.
@@ -37,8 +41,16 @@ var (
 	ErrNoGenesis = errors.New("genesis not found in chain")
 
 	// ErrTxHashTooHigh  is returned if Main chain transaction has been processed
-	ErrMainTxHashPresence = errors.New("main txhash presence")
+	ErrMainTxHashPresence = bridge.ErrMainTxHashPresence
 
 	// ErrElaToEthAddress   is returned if Ethereum address is incorrect
-	ErrElaToEthAddress = errors.New("Ethereum address is incorrect ")
+	ErrElaToEthAddress = bridge.ErrElaToEthAddress
+
+	// ErrRechargeFee is returned if the fee of the deposits can't pay for the
+	// gas of a recharge transaction.
+	ErrRechargeFee = bridge.ErrRechargeFee
+
+	// ErrReorgFinalized is returned if a reorg would remove a block which has
+	// been finalized by a pbft confirm.
+	ErrReorgFinalized = errors.New("reorg below finalized block")
 )
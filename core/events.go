@@ -47,6 +47,9 @@ type ChainSideEvent struct {
 
 type ChainHeadEvent struct{ Block *types.Block }
 
+// FinalizedHeadEvent is posted when the head block finalized by a pbft confirm changes.
+type FinalizedHeadEvent struct{ Block *types.Block }
+
 type DangerousChainSideEvent struct{}
 
 type EngineChangeEvent struct{}
\ No newline at end of file
@@ -6,11 +6,15 @@ import (
 	"errors"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus"
 	"math/big"
+	"sort"
 
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/blocksigner"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
 )
 
@@ -30,6 +34,18 @@ func NewEvilEvidence(height *big.Int) *EvilEvidence {
 	return r
 }
 
+// Blocks returns the conflicting blocks of the evidence sorted by hash.
+func (e *EvilEvidence) Blocks() []rawdb.EvilSignerBlock {
+	blocks := make([]rawdb.EvilSignerBlock, 0, len(e.BlockOnHeight))
+	for hash, elaHeight := range e.BlockOnHeight {
+		blocks = append(blocks, rawdb.EvilSignerBlock{Hash: hash, ElaHeight: elaHeight})
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return bytes.Compare(blocks[i].Hash[:], blocks[j].Hash[:]) < 0
+	})
+	return blocks
+}
+
 type EvilSingerEvent struct {
 	Singer    *common.Address
 	Height    *big.Int
@@ -88,10 +104,21 @@ func (signers *EvilSignersMap) RemoveOldEvilSigners(currentHeight *big.Int, rang
 
 }
 
-// update evil signers, send evil message to ela chain return de-duplication hashes
+// EvilProofSender reports the signers who signed different blocks at the same height to the ela chain.
+type EvilProofSender interface {
+	SendEvilProof(signer common.Address, height *big.Int, blocks map[common.Hash]uint64)
+}
+
+// update evil signers, return de-duplication hashes
 func (signers *EvilSignersMap) UpdateEvilSigners(signer common.Address, height *big.Int, hashes []*common.Hash,
 	elaHeights []uint64) (map[common.Hash]uint64, error) {
-	rangeValue := blocksigner.GetBlockSignersCount()
+	var elaHeight uint64
+	for _, h := range elaHeights {
+		if h > elaHeight {
+			elaHeight = h
+		}
+	}
+	rangeValue := blocksigner.GetBlockSignersCount(elaHeight)
 	signers.RemoveOldEvilSigners(height, int64(rangeValue))
 
 	evidences := &Evidences{}
@@ -99,7 +126,6 @@ func (signers *EvilSignersMap) UpdateEvilSigners(signer common.Address, height *
 		evidences = v
 	} else {
 		(*signers)[signer] = evidences
-		spv.SendEvilProof(signer, nil)
 	}
 	evidence := evidences.getEvidence(height)
 	for index, hash := range hashes {
@@ -112,13 +138,13 @@ func (signers *EvilSignersMap) UpdateEvilSigners(signer common.Address, height *
 	return evidence.BlockOnHeight, nil
 }
 
-// Return whether is to much evil signers.
-func (signers *EvilSignersMap) IsDanger(currentHeight *big.Int, threshold int) bool {
+// Return whether is to much evil signers among the signers working at the ela height.
+func (signers *EvilSignersMap) IsDanger(currentHeight *big.Int, elaHeight uint64, threshold int) bool {
 	if signers == nil || threshold <= 0 {
 		return false
 	}
 	count := 0
-	signersLen := blocksigner.GetBlockSignersCount()
+	signersLen := blocksigner.GetBlockSignersCount(elaHeight)
 	earliestHeight := new(big.Int).Sub(currentHeight, big.NewInt(int64(signersLen)))
 	for _, v := range *signers {
 		index := len(*v) - 1
@@ -161,6 +187,52 @@ func (signers *EvilSignersMap) GetEvilSignerEvents() (res []*EvilSingerEvent) {
 	return res
 }
 
+// StoreEvidence stores the conflicting blocks the signer signed at the height, and
+// removes the stored evidences of the signers removed from the map.
+func (signers *EvilSignersMap) StoreEvidence(db ethdb.KeyValueStore, signer common.Address, height *big.Int) {
+	if signers == nil || db == nil {
+		return
+	}
+	if evidences, ok := (*signers)[signer]; ok {
+		for _, evidence := range *evidences {
+			if evidence.Height.Cmp(height) == 0 {
+				rawdb.WriteEvilSignerBlocks(db, signer, height.Uint64(), evidence.Blocks())
+			}
+		}
+	}
+	signers.PruneEvidences(db)
+}
+
+// PruneEvidences removes the stored evidences of the signers which are not in the map.
+func (signers *EvilSignersMap) PruneEvidences(db ethdb.KeyValueStore) {
+	if signers == nil || db == nil {
+		return
+	}
+	for signer, evidence := range rawdb.ReadAllEvilSignerEvidences(db) {
+		if _, ok := (*signers)[signer]; ok {
+			continue
+		}
+		log.Info("Remove evil signer evidence", "signer", signer.String(), "count", len(evidence))
+		for number := range evidence {
+			rawdb.DeleteEvilSignerBlocks(db, signer, number)
+		}
+	}
+}
+
+// ReadEvilSignerEvents returns the events of the evil signer evidences stored in the database.
+func ReadEvilSignerEvents(db ethdb.Iteratee) []*EvilSingerEvent {
+	var events []*EvilSingerEvent
+	for signer, evidence := range rawdb.ReadAllEvilSignerEvidences(db) {
+		for number, blocks := range evidence {
+			for _, block := range blocks {
+				singer, hash := signer, block.Hash
+				events = append(events, &EvilSingerEvent{&singer, new(big.Int).SetUint64(number), block.ElaHeight, &hash})
+			}
+		}
+	}
+	return events
+}
+
 // Parse Ela chain height from header extra
 func ParseElaHeightFromHead(head *types.Header) (uint64, error) {
 	length := len(head.Extra)
@@ -168,18 +240,18 @@ func ParseElaHeightFromHead(head *types.Header) (uint64, error) {
 		return 0, errors.New("header's extra length is to short")
 	}
 	heightBytes := head.Extra[length -  spv.ExtraSeal - spv.ExtraElaHeight : length - spv.ExtraSeal]
-	return binary.LittleEndian.Uint64(heightBytes), nil
+	return binary.BigEndian.Uint64(heightBytes), nil
 }
 
-// whether the block was created by evil signer.
-func IsNeedStopChain(headerNew, headerOld *types.Header, engine consensus.Engine, signers *EvilSignersMap,
-	journal *EvilJournal) bool {
+// whether the block was created by evil signer, the evil signer is reported to the ela chain by sender if not nil.
+func IsNeedStopChain(config *params.ChainConfig, headerNew, headerOld *types.Header, engine consensus.Engine,
+	signers *EvilSignersMap, db ethdb.KeyValueStore, sender EvilProofSender) bool {
 
 	hashOld := headerOld.Hash()
 	hashNew := headerNew.Hash()
 
-	elaHeightOld, _ := ParseElaHeightFromHead(headerOld)
-	elaHeightNew, _ := ParseElaHeightFromHead(headerNew)
+	elaHeightOld := ElaHeight(config, headerOld)
+	elaHeightNew := ElaHeight(config, headerNew)
 
 	if bytes.Equal(hashNew[:], hashOld[:]) {
 		return false
@@ -202,13 +274,14 @@ func IsNeedStopChain(headerNew, headerOld *types.Header, engine consensus.Engine
 		return false
 	}
 
-	if journal != nil {
-		for hash, height := range addHashes {
-			log.Info("EvilSignerEvent Insert", "Singer", singerNew.String(), "Number:",
-				headerNew.Number.Uint64(), "Hash:", hash.String())
-			journal.Insert(&EvilSingerEvent{&singerNew, headerNew.Number, height, &hash})
-		}
+	for hash := range addHashes {
+		log.Info("EvilSignerEvent Insert", "Singer", singerNew.String(), "Number:",
+			headerNew.Number.Uint64(), "Hash:", hash.String())
+	}
+	signers.StoreEvidence(db, singerNew, headerNew.Number)
+	if sender != nil {
+		sender.SendEvilProof(singerNew, headerNew.Number, addHashes)
 	}
 
-	return signers.IsDanger(headerNew.Number, blocksigner.GetBlockSignersCount()*2/3)
+	return signers.IsDanger(headerNew.Number, elaHeightNew, blocksigner.GetBlockSignersCount(elaHeightNew)*2/3)
 }
\ No newline at end of file
@@ -114,6 +114,30 @@ func TestRemoveOldEvilSigners(t *testing.T) {
 	}
 }
 
+func TestStoreEvilEvidence(t *testing.T) {
+	signers := GetSigners(4)
+	defer func() { blocksigner.Signers = nil }()
+	db := rawdb.NewMemoryDatabase()
+	evilMaps := &EvilSignersMap{}
+
+	first, second := common.HexToHash("0x01"), common.HexToHash("0x02")
+	evilMaps.UpdateEvilSigners(signers[0], big.NewInt(1), []*common.Hash{&first, &second}, []uint64{10, 11})
+	evilMaps.StoreEvidence(db, signers[0], big.NewInt(1))
+	blocks := rawdb.ReadEvilSignerBlocks(db, signers[0], 1)
+	if len(blocks) != 2 || blocks[0].Hash != first || blocks[0].ElaHeight != 10 || blocks[1].Hash != second {
+		t.Fatalf("Stored evidence mismatch: have %v", blocks)
+	}
+	// the evidence out of the signers window is pruned with the signer
+	evilMaps.UpdateEvilSigners(signers[1], big.NewInt(10), []*common.Hash{&first}, []uint64{20})
+	evilMaps.StoreEvidence(db, signers[1], big.NewInt(10))
+	if evidence := rawdb.ReadEvilSignerEvidence(db, signers[0]); len(evidence) != 0 {
+		t.Errorf("Evidence of removed signer not pruned: have %v", evidence)
+	}
+	if evidence := rawdb.ReadEvilSignerEvidence(db, signers[1]); len(evidence[10]) != 1 {
+		t.Errorf("Evidence mismatch: have %v", evidence)
+	}
+}
+
 func TestEvilSigners(t *testing.T)  {
 	signerKeys := []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "M", "N"}
 	accounts := newTesterAccountPool()
@@ -212,7 +236,7 @@ func TestEvilSigners(t *testing.T)  {
 		chain.InsertChain(types.Blocks{block})
 	}
 
-	if !chain.evilSigners.IsDanger(big.NewInt(int64(len(signerKeys)*3)), len(signerKeys)*2/3) {
+	if !chain.evilSigners.IsDanger(big.NewInt(int64(len(signerKeys)*3)), 0, len(signerKeys)*2/3) {
 		t.Error("Count evil signers wrong")
 	}
 
@@ -1,32 +1,32 @@
 package core
 
 import (
-	"bytes"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rlp"
 )
 
-func TestEvilJoural(t *testing.T) {
+func TestMigrateEvilJoural(t *testing.T) {
 	dataDir, err := ioutil.TempDir("", "elaeth_test")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer os.RemoveAll(dataDir)
-	joural := NewEvilJournal(dataDir)
-	joural.Rotate(nil)
+	path := filepath.Join(dataDir, jouralFileName)
+	joural, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
 	eventsNumber := rand.Intn(10) + 1
 	events := make([]*EvilSingerEvent, eventsNumber)
-	index := 0
-	for {
-		if eventsNumber == index {
-			break
-		}
-
+	for index := range events {
 		addr := common.Address{}
 		height := big.NewInt(rand.Int63())
 		hash := common.Hash{}
@@ -37,46 +37,30 @@ func TestEvilJoural(t *testing.T) {
 			t.Fatal(err)
 		}
 		events[index] = &EvilSingerEvent{&addr, height, height.Uint64(), &hash}
-		joural.Insert(events[index])
-		index++
+		if err := rlp.Encode(joural, events[index]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the same event journaled twice is stored once
+	if err := rlp.Encode(joural, events[0]); err != nil {
+		t.Fatal(err)
 	}
-
 	joural.Close()
 
-	eventsNew := make([]*EvilSingerEvent, 0)
-
-	add := func(events []*EvilSingerEvent) []error {
-		errors := make([]error, len(events))
-		for _, v := range events {
-			eventsNew = append(eventsNew, v)
-		}
-
-		return errors
+	db := rawdb.NewMemoryDatabase()
+	if err := MigrateEvilJournal(dataDir, db); err != nil {
+		t.Fatal(err)
 	}
-	joural.Load(add)
-
-	if len(eventsNew) != len(events) {
-		t.Errorf("Write and Read events numbers are not equal!")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Migrated journal is not removed")
 	}
-
-	for i, v := range events {
-
-		if !bytes.Equal(v.Hash[:], eventsNew[i].Hash[:]) {
-			t.Errorf("Write and Read event are not equal!")
+	for _, v := range events {
+		blocks := rawdb.ReadEvilSignerBlocks(db, *v.Singer, v.Height.Uint64())
+		if len(blocks) != 1 || blocks[0].Hash != *v.Hash || blocks[0].ElaHeight != v.ElaHeight {
+			t.Errorf("Migrated event mismatch: have %v, want %x at %d", blocks, *v.Hash, v.ElaHeight)
 		}
-
-		if !bytes.Equal(v.Singer[:], eventsNew[i].Singer[:]) {
-			t.Errorf("Write and Read event are not equal!")
-		}
-
-		if v.Height.Cmp(eventsNew[i].Height) != 0 {
-			t.Errorf("Write and Read event are not equal!")
-		}
-
-		if v.ElaHeight != eventsNew[i].ElaHeight {
-			t.Errorf("Write and Read event are not equal!")
-		}
-
 	}
-
+	if stored := ReadEvilSignerEvents(db); len(stored) != len(events) {
+		t.Errorf("Read events numbers mismatch: have %d, want %d", len(stored), len(events))
+	}
 }
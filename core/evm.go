@@ -19,10 +19,13 @@ package core
 import (
 	"math/big"
 
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/vm"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
 )
 
 // ChainContext supports retrieving headers and consensus parameters from the
@@ -33,6 +36,12 @@ type ChainContext interface {
 
 	// GetHeader returns the hash corresponding to their hash.
 	GetHeader(common.Hash, uint64) *types.Header
+
+	// Config retrieves the chain's fork configuration.
+	Config() *params.ChainConfig
+
+	// Bridge retrieves the chain's bridge system contracts.
+	Bridge() *bridge.Registry
 }
 
 // NewEVMContext creates a new context for use in the EVM.
@@ -44,6 +53,14 @@ func NewEVMContext(msg Message, header *types.Header, chain ChainContext, author
 	} else {
 		beneficiary = *author
 	}
+	var (
+		elaHeight uint64
+		registry  *bridge.Registry
+	)
+	if chain != nil {
+		elaHeight = ElaHeight(chain.Config(), header)
+		registry = chain.Bridge()
+	}
 	return vm.Context{
 		CanTransfer: CanTransfer,
 		Transfer:    Transfer,
@@ -55,7 +72,31 @@ func NewEVMContext(msg Message, header *types.Header, chain ChainContext, author
 		Difficulty:  new(big.Int).Set(header.Difficulty),
 		GasLimit:    header.GasLimit,
 		GasPrice:    new(big.Int).Set(msg.GasPrice()),
+		ElaHeight:   elaHeight,
+		Bridge:      registry,
+	}
+}
+
+// ElaHeight returns the main chain height embedded in the header by the engine
+// sealing it, the pbft blocks carry it in the nonce from the pbft ela height
+// fork and the clique blocks in the extra-data before the seal from the
+// validated clique ela height fork. It returns 0 for a header without one.
+func ElaHeight(config *params.ChainConfig, header *types.Header) uint64 {
+	if config.IsPBFTFork(header.Number) {
+		if !config.IsPBFTElaHeight(header.Number) {
+			return 0
+		}
+		return header.Nonce.Uint64()
+	}
+	if config.Clique == nil || !config.IsCliqueElaHeight(header.Number) {
+		return 0
+	}
+	size := len(header.Extra) - spv.ExtraVanity - spv.ExtraSeal
+	if size < spv.ExtraElaHeight || size%common.AddressLength != spv.ExtraElaHeight {
+		return 0
 	}
+	height, _ := ParseElaHeightFromHead(header)
+	return height
 }
 
 // GetHashFn returns a GetHashFunc which retrieves header hashes by number
@@ -0,0 +1,104 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rlp"
+)
+
+// EvilSignerBlock is a block signed by an evil signer at a height it signed
+// another block at, together with the ela height carried by the block.
+type EvilSignerBlock struct {
+	Hash      common.Hash
+	ElaHeight uint64
+}
+
+// ReadEvilSignerBlocks retrieves the conflicting blocks the signer signed at
+// the given side chain height.
+func ReadEvilSignerBlocks(db ethdb.KeyValueReader, signer common.Address, number uint64) []EvilSignerBlock {
+	data, _ := db.Get(evilSignerKey(signer, number))
+	if len(data) == 0 {
+		return nil
+	}
+	var blocks []EvilSignerBlock
+	if err := rlp.DecodeBytes(data, &blocks); err != nil {
+		log.Error("Invalid evil signer blocks RLP", "signer", signer, "number", number, "err", err)
+		return nil
+	}
+	return blocks
+}
+
+// WriteEvilSignerBlocks stores the conflicting blocks the signer signed at the
+// given side chain height.
+func WriteEvilSignerBlocks(db ethdb.KeyValueWriter, signer common.Address, number uint64, blocks []EvilSignerBlock) {
+	data, err := rlp.EncodeToBytes(blocks)
+	if err != nil {
+		log.Crit("Failed to RLP encode evil signer blocks", "err", err)
+	}
+	if err := db.Put(evilSignerKey(signer, number), data); err != nil {
+		log.Crit("Failed to store evil signer blocks", "err", err)
+	}
+}
+
+// DeleteEvilSignerBlocks removes the conflicting blocks the signer signed at
+// the given side chain height.
+func DeleteEvilSignerBlocks(db ethdb.KeyValueWriter, signer common.Address, number uint64) {
+	if err := db.Delete(evilSignerKey(signer, number)); err != nil {
+		log.Crit("Failed to delete evil signer blocks", "err", err)
+	}
+}
+
+// ReadEvilSignerEvidence retrieves the conflicting blocks of the signer keyed
+// by the side chain height they were signed at.
+func ReadEvilSignerEvidence(db ethdb.Iteratee, signer common.Address) map[uint64][]EvilSignerBlock {
+	return readEvilSignersWithPrefix(db, append(append([]byte{}, evilSignerPrefix...), signer.Bytes()...))[signer]
+}
+
+// ReadAllEvilSignerEvidences retrieves the conflicting blocks of every evil
+// signer keyed by the side chain height they were signed at.
+func ReadAllEvilSignerEvidences(db ethdb.Iteratee) map[common.Address]map[uint64][]EvilSignerBlock {
+	return readEvilSignersWithPrefix(db, evilSignerPrefix)
+}
+
+func readEvilSignersWithPrefix(db ethdb.Iteratee, prefix []byte) map[common.Address]map[uint64][]EvilSignerBlock {
+	result := make(map[common.Address]map[uint64][]EvilSignerBlock)
+	it := db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(evilSignerPrefix)+common.AddressLength+8 {
+			continue
+		}
+		var blocks []EvilSignerBlock
+		if err := rlp.DecodeBytes(it.Value(), &blocks); err != nil {
+			log.Error("Invalid evil signer blocks RLP", "key", key, "err", err)
+			continue
+		}
+		signer := common.BytesToAddress(key[len(evilSignerPrefix) : len(evilSignerPrefix)+common.AddressLength])
+		if result[signer] == nil {
+			result[signer] = make(map[uint64][]EvilSignerBlock)
+		}
+		result[signer][binary.BigEndian.Uint64(key[len(evilSignerPrefix)+common.AddressLength:])] = blocks
+	}
+	return result
+}
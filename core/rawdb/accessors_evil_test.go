@@ -0,0 +1,50 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+)
+
+// Tests evil signer evidence storage and retrieval operations.
+func TestEvilSignerBlocksStorage(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	signer, other := common.Address{1}, common.Address{2}
+	blocks := []EvilSignerBlock{{Hash: common.HexToHash("0x01"), ElaHeight: 100}, {Hash: common.HexToHash("0x02"), ElaHeight: 101}}
+	if entry := ReadEvilSignerBlocks(db, signer, 42); entry != nil {
+		t.Fatalf("Non existent evidence returned: %v", entry)
+	}
+	WriteEvilSignerBlocks(db, signer, 42, blocks)
+	WriteEvilSignerBlocks(db, signer, 43, blocks[:1])
+	WriteEvilSignerBlocks(db, other, 42, blocks[1:])
+	if entry := ReadEvilSignerBlocks(db, signer, 42); len(entry) != 2 || entry[1] != blocks[1] {
+		t.Fatalf("Retrieved evidence mismatch: have %v, want %v", entry, blocks)
+	}
+	if evidence := ReadEvilSignerEvidence(db, signer); len(evidence) != 2 || len(evidence[43]) != 1 {
+		t.Fatalf("Retrieved signer evidence mismatch: have %v", evidence)
+	}
+	if all := ReadAllEvilSignerEvidences(db); len(all) != 2 || len(all[other][42]) != 1 {
+		t.Fatalf("Retrieved evidences mismatch: have %v", all)
+	}
+	DeleteEvilSignerBlocks(db, signer, 42)
+	if entry := ReadEvilSignerBlocks(db, signer, 42); entry != nil {
+		t.Fatalf("Deleted evidence returned: %v", entry)
+	}
+}
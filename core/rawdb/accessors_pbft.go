@@ -0,0 +1,249 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rlp"
+)
+
+// ReadPbftProducers retrieves the pbft producer set which became active at
+// the given side chain height.
+func ReadPbftProducers(db ethdb.KeyValueReader, number uint64) [][]byte {
+	data, _ := db.Get(pbftProducersKey(number))
+	if len(data) == 0 {
+		return nil
+	}
+	var producers [][]byte
+	if err := rlp.DecodeBytes(data, &producers); err != nil {
+		log.Error("Invalid pbft producers RLP", "number", number, "err", err)
+		return nil
+	}
+	return producers
+}
+
+// WritePbftProducers stores the pbft producer set which becomes active at the
+// given side chain height.
+func WritePbftProducers(db ethdb.KeyValueWriter, number uint64, producers [][]byte) {
+	data, err := rlp.EncodeToBytes(producers)
+	if err != nil {
+		log.Crit("Failed to RLP encode pbft producers", "err", err)
+	}
+	if err := db.Put(pbftProducersKey(number), data); err != nil {
+		log.Crit("Failed to store pbft producers", "err", err)
+	}
+}
+
+// DeletePbftProducers removes the pbft producer set activated at the given
+// side chain height.
+func DeletePbftProducers(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Delete(pbftProducersKey(number)); err != nil {
+		log.Crit("Failed to delete pbft producers", "err", err)
+	}
+}
+
+// ReadAllPbftProducers retrieves every stored pbft producer set keyed by the
+// side chain height it became active at.
+func ReadAllPbftProducers(db ethdb.Iteratee) map[uint64][][]byte {
+	return readProducersWithPrefix(db, pbftProducersPrefix)
+}
+
+// ReadNextPbftProducers retrieves the announced but not yet applied producer
+// set for the given main chain working height.
+func ReadNextPbftProducers(db ethdb.KeyValueReader, workingHeight uint64) [][]byte {
+	data, _ := db.Get(pbftNextProducersKey(workingHeight))
+	if len(data) == 0 {
+		return nil
+	}
+	var producers [][]byte
+	if err := rlp.DecodeBytes(data, &producers); err != nil {
+		log.Error("Invalid next pbft producers RLP", "workingHeight", workingHeight, "err", err)
+		return nil
+	}
+	return producers
+}
+
+// WriteNextPbftProducers stores an announced producer set which will become
+// active once the main chain reaches the given working height.
+func WriteNextPbftProducers(db ethdb.KeyValueWriter, workingHeight uint64, producers [][]byte) {
+	data, err := rlp.EncodeToBytes(producers)
+	if err != nil {
+		log.Crit("Failed to RLP encode next pbft producers", "err", err)
+	}
+	if err := db.Put(pbftNextProducersKey(workingHeight), data); err != nil {
+		log.Crit("Failed to store next pbft producers", "err", err)
+	}
+}
+
+// DeleteNextPbftProducers removes an announced producer set.
+func DeleteNextPbftProducers(db ethdb.KeyValueWriter, workingHeight uint64) {
+	if err := db.Delete(pbftNextProducersKey(workingHeight)); err != nil {
+		log.Crit("Failed to delete next pbft producers", "err", err)
+	}
+}
+
+// ReadAllNextPbftProducers retrieves every announced producer set keyed by
+// its main chain working height.
+func ReadAllNextPbftProducers(db ethdb.Iteratee) map[uint64][][]byte {
+	return readProducersWithPrefix(db, pbftNextProducersPrefix)
+}
+
+func readProducersWithPrefix(db ethdb.Iteratee, prefix []byte) map[uint64][][]byte {
+	result := make(map[uint64][][]byte)
+	it := db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+8 {
+			continue
+		}
+		var producers [][]byte
+		if err := rlp.DecodeBytes(it.Value(), &producers); err != nil {
+			log.Error("Invalid pbft producers RLP", "key", key, "err", err)
+			continue
+		}
+		result[binary.BigEndian.Uint64(key[len(prefix):])] = producers
+	}
+	return result
+}
+
+// PbftIllegalEvidence is the proof that a producer signed conflicting
+// proposals or votes, Data is the serialized evidence payload of Type.
+type PbftIllegalEvidence struct {
+	Type   uint8
+	Height uint64
+	Signer []byte
+	Data   []byte
+}
+
+// HasPbftIllegalEvidence checks if the evidence of the given hash is stored.
+func HasPbftIllegalEvidence(db ethdb.KeyValueReader, hash common.Hash) bool {
+	if has, err := db.Has(pbftIllegalKey(hash)); !has || err != nil {
+		return false
+	}
+	return true
+}
+
+// ReadPbftIllegalEvidence retrieves the illegal evidence of the given hash.
+func ReadPbftIllegalEvidence(db ethdb.KeyValueReader, hash common.Hash) *PbftIllegalEvidence {
+	data, _ := db.Get(pbftIllegalKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	evidence := new(PbftIllegalEvidence)
+	if err := rlp.DecodeBytes(data, evidence); err != nil {
+		log.Error("Invalid pbft illegal evidence RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return evidence
+}
+
+// WritePbftIllegalEvidence stores the illegal evidence of the given hash.
+func WritePbftIllegalEvidence(db ethdb.KeyValueWriter, hash common.Hash, evidence *PbftIllegalEvidence) {
+	data, err := rlp.EncodeToBytes(evidence)
+	if err != nil {
+		log.Crit("Failed to RLP encode pbft illegal evidence", "err", err)
+	}
+	if err := db.Put(pbftIllegalKey(hash), data); err != nil {
+		log.Crit("Failed to store pbft illegal evidence", "err", err)
+	}
+}
+
+// ReadAllPbftIllegalEvidences retrieves every stored illegal evidence keyed by
+// its hash.
+func ReadAllPbftIllegalEvidences(db ethdb.Iteratee) map[common.Hash]*PbftIllegalEvidence {
+	result := make(map[common.Hash]*PbftIllegalEvidence)
+	it := db.NewIteratorWithPrefix(pbftIllegalPrefix)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(pbftIllegalPrefix)+common.HashLength {
+			continue
+		}
+		evidence := new(PbftIllegalEvidence)
+		if err := rlp.DecodeBytes(it.Value(), evidence); err != nil {
+			log.Error("Invalid pbft illegal evidence RLP", "key", key, "err", err)
+			continue
+		}
+		result[common.BytesToHash(key[len(pbftIllegalPrefix):])] = evidence
+	}
+	return result
+}
+
+// HasPbftConfirm checks if the confirm of the block is stored.
+func HasPbftConfirm(db ethdb.KeyValueReader, hash common.Hash, number uint64) bool {
+	if has, err := db.Has(pbftConfirmKey(number, hash)); !has || err != nil {
+		return false
+	}
+	return true
+}
+
+// ReadPbftConfirm retrieves the serialized pbft confirm of the block.
+func ReadPbftConfirm(db ethdb.KeyValueReader, hash common.Hash, number uint64) []byte {
+	data, _ := db.Get(pbftConfirmKey(number, hash))
+	return data
+}
+
+// WritePbftConfirm stores the serialized pbft confirm of the block.
+func WritePbftConfirm(db ethdb.KeyValueWriter, hash common.Hash, number uint64, confirm []byte) {
+	if err := db.Put(pbftConfirmKey(number, hash), confirm); err != nil {
+		log.Crit("Failed to store pbft confirm", "err", err)
+	}
+}
+
+// DeletePbftConfirm removes the pbft confirm of the block.
+func DeletePbftConfirm(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
+	if err := db.Delete(pbftConfirmKey(number, hash)); err != nil {
+		log.Crit("Failed to delete pbft confirm", "err", err)
+	}
+}
+
+// WriteDposAddr stores the serialized DAddr message of the given hash.
+func WriteDposAddr(db ethdb.KeyValueWriter, hash common.Hash, addr []byte) {
+	if err := db.Put(dposAddrKey(hash), addr); err != nil {
+		log.Crit("Failed to store dpos address", "err", err)
+	}
+}
+
+// DeleteDposAddr removes the DAddr message of the given hash.
+func DeleteDposAddr(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Delete(dposAddrKey(hash)); err != nil {
+		log.Crit("Failed to delete dpos address", "err", err)
+	}
+}
+
+// ReadAllDposAddrs retrieves every stored DAddr message keyed by its hash.
+func ReadAllDposAddrs(db ethdb.Iteratee) map[common.Hash][]byte {
+	result := make(map[common.Hash][]byte)
+	it := db.NewIteratorWithPrefix(dposAddrPrefix)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(dposAddrPrefix)+common.HashLength {
+			continue
+		}
+		result[common.BytesToHash(key[len(dposAddrPrefix):])] = common.CopyBytes(it.Value())
+	}
+	return result
+}
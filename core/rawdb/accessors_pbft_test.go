@@ -0,0 +1,69 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+)
+
+// Tests pbft confirm storage and retrieval operations.
+func TestPbftConfirmStorage(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	hash, fork := common.HexToHash("0x01"), common.HexToHash("0x02")
+	confirm := []byte("test confirm")
+	if HasPbftConfirm(db, hash, 42) {
+		t.Fatalf("Non existent confirm returned")
+	}
+	WritePbftConfirm(db, hash, 42, confirm)
+	if entry := ReadPbftConfirm(db, hash, 42); !bytes.Equal(entry, confirm) {
+		t.Fatalf("Retrieved confirm mismatch: have %x, want %x", entry, confirm)
+	}
+	// Confirms of other blocks at the same height are kept apart
+	if HasPbftConfirm(db, fork, 42) || HasPbftConfirm(db, hash, 43) {
+		t.Fatalf("Confirm returned for another block")
+	}
+	DeletePbftConfirm(db, hash, 42)
+	if HasPbftConfirm(db, hash, 42) {
+		t.Fatalf("Deleted confirm returned")
+	}
+}
+
+// Tests dpos address storage and retrieval operations.
+func TestDposAddrStorage(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	first, second := common.HexToHash("0x01"), common.HexToHash("0x02")
+	WriteDposAddr(db, first, []byte("first addr"))
+	WriteDposAddr(db, second, []byte("second addr"))
+	WritePbftConfirm(db, first, 1, []byte("confirm"))
+
+	addrs := ReadAllDposAddrs(db)
+	if len(addrs) != 2 {
+		t.Fatalf("Retrieved address count mismatch: have %d, want 2", len(addrs))
+	}
+	if !bytes.Equal(addrs[first], []byte("first addr")) {
+		t.Fatalf("Retrieved address mismatch: have %x", addrs[first])
+	}
+	DeleteDposAddr(db, first)
+	if addrs := ReadAllDposAddrs(db); len(addrs) != 1 || addrs[second] == nil {
+		t.Fatalf("Deleted address returned: %v", addrs)
+	}
+}
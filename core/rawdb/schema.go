@@ -38,6 +38,9 @@ var (
 	// headFastBlockKey tracks the latest known incomplete block's hash during fast sync.
 	headFastBlockKey = []byte("LastFast")
 
+	// headFinalizedBlockKey tracks the latest block finalized by a pbft confirm.
+	headFinalizedBlockKey = []byte("LastFinalized")
+
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
@@ -56,6 +59,13 @@ var (
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
+	pbftProducersPrefix     = []byte("pbft-producers-")      // pbftProducersPrefix + num (uint64 big endian) -> producers active from num
+	pbftNextProducersPrefix = []byte("pbft-next-producers-") // pbftNextProducersPrefix + ela working height (uint64 big endian) -> pending producers
+	pbftIllegalPrefix       = []byte("pbft-illegal-")        // pbftIllegalPrefix + evidence hash -> illegal proposals or votes evidence
+	pbftConfirmPrefix       = []byte("pbft-confirm-")        // pbftConfirmPrefix + num (uint64 big endian) + hash -> serialized confirm
+	dposAddrPrefix          = []byte("dpos-addr-")           // dposAddrPrefix + DAddr hash -> serialized DAddr
+	evilSignerPrefix        = []byte("evil-signer-")         // evilSignerPrefix + signer + num (uint64 big endian) -> conflicting blocks signed at num
+
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
@@ -164,3 +174,33 @@ func preimageKey(hash common.Hash) []byte {
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
 }
+
+// pbftProducersKey = pbftProducersPrefix + num (uint64 big endian)
+func pbftProducersKey(number uint64) []byte {
+	return append(pbftProducersPrefix, encodeBlockNumber(number)...)
+}
+
+// pbftNextProducersKey = pbftNextProducersPrefix + ela working height (uint64 big endian)
+func pbftNextProducersKey(workingHeight uint64) []byte {
+	return append(pbftNextProducersPrefix, encodeBlockNumber(workingHeight)...)
+}
+
+// pbftIllegalKey = pbftIllegalPrefix + evidence hash
+func pbftIllegalKey(hash common.Hash) []byte {
+	return append(pbftIllegalPrefix, hash.Bytes()...)
+}
+
+// evilSignerKey = evilSignerPrefix + signer + num (uint64 big endian)
+func evilSignerKey(signer common.Address, number uint64) []byte {
+	return append(append(evilSignerPrefix, signer.Bytes()...), encodeBlockNumber(number)...)
+}
+
+// pbftConfirmKey = pbftConfirmPrefix + num (uint64 big endian) + hash
+func pbftConfirmKey(number uint64, hash common.Hash) []byte {
+	return append(append(pbftConfirmPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// dposAddrKey = dposAddrPrefix + DAddr hash
+func dposAddrKey(hash common.Hash) []byte {
+	return append(dposAddrPrefix, hash.Bytes()...)
+}
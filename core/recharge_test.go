@@ -0,0 +1,187 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus/ethash"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/state"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/vm"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb/memorydb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
+)
+
+// Tests that a batch recharge credits every pending deposit once and records
+// the completion of each of them.
+func TestBatchRecharge(t *testing.T) {
+	spvdb := memorydb.New()
+
+	var (
+		first, second, done = common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")
+		addr1, addr2, addr3 = common.Address{1}, common.Address{2}, common.Address{3}
+		deposits            = map[common.Hash][]spv.DepositOutput{
+			first:  {{Address: addr1.String(), Amount: 100000000, Fee: 10000}, {Address: addr2.String(), Amount: 200000000, Fee: 10000}},
+			second: {{Address: addr3.String(), Amount: 300000000, Fee: 10000}},
+			done:   {{Address: addr3.String(), Amount: 300000000, Fee: 10000}},
+		}
+	)
+	for hash, outputs := range deposits {
+		if err := spv.WriteDeposit(spvdb, &spv.DepositRecord{MainTxHash: hash, Outputs: outputs, Fee: 10000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := *params.TestChainConfig
+	config.MultiOutputRechargeBlock = big.NewInt(0)
+	config.BatchRechargeBlock = big.NewInt(1)
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		gspec  = &Genesis{
+			Config: &config,
+			Alloc: GenesisAlloc{
+				sender:           {Balance: big.NewInt(1000000000000000000)},
+				common.Address{}: {Balance: common.Big0, Storage: map[common.Hash]common.Hash{done: {0xff}}},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(config.GetChainIDByHeight(big.NewInt(0)))
+	)
+	chain, _ := NewBlockChain(db, nil, &config, ethash.NewFaker(), ethash.NewFaker(), vm.Config{}, nil)
+	defer chain.Stop()
+	chain.SetDeposits(spv.New(&spv.Config{}, spvdb, nil, nil))
+
+	data := spv.EncodeBatchRecharge([]common.Hash{first, second, first, done})
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db))
+	pending, fee := chain.Bridge().BatchRecharges(statedb, data)
+	if len(pending) != 2 || pending[0] != first.Hex() || pending[1] != second.Hex() {
+		t.Fatalf("pending recharges mismatch: have %v", pending)
+	}
+	if fee.Cmp(spv.SelaToWei(30000)) != 0 {
+		t.Fatalf("batch fee mismatch: have %v, want %v", fee, spv.SelaToWei(30000))
+	}
+
+	var batch *types.Transaction
+	blocks, _ := GenerateChain(&config, genesis, ethash.NewFaker(), db, 1, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(sender), common.Address{}, common.Big0, 100000, big.NewInt(1000000000), data), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTxWithChain(chain, tx)
+		batch = tx
+	})
+	statedb, _ = state.New(blocks[0].Root(), state.NewDatabase(db))
+	for addr, want := range map[common.Address]*big.Int{
+		addr1: spv.SelaToWei(100000000 - 10000),
+		addr2: spv.SelaToWei(200000000 - 10000),
+		addr3: spv.SelaToWei(300000000 - 10000),
+	} {
+		if have := statedb.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("balance of %x mismatch: have %v, want %v", addr, have, want)
+		}
+	}
+	for _, hash := range []common.Hash{first, second} {
+		if have := statedb.GetState(common.Address{}, hash); have != batch.Hash() {
+			t.Errorf("recharge of %x mismatch: have %x, want %x", hash, have, batch.Hash())
+		}
+	}
+	if have := statedb.GetState(common.Address{}, spv.RechargeOutputKey(first.Hex(), 1)); have != batch.Hash() {
+		t.Errorf("output recharge mismatch: have %x, want %x", have, batch.Hash())
+	}
+	if have := statedb.GetState(common.Address{}, done); have != (common.Hash{0xff}) {
+		t.Errorf("completed recharge overwritten: have %x", have)
+	}
+	// the same batch can't be replayed
+	if pending, _ := chain.Bridge().BatchRecharges(statedb, data); len(pending) != 0 {
+		t.Errorf("replayed recharges: have %v", pending)
+	}
+}
+
+// Tests that a single recharge after the multi-output fork is paid by the fee
+// of the outputs which can be credited, whatever the first output credits.
+func TestMultiOutputRecharge(t *testing.T) {
+	spvdb := memorydb.New()
+
+	var (
+		hash                = common.HexToHash("0x01")
+		addr1, addr2, addr3 = common.Address{1}, common.Address{2}, common.Address{3}
+		outputs             = []spv.DepositOutput{
+			{Address: addr1.String(), Amount: 15000, Fee: 10000},
+			{Address: addr2.String(), Amount: 200000000, Fee: 10000},
+			{Address: addr3.String(), Amount: 10000, Fee: 10000},
+		}
+	)
+	if err := spv.WriteDeposit(spvdb, &spv.DepositRecord{MainTxHash: hash, Outputs: outputs, Fee: 30000}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := *params.TestChainConfig
+	config.MultiOutputRechargeBlock = big.NewInt(0)
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		gspec  = &Genesis{
+			Config: &config,
+			Alloc:  GenesisAlloc{sender: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(config.GetChainIDByHeight(big.NewInt(0)))
+	)
+	chain, _ := NewBlockChain(db, nil, &config, ethash.NewFaker(), ethash.NewFaker(), vm.Config{}, nil)
+	defer chain.Stop()
+	chain.SetDeposits(spv.New(&spv.Config{}, spvdb, nil, nil))
+
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db))
+	recharge, err := chain.Bridge().Rules(common.Big1).CheckRecharge(statedb, &common.Address{}, hash.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recharge.Fee.Cmp(spv.SelaToWei(20000)) != 0 || recharge.To != addr1 {
+		t.Fatalf("recharge mismatch: have fee %v to %x, want %v to %x", recharge.Fee, recharge.To, spv.SelaToWei(20000), addr1)
+	}
+
+	var tx *types.Transaction
+	blocks, _ := GenerateChain(&config, genesis, ethash.NewFaker(), db, 1, func(i int, block *BlockGen) {
+		tx, err = types.SignTx(types.NewTransaction(block.TxNonce(sender), common.Address{}, common.Big0, 100000, big.NewInt(1000000000), hash.Bytes()), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTxWithChain(chain, tx)
+	})
+	statedb, _ = state.New(blocks[0].Root(), state.NewDatabase(db))
+	for addr, want := range map[common.Address]*big.Int{
+		addr1: spv.SelaToWei(15000 - 10000),
+		addr2: spv.SelaToWei(200000000 - 10000),
+		addr3: common.Big0,
+	} {
+		if have := statedb.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("balance of %x mismatch: have %v, want %v", addr, have, want)
+		}
+	}
+	if have := statedb.GetState(common.Address{}, hash); have != tx.Hash() {
+		t.Errorf("recharge mismatch: have %x, want %x", have, tx.Hash())
+	}
+}
@@ -21,13 +21,11 @@ import (
 	"math"
 	"math/big"
 
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
-	"github.com/elastos/Elastos.ELA.SideChain.ETH/common/hexutil"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/vm"
-	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
-	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
 )
 
 var (
@@ -193,78 +191,43 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 		// vm errors do not effect consensus and are therefor
 		// not assigned to err, except for insufficient balance
 		// error.
-		vmerr         error
-		snapshot      = evm.StateDB.Snapshot()
-		blackaddr     common.Address
-		blackcontract common.Address
+		vmerr    error
+		snapshot = evm.StateDB.Snapshot()
+		rules    = evm.BridgeRules()
+		recharge *bridge.Recharge
 	)
 
 	msg := st.msg
 	sender := vm.AccountRef(msg.From())
 	contractCreation := msg.To() == nil
-	txhash := hexutil.Encode(msg.Data())
-	//recharge tx
-	if len(msg.Data()) == 32 && msg.To() != nil && *msg.To() == blackaddr {
-		fee, toaddr, output := spv.FindOutputFeeAndaddressByTxHash(txhash)
-		completetxhash := evm.StateDB.GetState(blackaddr, common.HexToHash(txhash))
-		if toaddr != blackaddr {
-			if (completetxhash == common.Hash{}) && output.Cmp(fee) > 0 {
-				st.state.AddBalance(st.msg.From(), new(big.Int).SetUint64(evm.ChainConfig().PassBalance))
-				defer func() {
-					ethfee := new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice)
-					if fee.Cmp(new(big.Int)) <= 0 || fee.Cmp(ethfee) < 0 || st.state.GetBalance(toaddr).Cmp(fee) < 0 || vmerr != nil {
-						ret = nil
-						usedGas = 0
-						failed = false
-						if err == nil {
-							log.Error("fee is not enough ：", "fee", fee.String(), "need",ethfee.String(), "vmerr", vmerr)
-							err = ErrGasLimitReached
-						}
-						evm.StateDB.RevertToSnapshot(snapshot)
-						return
-					} else {
-						st.state.AddBalance(st.msg.From(), fee)
-					}
-
-					if st.state.GetBalance(st.msg.From()).Cmp(new(big.Int).SetUint64(evm.ChainConfig().PassBalance)) < 0 {
-						ret = nil
-						usedGas = 0
-						failed = false
-						if err == nil {
-							err = ErrGasLimitReached
-						}
-						evm.StateDB.RevertToSnapshot(snapshot)
-					} else {
-						st.state.SubBalance(st.msg.From(), new(big.Int).SetUint64(evm.ChainConfig().PassBalance))
-					}
-				}()
-			} else {
-				return nil, 0, false, ErrMainTxHashPresence
-			}
-		} else {
-			return nil, 0, false, ErrElaToEthAddress
-		}
-	} else if contractCreation {//deploy contract
-		blackcontract = crypto.CreateAddress(sender.Address(), evm.StateDB.GetNonce(sender.Address()))
-		if blackcontract.String() == evm.ChainConfig().BlackContractAddr {
-			st.state.AddBalance(st.msg.From(), new(big.Int).SetUint64(evm.ChainConfig().PassBalance))
-			defer func() {
-				fromValue := st.state.GetBalance(st.msg.From())
-				passValue := new(big.Int).SetUint64(evm.ChainConfig().PassBalance)
-				if fromValue.Cmp(passValue) < 0 {
-					ret = nil
-					usedGas = 0
-					failed = false
-					if err == nil {
-						err = ErrGasLimitReached
-					}
-					evm.StateDB.RevertToSnapshot(snapshot)
-				} else {
-					st.state.SubBalance(st.msg.From(), new(big.Int).SetUint64(evm.ChainConfig().PassBalance))
-				}
-			}()
+	blackContractCreation := contractCreation && rules.IsBlackContractCreation(msg.From(), evm.StateDB.GetNonce(msg.From()))
+	if !contractCreation {
+		if recharge, err = rules.CheckRecharge(evm.StateDB, msg.To(), msg.Data()); err != nil {
+			return nil, 0, false, err
 		}
 	}
+	// The system transactions are credited the pass balance to pay for their
+	// gas, it is taken back once they are applied or they are reverted.
+	if recharge != nil || blackContractCreation {
+		passBalance := rules.PassBalance()
+		st.state.AddBalance(msg.From(), passBalance)
+		defer func() {
+			if err == nil && recharge != nil {
+				err = st.payRecharge(rules, recharge, vmerr)
+			}
+			if err == nil && st.state.GetBalance(msg.From()).Cmp(passBalance) < 0 {
+				err = ErrGasLimitReached
+			}
+			if err != nil {
+				ret = nil
+				usedGas = 0
+				failed = false
+				evm.StateDB.RevertToSnapshot(snapshot)
+				return
+			}
+			st.state.SubBalance(msg.From(), passBalance)
+		}()
+	}
 
 	if err = st.preCheck(); err != nil {
 		return
@@ -298,7 +261,7 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 		}
 	}
 	st.refundGas()
-	if contractCreation && blackcontract.String() == evm.ChainConfig().BlackContractAddr {
+	if blackContractCreation {
 		st.state.AddBalance(st.msg.From(), new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice))
 	} else {
 		st.state.AddBalance(st.evm.Coinbase, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice))
@@ -307,6 +270,21 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 	return ret, st.gasUsed(), vmerr != nil, err
 }
 
+// payRecharge pays for the gas of the applied recharge with the fee of its
+// deposits, the recharge is reverted if the fee is not enough. Before the
+// multi-output fork the credited address of a single recharge must also hold
+// the fee.
+func (st *StateTransition) payRecharge(rules bridge.Rules, recharge *bridge.Recharge, vmerr error) error {
+	legacy := !recharge.Batch && !rules.IsMultiOutputRecharge
+	if vmerr != nil || !recharge.CoversGas(st.gasUsed(), st.gasPrice) || (legacy && st.state.GetBalance(recharge.To).Cmp(recharge.Fee) < 0) {
+		need := new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice)
+		log.Error("recharge fee is not enough", "fee", recharge.Fee, "need", need, "batch", recharge.Batch, "vmerr", vmerr)
+		return ErrGasLimitReached
+	}
+	st.state.AddBalance(st.msg.From(), recharge.Fee)
+	return nil
+}
+
 func (st *StateTransition) refundGas() {
 	// Apply refund counter, capped to half of the used gas.
 	refund := st.gasUsed() / 2
@@ -22,11 +22,11 @@ import (
 	"math/big"
 	"sort"
 
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/state"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
-	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
 )
 
 // nonceHeap is a heap.Interface implementation over 64bit unsigned integers for
@@ -101,20 +101,18 @@ func (m *txSortedMap) Forward(threshold uint64) types.Transactions {
 
 // Filter iterates over the list of transactions and removes all of them for which
 // the specified function evaluates to true.
-func (m *txSortedMap) Filter(filter func(*types.Transaction) bool, gasLimit uint64, currentState *state.StateDB, from common.Address, blackContractAddr string) types.Transactions {
+func (m *txSortedMap) Filter(filter func(*types.Transaction) bool, gasLimit uint64, currentState *state.StateDB, from common.Address, registry *bridge.Registry) types.Transactions {
 	var removed types.Transactions
-	var addr common.Address
 	// Collect all the transactions to filter out
 	for nonce, tx := range m.items {
 		if tx.To() != nil {//recharge tx
-			if len(tx.Data())==  32  && *tx.To() == addr {
+			if bridge.IsRecharge(tx.To(), tx.Data()) {
 				filter = func(transaction *types.Transaction) bool {
 					return tx.Gas() > gasLimit
 				}
 			}
-		} else if currentState != nil {//deploy contract
-			contractAddr := crypto.CreateAddress(from, currentState.GetNonce(from))
-			if (contractAddr.String() == blackContractAddr && gasLimit > 0 && from != common.Address{}) {
+		} else if currentState != nil && registry != nil {//deploy contract
+			if (registry.IsBlackContractCreation(from, currentState.GetNonce(from)) && gasLimit > 0 && from != common.Address{}) {
 				filter = func(tx *types.Transaction) bool { return tx.Gas() > gasLimit }
 			}
 		}
@@ -302,7 +300,7 @@ func (l *txList) Forward(threshold uint64) types.Transactions {
 // a point in calculating all the costs or if the balance covers all. If the threshold
 // is lower than the costgas cap, the caps will be reset to a new high after removing
 // the newly invalidated transactions.
-func (l *txList) Filter(costLimit *big.Int, gasLimit uint64, currentState *state.StateDB, from common.Address, blackContractAddr string) (types.Transactions, types.Transactions) {
+func (l *txList) Filter(costLimit *big.Int, gasLimit uint64, currentState *state.StateDB, from common.Address, registry *bridge.Registry) (types.Transactions, types.Transactions) {
 	// If all transactions are below the threshold, short circuit
 	if l.costcap.Cmp(costLimit) <= 0 && l.gascap <= gasLimit {
 		return nil, nil
@@ -311,7 +309,7 @@ func (l *txList) Filter(costLimit *big.Int, gasLimit uint64, currentState *state
 	l.gascap = gasLimit
 
 	// Filter out all the transactions above the account's funds
-	removed := l.txs.Filter(func(tx *types.Transaction) bool { return tx.Cost().Cmp(costLimit) > 0 || tx.Gas() > gasLimit }, gasLimit, currentState, from, blackContractAddr)
+	removed := l.txs.Filter(func(tx *types.Transaction) bool { return tx.Cost().Cmp(costLimit) > 0 || tx.Gas() > gasLimit }, gasLimit, currentState, from, registry)
 
 	// If the list was strict, filter anything above the lowest nonce
 	var invalids types.Transactions
@@ -323,7 +321,7 @@ func (l *txList) Filter(costLimit *big.Int, gasLimit uint64, currentState *state
 				lowest = nonce
 			}
 		}
-		invalids = l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > lowest }, 0, nil, common.Address{}, "")
+		invalids = l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > lowest }, 0, nil, common.Address{}, nil)
 	}
 	return removed, invalids
 }
@@ -345,7 +343,7 @@ func (l *txList) Remove(tx *types.Transaction) (bool, types.Transactions) {
 	}
 	// In strict mode, filter out non-executable transactions
 	if l.strict {
-		return true, l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > nonce }, 0, nil, common.Address{}, "")
+		return true, l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > nonce }, 0, nil, common.Address{}, nil)
 	}
 	return true, nil
 }
@@ -25,6 +25,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common/prque"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/state"
@@ -33,7 +34,6 @@ import (
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/metrics"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
-	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common/hexutil"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
 )
@@ -126,6 +126,7 @@ type blockChain interface {
 	CurrentBlock() *types.Block
 	GetBlock(hash common.Hash, number uint64) *types.Block
 	StateAt(root common.Hash) (*state.StateDB, error)
+	Bridge() *bridge.Registry
 
 	SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription
 }
@@ -214,6 +215,7 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 type TxPool struct {
 	config      TxPoolConfig
 	chainconfig *params.ChainConfig
+	registry    *bridge.Registry // Bridge system contracts of the chain
 	chain       blockChain
 	gasPrice    *big.Int
 	txFeed      event.Feed
@@ -260,6 +262,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 	pool := &TxPool{
 		config:          config,
 		chainconfig:     chainconfig,
+		registry:        chain.Bridge(),
 		chain:           chain,
 		signer:          types.NewEIP155Signer(chainconfig.GetChainIDByHeight(chain.CurrentBlock().Number())),
 		pending:         make(map[common.Address]*txList),
@@ -548,22 +551,12 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 		return ErrNonceTooLow
 	}
 
-	if tx.To() != nil {
-		to := *tx.To()
-		var addr common.Address
-		if len(tx.Data()) != 32 || to != addr {
-			// Transactor should have enough funds to cover the costs
-			// cost == V + GP * GL
-			if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
-				return ErrInsufficientFunds
-			}
-		}
-	} else {
-		contractAddr := crypto.CreateAddress(from, pool.currentState.GetNonce(from))
-		if contractAddr.String() != pool.chainconfig.BlackContractAddr {
-			if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
-				return ErrInsufficientFunds
-			}
+	// Transactor should have enough funds to cover the costs
+	// cost == V + GP * GL, the gas of the system transactions is paid by the bridge
+	rules := pool.registry.Rules(new(big.Int).Add(pool.chain.CurrentBlock().Number(), big.NewInt(1)))
+	if !rules.IsRecharge(tx.To(), tx.Data()) && !(tx.To() == nil && pool.registry.IsBlackContractCreation(from, pool.currentState.GetNonce(from))) {
+		if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
+			return ErrInsufficientFunds
 		}
 	}
 
@@ -837,32 +830,16 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) ([]error, *accountSet) {
 	dirty := newAccountSet(pool.signer)
 	errs := make([]error, len(txs))
+	// Recharges are admitted by the bridge rules of the next block
+	next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), big.NewInt(1))
+	rules := pool.registry.Rules(next)
 	for i, tx := range txs {
-		if tx.To() != nil {
-			to := *tx.To()
-			var blackAddr common.Address
-			if len(tx.Data()) == 32 && to == blackAddr {
-				txhash :=  hexutil.Encode(tx.Data())
-				fee, addr, output := spv.FindOutputFeeAndaddressByTxHash(txhash)
-				if addr != blackAddr {
-					if fee.Cmp(new(big.Int)) > 0 && output.Cmp(new(big.Int)) > 0 {
-						ethFee := new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasPrice())
-						completeTxHash := pool.currentState.GetState(blackAddr, common.HexToHash(txhash))
-						if fee.Cmp(ethFee) < 0 {
-							errs[i] = ErrGasLimitReached
-						} else if (completeTxHash != common.Hash{}) {
-							errs[i] = ErrMainTxHashPresence
-						}
-					} else {
-						errs[i] = ErrGasLimitReached
-					}
-				} else {
-					errs[i] = ErrElaToEthAddress
-				}
-			}
+		recharge, err := rules.CheckRecharge(pool.currentState, tx.To(), tx.Data())
+		if err == nil && recharge != nil && !recharge.CoversGas(tx.Gas(), tx.GasPrice()) {
+			err = ErrRechargeFee
 		}
-
-		if errs[i] != nil {
+		if err != nil {
+			errs[i] = err
 			continue
 		}
 
@@ -960,12 +937,20 @@ func RemoveLocalTx(pool *TxPool, hash common.Hash, outofbound bool, removetx boo
 func UptxhashIndex(pool *TxPool, tx *types.Transaction) bool {
 	if tx.To() != nil {
 		to := *tx.To()
-		var blackaddr common.Address
-		if len(tx.Data()) == 32 && to == blackaddr {
+		if hashes, ok := spv.DecodeBatchRecharge(tx.Data()); ok && to == bridge.RechargeAddress {
+			requeued := false
+			for _, hash := range hashes {
+				if (pool.currentState.GetState(bridge.RechargeAddress, hash) == common.Hash{}) {
+					pool.registry.RequeueRecharge(hash.Hex())
+					requeued = true
+				}
+			}
+			return requeued
+		} else if len(tx.Data()) == 32 && to == bridge.RechargeAddress {
 			txhash := hexutil.Encode(tx.Data())
-			completetxhash := pool.currentState.GetState(blackaddr, common.HexToHash(txhash))
+			completetxhash := pool.currentState.GetState(bridge.RechargeAddress, common.HexToHash(txhash))
 			if (completetxhash == common.Hash{}) {
-				spv.UpTransactionIndex(string(txhash))
+				pool.registry.RequeueRecharge(string(txhash))
 				return true
 			} else {
 				return false
@@ -980,6 +965,12 @@ func UptxhashIndex(pool *TxPool, tx *types.Transaction) bool {
 
 }
 
+// IsRechargeTx returns whether the transaction recharges main chain transactions,
+// a single one or a batch of them.
+func IsRechargeTx(tx *types.Transaction) bool {
+	return bridge.IsRecharge(tx.To(), tx.Data())
+}
+
 // removeTx removes a single transaction from the queue, moving all subsequent
 // transactions back to the future queue.
 func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
@@ -1302,7 +1293,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			log.Trace("Removed old queued transaction", "hash", hash)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas)
-		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas, pool.currentState, addr, pool.chainconfig.BlackContractAddr)
+		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas, pool.currentState, addr, pool.registry)
 		for _, tx := range drops {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
@@ -1494,7 +1485,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
-		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas, pool.currentState, addr, pool.chainconfig.BlackContractAddr)
+		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas, pool.currentState, addr, pool.registry)
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
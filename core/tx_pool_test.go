@@ -26,6 +26,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/state"
@@ -33,6 +34,7 @@ import (
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/event"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
 )
 
 // testTxPoolConfig is a transaction pool configuration without stateful disk
@@ -64,6 +66,10 @@ func (bc *testBlockChain) StateAt(common.Hash) (*state.StateDB, error) {
 	return bc.statedb, nil
 }
 
+func (bc *testBlockChain) Bridge() *bridge.Registry {
+	return bridge.NewRegistry(params.TestChainConfig, nil)
+}
+
 func (bc *testBlockChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription {
 	return bc.chainHeadFeed.Subscribe(ch)
 }
@@ -258,6 +264,21 @@ func TestInvalidTransactions(t *testing.T) {
 	}
 }
 
+// Tests that batch recharge data is charged like any other transaction to the
+// recharge address before the batch recharge fork.
+func TestBatchRechargeBeforeFork(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	data := spv.EncodeBatchRecharge([]common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")})
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{}, common.Big0, 100000, big.NewInt(1), data), types.HomesteadSigner{}, key)
+	if err := pool.AddRemote(tx); err != ErrInsufficientFunds {
+		t.Error("expected", ErrInsufficientFunds, "got", err)
+	}
+}
+
 func TestTransactionQueue(t *testing.T) {
 	t.Parallel()
 
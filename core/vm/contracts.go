@@ -22,6 +22,7 @@ import (
 	"errors"
 	"math/big"
 
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common/math"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
@@ -75,6 +76,11 @@ var PrecompiledContractsIstanbul = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{9}): &blake2F{},
 }
 
+// MainChainDataAddress is the address of the pre-compiled contract answering
+// from the main chain data committed to the side chain, it is available from
+// the main chain data fork.
+var MainChainDataAddress = common.BytesToAddress([]byte{0x03, 0xe8})
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
@@ -500,3 +506,55 @@ func (c *blake2F) Run(input []byte) ([]byte, error) {
 	}
 	return output, nil
 }
+
+var (
+	// errMainChainDataInput is returned if the main chain data input is invalid.
+	errMainChainDataInput = errors.New("invalid main chain data input length")
+)
+
+// mainChainData implemented as a native contract answering from the main chain
+// data committed to the side chain: the ela height embedded in the header of
+// the block and the ela heights of the blocks recharging the main chain
+// transactions recorded in the state.
+// The live SPV state is never read, so the answers are deterministic. The main
+// chain height including a transaction is not committed to the side chain, a
+// transaction is answered by the ela height it is recharged by instead.
+type mainChainData struct {
+	evm *EVM
+}
+
+func (c *mainChainData) RequiredGas(input []byte) uint64 {
+	if len(input) == 0 {
+		return params.MainChainHeightGas
+	}
+	return params.MainChainTxGas
+}
+
+// Run returns the ela height of the block for an empty input. For an input of
+// a main chain transaction hash and an ela height, it returns true if the
+// transaction is recharged by the height, that is in a side chain block sealed
+// at an ela height at or below it, and the block has confirmed the height,
+// false otherwise. Only the deposits to this side chain are recharged, the
+// other main chain transactions are always false. The recharges completed
+// before the main chain data fork, or in the clique blocks before the clique
+// ela height fork which have no validated ela height, are false as well.
+func (c *mainChainData) Run(input []byte) ([]byte, error) {
+	switch len(input) {
+	case 0:
+		return common.LeftPadBytes(new(big.Int).SetUint64(c.evm.ElaHeight).Bytes(), 32), nil
+	case 64:
+		var (
+			hash   = common.BytesToHash(input[:32])
+			height = new(big.Int).SetBytes(input[32:])
+		)
+		if height.Cmp(new(big.Int).SetUint64(c.evm.ElaHeight)) > 0 {
+			return false32Byte, nil
+		}
+		recharged := bridge.RechargedElaHeight(c.evm.StateDB, hash)
+		if recharged == 0 || recharged > height.Uint64() {
+			return false32Byte, nil
+		}
+		return true32Byte, nil
+	}
+	return nil, errMainChainDataInput
+}
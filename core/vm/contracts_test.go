@@ -23,7 +23,11 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/state"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
 )
 
 // precompiledTest defines the input/output pairs for precompiled contract tests.
@@ -637,3 +641,53 @@ func TestPrecompiledEcrecover(t *testing.T) {
 	}
 
 }
+
+func TestPrecompiledMainChainData(t *testing.T) {
+	var (
+		recharged = common.HexToHash("0x01")
+		unknown   = common.HexToHash("0x02")
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb.SetState(bridge.RechargeAddress, bridge.RechargedElaHeightKey(recharged), common.BigToHash(big.NewInt(100)))
+
+	config := *params.TestChainConfig
+	config.MainChainDataBlock = big.NewInt(1)
+	newEVM := func(number int64) *EVM {
+		vmctx := Context{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(number),
+			ElaHeight:   150,
+		}
+		return NewEVM(vmctx, statedb, &config, Config{})
+	}
+	query := func(hash common.Hash, height int64) []byte {
+		return append(hash.Bytes(), common.BigToHash(big.NewInt(height)).Bytes()...)
+	}
+	// the contract isn't available before the fork
+	if ret, _, err := newEVM(0).Call(AccountRef(common.Address{}), MainChainDataAddress, nil, 100000, new(big.Int)); err != nil || len(ret) != 0 {
+		t.Fatalf("main chain data before the fork: have %x, %v", ret, err)
+	}
+	for i, tt := range []struct {
+		input []byte
+		want  []byte
+		err   error
+	}{
+		{nil, common.BigToHash(big.NewInt(150)).Bytes(), nil},
+		{query(recharged, 100), true32Byte, nil},
+		{query(recharged, 150), true32Byte, nil},
+		{query(recharged, 99), false32Byte, nil},
+		{query(recharged, 151), false32Byte, nil},
+		{query(unknown, 150), false32Byte, nil},
+		{recharged.Bytes(), nil, errMainChainDataInput},
+	} {
+		ret, _, err := newEVM(1).StaticCall(AccountRef(common.Address{}), MainChainDataAddress, tt.input, 100000)
+		if err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+			continue
+		}
+		if !bytes.Equal(ret, tt.want) {
+			t.Errorf("test %d: output mismatch: have %x, want %x", i, ret, tt.want)
+		}
+	}
+}
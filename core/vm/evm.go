@@ -21,6 +21,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
@@ -33,6 +34,11 @@ import (
 // deployed contract addresses (relevant after the account abstraction).
 var emptyCodeHash = crypto.Keccak256Hash(nil)
 
+// RechargeTopic is the topic of the log added for every output credited by a
+// recharge, followed by the caller, the main chain transaction, the address and
+// the credited value.
+var RechargeTopic = common.HexToHash("0x09f15c376272c265d7fcb47bf57d8f84a928195e6ea156d12f5a3cd05b8fed5a")
+
 type (
 	// CanTransferFunc is the signature of a transfer guard function
 	CanTransferFunc func(StateDB, common.Address, *big.Int) bool
@@ -46,14 +52,7 @@ type (
 // run runs the given contract and takes care of running precompiles with a fallback to the byte code interpreter.
 func run(evm *EVM, contract *Contract, input []byte, readOnly bool) ([]byte, error) {
 	if contract.CodeAddr != nil {
-		precompiles := PrecompiledContractsHomestead
-		if evm.chainRules.IsByzantium {
-			precompiles = PrecompiledContractsByzantium
-		}
-		if evm.chainRules.IsIstanbul {
-			precompiles = PrecompiledContractsIstanbul
-		}
-		if p := precompiles[*contract.CodeAddr]; p != nil {
+		if p := evm.precompile(*contract.CodeAddr); p != nil {
 			return RunPrecompiledContract(p, input, contract)
 		}
 	}
@@ -94,6 +93,10 @@ type Context struct {
 	BlockNumber *big.Int       // Provides information for NUMBER
 	Time        *big.Int       // Provides information for TIME
 	Difficulty  *big.Int       // Provides information for DIFFICULTY
+	ElaHeight   uint64         // Provides the main chain height embedded in the block
+
+	// Bridge information
+	Bridge *bridge.Registry // Provides the bridge system contracts and the deposits they credit
 }
 
 // EVM is the Ethereum Virtual Machine base object and provides
@@ -117,6 +120,8 @@ type EVM struct {
 	chainConfig *params.ChainConfig
 	// chain rules contains the chain rules for the current epoch
 	chainRules params.Rules
+	// bridge rules contains the bridge rules for the current epoch
+	bridgeRules bridge.Rules
 	// virtual machine configuration options used to initialise the
 	// evm.
 	vmConfig Config
@@ -136,12 +141,16 @@ type EVM struct {
 // NewEVM returns a new EVM. The returned EVM is not thread safe and should
 // only ever be used *once*.
 func NewEVM(ctx Context, statedb StateDB, chainConfig *params.ChainConfig, vmConfig Config) *EVM {
+	if ctx.Bridge == nil {
+		ctx.Bridge = bridge.NewRegistry(chainConfig, nil)
+	}
 	evm := &EVM{
 		Context:      ctx,
 		StateDB:      statedb,
 		vmConfig:     vmConfig,
 		chainConfig:  chainConfig,
 		chainRules:   chainConfig.Rules(ctx.BlockNumber),
+		bridgeRules:  ctx.Bridge.Rules(ctx.BlockNumber),
 		interpreters: make([]Interpreter, 0, 1),
 	}
 
@@ -169,6 +178,21 @@ func NewEVM(ctx Context, statedb StateDB, chainConfig *params.ChainConfig, vmCon
 	return evm
 }
 
+// precompile returns the precompiled contract at the address, nil if there is none.
+func (evm *EVM) precompile(addr common.Address) PrecompiledContract {
+	if addr == MainChainDataAddress && evm.bridgeRules.IsMainChainData {
+		return &mainChainData{evm: evm}
+	}
+	precompiles := PrecompiledContractsHomestead
+	if evm.chainRules.IsByzantium {
+		precompiles = PrecompiledContractsByzantium
+	}
+	if evm.chainRules.IsIstanbul {
+		precompiles = PrecompiledContractsIstanbul
+	}
+	return precompiles[addr]
+}
+
 // Cancel cancels any running EVM operation. This may be called concurrently and
 // it's safe to be called multiple times.
 func (evm *EVM) Cancel() {
@@ -200,37 +224,38 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	}
 
 	var (
-		to        = AccountRef(addr)
-		snapshot  = evm.StateDB.Snapshot()
-		blackAddr common.Address
-		txHash    string
+		to       = AccountRef(addr)
+		snapshot = evm.StateDB.Snapshot()
+		txHash   string
 	)
 	isRechargeTx := false
 	//this is recharge tx
-	if blackAddr == addr && len(input) == 32 {
+	if addr == bridge.RechargeAddress && evm.depth == 0 && evm.bridgeRules.IsBatchRecharge && spv.IsBatchRecharge(input) {
+		isRechargeTx = true
+		pending, _ := evm.bridgeRules.BatchRecharges(evm.StateDB, input)
+		for _, txHash := range pending {
+			if output, amount, ok := evm.creditRecharge(caller, txHash); ok {
+				evm.Transfer(evm.StateDB, caller.Address(), output, amount)
+			}
+		}
+	} else if addr == bridge.RechargeAddress && len(input) == 32 {
 		txHash = hexutil.Encode(input)
-		completeTxHash := evm.StateDB.GetState(blackAddr, common.HexToHash(txHash))
-		fee, address, output :=  spv.FindOutputFeeAndaddressByTxHash(txHash)
-		addr = address
-		if (completeTxHash == common.Hash{} && addr != blackAddr && output.Cmp(fee) > 0) {
-			isRechargeTx = true
-			to = AccountRef(addr)
-			value = new(big.Int).Sub(output, fee)
-			topics := make([]common.Hash, 5)
-			topics[0] = common.HexToHash("0x09f15c376272c265d7fcb47bf57d8f84a928195e6ea156d12f5a3cd05b8fed5a")
-			topics[1] = common.HexToHash(caller.Address().String())
-			topics[2] = common.HexToHash(txHash)
-			topics[3] = common.HexToHash(addr.String())
-			topics[4] = common.BigToHash(value)
-			evm.StateDB.AddLog(&types.Log{
-				Address:blackAddr,
-				Topics:topics,
-				Data:nil,
-				// This is a non-consensus field, but assigned here because
-				// core/state doesn't know the current block number.
-				BlockNumber:evm.BlockNumber.Uint64(),
-			})
-			evm.StateDB.AddBalance(caller.Address(), value)
+		completeTxHash := evm.StateDB.GetState(bridge.RechargeAddress, common.HexToHash(txHash))
+		if evm.bridgeRules.IsMultiOutputRecharge {
+			if (completeTxHash == common.Hash{}) {
+				addr, value, isRechargeTx = evm.creditRecharge(caller, txHash)
+				to = AccountRef(addr)
+			}
+		} else {
+			fee, address, output := evm.bridgeRules.FindRecharge(txHash)
+			addr = address
+			if (completeTxHash == common.Hash{} && addr != bridge.RechargeAddress && output.Cmp(fee) > 0) {
+				isRechargeTx = true
+				to = AccountRef(addr)
+				value = new(big.Int).Sub(output, fee)
+				evm.addRechargeLog(caller, txHash, addr, value)
+				evm.StateDB.AddBalance(caller.Address(), value)
+			}
 		}
 	}
 	// Fail if we're trying to transfer more than the available balance
@@ -239,14 +264,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	}
 
 	if !evm.StateDB.Exist(addr) {
-		precompiles := PrecompiledContractsHomestead
-		if evm.chainRules.IsByzantium {
-			precompiles = PrecompiledContractsByzantium
-		}
-		if evm.chainRules.IsIstanbul {
-			precompiles = PrecompiledContractsIstanbul
-		}
-		if precompiles[addr] == nil && evm.chainRules.IsEIP158 && value.Sign() == 0 {
+		if evm.precompile(addr) == nil && evm.chainRules.IsEIP158 && value.Sign() == 0 {
 			// Calling a non existing account, don't do anything, but ping the tracer
 			if evm.vmConfig.Debug && evm.depth == 0 {
 				evm.vmConfig.Tracer.CaptureStart(caller.Address(), addr, false, input, gas, value)
@@ -279,7 +297,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 		ret, err = run(evm, contract, input, false)
 	}
 	//if is withdraw tx, reduce the contract eth. Because the withdrawal transaction is to transfer ETH token to the black contract, the black contract broadcast event
-	if to.Address().String() == evm.ChainConfig().BlackContractAddr && err == nil {
+	if evm.bridgeRules.IsBlackContract(to.Address()) && err == nil {
 		evm.StateDB.SubBalance(to.Address(), value)
 	}
 	// When an error was returned by the EVM or when setting the creation code
@@ -294,6 +312,50 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	return ret, contract.Gas, err
 }
 
+// addRechargeLog adds the log of the recharge of the main chain transaction to
+// the side chain address.
+func (evm *EVM) addRechargeLog(caller ContractRef, txHash string, addr common.Address, value *big.Int) {
+	topics := make([]common.Hash, 5)
+	topics[0] = RechargeTopic
+	topics[1] = common.HexToHash(caller.Address().String())
+	topics[2] = common.HexToHash(txHash)
+	topics[3] = common.HexToHash(addr.String())
+	topics[4] = common.BigToHash(value)
+	evm.StateDB.AddLog(&types.Log{
+		Address:common.Address{},
+		Topics:topics,
+		Data:nil,
+		// This is a non-consensus field, but assigned here because
+		// core/state doesn't know the current block number.
+		BlockNumber:evm.BlockNumber.Uint64(),
+	})
+}
+
+// creditRecharge credits every output of the main chain transaction. The first
+// credited output is returned to be transferred by the call, the others are
+// transferred to their addresses directly.
+func (evm *EVM) creditRecharge(caller ContractRef, txHash string) (common.Address, *big.Int, bool) {
+	var (
+		first common.Address
+		value = new(big.Int)
+		found bool
+	)
+	for _, o := range evm.bridgeRules.RechargeOutputs(txHash) {
+		if o.Output.Cmp(o.Fee) <= 0 {
+			continue
+		}
+		amount := new(big.Int).Sub(o.Output, o.Fee)
+		evm.addRechargeLog(caller, txHash, o.Address, amount)
+		evm.StateDB.AddBalance(caller.Address(), amount)
+		if !found {
+			first, value, found = o.Address, amount, true
+			continue
+		}
+		evm.Transfer(evm.StateDB, caller.Address(), o.Address, amount)
+	}
+	return first, value, found
+}
+
 // CallCode executes the contract associated with the addr with the given input
 // as parameters. It also handles any necessary value transfer required and takes
 // the necessary steps to create accounts and reverses the state in case of an
@@ -515,3 +577,6 @@ func (evm *EVM) Create2(caller ContractRef, code []byte, gas uint64, endowment *
 
 // ChainConfig returns the environment's chain configuration
 func (evm *EVM) ChainConfig() *params.ChainConfig { return evm.chainConfig }
+
+// BridgeRules returns the environment's bridge rules
+func (evm *EVM) BridgeRules() bridge.Rules { return evm.bridgeRules }
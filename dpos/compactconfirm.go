@@ -0,0 +1,259 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+
+	dmsg "github.com/elastos/Elastos.ELA.SideChain.ETH/dpos/msg"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+)
+
+// CompactConfirmPrefix is the first byte of a compact confirm, a serialized
+// full confirm starts with the length of the sponsor public key.
+const CompactConfirmPrefix byte = 0xcc
+
+// maxCompactBitmapSize is the max size of the producer bitmap.
+const maxCompactBitmapSize = 256
+
+var (
+	errInvalidCompactPrefix = errors.New("[CompactConfirm] invalid prefix")
+	errCompactBitmapSize    = errors.New("[CompactConfirm] bitmap is not match producers")
+)
+
+// CompactConfirm is the confirm of a block without the signer public key and
+// proposal hash of each vote. The signers are a bitmap against the producer
+// set active at the confirmed height sorted by public key, the signatures of
+// the accept votes follow in the order of the bitmap.
+type CompactConfirm struct {
+	Proposal payload.DPOSProposal
+	Bitmap   []byte
+	Signs    [][]byte
+}
+
+// sortedProducers returns a copy of the producers sorted by public key, so the
+// bitmap does not depend on the order the producers were announced in.
+func sortedProducers(producers [][]byte) [][]byte {
+	sorted := make([][]byte, len(producers))
+	copy(sorted, producers)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+	return sorted
+}
+
+// NewCompactConfirm compacts the confirm against the producers, the confirm
+// must only contain accept votes of the proposal by distinct producers.
+func NewCompactConfirm(confirm *payload.Confirm, producers [][]byte) (*CompactConfirm, error) {
+	sorted := sortedProducers(producers)
+	indexes := make(map[string]int, len(sorted))
+	for i, producer := range sorted {
+		indexes[common.BytesToHexString(producer)] = i
+	}
+	signs := make([][]byte, len(sorted))
+	proposalHash := confirm.Proposal.Hash()
+	for _, vote := range confirm.Votes {
+		if !vote.Accept || !proposalHash.IsEqual(vote.ProposalHash) {
+			return nil, errors.New("[CompactConfirm] confirm contains invalid vote")
+		}
+		if len(vote.Sign) != crypto.SignatureLength {
+			return nil, errors.New("[CompactConfirm] invalid vote signature length")
+		}
+		i, ok := indexes[common.BytesToHexString(vote.Signer)]
+		if !ok {
+			return nil, errors.New("[CompactConfirm] confirm contains vote of non producer")
+		}
+		if signs[i] != nil {
+			return nil, errors.New("[CompactConfirm] confirm contains duplicated signer")
+		}
+		signs[i] = vote.Sign
+	}
+
+	c := &CompactConfirm{
+		Proposal: confirm.Proposal,
+		Bitmap:   make([]byte, (len(sorted)+7)/8),
+		Signs:    make([][]byte, 0, len(confirm.Votes)),
+	}
+	for i, sign := range signs {
+		if sign != nil {
+			c.Bitmap[i/8] |= 1 << uint(i%8)
+			c.Signs = append(c.Signs, sign)
+		}
+	}
+	if len(c.Bitmap) > maxCompactBitmapSize {
+		return nil, errCompactBitmapSize
+	}
+	return c, nil
+}
+
+// Expand returns the full confirm with the votes of the producers in the
+// bitmap, the producers must be the set active at the confirmed height.
+func (c *CompactConfirm) Expand(producers [][]byte) (*payload.Confirm, error) {
+	sorted := sortedProducers(producers)
+	if len(c.Bitmap) != (len(sorted)+7)/8 {
+		return nil, errCompactBitmapSize
+	}
+	confirm := &payload.Confirm{
+		Proposal: c.Proposal,
+		Votes:    make([]payload.DPOSProposalVote, 0, len(c.Signs)),
+	}
+	proposalHash := c.Proposal.Hash()
+	for i := 0; i < len(c.Bitmap)*8; i++ {
+		if c.Bitmap[i/8]&(1<<uint(i%8)) == 0 {
+			continue
+		}
+		if i >= len(sorted) {
+			return nil, errCompactBitmapSize
+		}
+		n := len(confirm.Votes)
+		if n >= len(c.Signs) {
+			return nil, errors.New("[CompactConfirm] missing vote signature")
+		}
+		confirm.Votes = append(confirm.Votes, payload.DPOSProposalVote{
+			ProposalHash: proposalHash,
+			Signer:       sorted[i],
+			Accept:       true,
+			Sign:         c.Signs[n],
+		})
+	}
+	if len(confirm.Votes) != len(c.Signs) {
+		return nil, errors.New("[CompactConfirm] too many vote signatures")
+	}
+	return confirm, nil
+}
+
+// signerCount returns the count of the bits set in the bitmap.
+func (c *CompactConfirm) signerCount() int {
+	count := 0
+	for _, b := range c.Bitmap {
+		for ; b != 0; b &= b - 1 {
+			count++
+		}
+	}
+	return count
+}
+
+func (c *CompactConfirm) Serialize(w io.Writer) error {
+	if err := common.WriteUint8(w, CompactConfirmPrefix); err != nil {
+		return err
+	}
+	if err := c.Proposal.Serialize(w); err != nil {
+		return err
+	}
+	if err := common.WriteVarBytes(w, c.Bitmap); err != nil {
+		return err
+	}
+	for _, sign := range c.Signs {
+		if _, err := w.Write(sign); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CompactConfirm) Deserialize(r io.Reader) error {
+	prefix, err := common.ReadUint8(r)
+	if err != nil {
+		return err
+	}
+	if prefix != CompactConfirmPrefix {
+		return errInvalidCompactPrefix
+	}
+	if err := c.Proposal.Deserialize(r); err != nil {
+		return err
+	}
+	if c.Bitmap, err = common.ReadVarBytes(r, maxCompactBitmapSize, "bitmap"); err != nil {
+		return err
+	}
+	count := c.signerCount()
+	c.Signs = make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		sign := make([]byte, crypto.SignatureLength)
+		if _, err := io.ReadFull(r, sign); err != nil {
+			return err
+		}
+		c.Signs = append(c.Signs, sign)
+	}
+	return nil
+}
+
+// IsCompactConfirm reports if the header extra holds a compact confirm.
+func IsCompactConfirm(data []byte) bool {
+	return len(data) > 0 && data[0] == CompactConfirmPrefix
+}
+
+// EncodeConfirm serializes the confirm for the header extra, the compact
+// encoding against the producers is used if compact is set.
+func EncodeConfirm(confirm *payload.Confirm, producers [][]byte, compact bool) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if !compact {
+		if err := confirm.Serialize(buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	c, err := NewCompactConfirm(confirm, producers)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeConfirm deserializes the confirm in either encoding from the header
+// extra, a compact confirm is expanded with the producers. The votes of a full
+// confirm are bounded by the producers, or by MaxConfirmVotes if they are nil.
+func DecodeConfirm(data []byte, producers [][]byte) (*payload.Confirm, error) {
+	if IsCompactConfirm(data) {
+		var c CompactConfirm
+		if err := c.Deserialize(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		return c.Expand(producers)
+	}
+	maxVotes := dmsg.MaxConfirmVotes
+	if producers != nil {
+		maxVotes = len(producers)
+	}
+	return decodeFullConfirm(data, maxVotes)
+}
+
+// DecodeConfirmProposal returns the proposal of the confirm in either encoding,
+// it does not need the producer set.
+func DecodeConfirmProposal(data []byte) (*payload.DPOSProposal, error) {
+	if IsCompactConfirm(data) {
+		var c CompactConfirm
+		if err := c.Deserialize(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		return &c.Proposal, nil
+	}
+	confirm, err := decodeFullConfirm(data, dmsg.MaxConfirmVotes)
+	if err != nil {
+		return nil, err
+	}
+	return &confirm.Proposal, nil
+}
+
+// decodeFullConfirm deserializes the full confirm of the header extra, the
+// extra comes from untrusted blocks so its vote count is bounded by maxVotes
+// and by the size of the extra before the votes are allocated.
+func decodeFullConfirm(data []byte, maxVotes int) (*payload.Confirm, error) {
+	confirm := new(payload.Confirm)
+	r := &io.LimitedReader{R: bytes.NewReader(data), N: int64(len(data))}
+	if err := dmsg.DeserializeConfirm(r, confirm, maxVotes); err != nil {
+		return nil, err
+	}
+	return confirm, nil
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	dmsg "github.com/elastos/Elastos.ELA.SideChain.ETH/dpos/msg"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestConfirm(t *testing.T, keys []*testKey, signers ...int) *payload.Confirm {
+	proposal := payload.DPOSProposal{Sponsor: keys[0].pubKey, BlockHash: common.Uint256{1}, ViewOffset: 1}
+	proposal.Sign = keys[0].sign(proposal.Data())
+	confirm := &payload.Confirm{Proposal: proposal}
+	for _, i := range signers {
+		vote := payload.DPOSProposalVote{ProposalHash: proposal.Hash(), Signer: keys[i].pubKey, Accept: true}
+		vote.Sign = keys[i].sign(vote.Data())
+		confirm.Votes = append(confirm.Votes, vote)
+	}
+	return confirm
+}
+
+func TestCompactConfirm(t *testing.T) {
+	keys := make([]*testKey, 12)
+	producers := make([][]byte, len(keys))
+	for i := range keys {
+		keys[i] = newTestKey(t)
+		producers[i] = keys[i].pubKey
+	}
+	confirm := newTestConfirm(t, keys, 9, 0, 3, 5, 7, 1, 11, 2, 10)
+	assert.NoError(t, CheckConfirmWithProducers(confirm, producers))
+
+	full, err := EncodeConfirm(confirm, nil, false)
+	assert.NoError(t, err)
+	compact, err := EncodeConfirm(confirm, producers, true)
+	assert.NoError(t, err)
+	assert.True(t, IsCompactConfirm(compact))
+	assert.False(t, IsCompactConfirm(full))
+	assert.True(t, len(compact) < len(full)*2/3)
+
+	// the encoding does not depend on the order of the votes and producers
+	reversed := make([][]byte, len(producers))
+	for i, producer := range producers {
+		reversed[len(producers)-1-i] = producer
+	}
+	shuffled := newTestConfirm(t, keys)
+	shuffled.Proposal = confirm.Proposal
+	for i := len(confirm.Votes) - 1; i >= 0; i-- {
+		shuffled.Votes = append(shuffled.Votes, confirm.Votes[i])
+	}
+	data, err := EncodeConfirm(shuffled, reversed, true)
+	assert.NoError(t, err)
+	assert.Equal(t, compact, data)
+
+	expanded, err := DecodeConfirm(compact, reversed)
+	assert.NoError(t, err)
+	assert.Equal(t, len(confirm.Votes), len(expanded.Votes))
+	assert.NoError(t, CheckConfirmWithProducers(expanded, producers))
+	assert.Equal(t, confirm.Proposal.Hash(), expanded.Proposal.Hash())
+
+	proposal, err := DecodeConfirmProposal(compact)
+	assert.NoError(t, err)
+	assert.Equal(t, confirm.Proposal.Hash(), proposal.Hash())
+	proposal, err = DecodeConfirmProposal(full)
+	assert.NoError(t, err)
+	assert.Equal(t, confirm.Proposal.Hash(), proposal.Hash())
+
+	decoded, err := DecodeConfirm(full, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, len(confirm.Votes), len(decoded.Votes))
+
+	// expanding against another producer set fails the verification
+	other := make([][]byte, len(producers))
+	copy(other, producers)
+	other[3] = newTestKey(t).pubKey
+	expanded, err = DecodeConfirm(compact, other)
+	assert.NoError(t, err)
+	assert.Error(t, CheckConfirmWithProducers(expanded, other))
+	_, err = DecodeConfirm(compact, producers[:7])
+	assert.Equal(t, errCompactBitmapSize, err)
+
+	// truncated signatures
+	var c CompactConfirm
+	assert.Error(t, c.Deserialize(bytes.NewReader(compact[:len(compact)-1])))
+}
+
+func TestCompactConfirmInvalidVotes(t *testing.T) {
+	keys := make([]*testKey, 4)
+	producers := make([][]byte, len(keys))
+	for i := range keys {
+		keys[i] = newTestKey(t)
+		producers[i] = keys[i].pubKey
+	}
+
+	confirm := newTestConfirm(t, keys, 0, 1, 1)
+	_, err := NewCompactConfirm(confirm, producers)
+	assert.Error(t, err)
+
+	confirm = newTestConfirm(t, keys, 0, 1, 2)
+	_, err = NewCompactConfirm(confirm, producers[:2])
+	assert.Error(t, err)
+
+	confirm.Votes[1].Accept = false
+	_, err = NewCompactConfirm(confirm, producers)
+	assert.Error(t, err)
+}
+
+func TestDecodeConfirmMaliciousVoteCount(t *testing.T) {
+	keys := []*testKey{newTestKey(t), newTestKey(t), newTestKey(t)}
+	producers := [][]byte{keys[0].pubKey, keys[1].pubKey, keys[2].pubKey}
+	confirm := newTestConfirm(t, keys, 0, 1, 2)
+	full, err := EncodeConfirm(confirm, nil, false)
+	assert.NoError(t, err)
+
+	// overwrite the vote count following the proposal
+	buf := new(bytes.Buffer)
+	assert.NoError(t, confirm.Proposal.Serialize(buf))
+	countAt := buf.Len()
+	forge := func(count uint64) []byte {
+		data := append([]byte{}, full...)
+		binary.LittleEndian.PutUint64(data[countAt:], count)
+		return data
+	}
+
+	_, err = DecodeConfirmProposal(forge(math.MaxUint64))
+	assert.Equal(t, dmsg.ErrConfirmVoteCount, err)
+	_, err = DecodeConfirm(forge(math.MaxUint64), nil)
+	assert.Equal(t, dmsg.ErrConfirmVoteCount, err)
+	_, err = DecodeConfirm(forge(uint64(len(full))), nil)
+	assert.Equal(t, dmsg.ErrConfirmVoteCount, err)
+	_, err = DecodeConfirm(forge(4), nil)
+	assert.Error(t, err)
+
+	// the votes of a full confirm are bounded by the producers
+	_, err = DecodeConfirm(full, producers[:2])
+	assert.Equal(t, dmsg.ErrConfirmVoteCount, err)
+	decoded, err := DecodeConfirm(full, producers)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(decoded.Votes))
+}
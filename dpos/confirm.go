@@ -8,6 +8,8 @@ package dpos
 import (
 	"errors"
 
+	"github.com/elastos/Elastos.ELA/common"
+
 	"github.com/elastos/Elastos.ELA/core/types/payload"
 )
 
@@ -41,4 +43,29 @@ func CheckConfirm(confirm *payload.Confirm, minSignCount int) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// CheckConfirmWithProducers checks the confirm against the producer set which
+// was active at the confirmed height, the sponsor and every signer must belong
+// to the set and each producer can only vote once.
+func CheckConfirmWithProducers(confirm *payload.Confirm, producers [][]byte) error {
+	set := NewProducers(producers, 0)
+	if !set.IsProducers(confirm.Proposal.Sponsor) {
+		return errors.New("[CheckConfirm] sponsor is not a producer")
+	}
+	signers := make(map[string]struct{}, len(confirm.Votes))
+	for _, vote := range confirm.Votes {
+		if !set.IsProducers(vote.Signer) {
+			Error("confirm contains vote of ", common.BytesToHexString(vote.Signer), "who is not a producer")
+			return errors.New("[CheckConfirm] confirm contains " +
+				"vote of non producer")
+		}
+		signer := common.BytesToHexString(vote.Signer)
+		if _, ok := signers[signer]; ok {
+			return errors.New("[CheckConfirm] confirm contains " +
+				"duplicated signer")
+		}
+		signers[signer] = struct{}{}
+	}
+	return CheckConfirm(confirm, set.GetMajorityCount())
+}
@@ -83,6 +83,24 @@ func (v *ConsensusView) UpdateDutyIndex(height uint64) {
 	v.isDposOnDuty = bytes.Equal(currentProducer, v.publicKey)
 }
 
+// UpdateProducers switches the view to a new producer set which is active
+// from the given side chain height on.
+func (v *ConsensusView) UpdateProducers(producers [][]byte, startHeight uint64) error {
+	if err := v.producers.ChangeProducers(producers, startHeight); err != nil {
+		return err
+	}
+	currentProducer := v.producers.GetNextOnDutyProducer(v.viewOffset)
+	v.isDposOnDuty = bytes.Equal(currentProducer, v.publicKey)
+	return nil
+}
+
+// SetPublicKey replaces the public key of this producer.
+func (v *ConsensusView) SetPublicKey(publicKey []byte) {
+	v.publicKey = publicKey
+	currentProducer := v.producers.GetNextOnDutyProducer(v.viewOffset)
+	v.isDposOnDuty = bytes.Equal(currentProducer, v.publicKey)
+}
+
 func (v *ConsensusView) ChangeView(now time.Time, force bool, parentTime uint64) {
 	offset, offsetTime := v.calculateOffsetTime(v.viewStartTime, now)
 	if offset > 0 {
@@ -135,6 +153,12 @@ func (v *ConsensusView) GetChangeViewTime() time.Time {
 	return v.viewChangeTime
 }
 
+// GetDutyProducer returns the producer on duty at view offset zero for the
+// block of the given height.
+func (v *ConsensusView) GetDutyProducer(height uint64) []byte {
+	return v.producers.GetDutyProducer(height)
+}
+
 func (v *ConsensusView) GetViewOffset() uint32 {
 	return v.viewOffset
 }
@@ -158,7 +182,13 @@ func (v *ConsensusView) IsOnduty() bool {
 }
 
 func (v *ConsensusView) ProducerIsOnDuty(account []byte) bool {
-	producer := v.producers.GetNextOnDutyProducer(v.viewOffset)
+	return v.ProducerIsOnDutyAt(account, v.viewOffset)
+}
+
+// ProducerIsOnDutyAt reports if the producer of the account is on duty at the
+// given view offset of the current height.
+func (v *ConsensusView) ProducerIsOnDutyAt(account []byte, viewOffset uint32) bool {
+	producer := v.producers.GetNextOnDutyProducer(viewOffset)
 	return bytes.Equal(producer, account)
 }
 
@@ -29,6 +29,8 @@ type Dispatcher struct {
 	processingProposal *payload.DPOSProposal
 	consensusView      *ConsensusView
 	timeSource         dtime.MedianTimeSource
+	illegalMonitor     *IllegalMonitor
+	wal                *ConsensusWAL
 
 	onConfirm func(confirm *payload.Confirm) error
 	unConfirm func(confirm *payload.Confirm) error
@@ -69,6 +71,9 @@ func (d *Dispatcher) ProcessProposal(id peer.PID, proposal *payload.DPOSProposal
 	if err != nil {
 		return err, true, true
 	}
+	if d.illegalMonitor != nil {
+		d.illegalMonitor.AddProposal(proposal)
+	}
 
 	d.setProcessingProposal(proposal)
 	return nil, false, true
@@ -96,6 +101,11 @@ func (d *Dispatcher) GetFinishedProposal() common.Uint256 {
 
 func (d *Dispatcher) setProcessingProposal(p *payload.DPOSProposal) (finished bool) {
 	d.processingProposal = p
+	if d.wal != nil {
+		if err := d.wal.AddProposal(p); err != nil {
+			Error("[ConsensusWAL] write proposal error", "err", err)
+		}
+	}
 	log.Info("setProcessingProposal start")
 	defer log.Info("setProcessingProposal end")
 	for _, v := range d.pendingVotes {
@@ -144,6 +154,14 @@ func (d *Dispatcher) ProcessVote(vote *payload.DPOSProposalVote) (succeed bool,
 	if err := CheckVote(vote); err != nil {
 		return false, false, err
 	}
+	if d.illegalMonitor != nil {
+		d.illegalMonitor.AddVote(vote)
+	}
+	if d.wal != nil {
+		if err := d.wal.AddVote(vote); err != nil {
+			Error("[ConsensusWAL] write vote error", "err", err)
+		}
+	}
 
 	if vote.Accept {
 		d.acceptVotes[vote.Hash()] = vote
@@ -181,8 +199,17 @@ func (d *Dispatcher) FinishedProposal(height uint64, sealHash common.Uint256,
 
 	d.consensusView.SetReady()
 	d.CleanProposals(false)
+	if d.illegalMonitor != nil && height > cachedCount {
+		d.illegalMonitor.Clean(height - cachedCount)
+	}
 	d.consensusView.UpdateDutyIndex(height)
+	if d.wal != nil {
+		if err := d.wal.NewRound(height); err != nil {
+			Error("[ConsensusWAL] reset error", "err", err)
+		}
+	}
 	d.consensusView.ChangeView(d.timeSource.AdjustedTime(), true, headerTime)
+	d.recordView()
 }
 func (d *Dispatcher) CleanProposals(changeView bool) {
 	Info("Clean proposals")
@@ -238,11 +265,27 @@ func (d *Dispatcher) alreadyExistVote(v *payload.DPOSProposalVote) bool {
 func (d *Dispatcher) AcceptProposal(proposal *payload.DPOSProposal, ac account.Account) *msg.Vote {
 	hash := proposal.Hash()
 
+	if d.wal != nil {
+		if vote, ok := d.wal.AcceptedVote(proposal.ViewOffset); ok {
+			if !vote.ProposalHash.IsEqual(hash) {
+				Warn("[AcceptProposal] already accepted another proposal in view", proposal.ViewOffset,
+					"accepted:", vote.ProposalHash.String(), "proposal:", hash.String())
+				return nil
+			}
+			return &msg.Vote{Command: msg.CmdAcceptVote, Vote: *vote}
+		}
+	}
 	vote, err := StartVote(&hash, true, ac)
 	if err != nil {
 		Error("StartVote error", "err", err)
 		return nil
 	}
+	if d.wal != nil {
+		if err := d.wal.AddAcceptedVote(proposal.ViewOffset, vote); err != nil {
+			Error("[ConsensusWAL] write accepted vote error", "err", err)
+			return nil
+		}
+	}
 	return &msg.Vote{Command: msg.CmdAcceptVote, Vote: *vote}
 }
 
@@ -299,6 +342,22 @@ func (d *Dispatcher) GetProcessingProposal() *payload.DPOSProposal {
 	return d.processingProposal
 }
 
+// GetVotes returns the accept and reject votes collected for the processing
+// proposal.
+func (d *Dispatcher) GetVotes() (accepts, rejects []payload.DPOSProposalVote) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	accepts = make([]payload.DPOSProposalVote, 0, len(d.acceptVotes))
+	for _, v := range d.acceptVotes {
+		accepts = append(accepts, *v)
+	}
+	rejects = make([]payload.DPOSProposalVote, 0, len(d.rejectedVotes))
+	for _, v := range d.rejectedVotes {
+		rejects = append(rejects, *v)
+	}
+	return accepts, rejects
+}
+
 func (d *Dispatcher) GetNeedConnectProducers() []peer.PID {
 	peers := make([]peer.PID, len(d.consensusView.producers.producers))
 	for i, p := range d.consensusView.producers.producers {
@@ -311,16 +370,59 @@ func (d *Dispatcher) GetNeedConnectProducers() []peer.PID {
 
 func (d *Dispatcher) OnChangeView() {
 	d.consensusView.TryChangeView(d.timeSource.AdjustedTime())
+	d.recordView()
 }
 
 func (d *Dispatcher) ResetView(parentTime uint64) {
 	d.consensusView.ResetView(parentTime)
+	d.recordView()
 }
 
 func (d *Dispatcher) GetConsensusView() *ConsensusView {
 	return d.consensusView
 }
 
+// SetIllegalMonitor sets the monitor which checks the processed proposals and
+// votes for conflicting signatures.
+func (d *Dispatcher) SetIllegalMonitor(monitor *IllegalMonitor) {
+	d.illegalMonitor = monitor
+}
+
+// SetWAL sets the write-ahead log which records the in-flight consensus state.
+func (d *Dispatcher) SetWAL(wal *ConsensusWAL) {
+	d.wal = wal
+}
+
+// recordView writes the current view to the write-ahead log if it changed.
+func (d *Dispatcher) recordView() {
+	if d.wal == nil {
+		return
+	}
+	err := d.wal.AddViewChange(d.consensusView.GetViewOffset(), d.consensusView.GetViewStartTime())
+	if err != nil {
+		Error("[ConsensusWAL] write view change error", "err", err)
+	}
+}
+
+// ReplayWAL resumes the consensus round recorded in the write-ahead log if it
+// is the round after the block of the given height, otherwise a new round is
+// started in the log.
+func (d *Dispatcher) ReplayWAL(height uint64) bool {
+	if d.wal == nil {
+		return false
+	}
+	if status := d.wal.ConsensusStatus(height); status != nil {
+		Info("[ReplayWAL] resume consensus, height:", height, "viewOffset:", status.ViewOffset)
+		d.RecoverFromConsensusStatus(status)
+		return true
+	}
+	if err := d.wal.NewRound(height); err != nil {
+		Error("[ConsensusWAL] reset error", "err", err)
+	}
+	d.recordView()
+	return false
+}
+
 func (d *Dispatcher) HelpToRecoverAbnormal(id peer.PID, height uint64, currentHeight uint64) *msg.ConsensusStatus {
 	Info("[HelpToRecoverAbnormal] peer id:", common.BytesToHexString(id[:]))
 
@@ -393,6 +495,7 @@ func (d *Dispatcher) RecoverFromConsensusStatus(status *msg.ConsensusStatus) err
 	d.consensusView.viewOffset = status.ViewOffset
 	d.consensusView.ResetView(uint64(status.ViewStartTime.Unix()))
 	d.consensusView.isDposOnDuty = d.consensusView.ProducerIsOnDuty(d.consensusView.publicKey)
+	d.recordView()
 	Info("\n\n\n\n \n\n\n\n -------[End RecoverFromConsensusStatus]-------- startTime", d.consensusView.GetViewStartTime())
 	d.consensusView.DumpInfo()
 	Info("\n\n\n\n \n\n\n\n")
@@ -0,0 +1,239 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+)
+
+// IllegalMonitor watches the proposals and votes processed by the dispatcher
+// and reports the producers who signed conflicting ones.
+type IllegalMonitor struct {
+	proposals map[common.Uint256]*payload.ProposalEvidence
+	votes     map[common.Uint256]*payload.DPOSProposalVote
+	reported  map[common.Uint256]struct{}
+
+	getBlockHeader     func(sealHash common.Uint256) ([]byte, uint32, bool)
+	onIllegalProposals func(evidence *payload.DPOSIllegalProposals)
+	onIllegalVotes     func(evidence *payload.DPOSIllegalVotes)
+
+	mu sync.Mutex
+}
+
+func NewIllegalMonitor(getBlockHeader func(sealHash common.Uint256) ([]byte, uint32, bool),
+	onIllegalProposals func(evidence *payload.DPOSIllegalProposals),
+	onIllegalVotes func(evidence *payload.DPOSIllegalVotes)) *IllegalMonitor {
+	return &IllegalMonitor{
+		proposals:          make(map[common.Uint256]*payload.ProposalEvidence),
+		votes:              make(map[common.Uint256]*payload.DPOSProposalVote),
+		reported:           make(map[common.Uint256]struct{}),
+		getBlockHeader:     getBlockHeader,
+		onIllegalProposals: onIllegalProposals,
+		onIllegalVotes:     onIllegalVotes,
+	}
+}
+
+// AddProposal records a verified proposal and reports it if its sponsor has
+// already proposed another block of the same height in the same view.
+func (m *IllegalMonitor) AddProposal(proposal *payload.DPOSProposal) {
+	m.mu.Lock()
+	hash := proposal.Hash()
+	if _, ok := m.proposals[hash]; ok {
+		m.mu.Unlock()
+		return
+	}
+	header, height, ok := m.getBlockHeader(proposal.BlockHash)
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	evidence := &payload.ProposalEvidence{
+		Proposal:    *proposal,
+		BlockHeader: header,
+		BlockHeight: height,
+	}
+	var illegal *payload.DPOSIllegalProposals
+	for _, e := range m.proposals {
+		if isProposalsIllegal(e, evidence) {
+			illegal = newIllegalProposals(e, evidence)
+			break
+		}
+	}
+	m.proposals[hash] = evidence
+	if illegal != nil && !m.markReported(illegal.Hash()) {
+		illegal = nil
+	}
+	m.mu.Unlock()
+
+	if illegal != nil {
+		Warn("[IllegalMonitor] found illegal proposals, sponsor:",
+			common.BytesToHexString(proposal.Sponsor), "height:", height)
+		m.onIllegalProposals(illegal)
+	}
+}
+
+// AddVote records a verified vote and reports it if its signer has already
+// signed a conflicting vote.
+func (m *IllegalMonitor) AddVote(vote *payload.DPOSProposalVote) {
+	m.mu.Lock()
+	hash := vote.Hash()
+	if _, ok := m.votes[hash]; ok {
+		m.mu.Unlock()
+		return
+	}
+	if _, ok := m.proposals[vote.ProposalHash]; !ok {
+		m.mu.Unlock()
+		return
+	}
+	var illegal *payload.DPOSIllegalVotes
+	for _, v := range m.votes {
+		if m.isVotesIllegal(v, vote) {
+			illegal = newIllegalVotes(v, m.proposals[v.ProposalHash],
+				vote, m.proposals[vote.ProposalHash])
+			break
+		}
+	}
+	m.votes[hash] = vote
+	if illegal != nil && !m.markReported(illegal.Hash()) {
+		illegal = nil
+	}
+	m.mu.Unlock()
+
+	if illegal != nil {
+		Warn("[IllegalMonitor] found illegal votes, signer:",
+			common.BytesToHexString(vote.Signer))
+		m.onIllegalVotes(illegal)
+	}
+}
+
+// Clean removes the proposals and votes below the given height.
+func (m *IllegalMonitor) Clean(height uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for hash, e := range m.proposals {
+		if uint64(e.BlockHeight) < height {
+			delete(m.proposals, hash)
+		}
+	}
+	for hash, v := range m.votes {
+		if _, ok := m.proposals[v.ProposalHash]; !ok {
+			delete(m.votes, hash)
+		}
+	}
+	m.reported = make(map[common.Uint256]struct{})
+}
+
+func (m *IllegalMonitor) markReported(hash common.Uint256) bool {
+	if _, ok := m.reported[hash]; ok {
+		return false
+	}
+	m.reported[hash] = struct{}{}
+	return true
+}
+
+func (m *IllegalMonitor) isVotesIllegal(first, second *payload.DPOSProposalVote) bool {
+	if !bytes.Equal(first.Signer, second.Signer) {
+		return false
+	}
+	if first.ProposalHash.IsEqual(second.ProposalHash) {
+		return !first.Hash().IsEqual(second.Hash())
+	}
+	return isProposalsIllegal(m.proposals[first.ProposalHash],
+		m.proposals[second.ProposalHash])
+}
+
+func isProposalsIllegal(first, second *payload.ProposalEvidence) bool {
+	if first == nil || second == nil {
+		return false
+	}
+	if first.Proposal.BlockHash.IsEqual(second.Proposal.BlockHash) {
+		return false
+	}
+	if !bytes.Equal(first.Proposal.Sponsor, second.Proposal.Sponsor) ||
+		first.Proposal.ViewOffset != second.Proposal.ViewOffset {
+		return false
+	}
+	return first.BlockHeight == second.BlockHeight
+}
+
+func newIllegalProposals(first, second *payload.ProposalEvidence) *payload.DPOSIllegalProposals {
+	if first.Proposal.Hash().Compare(second.Proposal.Hash()) > 0 {
+		first, second = second, first
+	}
+	return &payload.DPOSIllegalProposals{
+		Evidence:        *first,
+		CompareEvidence: *second,
+	}
+}
+
+func newIllegalVotes(first *payload.DPOSProposalVote, firstProposal *payload.ProposalEvidence,
+	second *payload.DPOSProposalVote, secondProposal *payload.ProposalEvidence) *payload.DPOSIllegalVotes {
+	if first.Hash().Compare(second.Hash()) > 0 {
+		first, second = second, first
+		firstProposal, secondProposal = secondProposal, firstProposal
+	}
+	return &payload.DPOSIllegalVotes{
+		Evidence: payload.VoteEvidence{
+			ProposalEvidence: *firstProposal,
+			Vote:             *first,
+		},
+		CompareEvidence: payload.VoteEvidence{
+			ProposalEvidence: *secondProposal,
+			Vote:             *second,
+		},
+	}
+}
+
+// CheckIllegalProposals checks the evidence proves that the sponsor signed two
+// different blocks of the same height in the same view.
+func CheckIllegalProposals(evidence *payload.DPOSIllegalProposals) error {
+	first, second := &evidence.Evidence, &evidence.CompareEvidence
+	if first.Proposal.Hash().Compare(second.Proposal.Hash()) >= 0 {
+		return errors.New("[CheckIllegalProposals] evidence order error")
+	}
+	if !isProposalsIllegal(first, second) {
+		return errors.New("[CheckIllegalProposals] proposals are not conflicting")
+	}
+	if err := CheckProposal(&first.Proposal); err != nil {
+		return err
+	}
+	return CheckProposal(&second.Proposal)
+}
+
+// CheckIllegalVotes checks the evidence proves that the signer signed two
+// conflicting votes.
+func CheckIllegalVotes(evidence *payload.DPOSIllegalVotes) error {
+	first, second := &evidence.Evidence, &evidence.CompareEvidence
+	if first.Vote.Hash().Compare(second.Vote.Hash()) >= 0 {
+		return errors.New("[CheckIllegalVotes] evidence order error")
+	}
+	if !bytes.Equal(first.Vote.Signer, second.Vote.Signer) {
+		return errors.New("[CheckIllegalVotes] votes have different signers")
+	}
+	if !first.Vote.ProposalHash.IsEqual(first.Proposal.Hash()) ||
+		!second.Vote.ProposalHash.IsEqual(second.Proposal.Hash()) {
+		return errors.New("[CheckIllegalVotes] vote is not match proposal")
+	}
+	if !first.Vote.ProposalHash.IsEqual(second.Vote.ProposalHash) &&
+		!isProposalsIllegal(&first.ProposalEvidence, &second.ProposalEvidence) {
+		return errors.New("[CheckIllegalVotes] votes are not conflicting")
+	}
+	for _, e := range []*payload.VoteEvidence{first, second} {
+		if err := CheckProposal(&e.Proposal); err != nil {
+			return err
+		}
+		if err := CheckVote(&e.Vote); err != nil {
+			return err
+		}
+	}
+	return nil
+}
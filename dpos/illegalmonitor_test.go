@@ -0,0 +1,73 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIllegalMonitor(t *testing.T) {
+	var (
+		illegalProposals *payload.DPOSIllegalProposals
+		illegalVotes     *payload.DPOSIllegalVotes
+	)
+	heights := map[common.Uint256]uint32{}
+	monitor := NewIllegalMonitor(func(sealHash common.Uint256) ([]byte, uint32, bool) {
+		height, ok := heights[sealHash]
+		return sealHash.Bytes(), height, ok
+	}, func(evidence *payload.DPOSIllegalProposals) {
+		illegalProposals = evidence
+	}, func(evidence *payload.DPOSIllegalVotes) {
+		illegalVotes = evidence
+	})
+
+	sponsor, voter := []byte{1}, []byte{2}
+	hash1, hash2, hash3 := common.Uint256{1}, common.Uint256{2}, common.Uint256{3}
+	heights[hash1], heights[hash2], heights[hash3] = 10, 10, 11
+
+	proposal1 := &payload.DPOSProposal{Sponsor: sponsor, BlockHash: hash1}
+	monitor.AddProposal(proposal1)
+
+	// different height and view offset are legal
+	monitor.AddProposal(&payload.DPOSProposal{Sponsor: sponsor, BlockHash: hash3})
+	monitor.AddProposal(&payload.DPOSProposal{Sponsor: sponsor, BlockHash: hash2, ViewOffset: 1})
+	assert.Nil(t, illegalProposals)
+
+	proposal2 := &payload.DPOSProposal{Sponsor: sponsor, BlockHash: hash2}
+	monitor.AddProposal(proposal2)
+	assert.NotNil(t, illegalProposals)
+	assert.True(t, illegalProposals.Evidence.Proposal.Hash().Compare(
+		illegalProposals.CompareEvidence.Proposal.Hash()) < 0)
+	assert.Equal(t, uint32(10), illegalProposals.GetBlockHeight())
+
+	// accept and reject the same proposal
+	accept := &payload.DPOSProposalVote{ProposalHash: proposal1.Hash(), Signer: voter, Accept: true}
+	monitor.AddVote(accept)
+	assert.Nil(t, illegalVotes)
+	monitor.AddVote(&payload.DPOSProposalVote{ProposalHash: proposal1.Hash(), Signer: voter})
+	assert.NotNil(t, illegalVotes)
+
+	// vote for conflicting proposals
+	illegalVotes = nil
+	monitor.AddVote(&payload.DPOSProposalVote{ProposalHash: proposal2.Hash(), Signer: voter, Accept: true})
+	assert.NotNil(t, illegalVotes)
+	assert.Equal(t, voter, illegalVotes.Evidence.Vote.Signer)
+
+	// tampered evidence
+	illegalProposals.CompareEvidence.BlockHeight = 11
+	assert.Error(t, CheckIllegalProposals(illegalProposals))
+	illegalVotes.CompareEvidence.Vote.Signer = sponsor
+	assert.Error(t, CheckIllegalVotes(illegalVotes))
+
+	monitor.Clean(11)
+	assert.Equal(t, 1, len(monitor.proposals))
+	assert.Equal(t, 0, len(monitor.votes))
+}
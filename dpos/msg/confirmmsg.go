@@ -7,6 +7,7 @@ package msg
 
 import (
 	"bytes"
+	"errors"
 	"io"
 
 	"github.com/elastos/Elastos.ELA/common"
@@ -63,4 +64,38 @@ func (msg *ConfirmMsg) Deserialize(r io.Reader) error {
 		return err
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// MaxConfirmVotes is the max count of votes in a confirm whatever the size of
+// the producer set.
+const MaxConfirmVotes = 2048
+
+// minVoteSize is the size of a serialized vote with an empty signer and sign.
+const minVoteSize = 32 + 1 + 1 + 1
+
+// ErrConfirmVoteCount is returned if the vote count of a serialized confirm is
+// more than the producers or the remaining data can hold.
+var ErrConfirmVoteCount = errors.New("confirm vote count is out of range")
+
+// DeserializeConfirm deserializes the confirm like payload.Confirm, but the
+// vote count read from the data is checked against maxVotes and the bytes left
+// in the reader before the votes are allocated.
+func DeserializeConfirm(r *io.LimitedReader, confirm *payload.Confirm, maxVotes int) error {
+	if err := confirm.Proposal.Deserialize(r); err != nil {
+		return err
+	}
+	count, err := common.ReadUint64(r)
+	if err != nil {
+		return err
+	}
+	if count > uint64(maxVotes) || count > uint64(r.N)/minVoteSize {
+		return ErrConfirmVoteCount
+	}
+	confirm.Votes = make([]payload.DPOSProposalVote, count)
+	for i := range confirm.Votes {
+		if err := confirm.Votes[i].Deserialize(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package msg
+
+import (
+	"errors"
+	"io"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	elamsg "github.com/elastos/Elastos.ELA/dpos/p2p/msg"
+	"github.com/elastos/Elastos.ELA/elanet/pact"
+)
+
+// MaxBlocksPerResponse is the maximum number of blocks carried by one
+// ResponseBlocks message.
+const MaxBlocksPerResponse = 32
+
+// MaxResponseBlocksSize is the maximum size of one ResponseBlocks message.
+var MaxResponseBlocksSize = pact.MaxBlockContextSize
+
+// BlockConfirm is a block together with the confirm which finished its
+// consensus, Confirm is nil if the confirm is carried by the block header.
+type BlockConfirm struct {
+	Block   *BlockMsg
+	Confirm *payload.Confirm
+}
+
+func (bc *BlockConfirm) Serialize(w io.Writer) error {
+	if err := bc.Block.Serialize(w); err != nil {
+		return err
+	}
+	if bc.Confirm == nil {
+		return common.WriteUint8(w, 0)
+	}
+	if err := common.WriteUint8(w, 1); err != nil {
+		return err
+	}
+	return bc.Confirm.Serialize(w)
+}
+
+func (bc *BlockConfirm) Deserialize(r io.Reader) error {
+	return bc.deserialize(limitReader(r), MaxConfirmVotes)
+}
+
+func (bc *BlockConfirm) deserialize(r *io.LimitedReader, maxVotes int) error {
+	bc.Block = NewBlockMsg([]byte{})
+	if err := bc.Block.Deserialize(r); err != nil {
+		return err
+	}
+	hasConfirm, err := common.ReadUint8(r)
+	if err != nil {
+		return err
+	}
+	if hasConfirm == 0 {
+		bc.Confirm = nil
+		return nil
+	}
+	bc.Confirm = &payload.Confirm{}
+	return DeserializeConfirm(r, bc.Confirm, maxVotes)
+}
+
+// limitReader limits the reader to the max size of the message, or to the
+// bytes left in it if it knows them, so the counts read from the message can
+// be checked against the data which can still follow.
+func limitReader(r io.Reader) *io.LimitedReader {
+	n := int64(MaxResponseBlocksSize)
+	if l, ok := r.(interface{ Len() int }); ok && int64(l.Len()) < n {
+		n = int64(l.Len())
+	}
+	return &io.LimitedReader{R: r, N: n}
+}
+
+// ResponseBlocks answers a GetBlocks message with the confirmed blocks of the
+// requested height range.
+type ResponseBlocks struct {
+	BlockConfirms []*BlockConfirm
+
+	// MaxVotes is the max count of votes of a confirm, the count of the
+	// producers receiving the message. MaxConfirmVotes is used if it is zero.
+	MaxVotes int
+}
+
+func NewResponseBlocks(blockConfirms []*BlockConfirm) *ResponseBlocks {
+	return &ResponseBlocks{BlockConfirms: blockConfirms}
+}
+
+func (msg *ResponseBlocks) CMD() string {
+	return elamsg.CmdResponseBlocks
+}
+
+func (msg *ResponseBlocks) MaxLength() uint32 {
+	return MaxResponseBlocksSize
+}
+
+func (msg *ResponseBlocks) Serialize(w io.Writer) error {
+	if err := common.WriteVarUint(w, uint64(len(msg.BlockConfirms))); err != nil {
+		return err
+	}
+	for _, bc := range msg.BlockConfirms {
+		if err := bc.Serialize(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (msg *ResponseBlocks) Deserialize(reader io.Reader) error {
+	r := limitReader(reader)
+	maxVotes := msg.MaxVotes
+	if maxVotes <= 0 || maxVotes > MaxConfirmVotes {
+		maxVotes = MaxConfirmVotes
+	}
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > MaxBlocksPerResponse {
+		return errors.New("too many blocks in response blocks message")
+	}
+	msg.BlockConfirms = make([]*BlockConfirm, 0, count)
+	for i := uint64(0); i < count; i++ {
+		bc := &BlockConfirm{}
+		if err := bc.deserialize(r, maxVotes); err != nil {
+			return err
+		}
+		msg.BlockConfirms = append(msg.BlockConfirms, bc)
+	}
+	return nil
+}
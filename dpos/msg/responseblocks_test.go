@@ -0,0 +1,92 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package msg
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseBlocks(t *testing.T) {
+	proposal := payload.DPOSProposal{
+		Sponsor:    randomUint168().Bytes(),
+		BlockHash:  *randomUint256(),
+		ViewOffset: 1,
+		Sign:       []byte{1, 2, 3},
+	}
+	vote := payload.DPOSProposalVote{
+		ProposalHash: proposal.Hash(),
+		Signer:       randomUint168().Bytes(),
+		Accept:       true,
+		Sign:         []byte{4, 5, 6},
+	}
+	confirm := &payload.Confirm{Proposal: proposal, Votes: []payload.DPOSProposalVote{vote}}
+
+	msg := NewResponseBlocks([]*BlockConfirm{
+		{Block: NewBlockMsg([]byte{1, 2, 3})},
+		{Block: NewBlockMsg([]byte{4, 5, 6}), Confirm: confirm},
+	})
+	msgBuffer := new(bytes.Buffer)
+	assert.NoError(t, msg.Serialize(msgBuffer))
+
+	msg2 := ResponseBlocks{}
+	assert.NoError(t, msg2.Deserialize(msgBuffer))
+	assert.Equal(t, 2, len(msg2.BlockConfirms))
+
+	assert.Equal(t, []byte{1, 2, 3}, msg2.BlockConfirms[0].Block.GetData())
+	assert.Nil(t, msg2.BlockConfirms[0].Confirm)
+
+	assert.Equal(t, []byte{4, 5, 6}, msg2.BlockConfirms[1].Block.GetData())
+	assert.NotNil(t, msg2.BlockConfirms[1].Confirm)
+	assert.Equal(t, confirm.Proposal.Hash(), msg2.BlockConfirms[1].Confirm.Proposal.Hash())
+	assert.Equal(t, confirm.Votes[0].Hash(), msg2.BlockConfirms[1].Confirm.Votes[0].Hash())
+
+	// too many blocks in one message
+	blockConfirms := make([]*BlockConfirm, 0, MaxBlocksPerResponse+1)
+	for i := 0; i <= MaxBlocksPerResponse; i++ {
+		blockConfirms = append(blockConfirms, &BlockConfirm{Block: NewBlockMsg([]byte{byte(i)})})
+	}
+	msgBuffer.Reset()
+	assert.NoError(t, NewResponseBlocks(blockConfirms).Serialize(msgBuffer))
+	assert.Error(t, (&ResponseBlocks{}).Deserialize(msgBuffer))
+}
+
+func TestResponseBlocksMaliciousVoteCount(t *testing.T) {
+	proposal := payload.DPOSProposal{
+		Sponsor:    randomUint168().Bytes(),
+		BlockHash:  *randomUint256(),
+		ViewOffset: 1,
+		Sign:       []byte{1, 2, 3},
+	}
+	encode := func(voteCount uint64, votes int) *bytes.Buffer {
+		buf := new(bytes.Buffer)
+		assert.NoError(t, common.WriteVarUint(buf, 1))
+		assert.NoError(t, NewBlockMsg([]byte{1, 2, 3}).Serialize(buf))
+		assert.NoError(t, common.WriteUint8(buf, 1))
+		assert.NoError(t, proposal.Serialize(buf))
+		assert.NoError(t, common.WriteUint64(buf, voteCount))
+		for i := 0; i < votes; i++ {
+			vote := payload.DPOSProposalVote{ProposalHash: proposal.Hash(), Signer: randomUint168().Bytes(), Accept: true, Sign: []byte{4, 5, 6}}
+			assert.NoError(t, vote.Serialize(buf))
+		}
+		return buf
+	}
+
+	// a count the remaining data can't hold is rejected before the votes are allocated
+	assert.Equal(t, ErrConfirmVoteCount, (&ResponseBlocks{}).Deserialize(encode(math.MaxUint64, 0)))
+	assert.Equal(t, ErrConfirmVoteCount, (&ResponseBlocks{}).Deserialize(encode(1000, 2)))
+
+	// a count over the producers is rejected
+	assert.Equal(t, ErrConfirmVoteCount, (&ResponseBlocks{MaxVotes: 2}).Deserialize(encode(3, 3)))
+	msg := &ResponseBlocks{MaxVotes: 3}
+	assert.NoError(t, msg.Deserialize(encode(3, 3)))
+	assert.Equal(t, 3, len(msg.BlockConfirms[0].Confirm.Votes))
+}
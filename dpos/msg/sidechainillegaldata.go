@@ -0,0 +1,70 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package msg
+
+import (
+	"errors"
+	"io"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+	elamsg "github.com/elastos/Elastos.ELA/dpos/p2p/msg"
+	"github.com/elastos/Elastos.ELA/elanet/pact"
+)
+
+// ErrEvidenceSignCount is returned if the sign count of a serialized evidence
+// is more than the producers or the remaining data can hold.
+var ErrEvidenceSignCount = errors.New("evidence sign count is out of range")
+
+// SidechainIllegalData carries the main chain evidence of an illegal producer
+// together with the signs of the producers collected so far.
+type SidechainIllegalData struct {
+	Data payload.SidechainIllegalData
+
+	// MaxSigns is the max count of signs of the evidence, the count of the
+	// producers receiving the message. MaxConfirmVotes is used if it is zero.
+	MaxSigns int
+}
+
+func (msg *SidechainIllegalData) CMD() string {
+	return elamsg.CmdSidechainIllegalData
+}
+
+func (msg *SidechainIllegalData) MaxLength() uint32 {
+	return pact.MaxBlockContextSize
+}
+
+func (msg *SidechainIllegalData) Serialize(w io.Writer) error {
+	return msg.Data.Serialize(w, payload.SidechainIllegalDataVersion)
+}
+
+// Deserialize deserializes the evidence like payload.SidechainIllegalData, but
+// the sign count read from the data is checked before the signs are allocated.
+func (msg *SidechainIllegalData) Deserialize(reader io.Reader) error {
+	r := limitReader(reader)
+	maxSigns := msg.MaxSigns
+	if maxSigns <= 0 || maxSigns > MaxConfirmVotes {
+		maxSigns = MaxConfirmVotes
+	}
+	if err := msg.Data.DeserializeUnsigned(r, payload.SidechainIllegalDataVersion); err != nil {
+		return err
+	}
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > uint64(maxSigns) || count > uint64(r.N) {
+		return ErrEvidenceSignCount
+	}
+	msg.Data.Signs = make([][]byte, count)
+	for i := range msg.Data.Signs {
+		if msg.Data.Signs[i], err = common.ReadVarBytes(r, crypto.SignatureLength, "Signature"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package msg
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSidechainIllegalData(t *testing.T) {
+	data := payload.SidechainIllegalData{
+		IllegalType:         payload.SidechainIllegalProposal,
+		Height:              10,
+		IllegalSigner:       []byte{1, 2, 3},
+		Evidence:            payload.SidechainIllegalEvidence{DataHash: *randomUint256()},
+		CompareEvidence:     payload.SidechainIllegalEvidence{DataHash: *randomUint256()},
+		GenesisBlockAddress: "XKUh4GLhFJiqAMTF6HyWQrV9pK9HcGUdfJ",
+		Signs:               [][]byte{{4, 5, 6}, {7, 8, 9}},
+	}
+	msgBuffer := new(bytes.Buffer)
+	assert.NoError(t, (&SidechainIllegalData{Data: data}).Serialize(msgBuffer))
+	encoded := msgBuffer.Bytes()
+
+	msg := SidechainIllegalData{}
+	assert.NoError(t, msg.Deserialize(bytes.NewBuffer(encoded)))
+	assert.Equal(t, data.Hash(), msg.Data.Hash())
+	assert.Equal(t, data.Signs, msg.Data.Signs)
+
+	// the sign count is more than the producers
+	msg = SidechainIllegalData{MaxSigns: 1}
+	assert.Equal(t, ErrEvidenceSignCount, msg.Deserialize(bytes.NewBuffer(encoded)))
+
+	// the sign count is more than the data can hold
+	unsigned := new(bytes.Buffer)
+	assert.NoError(t, data.SerializeUnsigned(unsigned, payload.SidechainIllegalDataVersion))
+	assert.NoError(t, common.WriteVarUint(unsigned, math.MaxUint64))
+	msg = SidechainIllegalData{}
+	assert.Equal(t, ErrEvidenceSignCount, msg.Deserialize(unsigned))
+}
@@ -8,6 +8,7 @@ package dpos
 import (
 	"bytes"
 	"errors"
+	"time"
 	dmsg "github.com/elastos/Elastos.ELA.SideChain.ETH/dpos/msg"
 
 	"github.com/elastos/Elastos.ELA/common"
@@ -35,6 +36,18 @@ type NetworkConfig struct {
 	ProposalDispatcher *Dispatcher
 	PublicKey          []byte
 	AnnounceAddr       func()
+
+	// IsOnDuty reports if the producer of the public key is on duty at the
+	// view offset, the proposals of the other producers are penalized.
+	IsOnDuty func(pubKey []byte, viewOffset uint32) bool
+
+	// ViewOffset returns the local view offset, the proposals of a view
+	// ahead of it are not penalized.
+	ViewOffset func() uint32
+
+	// ProducersCount returns the count of the producers, a confirm received
+	// from the network holds at most one vote of each producer.
+	ProducersCount func() int
 }
 
 type DPOSNetwork interface {
@@ -48,6 +61,12 @@ type DPOSNetwork interface {
 
 	UpdatePeers(peers []dpeer.PID)
 	GetActivePeers() []p2p.Peer
+	AddDirectLinkAddr(pid dpeer.PID, addr string)
+	DumpPeersInfo() []*p2p.PeerInfo
+	PeerScore(pid dpeer.PID) PeerScoreInfo
+
+	PostChangeViewTask()
+	PostRecoverTask()
 }
 
 type StatusSyncEventListener interface {
@@ -57,12 +76,13 @@ type StatusSyncEventListener interface {
 	OnInv(id dpeer.PID, blockHash common.Uint256)
 	OnGetBlock(id dpeer.PID, blockHash common.Uint256)
 	OnGetBlocks(id dpeer.PID, startBlockHeight, endBlockHeight uint32)
-	OnResponseBlocks(id dpeer.PID, blockConfirms []*dmsg.BlockMsg)
+	OnResponseBlocks(id dpeer.PID, blockConfirms []*dmsg.BlockConfirm)
 	OnRequestConsensus(id dpeer.PID, height uint64)
 	OnResponseConsensus(id dpeer.PID, status *msg.ConsensusStatus)
 	OnRequestProposal(id dpeer.PID, hash common.Uint256)
 	OnIllegalProposalReceived(id dpeer.PID, proposals *payload.DPOSIllegalProposals)
 	OnIllegalVotesReceived(id dpeer.PID, votes *payload.DPOSIllegalVotes)
+	OnSidechainIllegalDataReceived(id dpeer.PID, data *payload.SidechainIllegalData)
 }
 
 type NetworkEventListener interface {
@@ -89,6 +109,10 @@ type Network struct {
 	listener           NetworkEventListener
 	publicKey          []byte
 	announceAddr       func()
+	isOnDuty           func(pubKey []byte, viewOffset uint32) bool
+	viewOffset         func() uint32
+	producersCount     func() int
+	scores             *PeerScores
 
 	p2pServer    p2p.Server
 	messageQueue chan *messageItem
@@ -150,87 +174,139 @@ func (n *Network) PostRecoverTask() {
 }
 
 func (n *Network) processMessage(msgItem *messageItem) {
-	m := msgItem.Message
+	if !n.scores.Allow(msgItem.ID, msgItem.Message.CMD()) {
+		return
+	}
+	if !n.checkMessage(msgItem.ID, msgItem.Message) {
+		return
+	}
+	DispatchMessage(n.listener, msgItem.ID, msgItem.Message)
+}
+
+// checkMessage penalizes the peer for an invalid proposal or vote, it
+// returns false if the message should be dropped.
+func (n *Network) checkMessage(id peer.PID, m elap2p.Message) bool {
+	switch m := m.(type) {
+	case *msg.Proposal:
+		if err := CheckProposal(&m.Proposal); err != nil {
+			n.scores.Penalize(id, PenaltyInvalidSignature, "invalid proposal: "+err.Error())
+			return false
+		}
+		// the proposal is still handled, off duty proposals trigger the
+		// recovering of the view
+		if !n.isOnDutyProposal(&m.Proposal) {
+			n.scores.Penalize(id, PenaltyOffDutyProposal, "proposal of off duty producer")
+		}
+	case *msg.Vote:
+		if err := CheckVote(&m.Vote); err != nil {
+			n.scores.Penalize(id, PenaltyInvalidSignature, "invalid vote: "+err.Error())
+			return false
+		}
+	}
+	return true
+}
+
+// isOnDutyProposal reports if the sponsor of the proposal is on duty at the
+// view offset of the proposal, the proposals of a view ahead of the local one
+// are taken as on duty since the producers of that view may not be known yet.
+func (n *Network) isOnDutyProposal(proposal *payload.DPOSProposal) bool {
+	if n.isOnDuty == nil {
+		return true
+	}
+	if n.viewOffset != nil && proposal.ViewOffset > n.viewOffset() {
+		return true
+	}
+	return n.isOnDuty(proposal.Sponsor, proposal.ViewOffset)
+}
+
+// DispatchMessage passes the message received from the peer to the handler
+// of the listener.
+func DispatchMessage(listener NetworkEventListener, id peer.PID, m elap2p.Message) {
 	switch m.CMD() {
 	case msg.CmdReceivedProposal:
 		msgProposal, processed := m.(*msg.Proposal)
 		if processed {
-			n.listener.OnProposalReceived(msgItem.ID, &msgProposal.Proposal)
+			listener.OnProposalReceived(id, &msgProposal.Proposal)
 		}
 	case msg.CmdAcceptVote:
 		msgVote, processed := m.(*msg.Vote)
 		if processed {
-			n.listener.OnVoteAccepted(msgItem.ID, &msgVote.Vote)
+			listener.OnVoteAccepted(id, &msgVote.Vote)
 		}
 	case msg.CmdRejectVote:
 		msgVote, processed := m.(*msg.Vote)
 		if processed {
-			n.listener.OnVoteRejected(msgItem.ID, &msgVote.Vote)
+			listener.OnVoteRejected(id, &msgVote.Vote)
 		}
 	case msg.CmdPing:
 		msgPing, processed := m.(*msg.Ping)
 		if processed {
-			n.listener.OnPing(msgItem.ID, uint32(msgPing.Nonce))
+			listener.OnPing(id, uint32(msgPing.Nonce))
 		}
 	case msg.CmdPong:
 		msgPong, processed := m.(*msg.Pong)
 		if processed {
-			n.listener.OnPong(msgItem.ID, uint32(msgPong.Nonce))
+			listener.OnPong(id, uint32(msgPong.Nonce))
 		}
 	case elap2p.CmdBlock:
 		blockMsg, processed := m.(*dmsg.BlockMsg)
 		if processed {
-			n.listener.OnBlock(msgItem.ID, blockMsg)
+			listener.OnBlock(id, blockMsg)
 		}
 	case msg.CmdInv:
 		msgInv, processed := m.(*msg.Inventory)
 		if processed {
-			n.listener.OnInv(msgItem.ID, msgInv.BlockHash)
+			listener.OnInv(id, msgInv.BlockHash)
 		}
 	case msg.CmdGetBlock:
 		msgGetBlock, processed := m.(*msg.GetBlock)
 		if processed {
-			n.listener.OnGetBlock(msgItem.ID, msgGetBlock.BlockHash)
+			listener.OnGetBlock(id, msgGetBlock.BlockHash)
 		}
 	case msg.CmdGetBlocks:
 		msgGetBlocks, processed := m.(*msg.GetBlocks)
 		if processed {
-			n.listener.OnGetBlocks(msgItem.ID, msgGetBlocks.StartBlockHeight, msgGetBlocks.EndBlockHeight)
+			listener.OnGetBlocks(id, msgGetBlocks.StartBlockHeight, msgGetBlocks.EndBlockHeight)
 		}
 	case msg.CmdResponseBlocks:
-		//msgResponseBlocks, processed := m.(*dmsg.BlockMsg)
-		//if processed {
-			//n.listener.OnResponseBlocks(msgItem.ID, msgResponseBlocks)
-		//}
+		msgResponseBlocks, processed := m.(*dmsg.ResponseBlocks)
+		if processed {
+			listener.OnResponseBlocks(id, msgResponseBlocks.BlockConfirms)
+		}
 	case msg.CmdRequestConsensus:
 		msgRequestConsensus, processed := m.(*dmsg.RequestConsensus)
 		if processed {
-			n.listener.OnRequestConsensus(msgItem.ID, msgRequestConsensus.Height)
+			listener.OnRequestConsensus(id, msgRequestConsensus.Height)
 		}
 	case msg.CmdResponseConsensus:
 		msgResponseConsensus, processed := m.(*msg.ResponseConsensus)
 		if processed {
-			n.listener.OnResponseConsensus(msgItem.ID, &msgResponseConsensus.Consensus)
+			listener.OnResponseConsensus(id, &msgResponseConsensus.Consensus)
 		}
 	case msg.CmdRequestProposal:
 		msgRequestProposal, processed := m.(*msg.RequestProposal)
 		if processed {
-			n.listener.OnRequestProposal(msgItem.ID, msgRequestProposal.ProposalHash)
+			listener.OnRequestProposal(id, msgRequestProposal.ProposalHash)
 		}
 	case msg.CmdIllegalProposals:
 		msgIllegalProposals, processed := m.(*msg.IllegalProposals)
 		if processed {
-			n.listener.OnIllegalProposalReceived(msgItem.ID, &msgIllegalProposals.Proposals)
+			listener.OnIllegalProposalReceived(id, &msgIllegalProposals.Proposals)
 		}
 	case msg.CmdIllegalVotes:
 		msgIllegalVotes, processed := m.(*msg.IllegalVotes)
 		if processed {
-			n.listener.OnIllegalVotesReceived(msgItem.ID, &msgIllegalVotes.Votes)
+			listener.OnIllegalVotesReceived(id, &msgIllegalVotes.Votes)
+		}
+	case msg.CmdSidechainIllegalData:
+		msgSidechainIllegalData, processed := m.(*dmsg.SidechainIllegalData)
+		if processed {
+			listener.OnSidechainIllegalDataReceived(id, &msgSidechainIllegalData.Data)
 		}
 	case dmsg.CmdConfirm:
 		msgConfirm, processed := m.(*dmsg.ConfirmMsg)
 		if processed {
-			n.listener.OnConfirmReceived(msgItem.ID, msgConfirm.Confirm, msgConfirm.Height)
+			listener.OnConfirmReceived(id, msgConfirm.Confirm, msgConfirm.Height)
 		}
 	}
 }
@@ -287,11 +363,20 @@ func (s *Network) DumpPeersInfo() []*p2p.PeerInfo {
 	return s.p2pServer.DumpPeersInfo()
 }
 
+// PeerScore returns the message and penalty score of the peer.
+func (s *Network) PeerScore(pid peer.PID) PeerScoreInfo {
+	return s.scores.Info(pid)
+}
+
 func NewNetwork(cfg *NetworkConfig) (*Network, error) {
 	network := &Network{
 		listener:           cfg.Listener,
 		publicKey:          cfg.PublicKey,
 		announceAddr:       cfg.AnnounceAddr,
+		isOnDuty:           cfg.IsOnDuty,
+		viewOffset:         cfg.ViewOffset,
+		producersCount:     cfg.ProducersCount,
+		scores:             NewPeerScores(time.Now),
 
 		messageQueue:       make(chan *messageItem, 10000),
 		quit:               make(chan bool),
@@ -312,7 +397,7 @@ func NewNetwork(cfg *NetworkConfig) (*Network, error) {
 		MagicNumber:      cfg.Magic,
 		DefaultPort:      cfg.DefaultPort,
 		TimeSource:       cfg.MedianTime,
-		MakeEmptyMessage: makeEmptyMessage,
+		MakeEmptyMessage: network.makeEmptyMessage,
 		HandleMessage:    network.handleMessage,
 		PingNonce:        network.GetCurrentHeight,
 		PongNonce:        network.GetCurrentHeight,
@@ -327,7 +412,24 @@ func NewNetwork(cfg *NetworkConfig) (*Network, error) {
 	return network, nil
 }
 
-func makeEmptyMessage(cmd string) (message elap2p.Message, err error) {
+// makeEmptyMessage creates an empty message of the command, the votes of the
+// confirms and the signs of the evidences in the message are bounded by the
+// producers count.
+func (n *Network) makeEmptyMessage(cmd string) (elap2p.Message, error) {
+	if n.producersCount != nil {
+		switch cmd {
+		case msg.CmdResponseBlocks:
+			return &dmsg.ResponseBlocks{MaxVotes: n.producersCount()}, nil
+		case msg.CmdSidechainIllegalData:
+			return &dmsg.SidechainIllegalData{MaxSigns: n.producersCount()}, nil
+		}
+	}
+	return MakeEmptyMessage(cmd)
+}
+
+// MakeEmptyMessage creates an empty message of the command to deserialize the
+// message received from the network into.
+func MakeEmptyMessage(cmd string) (message elap2p.Message, err error) {
 	switch cmd {
 	case elap2p.CmdBlock:
 		message = dmsg.NewBlockMsg([]byte{})
@@ -344,7 +446,7 @@ func makeEmptyMessage(cmd string) (message elap2p.Message, err error) {
 	case msg.CmdGetBlocks:
 		message = &msg.GetBlocks{}
 	case msg.CmdResponseBlocks:
-		message = &msg.ResponseBlocks{}
+		message = &dmsg.ResponseBlocks{}
 	case msg.CmdRequestConsensus:
 		message = &dmsg.RequestConsensus{}
 	case msg.CmdResponseConsensus:
@@ -356,7 +458,7 @@ func makeEmptyMessage(cmd string) (message elap2p.Message, err error) {
 	case msg.CmdIllegalVotes:
 		message = &msg.IllegalVotes{}
 	case msg.CmdSidechainIllegalData:
-		message = &msg.SidechainIllegalData{}
+		message = &dmsg.SidechainIllegalData{}
 	case msg.CmdResponseInactiveArbitrators:
 		message = &msg.ResponseInactiveArbitrators{}
 	case dmsg.CmdConfirm:
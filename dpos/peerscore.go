@@ -0,0 +1,207 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/dpos/p2p/msg"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
+	elap2p "github.com/elastos/Elastos.ELA/p2p"
+)
+
+const (
+	// scoreWindow is the interval the message budgets of a peer are counted
+	// in, the penalty score of the peer is halved after every window.
+	scoreWindow = 10 * time.Second
+
+	// defaultMessageBudget is the budget of the commands which are not
+	// listed in messageBudgets.
+	defaultMessageBudget = 50
+
+	// banThreshold is the penalty score which gets a peer banned.
+	banThreshold = 100
+
+	// banDuration is how long the messages of a banned peer are dropped.
+	banDuration = 10 * time.Minute
+)
+
+const (
+	// PenaltyOverBudget is given for every message over the budget.
+	PenaltyOverBudget = 2
+
+	// PenaltyOffDutyProposal is given for a proposal of a producer which is
+	// not on duty, a peer behind on the view may send a few of them.
+	PenaltyOffDutyProposal = 10
+
+	// PenaltyInvalidSignature is given for a proposal or vote with an
+	// invalid signature.
+	PenaltyInvalidSignature = 50
+)
+
+// messageBudgets is the max count of messages of each command a peer can
+// send in a score window.
+var messageBudgets = map[string]int{
+	msg.CmdReceivedProposal:  20,
+	msg.CmdAcceptVote:        200,
+	msg.CmdRejectVote:        200,
+	msg.CmdPing:              20,
+	msg.CmdPong:              20,
+	elap2p.CmdBlock:          40,
+	msg.CmdInv:               100,
+	msg.CmdGetBlock:          50,
+	msg.CmdGetBlocks:         20,
+	msg.CmdResponseBlocks:    20,
+	msg.CmdRequestConsensus:  10,
+	msg.CmdResponseConsensus: 20,
+	msg.CmdRequestProposal:   50,
+}
+
+// PeerScoreInfo is the score of a peer.
+type PeerScoreInfo struct {
+	Score     int       `json:"score"`
+	Dropped   uint64    `json:"dropped"`
+	Banned    bool      `json:"banned"`
+	BanExpiry time.Time `json:"banexpiry"`
+}
+
+type peerScore struct {
+	score       int
+	dropped     uint64
+	banExpiry   time.Time
+	windowStart time.Time
+	counts      map[string]int
+}
+
+// PeerScores counts the messages and penalties of the arbiter peers, a peer
+// over its message budget or with a penalty score over the threshold gets its
+// messages dropped.
+type PeerScores struct {
+	peers     map[peer.PID]*peerScore
+	now       func() time.Time
+	lastPrune time.Time
+
+	mu sync.Mutex
+}
+
+// NewPeerScores creates the scores, now returns the current time.
+func NewPeerScores(now func() time.Time) *PeerScores {
+	return &PeerScores{
+		peers:     make(map[peer.PID]*peerScore),
+		now:       now,
+		lastPrune: now(),
+	}
+}
+
+// peer returns the score of the pid with the expired window and ban reset.
+func (s *PeerScores) peer(pid peer.PID, now time.Time) *peerScore {
+	p, ok := s.peers[pid]
+	if !ok {
+		p = &peerScore{windowStart: now, counts: make(map[string]int)}
+		s.peers[pid] = p
+	}
+	p.expire(now)
+	return p
+}
+
+// expire resets the expired ban and windows of the score.
+func (p *peerScore) expire(now time.Time) {
+	if !p.banExpiry.IsZero() && !now.Before(p.banExpiry) {
+		p.banExpiry = time.Time{}
+		p.score = 0
+	}
+	for now.Sub(p.windowStart) >= scoreWindow {
+		p.windowStart = p.windowStart.Add(scoreWindow)
+		p.counts = make(map[string]int)
+		p.score /= 2
+		if p.score == 0 {
+			p.windowStart = now
+		}
+	}
+}
+
+// prune removes the scores of the peers which have not been banned or
+// penalized and sent no message in the current window, at most once a window.
+func (s *PeerScores) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < scoreWindow {
+		return
+	}
+	s.lastPrune = now
+	for pid, p := range s.peers {
+		p.expire(now)
+		if p.score == 0 && p.banExpiry.IsZero() && len(p.counts) == 0 {
+			delete(s.peers, pid)
+		}
+	}
+}
+
+func (s *PeerScores) penalize(pid peer.PID, p *peerScore, penalty int, reason string, now time.Time) {
+	p.score += penalty
+	if p.banExpiry.IsZero() && p.score >= banThreshold {
+		p.banExpiry = now.Add(banDuration)
+		Warn("[PeerScores] ban peer", pid.String(), "reason:", reason, "score:", p.score)
+	}
+}
+
+// Allow counts the message of the command and reports if it should be
+// handled.
+func (s *PeerScores) Allow(pid peer.PID, cmd string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.prune(now)
+	p := s.peer(pid, now)
+	if !p.banExpiry.IsZero() {
+		p.dropped++
+		return false
+	}
+	budget, ok := messageBudgets[cmd]
+	if !ok {
+		budget = defaultMessageBudget
+	}
+	p.counts[cmd]++
+	if p.counts[cmd] > budget {
+		p.dropped++
+		s.penalize(pid, p, PenaltyOverBudget, "over budget of "+cmd, now)
+		return false
+	}
+	return true
+}
+
+// Penalize adds the penalty to the score of the peer, the peer is banned if
+// the score crosses the threshold.
+func (s *PeerScores) Penalize(pid peer.PID, penalty int, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	Info("[PeerScores] penalize peer", pid.String(), "penalty:", penalty, "reason:", reason)
+	s.penalize(pid, s.peer(pid, now), penalty, reason, now)
+}
+
+// IsBanned reports if the messages of the peer are dropped.
+func (s *PeerScores) IsBanned(pid peer.PID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.peer(pid, s.now()).banExpiry.IsZero()
+}
+
+// Info returns the score of the peer.
+func (s *PeerScores) Info(pid peer.PID) PeerScoreInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.peer(pid, s.now())
+	return PeerScoreInfo{
+		Score:     p.score,
+		Dropped:   p.dropped,
+		Banned:    !p.banExpiry.IsZero(),
+		BanExpiry: p.banExpiry,
+	}
+}
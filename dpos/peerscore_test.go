@@ -0,0 +1,147 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/msg"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestPeerScoresBudget(t *testing.T) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	scores := NewPeerScores(clock.Now)
+	pid := peer.PID{1}
+
+	budget := messageBudgets[msg.CmdReceivedProposal]
+	for i := 0; i < budget; i++ {
+		assert.True(t, scores.Allow(pid, msg.CmdReceivedProposal))
+	}
+	assert.False(t, scores.Allow(pid, msg.CmdReceivedProposal))
+	// other commands have their own budget
+	assert.True(t, scores.Allow(pid, msg.CmdAcceptVote))
+	assert.True(t, scores.Allow(peer.PID{2}, msg.CmdReceivedProposal))
+
+	info := scores.Info(pid)
+	assert.Equal(t, PenaltyOverBudget, info.Score)
+	assert.Equal(t, uint64(1), info.Dropped)
+	assert.False(t, info.Banned)
+
+	// the budget is restored in the next window and the score decays
+	clock.now = clock.now.Add(scoreWindow)
+	assert.True(t, scores.Allow(pid, msg.CmdReceivedProposal))
+	assert.Equal(t, PenaltyOverBudget/2, scores.Info(pid).Score)
+}
+
+func TestPeerScoresBan(t *testing.T) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	scores := NewPeerScores(clock.Now)
+	pid := peer.PID{1}
+
+	scores.Penalize(pid, PenaltyInvalidSignature, "invalid vote")
+	assert.False(t, scores.IsBanned(pid))
+	scores.Penalize(pid, PenaltyInvalidSignature, "invalid vote")
+	assert.True(t, scores.IsBanned(pid))
+	assert.False(t, scores.Allow(pid, msg.CmdPing))
+	assert.False(t, scores.IsBanned(peer.PID{2}))
+
+	info := scores.Info(pid)
+	assert.True(t, info.Banned)
+	assert.Equal(t, clock.now.Add(banDuration), info.BanExpiry)
+	assert.Equal(t, uint64(1), info.Dropped)
+
+	clock.now = clock.now.Add(banDuration)
+	assert.False(t, scores.IsBanned(pid))
+	assert.True(t, scores.Allow(pid, msg.CmdPing))
+	assert.Equal(t, 0, scores.Info(pid).Score)
+
+	// flooding a command gets the peer banned
+	for i := 0; !scores.IsBanned(pid); i++ {
+		scores.Allow(pid, msg.CmdInv)
+		if i > messageBudgets[msg.CmdInv]+banThreshold {
+			t.Fatal("flooding peer is not banned")
+		}
+	}
+}
+
+func TestNetworkCheckMessage(t *testing.T) {
+	key := newTestKey(t)
+	onDuty := true
+	n := &Network{
+		isOnDuty: func(pubKey []byte, viewOffset uint32) bool {
+			return onDuty && viewOffset == 0
+		},
+		viewOffset: func() uint32 { return 1 },
+		scores:     NewPeerScores(time.Now),
+	}
+	pid := peer.PID{1}
+
+	proposal := payload.DPOSProposal{Sponsor: key.pubKey, BlockHash: common.Uint256{1}}
+	proposal.Sign = key.sign(proposal.Data())
+	assert.True(t, n.checkMessage(pid, &msg.Proposal{Proposal: proposal}))
+	assert.Equal(t, 0, n.PeerScore(pid).Score)
+
+	// the proposal of a view behind the local one is checked at its own view
+	proposal.ViewOffset = 1
+	proposal.Sign = key.sign(proposal.Data())
+	assert.True(t, n.checkMessage(pid, &msg.Proposal{Proposal: proposal}))
+	assert.Equal(t, PenaltyOffDutyProposal, n.PeerScore(pid).Score)
+
+	// the proposal of a view ahead of the local one is not penalized
+	onDuty = false
+	proposal.ViewOffset = 2
+	proposal.Sign = key.sign(proposal.Data())
+	assert.True(t, n.checkMessage(pid, &msg.Proposal{Proposal: proposal}))
+	assert.Equal(t, PenaltyOffDutyProposal, n.PeerScore(pid).Score)
+
+	vote := payload.DPOSProposalVote{ProposalHash: proposal.Hash(), Signer: key.pubKey, Accept: true}
+	vote.Sign = key.sign(proposal.Data())
+	assert.False(t, n.checkMessage(pid, &msg.Vote{Command: msg.CmdAcceptVote, Vote: vote}))
+	assert.Equal(t, PenaltyOffDutyProposal+PenaltyInvalidSignature, n.PeerScore(pid).Score)
+
+	vote.Sign = key.sign(vote.Data())
+	assert.True(t, n.checkMessage(pid, &msg.Vote{Command: msg.CmdAcceptVote, Vote: vote}))
+}
+
+func TestPeerScoresPrune(t *testing.T) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	scores := NewPeerScores(clock.Now)
+	idle, penalized, active := peer.PID{1}, peer.PID{2}, peer.PID{3}
+
+	assert.True(t, scores.Allow(idle, msg.CmdPing))
+	scores.Penalize(penalized, PenaltyOffDutyProposal, "proposal of off duty producer")
+	assert.Len(t, scores.peers, 2)
+
+	// the idle peer is removed once its window expired, the penalty score of
+	// the other peer is not decayed to zero yet
+	clock.now = clock.now.Add(scoreWindow)
+	assert.True(t, scores.Allow(active, msg.CmdPing))
+	assert.Len(t, scores.peers, 2)
+	assert.NotContains(t, scores.peers, idle)
+	assert.Equal(t, PenaltyOffDutyProposal/2, scores.Info(penalized).Score)
+
+	// the active peer is kept while it sends messages
+	for i := 0; i < 4; i++ {
+		clock.now = clock.now.Add(scoreWindow)
+		assert.True(t, scores.Allow(active, msg.CmdPing))
+	}
+	assert.Len(t, scores.peers, 1)
+	assert.Contains(t, scores.peers, active)
+}
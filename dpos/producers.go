@@ -35,6 +35,20 @@ func (p *Producers) UpdateProducers(producers [][]byte) error {
 	return nil
 }
 
+// ChangeProducers replaces the producer set and restarts the duty rotation
+// from the given height, so the first producer of the new set is on duty at
+// startHeight.
+func (p *Producers) ChangeProducers(producers [][]byte, startHeight uint64) error {
+	if err := p.UpdateProducers(producers); err != nil {
+		return err
+	}
+	p.mtx.Lock()
+	p.startHeight = startHeight
+	p.dutyIndex = 0
+	p.mtx.Unlock()
+	return nil
+}
+
 func (p *Producers) UpdateDutyIndex(height uint64) uint32 {
 	p.mtx.Lock()
 	index := (height + 1 - p.startHeight) % uint64(len(p.producers))
@@ -91,6 +105,18 @@ func (p *Producers) GetNextOnDutyProducer(offset uint32) []byte {
 	return producer
 }
 
+// GetDutyProducer returns the producer on duty at view offset zero for the
+// block of the given height.
+func (p *Producers) GetDutyProducer(height uint64) []byte {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if len(p.producers) == 0 || height < p.startHeight {
+		return nil
+	}
+	return p.producers[(height-p.startHeight)%uint64(len(p.producers))]
+}
+
 func (p *Producers) IsMajorityAgree(count int) bool {
 	return count > p.GetMajorityCount()
 }
@@ -38,3 +38,27 @@ func TestNewProducers(t *testing.T) {
 	rand.Read(data)
 	assert.False(t,  p.IsProducers(data))
 }
+
+func TestProducers_ChangeProducers(t *testing.T) {
+	p := NewProducers(getRandProducers(), 0)
+	p.UpdateDutyIndex(10)
+
+	signers := getRandProducers()
+	assert.NoError(t, p.ChangeProducers(signers, 100))
+	assert.Equal(t, len(signers), p.GetProducersCount())
+	assert.Equal(t, signers[0], p.GetNextOnDutyProducer(0))
+
+	// the block at height 100 is produced by the first producer of the set
+	assert.Equal(t, uint32(0), p.UpdateDutyIndex(99))
+	assert.Equal(t, uint32(1), p.UpdateDutyIndex(100)%uint32(len(signers)))
+}
+
+func TestProducers_GetDutyProducer(t *testing.T) {
+	signers := getRandProducers()
+	p := NewProducers(signers, 10)
+	assert.Nil(t, p.GetDutyProducer(9))
+	for height := uint64(10); height < 100; height++ {
+		p.UpdateDutyIndex(height - 1)
+		assert.Equal(t, p.GetNextOnDutyProducer(0), p.GetDutyProducer(height))
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"container/list"
 	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -45,8 +46,23 @@ const (
 	// maxKnownAddrs indicates the maximum known DAddrs cached in memory.
 	// The maximum of DAddrs can be calculated as [36(current)+72(candidate)]².
 	maxKnownAddrs = 108 * 110
+
+	// AddrExpiry indicates how long a DAddr is kept after it was announced.
+	AddrExpiry = 24 * time.Hour
 )
 
+// AddrStore persists the verified DAddrs so they survive a restart.
+type AddrStore interface {
+	// WriteAddr stores the serialized DAddr of the hash.
+	WriteAddr(hash common.Uint256, data []byte)
+
+	// DeleteAddr removes the DAddr of the hash.
+	DeleteAddr(hash common.Uint256)
+
+	// ReadAddrs returns every stored DAddr keyed by its hash.
+	ReadAddrs() map[common.Uint256][]byte
+}
+
 // Config defines the parameters to create a Route instance.
 type Config struct {
 	// The PID of this peer if it is an producer.
@@ -69,6 +85,15 @@ type Config struct {
 
 	// OnCipherAddr will be invoked when an address cipher received.
 	OnCipherAddr func(pid peer.PID, cipher []byte)
+
+	// Store persists the known addresses, it is optional.
+	Store AddrStore
+}
+
+// addrKey identifies the DAddr of an arbiter to a receiver.
+type addrKey struct {
+	pid    peer.PID
+	encode peer.PID
 }
 
 // cache stores the requested DAddrs from a peer.
@@ -90,6 +115,10 @@ type peersMsg struct {
 	peers []peer.PID
 }
 
+type pidMsg struct {
+	pid peer.PID
+}
+
 type invMsg struct {
 	peer IPeer
 	msg  *msg.Inv
@@ -164,6 +193,14 @@ func (r *Routes) PeersChanged(peers []peer.PID) {
 	r.queue <- peersMsg{peers: peers}
 }
 
+// SetPID replaces the PID of this peer after the producer key is rotated, the
+// address of the new PID is announced.
+func (r *Routes) SetPID(pid []byte) {
+	var m pidMsg
+	copy(m.pid[:], pid)
+	r.queue <- m
+}
+
 // NewPeer notifies the new connected peer.
 func (r *Routes) NewPeer(peer IPeer) {
 	r.queue <- newPeerMsg(peer)
@@ -204,9 +241,80 @@ func (r *Routes) Start() {
 	if !atomic.CompareAndSwapInt32(&r.started, 0, 1) {
 		return
 	}
+	r.loadAddrs()
 	go r.addrHandler()
 }
 
+// loadAddrs restores the known addresses from the store, so the producers
+// can be reached without waiting for their announces.  Expired or replaced
+// addresses are removed from the store.
+func (r *Routes) loadAddrs() {
+	if r.cfg.Store == nil {
+		return
+	}
+	now := time.Now()
+	latest := make(map[addrKey]*msg.DAddr)
+	for hash, data := range r.cfg.Store.ReadAddrs() {
+		m := new(msg.DAddr)
+		if err := m.Deserialize(bytes.NewReader(data)); err != nil ||
+			!m.Hash().IsEqual(hash) || verifyAddrSignature(m) != nil ||
+			now.Sub(m.Timestamp) > AddrExpiry {
+			r.cfg.Store.DeleteAddr(hash)
+			continue
+		}
+		key := addrKey{pid: m.PID, encode: m.Encode}
+		if old, ok := latest[key]; ok {
+			if !old.Timestamp.Before(m.Timestamp) {
+				r.cfg.Store.DeleteAddr(hash)
+				continue
+			}
+			r.cfg.Store.DeleteAddr(old.Hash())
+		}
+		latest[key] = m
+	}
+
+	// Only the newest maxKnownAddrs addresses are kept, the newest one is
+	// the front of the known list like an appended address.
+	addrs := make([]*msg.DAddr, 0, len(latest))
+	for _, m := range latest {
+		addrs = append(addrs, m)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].Timestamp.Before(addrs[j].Timestamp)
+	})
+	if len(addrs) > maxKnownAddrs {
+		for _, m := range addrs[:len(addrs)-maxKnownAddrs] {
+			r.cfg.Store.DeleteAddr(m.Hash())
+		}
+		addrs = addrs[len(addrs)-maxKnownAddrs:]
+	}
+
+	r.addrMtx.Lock()
+	for _, m := range addrs {
+		hash := m.Hash()
+		r.knownAddr[hash] = m
+		r.knownList.PushFront(hash)
+		index, ok := r.addrIndex[m.PID]
+		if !ok {
+			index = make(map[peer.PID]common.Uint256)
+			r.addrIndex[m.PID] = index
+		}
+		index[m.Encode] = hash
+	}
+	r.addrMtx.Unlock()
+	Info("[Routes] restored known addresses:", len(addrs))
+
+	// Connect the producers who announced their address to this producer.
+	if r.cfg.OnCipherAddr == nil {
+		return
+	}
+	for _, m := range addrs {
+		if r.selfPID.Equal(m.Encode) {
+			r.cfg.OnCipherAddr(m.PID, m.Cipher)
+		}
+	}
+}
+
 // Stop quits the syncing address handler.
 func (r *Routes) Stop() {
 	if !atomic.CompareAndSwapInt32(&r.stopped, 0, 1) {
@@ -250,6 +358,10 @@ out:
 
 			case peersMsg:
 				r.handlePeersMsg(state, m.peers)
+
+			case pidMsg:
+				r.selfPID = m.pid
+				go r.AnnounceAddr()
 			}
 
 		// Handle the announce request.
@@ -369,8 +481,11 @@ func (r *Routes) handlePeersMsg(state *state, peers []peer.PID) {
 		}
 
 		r.addrMtx.Lock()
-		for _, pid := range pids {
-			delete(r.knownAddr, pid)
+		for _, hash := range pids {
+			delete(r.knownAddr, hash)
+			if r.cfg.Store != nil {
+				r.cfg.Store.DeleteAddr(hash)
+			}
 		}
 		delete(r.addrIndex, pid)
 		r.addrMtx.Unlock()
@@ -468,6 +583,9 @@ func (r *Routes) appendAddr(m *msg.DAddr) {
 		lru := node.Value.(common.Uint256)
 
 		delete(r.knownAddr, lru)
+		if r.cfg.Store != nil {
+			r.cfg.Store.DeleteAddr(lru)
+		}
 
 		node.Value = hash
 		r.knownList.MoveToFront(node)
@@ -476,6 +594,13 @@ func (r *Routes) appendAddr(m *msg.DAddr) {
 	}
 	r.addrMtx.Unlock()
 
+	if r.cfg.Store != nil {
+		buf := new(bytes.Buffer)
+		if err := m.Serialize(buf); err == nil {
+			r.cfg.Store.WriteAddr(hash, buf.Bytes())
+		}
+	}
+
 	// Relay addr to the P2P network.
 	iv := msg.NewInvVect(msg.InvTypeAddress, &hash)
 	r.cfg.RelayAddr(iv, m)
@@ -548,9 +673,8 @@ func (r *Routes) handleInv(s *state, p IPeer, m *msg.Inv) {
 	}
 }
 
-// verifyDAddr verifies if this is a valid DPOS address message.
-func (r *Routes) verifyDAddr(s *state, m *msg.DAddr) error {
-	// Verify signature of the message.
+// verifyAddrSignature verifies the signature of the DPOS address message.
+func verifyAddrSignature(m *msg.DAddr) error {
 	pubKey, err := crypto.DecodePoint(m.PID[:])
 	if err != nil {
 		return fmt.Errorf("invalid public key")
@@ -559,6 +683,15 @@ func (r *Routes) verifyDAddr(s *state, m *msg.DAddr) error {
 	if err != nil {
 		return fmt.Errorf("invalid signature")
 	}
+	return nil
+}
+
+// verifyDAddr verifies if this is a valid DPOS address message.
+func (r *Routes) verifyDAddr(s *state, m *msg.DAddr) error {
+	// Verify signature of the message.
+	if err := verifyAddrSignature(m); err != nil {
+		return err
+	}
 
 	// Verify timestamp of the message. A DAddr to same arbiter can not be sent
 	// frequently to prevent attack, and a DAddr timestamp must not to far from
@@ -635,4 +768,58 @@ func (r *Routes) QueueInv(p IPeer, m *msg.Inv) {
 			return
 		}
 	}
-}
\ No newline at end of file
+}
+
+// KnownAddrs returns the known DPOS address messages.
+func (r *Routes) KnownAddrs() []*msg.DAddr {
+	r.addrMtx.RLock()
+	defer r.addrMtx.RUnlock()
+
+	addrs := make([]*msg.DAddr, 0, len(r.knownAddr))
+	for _, addr := range r.knownAddr {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// AddAddr appends the DPOS address message to the known addresses after it
+// is verified, it is relayed to the P2P network like a received address.
+func (r *Routes) AddAddr(m *msg.DAddr) error {
+	if err := verifyAddrSignature(m); err != nil {
+		return err
+	}
+	if time.Since(m.Timestamp) > AddrExpiry {
+		return fmt.Errorf("address expired")
+	}
+	r.appendAddr(m)
+
+	if r.selfPID.Equal(m.Encode) && r.cfg.OnCipherAddr != nil {
+		r.cfg.OnCipherAddr(m.PID, m.Cipher)
+	}
+	return nil
+}
+
+// EvictAddr removes the DPOS address message of the hash from the known and
+// stored addresses.
+func (r *Routes) EvictAddr(hash common.Uint256) bool {
+	r.addrMtx.Lock()
+	m, ok := r.knownAddr[hash]
+	if ok {
+		delete(r.knownAddr, hash)
+		if index, exist := r.addrIndex[m.PID]; exist && index[m.Encode].IsEqual(hash) {
+			delete(index, m.Encode)
+		}
+		for e := r.knownList.Front(); e != nil; e = e.Next() {
+			if e.Value.(common.Uint256).IsEqual(hash) {
+				r.knownList.Remove(e)
+				break
+			}
+		}
+	}
+	r.addrMtx.Unlock()
+
+	if ok && r.cfg.Store != nil {
+		r.cfg.Store.DeleteAddr(hash)
+	}
+	return ok
+}
@@ -6,7 +6,9 @@
 package dpos
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"net"
@@ -411,4 +413,100 @@ func TestEventNotify(t *testing.T) {
 
 	go events.Notify(ET_TEST_EVENT, nil)
 	time.Sleep(1 * time.Second)
-}
\ No newline at end of file
+}
+type testAddrStore map[common.Uint256][]byte
+
+func (s testAddrStore) WriteAddr(hash common.Uint256, data []byte) { s[hash] = data }
+
+func (s testAddrStore) DeleteAddr(hash common.Uint256) { delete(s, hash) }
+
+func (s testAddrStore) ReadAddrs() map[common.Uint256][]byte {
+	addrs := make(map[common.Uint256][]byte, len(s))
+	for hash, data := range s {
+		addrs[hash] = data
+	}
+	return addrs
+}
+
+func TestRoutes_AddrStore(t *testing.T) {
+	self, other := newTestKey(t), newTestKey(t)
+	var selfPID dp.PID
+	copy(selfPID[:], self.pubKey)
+	newAddr := func(encode dp.PID, timestamp time.Time) *msg.DAddr {
+		addr := &msg.DAddr{Timestamp: timestamp, Encode: encode, Cipher: []byte("cipher")}
+		copy(addr.PID[:], other.pubKey)
+		addr.Signature = other.sign(addr.Data())
+		return addr
+	}
+
+	store := make(testAddrStore)
+	routes := New(&Config{
+		PID:       self.pubKey,
+		Addr:      "localhost",
+		RelayAddr: func(iv *msg.InvVect, data interface{}) {},
+		Store:     store,
+	})
+	now := time.Now()
+	assert.NoError(t, routes.AddAddr(newAddr(selfPID, now.Add(-time.Hour))))
+	assert.NoError(t, routes.AddAddr(newAddr(selfPID, now)))
+	assert.NoError(t, routes.AddAddr(newAddr(dp.PID{1}, now)))
+	assert.Error(t, routes.AddAddr(newAddr(dp.PID{2}, now.Add(-AddrExpiry-time.Minute))))
+	invalid := newAddr(dp.PID{3}, now)
+	invalid.Cipher = []byte("other")
+	assert.Error(t, routes.AddAddr(invalid))
+	assert.Equal(t, 3, len(store))
+	assert.Equal(t, 3, len(routes.KnownAddrs()))
+
+	evicted := newAddr(dp.PID{4}, now)
+	assert.NoError(t, routes.AddAddr(evicted))
+	assert.True(t, routes.EvictAddr(evicted.Hash()))
+	assert.False(t, routes.EvictAddr(evicted.Hash()))
+	assert.Equal(t, 3, len(store))
+	assert.Equal(t, 3, routes.knownList.Len())
+
+	// the restarted routes restores the latest addresses and connects the
+	// producer announced to itself
+	var connected []dp.PID
+	restarted := New(&Config{
+		PID:          self.pubKey,
+		Addr:         "localhost",
+		OnCipherAddr: func(pid dp.PID, cipher []byte) { connected = append(connected, pid) },
+		Store:        store,
+	})
+	restarted.loadAddrs()
+	assert.Equal(t, 2, len(store))
+	assert.Equal(t, 2, len(restarted.KnownAddrs()))
+	assert.Equal(t, 1, len(connected))
+	assert.True(t, connected[0].Equal(newAddr(selfPID, now).PID))
+	_, ok := restarted.knownAddr[newAddr(selfPID, now).Hash()]
+	assert.True(t, ok)
+	// the restored addresses are indexed for the verification of the announces
+	latest := newAddr(selfPID, now)
+	assert.Equal(t, latest.Hash(), restarted.addrIndex[latest.PID][selfPID])
+	assert.Error(t, restarted.verifyDAddr(nil, newAddr(selfPID, now.Add(-time.Minute))))
+
+	// the restored addresses are capped like the appended ones, the oldest
+	// ones are dropped
+	addrAt := func(i int) *msg.DAddr {
+		var encode dp.PID
+		binary.BigEndian.PutUint32(encode[:], uint32(i))
+		return newAddr(encode, now.Add(time.Duration(i-maxKnownAddrs-1)*time.Second))
+	}
+	store = make(testAddrStore)
+	for i := 0; i < maxKnownAddrs+2; i++ {
+		addr := addrAt(i)
+		buf := new(bytes.Buffer)
+		assert.NoError(t, addr.Serialize(buf))
+		store.WriteAddr(addr.Hash(), buf.Bytes())
+	}
+	capped := New(&Config{PID: self.pubKey, Addr: "localhost", Store: store})
+	capped.loadAddrs()
+	assert.Equal(t, maxKnownAddrs, len(capped.knownAddr))
+	assert.Equal(t, maxKnownAddrs, capped.knownList.Len())
+	assert.Equal(t, maxKnownAddrs, len(store))
+	assert.Equal(t, maxKnownAddrs, len(capped.addrIndex[addrAt(0).PID]))
+	_, ok = capped.knownAddr[addrAt(1).Hash()]
+	assert.False(t, ok)
+	assert.Equal(t, addrAt(2).Hash(), capped.knownList.Back().Value)
+	assert.Equal(t, addrAt(maxKnownAddrs+1).Hash(), capped.knownList.Front().Value)
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+	daccount "github.com/elastos/Elastos.ELA/dpos/account"
+)
+
+const (
+	// MimetypeDposProposal is the content type of a proposal sent to an
+	// external signer.
+	MimetypeDposProposal = "application/x-ela-dpos-proposal"
+
+	// MimetypeDposVote is the content type of a vote sent to an external
+	// signer.
+	MimetypeDposVote = "application/x-ela-dpos-vote"
+
+	// MimetypeDposData is the content type of the other data signed by the
+	// producer key, such as DAddr messages and illegal evidences.
+	MimetypeDposData = "application/x-ela-dpos-data"
+)
+
+var (
+	// errInvalidSignerURL is returned if the signer url has an unknown scheme.
+	errInvalidSignerURL = errors.New("invalid signer url")
+
+	// errInvalidSignature is returned if a signer returns a signature which
+	// can not be verified by its public key.
+	errInvalidSignature = errors.New("invalid signature from signer")
+
+	// errSignTxNotSupported is returned by SignerAccount.SignTx, the producer
+	// key does not sign main chain transactions on the side chain.
+	errSignTxNotSupported = errors.New("producer signer does not sign transactions")
+)
+
+// Signer holds the producer key and signs the consensus messages with it, an
+// external implementation keeps the key out of the node's memory.
+type Signer interface {
+	// PublicKeyBytes returns the compressed public key of the producer.
+	PublicKeyBytes() []byte
+
+	// SignProposal signs the proposal of a block.
+	SignProposal(proposal *payload.DPOSProposal) ([]byte, error)
+
+	// SignVote signs the vote of a proposal.
+	SignVote(vote *payload.DPOSProposalVote) ([]byte, error)
+
+	// Sign signs other data like DAddr messages and illegal evidences.
+	Sign(data []byte) ([]byte, error)
+
+	// DecryptAddr decrypts the DAddr cipher sent to the producer.
+	DecryptAddr(cipher []byte) (string, error)
+}
+
+// OpenSigner opens the signer described by the url:
+//
+//   ""                      the keystore file with the password
+//   keystore:<path>         the keystore file at path with the password
+//   clef:<endpoint>         the dpos namespace of a clef instance
+//   hsm:<endpoint>#<key>    the key of an hsm daemon listening on endpoint
+func OpenSigner(url string, keystore string, password []byte) (Signer, error) {
+	if url == "" {
+		return NewKeystoreSigner(keystore, password)
+	}
+	parts := strings.SplitN(url, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, errInvalidSignerURL
+	}
+	switch parts[0] {
+	case "keystore":
+		return NewKeystoreSigner(parts[1], password)
+	case "clef":
+		return NewClefSigner(parts[1])
+	case "hsm":
+		endpoint, key := parts[1], ""
+		if i := strings.LastIndex(endpoint, "#"); i >= 0 {
+			endpoint, key = endpoint[:i], endpoint[i+1:]
+		}
+		return NewHSMSigner(endpoint, key)
+	}
+	return nil, errInvalidSignerURL
+}
+
+// keystoreSigner signs with the key of an ELA keystore file loaded into
+// memory.
+type keystoreSigner struct {
+	account daccount.Account
+}
+
+// NewKeystoreSigner opens the keystore file with the password.
+func NewKeystoreSigner(path string, password []byte) (Signer, error) {
+	account, err := GetDposAccount(path, password)
+	if err != nil {
+		return nil, err
+	}
+	return &keystoreSigner{account: account}, nil
+}
+
+func (s *keystoreSigner) PublicKeyBytes() []byte {
+	return s.account.PublicKeyBytes()
+}
+
+func (s *keystoreSigner) SignProposal(proposal *payload.DPOSProposal) ([]byte, error) {
+	return s.account.SignProposal(proposal)
+}
+
+func (s *keystoreSigner) SignVote(vote *payload.DPOSProposalVote) ([]byte, error) {
+	return s.account.SignVote(vote)
+}
+
+func (s *keystoreSigner) Sign(data []byte) ([]byte, error) {
+	signature := s.account.Sign(data)
+	if signature == nil {
+		return nil, errInvalidSignature
+	}
+	return signature, nil
+}
+
+func (s *keystoreSigner) DecryptAddr(cipher []byte) (string, error) {
+	return s.account.DecryptAddr(cipher)
+}
+
+// SignerAccount is the dpos account of a producer backed by a Signer, the
+// signer can be rotated while the node is running. Every signature is
+// verified against the public key before it is used.
+type SignerAccount struct {
+	signer    Signer
+	publicKey *crypto.PublicKey
+	pubKey    []byte
+
+	mu sync.RWMutex
+}
+
+// NewSignerAccount creates the account of the signer.
+func NewSignerAccount(signer Signer) (*SignerAccount, error) {
+	a := &SignerAccount{}
+	if err := a.Rotate(signer); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// OpenDposAccount opens the signer of the url and creates its account, see
+// OpenSigner for the format of the url.
+func OpenDposAccount(url string, keystore string, password []byte) (*SignerAccount, error) {
+	signer, err := OpenSigner(url, keystore, password)
+	if err != nil {
+		return nil, err
+	}
+	return NewSignerAccount(signer)
+}
+
+// Rotate replaces the signer of the account.
+func (a *SignerAccount) Rotate(signer Signer) error {
+	pubKey := signer.PublicKeyBytes()
+	publicKey, err := crypto.DecodePoint(pubKey)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.signer = signer
+	a.publicKey = publicKey
+	a.pubKey = pubKey
+	return nil
+}
+
+// Signer returns the current signer of the account.
+func (a *SignerAccount) Signer() Signer {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.signer
+}
+
+func (a *SignerAccount) current() (Signer, *crypto.PublicKey) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.signer, a.publicKey
+}
+
+func (a *SignerAccount) PublicKey() *crypto.PublicKey {
+	_, publicKey := a.current()
+	return publicKey
+}
+
+func (a *SignerAccount) PublicKeyBytes() []byte {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.pubKey
+}
+
+func (a *SignerAccount) SignProposal(proposal *payload.DPOSProposal) ([]byte, error) {
+	signer, publicKey := a.current()
+	signature, err := signer.SignProposal(proposal)
+	if err != nil {
+		return nil, err
+	}
+	return verifySignature(publicKey, proposal.Data(), signature)
+}
+
+func (a *SignerAccount) SignVote(vote *payload.DPOSProposalVote) ([]byte, error) {
+	signer, publicKey := a.current()
+	signature, err := signer.SignVote(vote)
+	if err != nil {
+		return nil, err
+	}
+	return verifySignature(publicKey, vote.Data(), signature)
+}
+
+// Sign signs the data, it returns nil if the signer failed.
+func (a *SignerAccount) Sign(data []byte) []byte {
+	signer, publicKey := a.current()
+	signature, err := signer.Sign(data)
+	if err == nil {
+		signature, err = verifySignature(publicKey, data, signature)
+	}
+	if err != nil {
+		Error("[SignerAccount] sign error:", err)
+		return nil
+	}
+	return signature
+}
+
+func (a *SignerAccount) SignTx(tx *types.Transaction) ([]byte, error) {
+	return nil, errSignTxNotSupported
+}
+
+func (a *SignerAccount) DecryptAddr(cipher []byte) (string, error) {
+	signer, _ := a.current()
+	return signer.DecryptAddr(cipher)
+}
+
+func verifySignature(publicKey *crypto.PublicKey, data []byte, signature []byte) ([]byte, error) {
+	if err := crypto.Verify(*publicKey, data, signature); err != nil {
+		return nil, errInvalidSignature
+	}
+	return signature, nil
+}
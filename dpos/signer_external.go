@@ -0,0 +1,161 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"context"
+	"crypto/sha256"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common/hexutil"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rpc"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+)
+
+// externalSignerTimeout is the max time to wait for an external signer.
+const externalSignerTimeout = 5 * time.Second
+
+// ClefSigner signs with the producer key held by clef, the requests are sent
+// to the dpos namespace of clef and approved by its rules or its user.
+type ClefSigner struct {
+	client *rpc.Client
+	pubKey []byte
+}
+
+// NewClefSigner connects to the clef endpoint and loads the public key of
+// the producer.
+func NewClefSigner(endpoint string) (Signer, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := newClefSigner(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return signer, nil
+}
+
+func newClefSigner(client *rpc.Client) (*ClefSigner, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), externalSignerTimeout)
+	defer cancel()
+
+	var pubKey hexutil.Bytes
+	if err := client.CallContext(ctx, &pubKey, "dpos_publicKey"); err != nil {
+		return nil, err
+	}
+	return &ClefSigner{client: client, pubKey: pubKey}, nil
+}
+
+func (s *ClefSigner) PublicKeyBytes() []byte {
+	return s.pubKey
+}
+
+func (s *ClefSigner) SignProposal(proposal *payload.DPOSProposal) ([]byte, error) {
+	return s.signData(MimetypeDposProposal, proposal.Data())
+}
+
+func (s *ClefSigner) SignVote(vote *payload.DPOSProposalVote) ([]byte, error) {
+	return s.signData(MimetypeDposVote, vote.Data())
+}
+
+func (s *ClefSigner) Sign(data []byte) ([]byte, error) {
+	return s.signData(MimetypeDposData, data)
+}
+
+func (s *ClefSigner) signData(contentType string, data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), externalSignerTimeout)
+	defer cancel()
+
+	var signature hexutil.Bytes
+	if err := s.client.CallContext(ctx, &signature, "dpos_signData", contentType, hexutil.Bytes(data)); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+func (s *ClefSigner) DecryptAddr(cipher []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), externalSignerTimeout)
+	defer cancel()
+
+	var addr string
+	if err := s.client.CallContext(ctx, &addr, "dpos_decryptAddr", hexutil.Bytes(cipher)); err != nil {
+		return "", err
+	}
+	return addr, nil
+}
+
+// HSMSigner signs with a key which never leaves a hardware security module,
+// the module is reached through a daemon serving the hsm namespace over IPC.
+// Only the sha256 digest of the data is sent to be signed.
+type HSMSigner struct {
+	client *rpc.Client
+	key    string
+	pubKey []byte
+}
+
+// NewHSMSigner connects to the hsm daemon and loads the public key of the
+// given key label.
+func NewHSMSigner(endpoint string, key string) (Signer, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := newHSMSigner(client, key)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return signer, nil
+}
+
+func newHSMSigner(client *rpc.Client, key string) (*HSMSigner, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), externalSignerTimeout)
+	defer cancel()
+
+	var pubKey hexutil.Bytes
+	if err := client.CallContext(ctx, &pubKey, "hsm_publicKey", key); err != nil {
+		return nil, err
+	}
+	return &HSMSigner{client: client, key: key, pubKey: pubKey}, nil
+}
+
+func (s *HSMSigner) PublicKeyBytes() []byte {
+	return s.pubKey
+}
+
+func (s *HSMSigner) SignProposal(proposal *payload.DPOSProposal) ([]byte, error) {
+	return s.Sign(proposal.Data())
+}
+
+func (s *HSMSigner) SignVote(vote *payload.DPOSProposalVote) ([]byte, error) {
+	return s.Sign(vote.Data())
+}
+
+func (s *HSMSigner) Sign(data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), externalSignerTimeout)
+	defer cancel()
+
+	digest := sha256.Sum256(data)
+	var signature hexutil.Bytes
+	if err := s.client.CallContext(ctx, &signature, "hsm_signDigest", s.key, hexutil.Bytes(digest[:])); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+func (s *HSMSigner) DecryptAddr(cipher []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), externalSignerTimeout)
+	defer cancel()
+
+	var plain hexutil.Bytes
+	if err := s.client.CallContext(ctx, &plain, "hsm_decrypt", s.key, hexutil.Bytes(cipher)); err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
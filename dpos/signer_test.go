@@ -0,0 +1,183 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common/hexutil"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rpc"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testKey is a producer key which signs raw digests, the ELA crypto.Sign
+// helper is not used as it needs the public point of the key.
+type testKey struct {
+	key    *ecdsa.PrivateKey
+	pubKey []byte
+}
+
+func newTestKey(t *testing.T) *testKey {
+	key, err := ecdsa.GenerateKey(crypto.DefaultCurve, rand.Reader)
+	assert.NoError(t, err)
+	pubKey, err := (&crypto.PublicKey{X: key.X, Y: key.Y}).EncodePoint(true)
+	assert.NoError(t, err)
+	return &testKey{key: key, pubKey: pubKey}
+}
+
+func (k *testKey) signDigest(digest []byte) []byte {
+	r, s, err := ecdsa.Sign(rand.Reader, k.key, digest)
+	if err != nil {
+		panic(err)
+	}
+	signature := make([]byte, crypto.SignatureLength)
+	copy(signature[crypto.SignerLength-len(r.Bytes()):], r.Bytes())
+	copy(signature[crypto.SignatureLength-len(s.Bytes()):], s.Bytes())
+	return signature
+}
+
+func (k *testKey) sign(data []byte) []byte {
+	digest := sha256.Sum256(data)
+	return k.signDigest(digest[:])
+}
+
+// testHSM serves the hsm namespace with a single key.
+type testHSM struct {
+	key *testKey
+}
+
+func (h *testHSM) PublicKey(label string) (hexutil.Bytes, error) {
+	if label != "producer" {
+		return nil, errors.New("unknown key")
+	}
+	return h.key.pubKey, nil
+}
+
+func (h *testHSM) SignDigest(label string, digest hexutil.Bytes) (hexutil.Bytes, error) {
+	return h.key.signDigest(digest), nil
+}
+
+func (h *testHSM) Decrypt(label string, cipher hexutil.Bytes) (hexutil.Bytes, error) {
+	return cipher, nil
+}
+
+// testClef serves the dpos namespace of clef, it only approves proposals and
+// votes.
+type testClef struct {
+	key *testKey
+}
+
+func (c *testClef) PublicKey(ctx context.Context) (hexutil.Bytes, error) {
+	return c.key.pubKey, nil
+}
+
+func (c *testClef) SignData(ctx context.Context, contentType string, data hexutil.Bytes) (hexutil.Bytes, error) {
+	if contentType != MimetypeDposProposal && contentType != MimetypeDposVote {
+		return nil, errors.New("Request denied")
+	}
+	return c.key.sign(data), nil
+}
+
+func (c *testClef) DecryptAddr(ctx context.Context, cipher hexutil.Bytes) (string, error) {
+	return string(cipher), nil
+}
+
+func dialTestService(t *testing.T, namespace string, service interface{}) *rpc.Client {
+	server := rpc.NewServer()
+	assert.NoError(t, server.RegisterName(namespace, service))
+	return rpc.DialInProc(server)
+}
+
+func TestHSMSigner(t *testing.T) {
+	key := newTestKey(t)
+	client := dialTestService(t, "hsm", &testHSM{key: key})
+	defer client.Close()
+
+	_, err := newHSMSigner(client, "unknown")
+	assert.Error(t, err)
+	signer, err := newHSMSigner(client, "producer")
+	assert.NoError(t, err)
+	assert.Equal(t, key.pubKey, signer.PublicKeyBytes())
+
+	account, err := NewSignerAccount(signer)
+	assert.NoError(t, err)
+	proposal := &payload.DPOSProposal{Sponsor: key.pubKey, BlockHash: common.Uint256{1}, ViewOffset: 2}
+	sign, err := account.SignProposal(proposal)
+	assert.NoError(t, err)
+	proposal.Sign = sign
+	assert.NoError(t, CheckProposal(proposal))
+
+	addr, err := account.DecryptAddr([]byte("127.0.0.1:20639"))
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:20639", addr)
+}
+
+func TestClefSigner(t *testing.T) {
+	key := newTestKey(t)
+	client := dialTestService(t, "dpos", &testClef{key: key})
+	defer client.Close()
+
+	signer, err := newClefSigner(client)
+	assert.NoError(t, err)
+	account, err := NewSignerAccount(signer)
+	assert.NoError(t, err)
+
+	vote := &payload.DPOSProposalVote{ProposalHash: common.Uint256{1}, Signer: key.pubKey, Accept: true}
+	sign, err := account.SignVote(vote)
+	assert.NoError(t, err)
+	vote.Sign = sign
+	assert.NoError(t, CheckVote(vote))
+
+	// the other data is denied by the rules of clef
+	assert.Nil(t, account.Sign([]byte{1, 2, 3}))
+}
+
+func TestSignerAccountRotate(t *testing.T) {
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+	oldClient := dialTestService(t, "hsm", &testHSM{key: oldKey})
+	defer oldClient.Close()
+	newClient := dialTestService(t, "hsm", &testHSM{key: newKey})
+	defer newClient.Close()
+
+	oldSigner, err := newHSMSigner(oldClient, "producer")
+	assert.NoError(t, err)
+	newSigner, err := newHSMSigner(newClient, "producer")
+	assert.NoError(t, err)
+
+	account, err := NewSignerAccount(oldSigner)
+	assert.NoError(t, err)
+	data := []byte("daddr")
+	assert.NoError(t, crypto.Verify(*account.PublicKey(), data, account.Sign(data)))
+
+	assert.NoError(t, account.Rotate(newSigner))
+	assert.Equal(t, newKey.pubKey, account.PublicKeyBytes())
+	assert.Equal(t, newSigner, account.Signer())
+	sign := account.Sign(data)
+	assert.NoError(t, crypto.Verify(*account.PublicKey(), data, sign))
+	pubKey, _ := crypto.DecodePoint(oldKey.pubKey)
+	assert.Error(t, crypto.Verify(*pubKey, data, sign))
+
+	// a signer returning signatures of another key is rejected
+	account.signer = oldSigner
+	assert.Nil(t, account.Sign(data))
+	_, err = account.SignProposal(&payload.DPOSProposal{Sponsor: newKey.pubKey})
+	assert.Equal(t, errInvalidSignature, err)
+
+	_, err = OpenSigner("unknown:endpoint", "", nil)
+	assert.Equal(t, errInvalidSignerURL, err)
+	_, err = OpenSigner("clef:", "", nil)
+	assert.Equal(t, errInvalidSignerURL, err)
+}
@@ -0,0 +1,321 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/msg"
+)
+
+const (
+	// walFileName is the name of the consensus write-ahead log file.
+	walFileName = "consensus.wal"
+
+	// maxWALRecordSize is the max size of a single record of the log.
+	maxWALRecordSize = 1024 * 1024
+)
+
+// walRecordType is the type of a record of the consensus write-ahead log.
+type walRecordType uint8
+
+const (
+	// walRound starts a new consensus round after the block of the height.
+	walRound walRecordType = iota
+	// walProposal records the proposal which is being processed.
+	walProposal
+	// walVote records a vote received for the processing proposal.
+	walVote
+	// walAcceptedVote records the accept vote signed by this node in a view.
+	walAcceptedVote
+	// walViewChange records the view offset and its start time.
+	walViewChange
+)
+
+// ConsensusWAL is the write-ahead log of the in-flight consensus state, it
+// records the proposals, votes and view changes of the current round so the
+// round can be resumed after a restart.
+type ConsensusWAL struct {
+	file *os.File
+
+	height        uint64
+	viewOffset    uint32
+	viewStartTime time.Time
+	processing    *payload.DPOSProposal
+	votes         map[common.Uint256]*payload.DPOSProposalVote
+	acceptedVotes map[uint32]*payload.DPOSProposalVote
+
+	mu sync.Mutex
+}
+
+// OpenConsensusWAL opens the write-ahead log in the directory and loads the
+// round recorded in it.
+func OpenConsensusWAL(dir string) (*ConsensusWAL, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w := &ConsensusWAL{file: file}
+	w.resetState(0)
+	if err := w.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *ConsensusWAL) resetState(height uint64) {
+	w.height = height
+	w.viewOffset = 0
+	w.viewStartTime = time.Time{}
+	w.processing = nil
+	w.votes = make(map[common.Uint256]*payload.DPOSProposalVote)
+	w.acceptedVotes = make(map[uint32]*payload.DPOSProposalVote)
+}
+
+// load replays the records of the file, a torn record at the tail left by a
+// crash is dropped.
+func (w *ConsensusWAL) load() error {
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var offset int64
+	for {
+		recordType, err := common.ReadUint8(w.file)
+		if err != nil {
+			break
+		}
+		data, err := common.ReadVarBytes(w.file, maxWALRecordSize, "wal record")
+		if err != nil {
+			break
+		}
+		if err := w.apply(walRecordType(recordType), data); err != nil {
+			Warn("[ConsensusWAL] invalid record:", err)
+			break
+		}
+		if offset, err = w.file.Seek(0, io.SeekCurrent); err != nil {
+			return err
+		}
+	}
+	if err := w.file.Truncate(offset); err != nil {
+		return err
+	}
+	_, err := w.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+func (w *ConsensusWAL) apply(recordType walRecordType, data []byte) error {
+	r := bytes.NewReader(data)
+	switch recordType {
+	case walRound:
+		height, err := common.ReadUint64(r)
+		if err != nil {
+			return err
+		}
+		w.resetState(height)
+	case walProposal:
+		proposal := new(payload.DPOSProposal)
+		if err := proposal.Deserialize(r); err != nil {
+			return err
+		}
+		w.processing = proposal
+	case walVote:
+		vote := new(payload.DPOSProposalVote)
+		if err := vote.Deserialize(r); err != nil {
+			return err
+		}
+		w.votes[vote.Hash()] = vote
+	case walAcceptedVote:
+		viewOffset, err := common.ReadUint32(r)
+		if err != nil {
+			return err
+		}
+		vote := new(payload.DPOSProposalVote)
+		if err := vote.Deserialize(r); err != nil {
+			return err
+		}
+		w.acceptedVotes[viewOffset] = vote
+	case walViewChange:
+		viewOffset, err := common.ReadUint32(r)
+		if err != nil {
+			return err
+		}
+		startTime, err := common.ReadUint64(r)
+		if err != nil {
+			return err
+		}
+		w.viewOffset = viewOffset
+		w.viewStartTime = time.Unix(0, int64(startTime))
+	}
+	return nil
+}
+
+// append writes the record to the file and syncs it to disk before applying
+// it to the state.
+func (w *ConsensusWAL) append(recordType walRecordType, data []byte) error {
+	buf := new(bytes.Buffer)
+	if err := common.WriteUint8(buf, uint8(recordType)); err != nil {
+		return err
+	}
+	if err := common.WriteVarBytes(buf, data); err != nil {
+		return err
+	}
+	if _, err := w.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	return w.apply(recordType, data)
+}
+
+// NewRound truncates the log and starts the round after the block of the
+// given height.
+func (w *ConsensusWAL) NewRound(height uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	if err := common.WriteUint64(buf, height); err != nil {
+		return err
+	}
+	return w.append(walRound, buf.Bytes())
+}
+
+// AddProposal records the proposal which starts being processed.
+func (w *ConsensusWAL) AddProposal(proposal *payload.DPOSProposal) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.processing != nil && w.processing.Hash().IsEqual(proposal.Hash()) {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	if err := proposal.Serialize(buf); err != nil {
+		return err
+	}
+	return w.append(walProposal, buf.Bytes())
+}
+
+// AddVote records a vote of the processing proposal.
+func (w *ConsensusWAL) AddVote(vote *payload.DPOSProposalVote) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.votes[vote.Hash()]; ok {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	if err := vote.Serialize(buf); err != nil {
+		return err
+	}
+	return w.append(walVote, buf.Bytes())
+}
+
+// AddAcceptedVote records the accept vote signed by this node in the view, it
+// must be written before the vote is sent.
+func (w *ConsensusWAL) AddAcceptedVote(viewOffset uint32, vote *payload.DPOSProposalVote) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	buf := new(bytes.Buffer)
+	if err := common.WriteUint32(buf, viewOffset); err != nil {
+		return err
+	}
+	if err := vote.Serialize(buf); err != nil {
+		return err
+	}
+	return w.append(walAcceptedVote, buf.Bytes())
+}
+
+// AcceptedVote returns the accept vote signed by this node in the view of
+// this round.
+func (w *ConsensusWAL) AcceptedVote(viewOffset uint32) (*payload.DPOSProposalVote, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	vote, ok := w.acceptedVotes[viewOffset]
+	return vote, ok
+}
+
+// AddViewChange records the view offset and the time it started.
+func (w *ConsensusWAL) AddViewChange(viewOffset uint32, startTime time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.viewOffset == viewOffset && w.viewStartTime.Equal(startTime) {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	if err := common.WriteUint32(buf, viewOffset); err != nil {
+		return err
+	}
+	if err := common.WriteUint64(buf, uint64(startTime.UnixNano())); err != nil {
+		return err
+	}
+	return w.append(walViewChange, buf.Bytes())
+}
+
+// ConsensusStatus returns the consensus status recorded for the round after
+// the block of the given height, it returns nil if the log belongs to
+// another round or has no view recorded.
+func (w *ConsensusWAL) ConsensusStatus(height uint64) *msg.ConsensusStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.height != height || w.viewStartTime.IsZero() {
+		return nil
+	}
+	status := &msg.ConsensusStatus{
+		ConsensusStatus:  ConsensusReady,
+		ViewOffset:       w.viewOffset,
+		ViewStartTime:    w.viewStartTime,
+		AcceptVotes:      make([]payload.DPOSProposalVote, 0),
+		RejectedVotes:    make([]payload.DPOSProposalVote, 0),
+		PendingProposals: make([]payload.DPOSProposal, 0, 1),
+		PendingVotes:     make([]payload.DPOSProposalVote, 0),
+	}
+	if w.processing == nil || w.processing.ViewOffset != w.viewOffset {
+		return status
+	}
+	status.ConsensusStatus = ConsensusRunning
+	status.PendingProposals = append(status.PendingProposals, *w.processing)
+	hash := w.processing.Hash()
+	for _, vote := range w.votes {
+		if !vote.ProposalHash.IsEqual(hash) {
+			continue
+		}
+		if vote.Accept {
+			status.AcceptVotes = append(status.AcceptVotes, *vote)
+		} else {
+			status.RejectedVotes = append(status.RejectedVotes, *vote)
+		}
+	}
+	return status
+}
+
+// Close closes the log file.
+func (w *ConsensusWAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsensusWAL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consensus-wal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	wal, err := OpenConsensusWAL(dir)
+	assert.NoError(t, err)
+	assert.Nil(t, wal.ConsensusStatus(0))
+
+	startTime := time.Unix(1000, 0)
+	proposal := &payload.DPOSProposal{Sponsor: []byte{1}, BlockHash: common.Uint256{1}, ViewOffset: 1}
+	accept := &payload.DPOSProposalVote{ProposalHash: proposal.Hash(), Signer: []byte{2}, Accept: true}
+	reject := &payload.DPOSProposalVote{ProposalHash: proposal.Hash(), Signer: []byte{3}}
+	own := &payload.DPOSProposalVote{ProposalHash: proposal.Hash(), Signer: []byte{4}, Accept: true}
+
+	assert.NoError(t, wal.NewRound(10))
+	assert.NoError(t, wal.AddViewChange(1, startTime))
+	assert.NoError(t, wal.AddProposal(proposal))
+	assert.NoError(t, wal.AddVote(accept))
+	assert.NoError(t, wal.AddVote(reject))
+	assert.NoError(t, wal.AddVote(accept))
+	assert.NoError(t, wal.AddAcceptedVote(1, own))
+	assert.NoError(t, wal.Close())
+
+	// append a torn record which must be dropped on replay
+	path := filepath.Join(dir, walFileName)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	assert.NoError(t, err)
+	f.Write([]byte{byte(walVote), 0x20, 1, 2})
+	f.Close()
+
+	wal, err = OpenConsensusWAL(dir)
+	assert.NoError(t, err)
+	assert.Nil(t, wal.ConsensusStatus(11))
+	status := wal.ConsensusStatus(10)
+	if assert.NotNil(t, status) {
+		assert.Equal(t, uint32(ConsensusRunning), status.ConsensusStatus)
+		assert.Equal(t, uint32(1), status.ViewOffset)
+		assert.True(t, startTime.Equal(status.ViewStartTime))
+		assert.Equal(t, 1, len(status.PendingProposals))
+		assert.Equal(t, proposal.Hash(), status.PendingProposals[0].Hash())
+		assert.Equal(t, 1, len(status.AcceptVotes))
+		assert.Equal(t, accept.Hash(), status.AcceptVotes[0].Hash())
+		assert.Equal(t, 1, len(status.RejectedVotes))
+		assert.Equal(t, reject.Hash(), status.RejectedVotes[0].Hash())
+	}
+	vote, ok := wal.AcceptedVote(1)
+	assert.True(t, ok)
+	assert.Equal(t, own.Hash(), vote.Hash())
+	_, ok = wal.AcceptedVote(0)
+	assert.False(t, ok)
+
+	// records appended after the replay are kept
+	assert.NoError(t, wal.AddViewChange(2, startTime.Add(time.Second)))
+	assert.NoError(t, wal.Close())
+	wal, err = OpenConsensusWAL(dir)
+	assert.NoError(t, err)
+	status = wal.ConsensusStatus(10)
+	if assert.NotNil(t, status) {
+		assert.Equal(t, uint32(ConsensusReady), status.ConsensusStatus)
+		assert.Equal(t, uint32(2), status.ViewOffset)
+	}
+
+	// a new round drops the state of the previous one
+	assert.NoError(t, wal.NewRound(11))
+	assert.Nil(t, wal.ConsensusStatus(10))
+	_, ok = wal.AcceptedVote(1)
+	assert.False(t, ok)
+	assert.NoError(t, wal.Close())
+	wal, err = OpenConsensusWAL(dir)
+	assert.NoError(t, err)
+	assert.Nil(t, wal.ConsensusStatus(11))
+	assert.NoError(t, wal.AddViewChange(0, startTime))
+	assert.NotNil(t, wal.ConsensusStatus(11))
+	assert.NoError(t, wal.Close())
+}
@@ -17,6 +17,7 @@
 package eth
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"errors"
@@ -25,6 +26,7 @@ import (
 	"math/big"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -34,10 +36,14 @@ import (
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/state"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/internal/ethapi"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/rlp"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/rpc"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/trie"
+
+	elacom "github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/p2p/msg"
 )
 
 // PublicEthereumAPI provides an API to access Ethereum full node-related
@@ -252,6 +258,83 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// errNoDposRoutes is returned if the node does not route dpos addresses.
+var errNoDposRoutes = errors.New("dpos address routes not running")
+
+// DposAddr is a verified address announce of a dpos producer.
+type DposAddr struct {
+	Hash      common.Hash   `json:"hash"`
+	PID       hexutil.Bytes `json:"pid"`
+	Encode    hexutil.Bytes `json:"encode"`
+	Timestamp time.Time     `json:"timestamp"`
+	Expiry    time.Time     `json:"expiry"`
+	Raw       hexutil.Bytes `json:"raw"`
+}
+
+// DposAddrs returns the known dpos address announces.
+func (api *PrivateAdminAPI) DposAddrs() ([]*DposAddr, error) {
+	routes := api.eth.dposRoutes
+	if routes == nil {
+		return nil, errNoDposRoutes
+	}
+	addrs := routes.KnownAddrs()
+	result := make([]*DposAddr, 0, len(addrs))
+	for _, addr := range addrs {
+		buf := new(bytes.Buffer)
+		if err := addr.Serialize(buf); err != nil {
+			return nil, err
+		}
+		result = append(result, &DposAddr{
+			Hash:      common.Hash(addr.Hash()),
+			PID:       addr.PID[:],
+			Encode:    addr.Encode[:],
+			Timestamp: addr.Timestamp,
+			Expiry:    addr.Timestamp.Add(dpos.AddrExpiry),
+			Raw:       buf.Bytes(),
+		})
+	}
+	return result, nil
+}
+
+// AddDposAddr verifies and adds a serialized dpos address announce, it is
+// relayed to the network and persisted like a received one.
+func (api *PrivateAdminAPI) AddDposAddr(raw hexutil.Bytes) (common.Hash, error) {
+	routes := api.eth.dposRoutes
+	if routes == nil {
+		return common.Hash{}, errNoDposRoutes
+	}
+	addr := new(msg.DAddr)
+	if err := addr.Deserialize(bytes.NewReader(raw)); err != nil {
+		return common.Hash{}, err
+	}
+	if err := routes.AddAddr(addr); err != nil {
+		return common.Hash{}, err
+	}
+	return common.Hash(addr.Hash()), nil
+}
+
+// EvictDposAddr removes the dpos address announce of the hash.
+func (api *PrivateAdminAPI) EvictDposAddr(hash common.Hash) (bool, error) {
+	routes := api.eth.dposRoutes
+	if routes == nil {
+		return false, errNoDposRoutes
+	}
+	return routes.EvictAddr(elacom.Uint256(hash)), nil
+}
+
+// EvilSigners returns the signers with stored evidences of conflicting blocks.
+func (api *PrivateAdminAPI) EvilSigners() []*EvilSigner {
+	stored := api.eth.blockchain.EvilSigners()
+	result := make([]*EvilSigner, 0, len(stored))
+	for signer, evidences := range stored {
+		result = append(result, &EvilSigner{Signer: signer, Evidences: evilEvidences(evidences)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].Signer[:], result[j].Signer[:]) < 0
+	})
+	return result
+}
+
 // PublicDebugAPI is the collection of Ethereum full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -276,6 +359,8 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 	var block *types.Block
 	if blockNr == rpc.LatestBlockNumber {
 		block = api.eth.blockchain.CurrentBlock()
+	} else if blockNr == rpc.FinalizedBlockNumber {
+		block = api.eth.blockchain.CurrentFinalizedBlock()
 	} else {
 		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
 	}
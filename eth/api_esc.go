@@ -0,0 +1,258 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"sort"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common/hexutil"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rpc"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
+)
+
+// The recharge states of a main chain deposit.
+const (
+	RechargeUnknown   = "unknown"   // the deposit is not known by the spv module
+	RechargeSeen      = "seen"      // the deposit is received from the main chain
+	RechargeQueued    = "queued"    // the deposit is waiting in the recharge queue
+	RechargeSubmitted = "submitted" // the recharge transaction is sent to the pool
+	RechargeMinted    = "minted"    // the recharge transaction is included in the chain
+	RechargeFailed    = "failed"    // the deposit is rolled back from the main chain
+)
+
+// RechargeOutput is an output of a main chain deposit, the amounts are in wei.
+type RechargeOutput struct {
+	Address string       `json:"address"`
+	Amount  *hexutil.Big `json:"amount"`
+	Fee     *hexutil.Big `json:"fee"`
+}
+
+// RechargeStatus is the recharge state of a main chain deposit.
+type RechargeStatus struct {
+	MainTxHash common.Hash      `json:"mainTxHash"`
+	MainHeight uint32           `json:"mainHeight"`
+	Status     string           `json:"status"`
+	SideTxHash *common.Hash     `json:"sideTxHash"`
+	Outputs    []RechargeOutput `json:"outputs"`
+	Fee        *hexutil.Big     `json:"fee"`
+	CreatedAt  hexutil.Uint64   `json:"createdAt"`
+	UpdatedAt  hexutil.Uint64   `json:"updatedAt"`
+}
+
+// PublicEscAPI provides an API to query the recharges of the deposits from
+// the ELA main chain.
+type PublicEscAPI struct {
+	eth *Ethereum
+}
+
+// NewPublicEscAPI creates a new recharge status API.
+func NewPublicEscAPI(eth *Ethereum) *PublicEscAPI {
+	return &PublicEscAPI{eth: eth}
+}
+
+func toRechargeWei(sela uint64) *hexutil.Big {
+	return (*hexutil.Big)(spv.SelaToWei(sela))
+}
+
+// rechargeStatus returns the recharge state of the deposit record, queued is
+// the set of the deposits waiting in the recharge queue.
+func (api *PublicEscAPI) rechargeStatus(record *spv.DepositRecord, queued map[common.Hash]bool) (*RechargeStatus, error) {
+	state, err := api.eth.blockchain.State()
+	if err != nil {
+		return nil, err
+	}
+	status := &RechargeStatus{
+		MainTxHash: record.MainTxHash,
+		MainHeight: record.MainHeight,
+		Fee:        toRechargeWei(record.Fee),
+		CreatedAt:  hexutil.Uint64(record.CreatedAt),
+		UpdatedAt:  hexutil.Uint64(record.UpdatedAt),
+	}
+	for _, output := range record.Outputs {
+		status.Outputs = append(status.Outputs, RechargeOutput{
+			Address: output.Address,
+			Amount:  toRechargeWei(output.Amount),
+			Fee:     toRechargeWei(output.Fee),
+		})
+	}
+	if record.SideTxHash != (common.Hash{}) {
+		sideTx := record.SideTxHash
+		status.SideTxHash = &sideTx
+	}
+
+	// The completed recharge is recorded in the state of the zero address.
+	if minted := state.GetState(common.Address{}, record.MainTxHash); minted != (common.Hash{}) {
+		status.Status = RechargeMinted
+		status.SideTxHash = &minted
+		return status, nil
+	}
+	switch {
+	case record.Status == spv.DepositRolledBack:
+		status.Status = RechargeFailed
+	case record.Status == spv.DepositSent:
+		status.Status = RechargeSubmitted
+	case queued[record.MainTxHash]:
+		status.Status = RechargeQueued
+	default:
+		status.Status = RechargeSeen
+	}
+	return status, nil
+}
+
+func (api *PublicEscAPI) queuedDeposits() map[common.Hash]bool {
+	queued := make(map[common.Hash]bool)
+	for _, hash := range api.eth.spv.QueuedDeposits() {
+		queued[hash] = true
+	}
+	return queued
+}
+
+// GetRechargeStatus returns the recharge state of the main chain transaction.
+func (api *PublicEscAPI) GetRechargeStatus(mainTxHash string) (*RechargeStatus, error) {
+	hash := common.HexToHash(mainTxHash)
+	record := api.eth.spv.GetDeposit(hash)
+	if record == nil {
+		return &RechargeStatus{MainTxHash: hash, Status: RechargeUnknown}, nil
+	}
+	return api.rechargeStatus(record, api.queuedDeposits())
+}
+
+// PendingRecharges returns the recharge states of the deposits waiting in the
+// recharge queue.
+func (api *PublicEscAPI) PendingRecharges() ([]*RechargeStatus, error) {
+	hashes := api.eth.spv.QueuedDeposits()
+	queued := make(map[common.Hash]bool, len(hashes))
+	for _, hash := range hashes {
+		queued[hash] = true
+	}
+	result := make([]*RechargeStatus, 0, len(hashes))
+	for _, hash := range hashes {
+		record := api.eth.spv.GetDeposit(hash)
+		if record == nil {
+			continue
+		}
+		status, err := api.rechargeStatus(record, queued)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// RechargeStatus creates a subscription that is triggered each time the
+// recharge state of a deposit changes.
+func (api *PublicEscAPI) RechargeStatus(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		deposits := make(chan *spv.DepositRecord, 10)
+		depositSub := api.eth.spv.SubscribeDepositEvent(deposits)
+		defer depositSub.Unsubscribe()
+		heads := make(chan core.ChainHeadEvent, 10)
+		headSub := api.eth.blockchain.SubscribeChainHeadEvent(heads)
+		defer headSub.Unsubscribe()
+
+		notify := func(record *spv.DepositRecord) {
+			if status, err := api.rechargeStatus(record, api.queuedDeposits()); err == nil {
+				notifier.Notify(rpcSub.ID, status)
+			}
+		}
+		for {
+			select {
+			case record := <-deposits:
+				notify(record)
+			case head := <-heads:
+				// notify the recharges minted in the new block
+				for _, tx := range head.Block.Transactions() {
+					for _, hash := range rechargedDeposits(tx) {
+						if record := api.eth.spv.GetDeposit(hash); record != nil {
+							notify(record)
+						}
+					}
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// rechargedDeposits returns the main chain transactions the transaction
+// recharges, a single one or a batch of them.
+func rechargedDeposits(tx *types.Transaction) []common.Hash {
+	if tx.To() == nil || *tx.To() != bridge.RechargeAddress {
+		return nil
+	}
+	if len(tx.Data()) == common.HashLength {
+		return []common.Hash{common.BytesToHash(tx.Data())}
+	}
+	hashes, _ := spv.DecodeBatchRecharge(tx.Data())
+	return hashes
+}
+
+// EvilBlock is a block signed by an evil signer.
+type EvilBlock struct {
+	Hash      common.Hash    `json:"hash"`
+	ElaHeight hexutil.Uint64 `json:"elaHeight"`
+}
+
+// EvilEvidence is the conflicting blocks an evil signer signed at a height.
+type EvilEvidence struct {
+	Number hexutil.Uint64 `json:"number"`
+	Blocks []EvilBlock    `json:"blocks"`
+}
+
+// EvilSigner is a signer with the evidences of its conflicting blocks.
+type EvilSigner struct {
+	Signer    common.Address  `json:"signer"`
+	Evidences []*EvilEvidence `json:"evidences"`
+}
+
+// evilEvidences returns the stored evidences sorted by height.
+func evilEvidences(stored map[uint64][]rawdb.EvilSignerBlock) []*EvilEvidence {
+	evidences := make([]*EvilEvidence, 0, len(stored))
+	for number, blocks := range stored {
+		evidence := &EvilEvidence{Number: hexutil.Uint64(number)}
+		for _, block := range blocks {
+			evidence.Blocks = append(evidence.Blocks, EvilBlock{Hash: block.Hash, ElaHeight: hexutil.Uint64(block.ElaHeight)})
+		}
+		evidences = append(evidences, evidence)
+	}
+	sort.Slice(evidences, func(i, j int) bool {
+		return evidences[i].Number < evidences[j].Number
+	})
+	return evidences
+}
+
+// GetEvilEvidence returns the conflicting blocks stored for the signer.
+func (api *PublicEscAPI) GetEvilEvidence(signer common.Address) []*EvilEvidence {
+	return evilEvidences(api.eth.blockchain.EvilSignerEvidence(signer))
+}
@@ -0,0 +1,176 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus/ethash"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/vm"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
+)
+
+func TestRechargeStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esc-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	service, err := spv.NewService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer service.Close()
+	spvdb := service.GetDatabase()
+
+	var (
+		minted    = common.HexToHash("0x01")
+		queued    = common.HexToHash("0x02")
+		submitted = common.HexToHash("0x03")
+		failed    = common.HexToHash("0x04")
+		seen      = common.HexToHash("0x05")
+		sideTx    = common.HexToHash("0xff")
+	)
+	db := rawdb.NewMemoryDatabase()
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			common.Address{}: {Balance: common.Big0, Storage: map[common.Hash]common.Hash{minted: sideTx}},
+		},
+	}
+	gspec.MustCommit(db)
+	blockchain, err := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+
+	statuses := map[common.Hash]spv.DepositStatus{
+		minted:    spv.DepositSent,
+		queued:    spv.DepositPending,
+		submitted: spv.DepositSent,
+		failed:    spv.DepositRolledBack,
+		seen:      spv.DepositPending,
+	}
+	for hash, status := range statuses {
+		record := &spv.DepositRecord{
+			MainTxHash: hash,
+			MainHeight: 100,
+			Outputs:    []spv.DepositOutput{{Address: common.Address{1}.String(), Amount: 100000000, Fee: 10000}},
+			Fee:        10000,
+			Status:     status,
+		}
+		if err := spv.WriteDeposit(spvdb, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	service.UpTransactionIndex(common.Bytes2Hex(queued.Bytes()))
+
+	api := NewPublicEscAPI(&Ethereum{blockchain: blockchain, spv: service})
+	want := map[common.Hash]string{
+		minted:                     RechargeMinted,
+		queued:                     RechargeQueued,
+		submitted:                  RechargeSubmitted,
+		failed:                     RechargeFailed,
+		seen:                       RechargeSeen,
+		common.HexToHash("0x0606"): RechargeUnknown,
+	}
+	for hash, status := range want {
+		result, err := api.GetRechargeStatus(hash.Hex())
+		if err != nil {
+			t.Fatalf("%x: failed to get status: %v", hash, err)
+		}
+		if result.Status != status {
+			t.Errorf("%x: status mismatch: have %s, want %s", hash, result.Status, status)
+		}
+	}
+	result, _ := api.GetRechargeStatus(minted.Hex())
+	if result.SideTxHash == nil || *result.SideTxHash != sideTx {
+		t.Errorf("minted side chain tx mismatch: have %v, want %x", result.SideTxHash, sideTx)
+	}
+	if len(result.Outputs) != 1 || result.Outputs[0].Amount.ToInt().Cmp(spv.SelaToWei(100000000)) != 0 {
+		t.Errorf("outputs mismatch: %v", result.Outputs)
+	}
+
+	pending, err := api.PendingRecharges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].MainTxHash != queued {
+		t.Errorf("pending recharges mismatch: %v", pending)
+	}
+}
+
+func TestRechargedDeposits(t *testing.T) {
+	hashes := []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")}
+	for i, tt := range []struct {
+		to   common.Address
+		data []byte
+		want []common.Hash
+	}{
+		{bridge.RechargeAddress, hashes[0].Bytes(), hashes[:1]},
+		{bridge.RechargeAddress, spv.EncodeBatchRecharge(hashes), hashes},
+		{common.Address{1}, hashes[0].Bytes(), nil},
+		{bridge.RechargeAddress, []byte{1}, nil},
+	} {
+		tx := types.NewTransaction(0, tt.to, common.Big0, 0, common.Big0, tt.data)
+		if have := rechargedDeposits(tx); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: deposits mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
+
+func TestEvilEvidence(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	gspec := &core.Genesis{Config: params.TestChainConfig}
+	gspec.MustCommit(db)
+
+	signer := common.Address{1}
+	first, second := common.HexToHash("0x01"), common.HexToHash("0x02")
+	rawdb.WriteEvilSignerBlocks(db, signer, 7, []rawdb.EvilSignerBlock{{Hash: first, ElaHeight: 100}, {Hash: second, ElaHeight: 101}})
+	rawdb.WriteEvilSignerBlocks(db, signer, 3, []rawdb.EvilSignerBlock{{Hash: first, ElaHeight: 99}})
+	blockchain, err := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+	eth := &Ethereum{blockchain: blockchain}
+
+	evidences := NewPublicEscAPI(eth).GetEvilEvidence(signer)
+	if len(evidences) != 2 || evidences[0].Number != 3 || evidences[1].Number != 7 {
+		t.Fatalf("evidences mismatch: have %v", evidences)
+	}
+	if blocks := evidences[1].Blocks; len(blocks) != 2 || blocks[0].Hash != first || blocks[1].Hash != second || blocks[1].ElaHeight != 101 {
+		t.Errorf("conflicting blocks mismatch: have %v", blocks)
+	}
+	if evidences := NewPublicEscAPI(eth).GetEvilEvidence(common.Address{2}); len(evidences) != 0 {
+		t.Errorf("evidences of unknown signer: have %v", evidences)
+	}
+	signers := NewPrivateAdminAPI(eth).EvilSigners()
+	if len(signers) != 1 || signers[0].Signer != signer || len(signers[0].Evidences) != 2 {
+		t.Errorf("evil signers mismatch: have %v", signers)
+	}
+}
@@ -60,6 +60,7 @@ import (
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
 
 	"github.com/elastos/Elastos.ELA/core/types/payload"
+	daccount "github.com/elastos/Elastos.ELA/dpos/account"
 	elapeer "github.com/elastos/Elastos.ELA/dpos/p2p/peer"
 	"github.com/elastos/Elastos.ELA/p2p/msg"
 )
@@ -105,6 +106,9 @@ type Ethereum struct {
 	networkID     uint64
 	netRPCService *ethapi.PublicNetAPI
 
+	dposRoutes *dpos.Routes // Routes of the dpos addresses, nil if not a producer
+	spv        *spv.Service // Spv service following the main chain, nil if the node doesn't run it
+
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
 }
 
@@ -193,6 +197,12 @@ func New(ctx *node.ServiceContext, config *Config, node *node.Node) (*Ethereum,
 		chainConfig.PbftKeyStorePassWord = config.PbftKeyStorePassWord
 	}
 
+	if len(chainConfig.PbftSigner) > 0 {
+		config.PbftSigner = chainConfig.PbftSigner
+	} else {
+		chainConfig.PbftSigner = config.PbftSigner
+	}
+
 	if chainConfig.Pbft != nil {
 		if len(chainConfig.Pbft.IPAddress) > 0 {
 			config.PbftIPAddress = chainConfig.Pbft.IPAddress
@@ -251,11 +261,21 @@ func New(ctx *node.ServiceContext, config *Config, node *node.Node) (*Ethereum,
 			TrieTimeLimit:       config.TrieTimeout,
 		}
 	)
-	engine := pbft.New(chainConfig.Pbft, chainConfig.PbftKeyStore, []byte(chainConfig.PbftKeyStorePassWord), ctx.ResolvePath(""), chainConfig.GetPbftBlock())
+	engine := pbft.New(chainConfig.Pbft, chainConfig.PbftKeyStore, []byte(chainConfig.PbftKeyStorePassWord), chainConfig.PbftSigner, ctx.ResolvePath(""), chainConfig.GetPbftBlock())
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, engine, vmConfig, eth.shouldPreserve)
 	if err != nil {
 		return nil, err
 	}
+	// The recharges credit the deposits of the main chain synced by the spv module
+	if config.Spv != nil {
+		eth.spv = config.Spv
+		eth.blockchain.SetDeposits(config.Spv)
+		eth.blockchain.SetEvilProofSender(config.Spv)
+		if clique, ok := eth.engine.(*clique.Clique); ok {
+			clique.SetSpv(config.Spv)
+		}
+		engine.SetSpv(config.Spv)
+	}
 	eth.SetEngine(eth.blockchain.Engine())
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
@@ -353,8 +373,8 @@ func New(ctx *node.ServiceContext, config *Config, node *node.Node) (*Ethereum,
 
 	engine.SetBlockChain(eth.blockchain)
 
-	dposAccount, err := dpos.GetDposAccount(chainConfig.PbftKeyStore, []byte(chainConfig.PbftKeyStorePassWord))
-	if err != nil {
+	dposAccount := engine.GetAccount()
+	if dposAccount == nil {
 		return eth, nil
 	}
 	if chainConfig.Pbft != nil {
@@ -386,8 +406,14 @@ func New(ctx *node.ServiceContext, config *Config, node *node.Node) (*Ethereum,
 				log.Info("AddDirectLinkPeer", "address:", addr)
 				engine.AddDirectLinkPeer(pid, addr)
 			},
+			Store: &dposAddrStore{db: chainDb},
 		}
 		routes := dpos.New(&routeCfg)
+		eth.dposRoutes = routes
+		engine.OnSignerRotated = func(account daccount.Account) {
+			routes.SetPID(account.PublicKeyBytes())
+			blocksigner.SelfIsProducer = engine.IsProducer()
+		}
 		go routes.Start()
 		go engine.StartServer()
 
@@ -429,6 +455,7 @@ func SubscriptEvent(eth *Ethereum, engine consensus.Engine) {
 			select {
 			case b := <-blockEvent:
 				pbftEngine := engine.(*pbft.Pbft)
+				pbftEngine.OnInsertBlock(b.Block)
 				pbftEngine.AccessFutureBlock(b.Block)
 			}
 		}
@@ -528,6 +555,11 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   filters.NewPublicFilterAPI(s.APIBackend, false),
 			Public:    true,
+		}, {
+			Namespace: "esc",
+			Version:   "1.0",
+			Service:   NewPublicEscAPI(s),
+			Public:    true,
 		}, {
 			Namespace: "admin",
 			Version:   "1.0",
@@ -635,23 +667,19 @@ func (s *Ethereum) shouldPreserve(block *types.Block) bool {
 			}
 			log.Info("detected chain fork", "old block", oldBlock.Hash().String(), "new block", block.Hash().String(),
 				"oldBlock time", oldBlock.Time(), "newBlock time", block.Time())
-			var oldConfirm payload.Confirm
-			oldErr := oldConfirm.Deserialize(bytes.NewReader(oldBlock.Extra()))
+			oldProposal, oldErr := dpos.DecodeConfirmProposal(oldBlock.Extra())
 			if oldErr != nil {
 				log.Error("old Block is error confirm")
+				oldProposal = new(payload.DPOSProposal)
 			}
-			var newConfirm payload.Confirm
-			newErr := newConfirm.Deserialize(bytes.NewReader(block.Extra()))
+			newProposal, newErr := dpos.DecodeConfirmProposal(block.Extra())
 			if newErr != nil {
 				log.Error("new Block is error confirm")
 				return false
 			}
-			if newErr != nil {
-				return true
-			}
 
-			oldViewOffset := oldConfirm.Proposal.ViewOffset
-			newViewOffset := newConfirm.Proposal.ViewOffset
+			oldViewOffset := oldProposal.ViewOffset
+			newViewOffset := newProposal.ViewOffset
 			log.Info("detected chain fork", "oldViewOffset", oldViewOffset, "newViewOffset", newViewOffset, "SignersCount", s.engine.SignersCount())
 			return newViewOffset > oldViewOffset
 		}
@@ -743,6 +771,7 @@ func (s *Ethereum) NetVersion() uint64                 { return s.networkID }
 func (s *Ethereum) Downloader() *downloader.Downloader { return s.protocolManager.downloader }
 func (s *Ethereum) Synced() bool                       { return atomic.LoadUint32(&s.protocolManager.acceptTxs) == 1 }
 func (s *Ethereum) ArchiveMode() bool                  { return s.config.NoPruning }
+func (s *Ethereum) Spv() *spv.Service                  { return s.spv }
 
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
@@ -801,12 +830,8 @@ func (s *Ethereum) Stop() error {
 
 	s.chainDb.Close()
 
-	if nil != spv.MinedBlockSub {
-		spv.MinedBlockSub.Unsubscribe()
-	}
-	spvdb := spv.SpvService.GetDatabase()
-	if spvdb != nil {
-		spvdb.Close()
+	if s.spv != nil {
+		s.spv.Close()
 	}
 
 	close(s.shutdownChan)
@@ -31,6 +31,7 @@ import (
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/eth/gasprice"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/miner"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/spv"
 )
 
 // DefaultConfig contains default settings for use on the Ethereum main net.
@@ -101,6 +102,9 @@ type Config struct {
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
 
+	// Spv service following the main chain, the recharges credit its deposits
+	Spv *spv.Service `toml:"-"`
+
 	// Light client options
 	LightServ    int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightIngress int `toml:",omitempty"` // Incoming bandwidth limit for light servers
@@ -162,12 +166,13 @@ type Config struct {
 
 	PassBalance uint64
 
-	// evil signer events jouranl local path
+	// path of the legacy evil signer events journal, migrated to the chain database
 	EvilSignersJournalDir  string
 
 	PreConnectOffset uint64
 	PbftKeyStore string
 	PbftKeyStorePassWord string
+	PbftSigner string
 	PbftIPAddress string
 	PbftDPosPort uint16
 }
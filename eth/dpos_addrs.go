@@ -0,0 +1,46 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb"
+
+	elacom "github.com/elastos/Elastos.ELA/common"
+)
+
+// dposAddrStore persists the verified dpos addresses in the chain database.
+type dposAddrStore struct {
+	db ethdb.Database
+}
+
+func (s *dposAddrStore) WriteAddr(hash elacom.Uint256, data []byte) {
+	rawdb.WriteDposAddr(s.db, common.Hash(hash), data)
+}
+
+func (s *dposAddrStore) DeleteAddr(hash elacom.Uint256) {
+	rawdb.DeleteDposAddr(s.db, common.Hash(hash))
+}
+
+func (s *dposAddrStore) ReadAddrs() map[elacom.Uint256][]byte {
+	addrs := make(map[elacom.Uint256][]byte)
+	for hash, data := range rawdb.ReadAllDposAddrs(s.db) {
+		addrs[elacom.Uint256(hash)] = data
+	}
+	return addrs
+}
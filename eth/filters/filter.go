@@ -40,6 +40,7 @@ type Backend interface {
 
 	SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
+	SubscribeFinalizedHeadEvent(ch chan<- core.FinalizedHeadEvent) event.Subscription
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
 
@@ -135,6 +136,19 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	}
 	head := header.Number.Uint64()
 
+	finalizedNumber := rpc.FinalizedBlockNumber.Int64()
+	if f.begin == finalizedNumber || f.end == finalizedNumber {
+		finalized, _ := f.backend.HeaderByNumber(ctx, rpc.FinalizedBlockNumber)
+		if finalized == nil {
+			return nil, nil
+		}
+		if f.begin == finalizedNumber {
+			f.begin = finalized.Number.Int64()
+		}
+		if f.end == finalizedNumber {
+			f.end = finalized.Number.Int64()
+		}
+	}
 	if f.begin == -1 {
 		f.begin = int64(head)
 	}
@@ -25,6 +25,7 @@ var Modules = map[string]string{
 	"pbft":       PbftJs,
 	"ethash":     EthashJs,
 	"debug":      DebugJs,
+	"esc":        EscJs,
 	"eth":        EthJs,
 	"miner":      MinerJs,
 	"net":        NetJs,
@@ -113,6 +114,31 @@ web3._extend({
 });
 `
 
+const EscJs = `
+web3._extend({
+	property: 'esc',
+	methods: [
+		new web3._extend.Method({
+			name: 'getRechargeStatus',
+			call: 'esc_getRechargeStatus',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getEvilEvidence',
+			call: 'esc_getEvilEvidence',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'pendingRecharges',
+			getter: 'esc_pendingRecharges'
+		}),
+	]
+});
+`
+
 const PbftJs = `
 web3._extend({
 	property: 'pbft',
@@ -125,8 +151,56 @@ web3._extend({
 			name: 'getArbiterPeersInfo',
 			call: 'pbft_getAtbiterPeersInfo',
 		}),
+		new web3._extend.Method({
+			name: 'getIllegalEvidence',
+			call: 'pbft_getIllegalEvidence',
+		}),
+		new web3._extend.Method({
+			name: 'getConfirmByHeight',
+			call: 'pbft_getConfirmByHeight',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'getConfirmByHash',
+			call: 'pbft_getConfirmByHash',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'getVotersByHeight',
+			call: 'pbft_getVotersByHeight',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'getDutySchedule',
+			call: 'pbft_getDutySchedule',
+			params: 1,
+		}),
 	],
 	properties: [
+		new web3._extend.Property({
+			name: 'producers',
+			getter: 'pbft_getProducers'
+		}),
+		new web3._extend.Property({
+			name: 'onDutyProducer',
+			getter: 'pbft_getOnDutyProducer'
+		}),
+		new web3._extend.Property({
+			name: 'viewInfo',
+			getter: 'pbft_getViewInfo'
+		}),
+		new web3._extend.Property({
+			name: 'processingProposal',
+			getter: 'pbft_getProcessingProposal'
+		}),
+		new web3._extend.Property({
+			name: 'voteTally',
+			getter: 'pbft_getVoteTally'
+		}),
+		new web3._extend.Property({
+			name: 'recoveryStatus',
+			getter: 'pbft_getRecoveryStatus'
+		}),
 		new web3._extend.Property({
 			name: 'dispatcher',
 			getter: 'pbft_dispatcher'
@@ -221,6 +295,12 @@ web3._extend({
 			name: 'stopRPC',
 			call: 'admin_stopRPC'
 		}),
+		new web3._extend.Method({
+			name: 'rotateSigner',
+			call: 'admin_rotateSigner',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
 		new web3._extend.Method({
 			name: 'startWS',
 			call: 'admin_startWS',
@@ -231,8 +311,26 @@ web3._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new web3._extend.Method({
+			name: 'addDposAddr',
+			call: 'admin_addDposAddr',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'evictDposAddr',
+			call: 'admin_evictDposAddr',
+			params: 1
+		}),
 	],
 	properties: [
+		new web3._extend.Property({
+			name: 'dposAddrs',
+			getter: 'admin_dposAddrs'
+		}),
+		new web3._extend.Property({
+			name: 'evilSigners',
+			getter: 'admin_evilSigners'
+		}),
 		new web3._extend.Property({
 			name: 'nodeInfo',
 			getter: 'admin_nodeInfo'
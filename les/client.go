@@ -97,6 +97,12 @@ func New(ctx *node.ServiceContext, config *eth.Config, node *node.Node) (*LightE
 		chainConfig.PbftKeyStorePassWord = config.PbftKeyStorePassWord
 	}
 
+	if len(chainConfig.PbftSigner) > 0 {
+		config.PbftSigner = chainConfig.PbftSigner
+	} else {
+		chainConfig.PbftSigner = config.PbftSigner
+	}
+
 	if chainConfig.Pbft != nil {
 		if len(chainConfig.Pbft.IPAddress) > 0 {
 			config.PbftIPAddress = chainConfig.Pbft.IPAddress
@@ -181,7 +187,7 @@ func New(ctx *node.ServiceContext, config *eth.Config, node *node.Node) (*LightE
 		gpoParams.Default = config.Miner.GasPrice
 	}
 	leth.ApiBackend.gpo = gasprice.NewOracle(leth.ApiBackend, gpoParams)
-	engine := pbft.New(chainConfig.Pbft, chainConfig.PbftKeyStore, []byte(chainConfig.PbftKeyStorePassWord), ctx.ResolvePath(""), chainConfig.GetPbftBlock())
+	engine := pbft.New(chainConfig.Pbft, chainConfig.PbftKeyStore, []byte(chainConfig.PbftKeyStorePassWord), chainConfig.PbftSigner, ctx.ResolvePath(""), chainConfig.GetPbftBlock())
 	if leth.blockchain.Config().IsPBFTFork(leth.blockchain.CurrentHeader().Number) {
 		leth.SetEngine(engine)
 	}
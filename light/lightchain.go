@@ -26,6 +26,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/blocksigner"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/bridge"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/core"
@@ -75,7 +77,6 @@ type LightChain struct {
 
 	evilSigners *core.EvilSignersMap // EvilSigners contains evil signers
 	evilmu      sync.RWMutex         // evil signers lock
-	journal     *core.EvilJournal    // Journal of local  evilSingeerEvents to back up to disk
 }
 
 // NewLightChain returns a fully initialised light chain using information
@@ -95,7 +96,6 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 		bodyRLPCache:  bodyRLPCache,
 		blockCache:    blockCache,
 		engine:        engine,
-		journal:       core.NewEvilJournal(config.EvilSignersJournalDir),
 	}
 	var err error
 	bc.hc, err = core.NewHeaderChain(odr.Database(), config, bc.engine, bc.getProcInterrupt)
@@ -123,17 +123,10 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 		}
 	}
 
-	if bc.journal != nil {
-		if err := bc.journal.Load(bc.addEvilSingerEvents); err != nil {
-			log.Warn("Failed to load evil singer events journal", "err", err)
-		}
-		if err := bc.removeOldEvilSigners(bc.CurrentHeader().Number, 0); err != nil {
-			log.Warn("Failed to remove old evil signers", "err", err)
-		}
-		if err := bc.journal.Rotate(bc.getEvilSignerEvents()); err != nil {
-			log.Warn("Failed to rotate evil singer events ournal", "err", err)
-		}
+	if err := core.MigrateEvilJournal(config.EvilSignersJournalDir, bc.chainDb); err != nil {
+		log.Warn("Failed to migrate evil singer events journal", "err", err)
 	}
+	bc.loadEvilSigners()
 	return bc, nil
 }
 
@@ -238,6 +231,10 @@ func (lc *LightChain) ResetWithGenesisBlock(genesis *types.Block) {
 // Engine retrieves the light chain's consensus engine.
 func (lc *LightChain) Engine() consensus.Engine { return lc.engine }
 
+// Bridge retrieves the light chain's bridge system contracts, a light client
+// doesn't follow the main chain and finds no deposit.
+func (lc *LightChain) Bridge() *bridge.Registry { return bridge.NewRegistry(lc.Config(), nil) }
+
 // Genesis returns the genesis block
 func (lc *LightChain) Genesis() *types.Block {
 	return lc.genesisBlock
@@ -341,9 +338,6 @@ func (lc *LightChain) Stop() {
 	atomic.StoreInt32(&lc.procInterrupt, 1)
 
 	lc.wg.Wait()
-	if lc.journal != nil {
-		lc.journal.Close()
-	}
 	log.Info("Blockchain manager stopped")
 }
 
@@ -585,6 +579,12 @@ func (lc *LightChain) SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) eve
 	return lc.scope.Track(lc.chainSideFeed.Subscribe(ch))
 }
 
+// SubscribeFinalizedHeadEvent implements the interface of filters.Backend
+// LightChain does not track finalized blocks, so return an empty subscription.
+func (lc *LightChain) SubscribeFinalizedHeadEvent(ch chan<- core.FinalizedHeadEvent) event.Subscription {
+	return lc.scope.Track(new(event.Feed).Subscribe(ch))
+}
+
 // SubscribeLogsEvent implements the interface of filters.Backend
 // LightChain does not send logs events, so return an empty subscription.
 func (lc *LightChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
@@ -617,29 +617,20 @@ func (lc *LightChain) isToManyEvilSigners(header *types.Header) bool {
 	if headerOld == nil {
 		return false
 	}
-	return core.IsNeedStopChain(header, headerOld, lc.engine, lc.evilSigners, lc.journal)
+	return core.IsNeedStopChain(lc.Config(), header, headerOld, lc.engine, lc.evilSigners, lc.chainDb, nil)
 }
 
-// remove old evilSigners who have created  different blocks, and difference between  the blocks height
-// and currentHeight should biger then rangeValue.
-func (lc *LightChain) removeOldEvilSigners(currentHeight *big.Int, rangeValue int64) error {
-	lc.evilmu.Lock()
-	defer lc.evilmu.Unlock()
-	if lc.evilSigners == nil {
-		return nil
-	}
-	//lc.evilSigners.RemoveOldEvilSigners(currentHeight, rangeValue)
-	return nil
-}
+// loadEvilSigners restores the evil signers from the evidences stored in the
+// database, and removes the stored evidences which are out of date.
+func (lc *LightChain) loadEvilSigners() {
+	lc.addEvilSingerEvents(core.ReadEvilSignerEvents(lc.chainDb))
 
-// getEvilSignerEvents return evilSignerEvents by now
-func (lc *LightChain) getEvilSignerEvents() (res []*core.EvilSingerEvent) {
 	lc.evilmu.Lock()
 	defer lc.evilmu.Unlock()
-	if lc.evilSigners == nil {
-		lc.evilSigners = &core.EvilSignersMap{}
-	}
-	return lc.evilSigners.GetEvilSignerEvents()
+	current := lc.CurrentHeader()
+	elaHeight := core.ElaHeight(lc.Config(), current)
+	lc.evilSigners.RemoveOldEvilSigners(current.Number, int64(blocksigner.GetBlockSignersCount(elaHeight)))
+	lc.evilSigners.PruneEvidences(lc.chainDb)
 }
 
 // addEvilSignerEvents add evilSignerEvents of []*EvilSingerEvent.
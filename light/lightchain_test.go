@@ -474,7 +474,7 @@ func TestEvilSigners(t *testing.T)  {
 		chain.InsertHeaderChain([]*types.Header{block.Header()}, 1)
 	}
 
-	if !chain.evilSigners.IsDanger(big.NewInt(int64(len(signerKeys)*3)), len(signerKeys)*2/3) {
+	if !chain.evilSigners.IsDanger(big.NewInt(int64(len(signerKeys)*3)), 0, len(signerKeys)*2/3) {
 		t.Error("Count evil signers wrong")
 	}
 }
@@ -783,22 +783,14 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 			// Pop the current out-of-gas transaction without shifting in the next from the account
 			log.Trace("Gas limit exceeded for current block", "sender", from)
 			txs.Pop()
-			var addr common.Address
-			if tx.To() != nil {
-				to := *tx.To()
-				if len(tx.Data()) == 32 && to == addr {
-					core.RemoveLocalTx(w.eth.TxPool(), tx.Hash(), true, true)
-				}
+			if core.IsRechargeTx(tx) {
+				core.RemoveLocalTx(w.eth.TxPool(), tx.Hash(), true, true)
 			}
 		case core.ErrMainTxHashPresence:
 			log.Trace("ErrTxHashTooHigh  is returned if Main chain transaction has been processed", "sender", from)
 			txs.Pop()
-			var addr common.Address
-			if tx.To() != nil {
-				to := *tx.To()
-				if len(tx.Data()) == 32 && to == addr {
-					core.RemoveLocalTx(w.eth.TxPool(), tx.Hash(), true, false)
-				}
+			if core.IsRechargeTx(tx) {
+				core.RemoveLocalTx(w.eth.TxPool(), tx.Hash(), true, false)
 			}
 		case core.ErrNonceTooLow:
 			// New head notification data race between the transaction pool and miner, shift
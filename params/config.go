@@ -323,16 +323,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(20), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0),new(EthashConfig), nil, nil, "", 0, "", 0, "", ""}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(20), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), nil, nil, nil, nil, nil, nil,new(EthashConfig), nil, nil, "", 0, "", 0, "", "", ""}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(20), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil, "", 0, "",0, "", ""}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(20), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil, "", 0, "",0, "", "", ""}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(20), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), new(EthashConfig), nil, nil, "", 0, "", 0, "", ""}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(20), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), nil, nil, nil, nil, nil, nil, new(EthashConfig), nil, nil, "", 0, "", 0, "", "", ""}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -407,6 +407,13 @@ type ChainConfig struct {
 	EWASMBlock          *big.Int `json:"ewasmBlock,omitempty"`          // EWASM switch block (nil = no fork, 0 = already activated)
 	PBFTBlock           *big.Int `json:"pbftBlock,omitempty"`           // PBFT switch block (nil = no fork, 0 = already activated)
 
+	PBFTCompactConfirmBlock  *big.Int `json:"pbftCompactConfirmBlock,omitempty"`  // Compact pbft confirm switch block (nil = no fork, 0 = already activated)
+	PBFTElaHeightBlock       *big.Int `json:"pbftElaHeightBlock,omitempty"`       // Pbft ela height in the header nonce switch block (nil = no fork, 0 = already activated)
+	MultiOutputRechargeBlock *big.Int `json:"multiOutputRechargeBlock,omitempty"` // Multi-output recharge switch block (nil = no fork, 0 = already activated)
+	BatchRechargeBlock       *big.Int `json:"batchRechargeBlock,omitempty"`       // Batch recharge switch block (nil = no fork, 0 = already activated)
+	MainChainDataBlock       *big.Int `json:"mainChainDataBlock,omitempty"`       // Main chain data precompile switch block (nil = no fork, 0 = already activated)
+	CliqueElaHeightBlock     *big.Int `json:"cliqueElaHeightBlock,omitempty"`     // Validated clique ela height switch block (nil = no fork, 0 = already activated)
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
@@ -418,6 +425,7 @@ type ChainConfig struct {
 	PreConnectOffset      uint64 `json:"preConnectOffset,omitempty"`
 	PbftKeyStore          string `json:"pbftKeyStore,omitempty"`
 	PbftKeyStorePassWord  string
+	PbftSigner            string `json:"pbftSigner,omitempty"`
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -447,6 +455,10 @@ type PbftConfig struct {
 	PrintLevel    uint8  `json:"printlevel"`
 	MaxLogsSize   int64  `json:"maxlogssize"`
 	MaxPerLogSize int64  `json:"maxperlogsize"`
+
+	// ArbiterSigners maps the hex encoded public keys of the main chain arbiters to the
+	// side chain accounts they sign blocks with.
+	ArbiterSigners map[string]common.Address `json:"arbiterSigners,omitempty"`
 }
 
 func (p *PbftConfig) String() string {
@@ -546,6 +558,42 @@ func (c *ChainConfig) IsPBFTFork(num *big.Int) bool {
 	return isForked(c.PBFTBlock, num)
 }
 
+// IsPBFTCompactConfirm returns whether num is either equal to the compact pbft
+// confirm fork block or greater.
+func (c *ChainConfig) IsPBFTCompactConfirm(num *big.Int) bool {
+	return isForked(c.PBFTCompactConfirmBlock, num)
+}
+
+// IsPBFTElaHeight returns whether num is either equal to the pbft ela height
+// fork block or greater.
+func (c *ChainConfig) IsPBFTElaHeight(num *big.Int) bool {
+	return isForked(c.PBFTElaHeightBlock, num)
+}
+
+// IsMultiOutputRecharge returns whether num is either equal to the multi-output
+// recharge fork block or greater.
+func (c *ChainConfig) IsMultiOutputRecharge(num *big.Int) bool {
+	return isForked(c.MultiOutputRechargeBlock, num)
+}
+
+// IsBatchRecharge returns whether num is either equal to the batch recharge
+// fork block or greater.
+func (c *ChainConfig) IsBatchRecharge(num *big.Int) bool {
+	return isForked(c.BatchRechargeBlock, num)
+}
+
+// IsMainChainData returns whether num is either equal to the main chain data
+// fork block or greater.
+func (c *ChainConfig) IsMainChainData(num *big.Int) bool {
+	return isForked(c.MainChainDataBlock, num)
+}
+
+// IsCliqueElaHeight returns whether num is either equal to the validated clique
+// ela height fork block or greater.
+func (c *ChainConfig) IsCliqueElaHeight(num *big.Int) bool {
+	return isForked(c.CliqueElaHeightBlock, num)
+}
+
 func (c *ChainConfig) GetPbftBlock() uint64 {
 	if c.PBFTBlock == nil {
 		return 0
@@ -662,6 +710,24 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	if isForkIncompatible(c.PBFTCompactConfirmBlock, newcfg.PBFTCompactConfirmBlock, head) {
+		return newCompatError("PBFT compact confirm fork block", c.PBFTCompactConfirmBlock, newcfg.PBFTCompactConfirmBlock)
+	}
+	if isForkIncompatible(c.PBFTElaHeightBlock, newcfg.PBFTElaHeightBlock, head) {
+		return newCompatError("PBFT ela height fork block", c.PBFTElaHeightBlock, newcfg.PBFTElaHeightBlock)
+	}
+	if isForkIncompatible(c.MultiOutputRechargeBlock, newcfg.MultiOutputRechargeBlock, head) {
+		return newCompatError("Multi-output recharge fork block", c.MultiOutputRechargeBlock, newcfg.MultiOutputRechargeBlock)
+	}
+	if isForkIncompatible(c.BatchRechargeBlock, newcfg.BatchRechargeBlock, head) {
+		return newCompatError("Batch recharge fork block", c.BatchRechargeBlock, newcfg.BatchRechargeBlock)
+	}
+	if isForkIncompatible(c.MainChainDataBlock, newcfg.MainChainDataBlock, head) {
+		return newCompatError("Main chain data fork block", c.MainChainDataBlock, newcfg.MainChainDataBlock)
+	}
+	if isForkIncompatible(c.CliqueElaHeightBlock, newcfg.CliqueElaHeightBlock, head) {
+		return newCompatError("Clique ela height fork block", c.CliqueElaHeightBlock, newcfg.CliqueElaHeightBlock)
+	}
 	return nil
 }
 
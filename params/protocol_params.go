@@ -129,6 +129,9 @@ const (
 	Bn256PairingBaseGasIstanbul      uint64 = 45000  // Base price for an elliptic curve pairing check
 	Bn256PairingPerPointGasByzantium uint64 = 80000  // Byzantium per-point price for an elliptic curve pairing check
 	Bn256PairingPerPointGasIstanbul  uint64 = 34000  // Per-point price for an elliptic curve pairing check
+
+	MainChainHeightGas uint64 = 100  // Price for reading the ela height of the block
+	MainChainTxGas     uint64 = 1600 // Price for checking the inclusion of a main chain transaction
 )
 
 var (
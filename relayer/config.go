@@ -0,0 +1,33 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package relayer
+
+// DefaultConfig contains default settings for the withdrawal relayer.
+var DefaultConfig = Config{
+	Host: "localhost",
+	Port: 20632,
+}
+
+// Config contains the configuration parameters of the withdrawal relayer.
+type Config struct {
+	// Host is the host interface on which to start the relayer server.
+	Host string `toml:",omitempty"`
+
+	// Port is the TCP port number on which to start the relayer server, the
+	// arbiters connect to the port the former oracle listened on by default.
+	Port int `toml:",omitempty"`
+}
@@ -0,0 +1,325 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package relayer implements the service relaying the withdrawals of the side
+// chain to the arbiters of the ELA main chain.
+package relayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/eth"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/eth/filters"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/p2p"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rpc"
+)
+
+const (
+	// confirmations is the depth a block must reach before its withdrawals are relayed.
+	confirmations = 6
+
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
+
+	// serverTimeout is the read and write timeout of the relayer server.
+	serverTimeout = 6 * time.Minute
+)
+
+var errUnknownTx = errors.New("unknown transaction")
+
+// Relayer watches the logs of the cross chain payload contract and serves the
+// withdrawals to the arbiters.
+type Relayer struct {
+	config   *Config
+	chain    *core.BlockChain
+	chainDb  ethdb.Database // Blockchain database to look up the receipts
+	db       ethdb.Database // Database of the relayed withdrawals
+	contract common.Address
+
+	// blockLogs returns the payload contract logs of the block.
+	blockLogs func(hash common.Hash) ([]*types.Log, error)
+
+	lock     sync.RWMutex // Protects the relayed records from concurrent rewinds
+	listener net.Listener
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New creates a withdrawal relayer of the full node, the relayed withdrawals
+// are persisted in db.
+func New(config *Config, ethServ *eth.Ethereum, db ethdb.Database) (*Relayer, error) {
+	if ethServ == nil {
+		return nil, errors.New("withdrawal relayer requires a full node")
+	}
+	chain := ethServ.BlockChain()
+	contract, ok := chain.Bridge().BlackContract()
+	if !ok {
+		return nil, fmt.Errorf("invalid black contract address: %q", chain.Config().BlackContractAddr)
+	}
+	r := newRelayer(config, chain, ethServ.ChainDb(), db, contract)
+	r.blockLogs = func(hash common.Hash) ([]*types.Log, error) {
+		filter := filters.NewBlockFilter(ethServ.APIBackend, hash, []common.Address{r.contract}, [][]common.Hash{{payloadTopic}})
+		return filter.Logs(context.Background())
+	}
+	return r, nil
+}
+
+func newRelayer(config *Config, chain *core.BlockChain, chainDb ethdb.Database, db ethdb.Database, contract common.Address) *Relayer {
+	return &Relayer{
+		config:   config,
+		chain:    chain,
+		chainDb:  chainDb,
+		db:       db,
+		contract: contract,
+		quit:     make(chan struct{}),
+	}
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the relayer (nil as it doesn't use the devp2p overlay network).
+func (r *Relayer) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// relayer (nil as the arbiters are served by its own server).
+func (r *Relayer) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting the relay loop and the server of the arbiters.
+func (r *Relayer) Start(server *p2p.Server) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", r.config.Host, r.config.Port))
+	if err != nil {
+		return err
+	}
+	r.listener = listener
+
+	r.wg.Add(2)
+	go r.loop()
+	go func() {
+		defer r.wg.Done()
+		srv := &http.Server{Handler: r, ReadTimeout: serverTimeout, WriteTimeout: serverTimeout}
+		srv.Serve(listener)
+	}()
+	log.Info("Withdrawal relayer started", "url", fmt.Sprintf("http://%v", listener.Addr()), "contract", r.contract)
+	return nil
+}
+
+// Stop implements node.Service, stopping the relayer.
+func (r *Relayer) Stop() error {
+	close(r.quit)
+	var err error
+	if r.listener != nil {
+		err = r.listener.Close()
+	}
+	r.wg.Wait()
+	r.db.Close()
+	log.Info("Withdrawal relayer stopped")
+	return err
+}
+
+// loop relays the withdrawals of the confirmed blocks whenever the chain head changes.
+func (r *Relayer) loop() {
+	defer r.wg.Done()
+
+	heads := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	sub := r.chain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	for {
+		if err := r.sync(); err != nil {
+			log.Error("Failed to relay withdrawals", "err", err)
+		}
+		select {
+		case <-heads:
+		case <-sub.Err():
+			return
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// sync relays the withdrawals of the canonical blocks up to the confirmed head,
+// the relayed blocks which have been reorganised out are rewound first. The
+// first sync starts from the confirmed head, earlier blocks are served from the
+// chain on demand.
+func (r *Relayer) sync() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	head := r.chain.CurrentBlock().NumberU64()
+	if head < confirmations {
+		return nil
+	}
+	target := head - confirmations
+
+	cursor, err := r.rewind(ReadCursor(r.db))
+	if err != nil {
+		return err
+	}
+	next := target
+	if cursor != nil {
+		next = cursor.Number + 1
+	}
+	for ; next <= target; next++ {
+		select {
+		case <-r.quit:
+			return nil
+		default:
+		}
+		header := r.chain.GetHeaderByNumber(next)
+		if header == nil {
+			return fmt.Errorf("missing canonical header #%d", next)
+		}
+		if cursor != nil && header.ParentHash != cursor.Hash {
+			// the chain has been reorganised meanwhile, the next head rewinds it
+			log.Debug("Relay interrupted by reorg", "number", next)
+			return nil
+		}
+		hash := header.Hash()
+		logs, err := r.blockLogs(hash)
+		if err != nil {
+			return err
+		}
+		withdrawals := DecodeWithdrawals(r.contract, logs)
+
+		updated := &Cursor{First: next, Number: next, Hash: hash}
+		if cursor != nil {
+			updated.First = cursor.First
+		}
+		batch := r.db.NewBatch()
+		if err := writeBlockWithdrawals(batch, next, hash, withdrawals); err != nil {
+			return err
+		}
+		if err := writeCursor(batch, updated); err != nil {
+			return err
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		cursor = updated
+		if len(withdrawals) > 0 {
+			log.Info("Relayed withdrawals", "number", next, "hash", hash, "txs", len(withdrawals))
+		}
+	}
+	return nil
+}
+
+// rewind removes the relayed blocks which are no longer canonical, returning
+// the cursor of the last relayed canonical block.
+func (r *Relayer) rewind(cursor *Cursor) (*Cursor, error) {
+	if cursor == nil || r.chain.GetCanonicalHash(cursor.Number) == cursor.Hash {
+		return cursor, nil
+	}
+	from := cursor.Number
+	batch := r.db.NewBatch()
+	for cursor != nil && r.chain.GetCanonicalHash(cursor.Number) != cursor.Hash {
+		if err := deleteBlockWithdrawals(r.db, batch, cursor.Number); err != nil {
+			return nil, err
+		}
+		header := r.chain.GetHeaderByHash(cursor.Hash)
+		if header == nil || cursor.Number == cursor.First {
+			cursor = nil
+			break
+		}
+		cursor = &Cursor{First: cursor.First, Number: cursor.Number - 1, Hash: header.ParentHash}
+	}
+	if err := writeCursor(batch, cursor); err != nil {
+		return nil, err
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	if cursor != nil {
+		log.Warn("Rewound relayed withdrawals", "from", from, "to", cursor.Number)
+	} else {
+		log.Warn("Rewound all relayed withdrawals", "from", from)
+	}
+	return cursor, nil
+}
+
+// relayed reports whether the withdrawals of the canonical block at the number
+// are stored in the relayer database.
+func (r *Relayer) relayed(number uint64) bool {
+	cursor := ReadCursor(r.db)
+	if cursor == nil || number < cursor.First || number > cursor.Number {
+		return false
+	}
+	return r.chain.GetCanonicalHash(cursor.Number) == cursor.Hash
+}
+
+// BlockWithdrawals returns the withdrawals of the canonical block at the number.
+func (r *Relayer) BlockWithdrawals(number uint64) ([]*Withdrawal, error) {
+	r.lock.RLock()
+	if r.relayed(number) {
+		defer r.lock.RUnlock()
+		return ReadBlockWithdrawals(r.db, number), nil
+	}
+	r.lock.RUnlock()
+
+	header := r.chain.GetHeaderByNumber(number)
+	if header == nil {
+		return nil, nil
+	}
+	logs, err := r.blockLogs(header.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return DecodeWithdrawals(r.contract, logs), nil
+}
+
+// TransactionWithdrawal returns the withdrawal of the canonical transaction,
+// nil if the transaction doesn't withdraw any asset.
+func (r *Relayer) TransactionWithdrawal(hash common.Hash) (*Withdrawal, error) {
+	r.lock.RLock()
+	withdrawal := ReadWithdrawal(r.db, hash)
+	r.lock.RUnlock()
+	if withdrawal != nil && r.chain.GetCanonicalHash(withdrawal.BlockNumber) == withdrawal.BlockHash {
+		return withdrawal, nil
+	}
+
+	receipt, _, _, _ := rawdb.ReadReceipt(r.chainDb, hash, r.chain.Config())
+	if receipt == nil {
+		return nil, errUnknownTx
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, nil
+	}
+	if withdrawals := DecodeWithdrawals(r.contract, receipt.Logs); len(withdrawals) > 0 {
+		return withdrawals[0], nil
+	}
+	return nil, nil
+}
+
+// rechargeTx returns the side chain transaction which has minted the recharge of
+// the main chain transaction, the zero hash if not minted.
+func (r *Relayer) rechargeTx(mainTxHash common.Hash) (common.Hash, error) {
+	state, err := r.chain.State()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return state.GetState(common.Address{}, mainTxHash), nil
+}
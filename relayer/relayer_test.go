@@ -0,0 +1,239 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package relayer
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/consensus/ethash"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/rawdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/vm"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+)
+
+var testContract = common.HexToAddress("0x491bC043672B9286fA02FA7e0d6A3E5A0384A31A")
+
+func selaToWei(sela int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(sela), rate)
+}
+
+func payloadLog(t *testing.T, contract common.Address, tx common.Hash, addr string, amount, crossChainAmount *big.Int) *types.Log {
+	data, err := payloadArgs.Pack(addr, amount, crossChainAmount)
+	if err != nil {
+		t.Fatalf("failed to pack payload: %v", err)
+	}
+	return &types.Log{
+		Address: contract,
+		Topics:  []common.Hash{payloadTopic, common.BytesToHash(common.Address{0xaa}.Bytes())},
+		Data:    data,
+		TxHash:  tx,
+	}
+}
+
+func TestDecodeWithdrawals(t *testing.T) {
+	tx1, tx2 := common.Hash{1}, common.Hash{2}
+	logs := []*types.Log{
+		payloadLog(t, testContract, tx1, "EKsSQae7goc5oGGxwvgbUxkMsiQhC9ZfJ3", selaToWei(100000000), selaToWei(99990000)),
+		payloadLog(t, testContract, tx1, "EKsSQae7goc5oGGxwvgbUxkMsiQhC9ZfJ3", selaToWei(20000), selaToWei(10000)),
+		// fee below the contract minimum
+		payloadLog(t, testContract, tx1, "EKsSQae7goc5oGGxwvgbUxkMsiQhC9ZfJ3", selaToWei(100000000), selaToWei(99999999)),
+		// amount not a multiple of sela
+		payloadLog(t, testContract, tx1, "EKsSQae7goc5oGGxwvgbUxkMsiQhC9ZfJ3", new(big.Int).Add(selaToWei(100000000), common.Big1), selaToWei(99990000)),
+		// crosschain amount below the fee
+		payloadLog(t, testContract, tx1, "EKsSQae7goc5oGGxwvgbUxkMsiQhC9ZfJ3", selaToWei(30000), selaToWei(10000)),
+		// not emitted by the payload contract
+		payloadLog(t, common.Address{1}, tx2, "EKsSQae7goc5oGGxwvgbUxkMsiQhC9ZfJ3", selaToWei(100000000), selaToWei(99990000)),
+		payloadLog(t, testContract, tx2, "EXgsSzKMnfkdLzFbMFVEbzbPKxLBv8T1eS", selaToWei(110000000), selaToWei(100000000)),
+	}
+	withdrawals := DecodeWithdrawals(testContract, logs)
+	if len(withdrawals) != 2 {
+		t.Fatalf("withdrawals mismatch: have %d, want 2", len(withdrawals))
+	}
+	if withdrawals[0].TxHash != tx1 || len(withdrawals[0].Assets) != 2 {
+		t.Fatalf("withdrawal 0 mismatch: have %x with %d assets", withdrawals[0].TxHash, len(withdrawals[0].Assets))
+	}
+	if withdrawals[1].TxHash != tx2 || len(withdrawals[1].Assets) != 1 {
+		t.Fatalf("withdrawal 1 mismatch: have %x with %d assets", withdrawals[1].TxHash, len(withdrawals[1].Assets))
+	}
+	asset := withdrawals[1].Assets[0]
+	if asset.Address != "EXgsSzKMnfkdLzFbMFVEbzbPKxLBv8T1eS" || asset.Fee().Cmp(selaToWei(10000000)) != 0 {
+		t.Errorf("asset mismatch: have %s with fee %v", asset.Address, asset.Fee())
+	}
+
+	tx := newWithdrawTx(tx1, withdrawals[0])
+	if tx.TxID != "01"+strings.Repeat("0", 62) {
+		t.Errorf("txid mismatch: have %s", tx.TxID)
+	}
+	for i, want := range [][2]string{{"0.9999", "1"}, {"0.0001", "0.0002"}} {
+		if have := tx.CrossChainAssets[i]; have.CrossChainAmount != want[0] || have.OutputAmount != want[1] {
+			t.Errorf("asset %d amounts mismatch: have %s/%s, want %s/%s", i, have.CrossChainAmount, have.OutputAmount, want[0], want[1])
+		}
+	}
+}
+
+// testChain generates blocks withdrawing an asset at the multiples of three.
+type testChain struct {
+	t     *testing.T
+	db    ethdb.Database
+	chain *core.BlockChain
+	logs  map[common.Hash][]*types.Log
+}
+
+func newTestChain(t *testing.T) *testChain {
+	db := rawdb.NewMemoryDatabase()
+	gspec := &core.Genesis{Config: params.TestChainConfig}
+	gspec.MustCommit(db)
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return &testChain{t: t, db: db, chain: chain, logs: make(map[common.Hash][]*types.Log)}
+}
+
+func (c *testChain) insert(parent *types.Block, n int, coinbase common.Address) []*types.Block {
+	blocks, _ := core.GenerateChain(c.chain.Config(), parent, ethash.NewFaker(), c.db, n, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(coinbase)
+	})
+	for _, block := range blocks {
+		if block.NumberU64()%3 != 0 {
+			continue
+		}
+		l := payloadLog(c.t, testContract, crypto.Keccak256Hash(block.Hash().Bytes()), "EKsSQae7goc5oGGxwvgbUxkMsiQhC9ZfJ3", selaToWei(100000000), selaToWei(99990000))
+		l.BlockHash, l.BlockNumber = block.Hash(), block.NumberU64()
+		c.logs[block.Hash()] = []*types.Log{l}
+	}
+	if _, err := c.chain.InsertChain(blocks); err != nil {
+		c.t.Fatalf("failed to insert chain: %v", err)
+	}
+	return blocks
+}
+
+func (c *testChain) newRelayer(db ethdb.Database) *Relayer {
+	r := newRelayer(&DefaultConfig, c.chain, c.db, db, testContract)
+	r.blockLogs = func(hash common.Hash) ([]*types.Log, error) {
+		return c.logs[hash], nil
+	}
+	return r
+}
+
+func TestRelayerReorg(t *testing.T) {
+	c := newTestChain(t)
+	defer c.chain.Stop()
+	db := rawdb.NewMemoryDatabase()
+	r := c.newRelayer(db)
+
+	// the first sync starts at the confirmed head
+	blocks := c.insert(c.chain.Genesis(), 10, common.Address{1})
+	if err := r.sync(); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	if cursor := ReadCursor(db); cursor == nil || cursor.First != 4 || cursor.Number != 4 {
+		t.Fatalf("cursor mismatch: have %+v, want 4-4", cursor)
+	}
+	blocks = append(blocks, c.insert(blocks[9], 10, common.Address{1})...)
+	if err := r.sync(); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	if cursor := ReadCursor(db); cursor.First != 4 || cursor.Number != 14 || cursor.Hash != blocks[13].Hash() {
+		t.Fatalf("cursor mismatch: have %+v, want 4-14", cursor)
+	}
+	for number := uint64(4); number <= 14; number++ {
+		if have, want := len(ReadBlockWithdrawals(db, number)), len(c.logs[blocks[number-1].Hash()]); have != want {
+			t.Errorf("block %d withdrawals mismatch: have %d, want %d", number, have, want)
+		}
+	}
+	reorged := crypto.Keccak256Hash(blocks[11].Hash().Bytes())
+	if ReadWithdrawal(db, reorged) == nil {
+		t.Fatalf("withdrawal of block 12 not relayed")
+	}
+
+	// a longer fork from block 8 replaces the relayed blocks
+	fork := c.insert(blocks[7], 15, common.Address{2})
+	if err := r.sync(); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	if cursor := ReadCursor(db); cursor.First != 4 || cursor.Number != 17 || cursor.Hash != fork[8].Hash() {
+		t.Fatalf("cursor mismatch: have %+v, want 4-17", cursor)
+	}
+	if ReadWithdrawal(db, reorged) != nil {
+		t.Errorf("withdrawal of the reorged block 12 not rewound")
+	}
+	withdrawals := ReadBlockWithdrawals(db, 12)
+	if len(withdrawals) != 1 || withdrawals[0].BlockHash != fork[3].Hash() {
+		t.Fatalf("block 12 withdrawals mismatch: have %v", withdrawals)
+	}
+	if withdrawal, err := r.TransactionWithdrawal(withdrawals[0].TxHash); err != nil || withdrawal == nil {
+		t.Errorf("withdrawal of the fork not found: %v", err)
+	}
+
+	// the arbiters query the withdrawals six blocks below the height
+	server := httptest.NewServer(r)
+	defer server.Close()
+	call := func(method string, params interface{}, result interface{}) {
+		body, _ := json.Marshal(map[string]interface{}{"method": method, "params": params})
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(string(body)))
+		if err != nil {
+			t.Fatalf("failed to call %s: %v", method, err)
+		}
+		defer resp.Body.Close()
+		var reply struct {
+			Result json.RawMessage
+			Error  interface{}
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+			t.Fatalf("failed to decode %s: %v", method, err)
+		}
+		if reply.Error != nil {
+			t.Fatalf("%s failed: %v", method, reply.Error)
+		}
+		if err := json.Unmarshal(reply.Result, result); err != nil {
+			t.Fatalf("failed to decode %s result: %v", method, err)
+		}
+	}
+	var count uint64
+	call("getblockcount", nil, &count)
+	if count != 23 {
+		t.Errorf("block count mismatch: have %d, want 23", count)
+	}
+	for _, height := range []interface{}{18, "18", 24} {
+		var txs []*withdrawTx
+		call("getwithdrawtransactionsbyheight", map[string]interface{}{"height": height}, &txs)
+		if len(txs) != 1 || len(txs[0].CrossChainAssets) != 1 || txs[0].CrossChainAssets[0].OutputAmount != "1" {
+			t.Fatalf("height %v withdrawals mismatch: have %v", height, txs)
+		}
+	}
+	var tx withdrawTx
+	call("getwithdrawtransaction", map[string]interface{}{"txid": withdrawals[0].TxHash.Hex()}, &tx)
+	if tx.TxID != strings.TrimPrefix(withdrawals[0].TxHash.Hex(), "0x") || len(tx.CrossChainAssets) != 1 {
+		t.Errorf("withdraw transaction mismatch: have %+v", tx)
+	}
+	var exist []string
+	call("getexistdeposittransactions", map[string]interface{}{"txs": []string{"01"}}, &exist)
+	if len(exist) != 0 {
+		t.Errorf("exist deposits mismatch: have %v", exist)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package relayer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+)
+
+const (
+	// maxRequestContentLength is the maximum size of a request of the arbiters.
+	maxRequestContentLength = 150 * 1024 * 1024
+
+	// heightOffset is the distance of the block of the withdrawals from the
+	// height requested by the arbiters.
+	heightOffset = 6
+)
+
+// Error codes of the main chain returned for the recharges.
+const (
+	errCodeMainchainTxDuplicate codeError = 45013
+	errCodeInvalidMainchainTx   codeError = 45022
+)
+
+var errInternal = errors.New("InternalError")
+
+// codeError is an error reported to the arbiters by its code.
+type codeError int
+
+func (e codeError) Error() string { return strconv.Itoa(int(e)) }
+
+// request is a call of the arbiters, the parameters are passed by name.
+type request struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// response is the reply to the arbiters.
+type response struct {
+	Result  interface{} `json:"result"`
+	ID      interface{} `json:"id"`
+	Error   interface{} `json:"error"`
+	JSONRPC string      `json:"jsonrpc"`
+}
+
+// crossChainAsset is a withdraw output, the amounts are in ELA.
+type crossChainAsset struct {
+	CrossChainAddress string `json:"crosschainaddress"`
+	CrossChainAmount  string `json:"crosschainamount"`
+	OutputAmount      string `json:"outputamount"`
+}
+
+// withdrawTx is a withdraw transaction as known by the arbiters.
+type withdrawTx struct {
+	TxID             string             `json:"txid"`
+	CrossChainAssets []*crossChainAsset `json:"crosschainassets"`
+}
+
+func newWithdrawTx(hash common.Hash, withdrawal *Withdrawal) *withdrawTx {
+	tx := &withdrawTx{TxID: strings.TrimPrefix(hash.Hex(), "0x")}
+	if withdrawal == nil {
+		return tx
+	}
+	for _, asset := range withdrawal.Assets {
+		tx.CrossChainAssets = append(tx.CrossChainAssets, &crossChainAsset{
+			CrossChainAddress: asset.Address,
+			CrossChainAmount:  formatAmount(asset.CrossChainAmount),
+			OutputAmount:      formatAmount(asset.Amount),
+		})
+	}
+	return tx
+}
+
+// ServeHTTP serves the calls of the arbiters.
+func (r *Relayer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var call request
+	body := http.MaxBytesReader(w, req.Body, maxRequestContentLength)
+	if err := json.NewDecoder(body).Decode(&call); err != nil {
+		writeResponse(w, nil, err)
+		return
+	}
+	log.Debug("Relayer request", "method", call.Method, "params", string(call.Params))
+
+	var (
+		result interface{}
+		err    error
+	)
+	switch call.Method {
+	case "getblockcount":
+		result, err = r.getBlockCount()
+	case "sendrechargetransaction":
+		result, err = r.sendRechargeTransaction(call.Params)
+	case "getwithdrawtransaction":
+		result, err = r.getWithdrawTransaction(call.Params)
+	case "getwithdrawtransactionsbyheight":
+		result, err = r.getWithdrawTransactionsByHeight(call.Params)
+	case "getexistdeposittransactions":
+		result, err = r.getExistDepositTransactions(call.Params)
+	case "getillegalevidencebyheight":
+		result = []interface{}{}
+	case "checkillegalevidence":
+		result = false
+	default:
+		result = "received"
+	}
+	writeResponse(w, result, err)
+}
+
+func writeResponse(w http.ResponseWriter, result interface{}, err error) {
+	resp := &response{Result: result, JSONRPC: "2.0"}
+	if err != nil {
+		log.Debug("Relayer request failed", "err", err)
+		resp.Result, resp.Error = nil, err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Debug("Failed to write relayer response", "err", err)
+	}
+}
+
+func (r *Relayer) getBlockCount() (uint64, error) {
+	number := r.chain.CurrentBlock().NumberU64()
+	if number == 0 {
+		return 0, errInternal
+	}
+	return number, nil
+}
+
+func (r *Relayer) sendRechargeTransaction(params json.RawMessage) (interface{}, error) {
+	var args struct {
+		TxID string `json:"txid"`
+	}
+	if err := json.Unmarshal(params, &args); err != nil {
+		return nil, errCodeInvalidMainchainTx
+	}
+	sideTx, err := r.rechargeTx(common.HexToHash(args.TxID))
+	if err != nil {
+		return nil, errCodeInvalidMainchainTx
+	}
+	if sideTx != (common.Hash{}) {
+		return nil, errCodeMainchainTxDuplicate
+	}
+	return sideTx.Hex(), nil
+}
+
+func (r *Relayer) getWithdrawTransaction(params json.RawMessage) (interface{}, error) {
+	var args struct {
+		TxID string `json:"txid"`
+	}
+	if err := json.Unmarshal(params, &args); err != nil {
+		return nil, err
+	}
+	hash := common.HexToHash(args.TxID)
+	withdrawal, err := r.TransactionWithdrawal(hash)
+	if err != nil {
+		return nil, err
+	}
+	return newWithdrawTx(hash, withdrawal), nil
+}
+
+func (r *Relayer) getWithdrawTransactionsByHeight(params json.RawMessage) (interface{}, error) {
+	var args struct {
+		Height json.Number `json:"height"`
+	}
+	if err := json.Unmarshal(params, &args); err != nil {
+		return nil, err
+	}
+	height, err := strconv.ParseUint(args.Height.String(), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*withdrawTx, 0)
+	if height <= heightOffset+1 {
+		return txs, nil
+	}
+	withdrawals, err := r.BlockWithdrawals(height - heightOffset)
+	if err != nil {
+		return nil, err
+	}
+	for _, withdrawal := range withdrawals {
+		txs = append(txs, newWithdrawTx(withdrawal.TxHash, withdrawal))
+	}
+	return txs, nil
+}
+
+func (r *Relayer) getExistDepositTransactions(params json.RawMessage) (interface{}, error) {
+	var args struct {
+		Txs []string `json:"txs"`
+	}
+	if err := json.Unmarshal(params, &args); err != nil {
+		return nil, err
+	}
+	exist := make([]string, 0)
+	for _, tx := range args.Txs {
+		sideTx, err := r.rechargeTx(common.HexToHash(tx))
+		if err != nil {
+			return nil, err
+		}
+		if sideTx != (common.Hash{}) {
+			exist = append(exist, common.HexToHash(tx).Hex())
+		}
+	}
+	return exist, nil
+}
@@ -0,0 +1,287 @@
+// Copyright 2018 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package relayer
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/accounts/abi"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/core/types"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rlp"
+
+	elacom "github.com/elastos/Elastos.ELA/common"
+)
+
+// payloadABI is the interface of the events emitted by the cross chain payload
+// contract deployed at the black contract address.
+const payloadABI = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"_addr","type":"string"},{"indexed":false,"name":"_amount","type":"uint256"},{"indexed":false,"name":"_crosschainamount","type":"uint256"},{"indexed":true,"name":"_sender","type":"address"}],"name":"PayloadReceived","type":"event"}]`
+
+var (
+	payloadEvent   abi.Event
+	payloadTopic   common.Hash
+	payloadArgs    abi.Arguments
+	rate           = big.NewInt(1e10) // wei per sela
+	minWithdrawFee = big.NewInt(1e14) // minimum fee accepted by the contract, in wei
+
+	errInvalidAmount = errors.New("withdraw amount is not a multiple of sela")
+	errInvalidFee    = errors.New("withdraw fee is not accepted by the contract")
+	errNoAddress     = errors.New("withdraw address is empty")
+)
+
+func init() {
+	parsed, err := abi.JSON(strings.NewReader(payloadABI))
+	if err != nil {
+		panic(err)
+	}
+	payloadEvent = parsed.Events["PayloadReceived"]
+	payloadTopic = payloadEvent.ID()
+	payloadArgs = payloadEvent.Inputs.NonIndexed()
+}
+
+// WithdrawAsset is an output of a withdrawal to the main chain, the amounts are in wei.
+type WithdrawAsset struct {
+	Address          string
+	Amount           *big.Int // value paid to the contract, fee included
+	CrossChainAmount *big.Int // value received on the main chain
+}
+
+// Fee returns the fee of the withdraw output.
+func (a *WithdrawAsset) Fee() *big.Int {
+	return new(big.Int).Sub(a.Amount, a.CrossChainAmount)
+}
+
+// validate checks the asset against the rules enforced by the payload contract.
+func (a *WithdrawAsset) validate() error {
+	if a.Address == "" {
+		return errNoAddress
+	}
+	if a.Amount.Sign() <= 0 || new(big.Int).Mod(a.Amount, rate).Sign() != 0 {
+		return errInvalidAmount
+	}
+	fee := a.Fee()
+	if fee.Cmp(minWithdrawFee) < 0 || new(big.Int).Mod(fee, rate).Sign() != 0 {
+		return errInvalidFee
+	}
+	if a.CrossChainAmount.Cmp(fee) < 0 {
+		return errInvalidFee
+	}
+	return nil
+}
+
+// Withdrawal is a side chain transaction sending assets to the main chain.
+type Withdrawal struct {
+	TxHash      common.Hash
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Assets      []*WithdrawAsset
+}
+
+// blockRecord lists the withdrawals relayed from a block.
+type blockRecord struct {
+	Hash common.Hash
+	Txs  []common.Hash
+}
+
+// Cursor is the range of the canonical blocks whose withdrawals have been relayed.
+type Cursor struct {
+	First  uint64      // first relayed block
+	Number uint64      // last relayed block
+	Hash   common.Hash // hash of the last relayed block
+}
+
+var (
+	cursorKey   = []byte("LastRelayed")
+	blockPrefix = []byte("b") // blockPrefix + num (uint64 big endian) -> blockRecord
+	txPrefix    = []byte("t") // txPrefix + hash -> Withdrawal
+)
+
+func blockKey(number uint64) []byte {
+	key := make([]byte, len(blockPrefix)+8)
+	copy(key, blockPrefix)
+	binary.BigEndian.PutUint64(key[len(blockPrefix):], number)
+	return key
+}
+
+func txKey(hash common.Hash) []byte {
+	return append(append([]byte{}, txPrefix...), hash.Bytes()...)
+}
+
+// DecodeWithdrawals builds the withdrawals of the payload contract logs, the
+// assets violating the contract rules are dropped.
+func DecodeWithdrawals(contract common.Address, logs []*types.Log) []*Withdrawal {
+	var (
+		withdrawals []*Withdrawal
+		last        *Withdrawal
+	)
+	for _, l := range logs {
+		if l.Removed || l.Address != contract || len(l.Topics) == 0 || l.Topics[0] != payloadTopic {
+			continue
+		}
+		asset, err := decodeAsset(l.Data)
+		if err != nil {
+			log.Warn("Drop invalid withdraw asset", "tx", l.TxHash, "index", l.Index, "err", err)
+			continue
+		}
+		if last == nil || last.TxHash != l.TxHash {
+			last = &Withdrawal{TxHash: l.TxHash, BlockHash: l.BlockHash, BlockNumber: l.BlockNumber}
+			withdrawals = append(withdrawals, last)
+		}
+		last.Assets = append(last.Assets, asset)
+	}
+	return withdrawals
+}
+
+func decodeAsset(data []byte) (*WithdrawAsset, error) {
+	var event struct {
+		Addr             string
+		Amount           *big.Int
+		Crosschainamount *big.Int
+	}
+	if err := payloadArgs.Unpack(&event, data); err != nil {
+		return nil, err
+	}
+	asset := &WithdrawAsset{
+		Address:          event.Addr,
+		Amount:           event.Amount,
+		CrossChainAmount: event.Crosschainamount,
+	}
+	if err := asset.validate(); err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// formatAmount converts the amount in wei to the decimal ELA amount used by the arbiters.
+func formatAmount(wei *big.Int) string {
+	sela := new(big.Int).Div(wei, rate)
+	amount := elacom.Fixed64(sela.Int64()).String()
+	if strings.Contains(amount, ".") {
+		amount = strings.TrimRight(strings.TrimRight(amount, "0"), ".")
+	}
+	return amount
+}
+
+// ReadCursor retrieves the relay cursor, nil if nothing has been relayed.
+func ReadCursor(db ethdb.KeyValueReader) *Cursor {
+	data, _ := db.Get(cursorKey)
+	if len(data) == 0 {
+		return nil
+	}
+	cursor := new(Cursor)
+	if err := rlp.DecodeBytes(data, cursor); err != nil {
+		log.Error("Invalid relay cursor", "err", err)
+		return nil
+	}
+	return cursor
+}
+
+// writeCursor stores the relay cursor, a nil cursor is removed.
+func writeCursor(db ethdb.KeyValueWriter, cursor *Cursor) error {
+	if cursor == nil {
+		return db.Delete(cursorKey)
+	}
+	data, err := rlp.EncodeToBytes(cursor)
+	if err != nil {
+		return err
+	}
+	return db.Put(cursorKey, data)
+}
+
+// ReadWithdrawal retrieves the relayed withdrawal of the transaction, nil if not found.
+func ReadWithdrawal(db ethdb.KeyValueReader, hash common.Hash) *Withdrawal {
+	data, _ := db.Get(txKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	withdrawal := new(Withdrawal)
+	if err := rlp.DecodeBytes(data, withdrawal); err != nil {
+		log.Error("Invalid withdrawal", "hash", hash, "err", err)
+		return nil
+	}
+	return withdrawal
+}
+
+// ReadBlockWithdrawals retrieves the relayed withdrawals of the block.
+func ReadBlockWithdrawals(db ethdb.KeyValueReader, number uint64) []*Withdrawal {
+	record := readBlockRecord(db, number)
+	if record == nil {
+		return nil
+	}
+	withdrawals := make([]*Withdrawal, 0, len(record.Txs))
+	for _, hash := range record.Txs {
+		if withdrawal := ReadWithdrawal(db, hash); withdrawal != nil {
+			withdrawals = append(withdrawals, withdrawal)
+		}
+	}
+	return withdrawals
+}
+
+func readBlockRecord(db ethdb.KeyValueReader, number uint64) *blockRecord {
+	data, _ := db.Get(blockKey(number))
+	if len(data) == 0 {
+		return nil
+	}
+	record := new(blockRecord)
+	if err := rlp.DecodeBytes(data, record); err != nil {
+		log.Error("Invalid relayed block", "number", number, "err", err)
+		return nil
+	}
+	return record
+}
+
+// writeBlockWithdrawals stores the withdrawals of the block, empty blocks are not recorded.
+func writeBlockWithdrawals(db ethdb.KeyValueWriter, number uint64, hash common.Hash, withdrawals []*Withdrawal) error {
+	if len(withdrawals) == 0 {
+		return nil
+	}
+	record := &blockRecord{Hash: hash}
+	for _, withdrawal := range withdrawals {
+		data, err := rlp.EncodeToBytes(withdrawal)
+		if err != nil {
+			return err
+		}
+		if err := db.Put(txKey(withdrawal.TxHash), data); err != nil {
+			return err
+		}
+		record.Txs = append(record.Txs, withdrawal.TxHash)
+	}
+	data, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		return err
+	}
+	return db.Put(blockKey(number), data)
+}
+
+// deleteBlockWithdrawals removes the withdrawals relayed from the block.
+func deleteBlockWithdrawals(db ethdb.KeyValueStore, batch ethdb.KeyValueWriter, number uint64) error {
+	record := readBlockRecord(db, number)
+	if record == nil {
+		return nil
+	}
+	for _, hash := range record.Txs {
+		if err := batch.Delete(txKey(hash)); err != nil {
+			return err
+		}
+	}
+	return batch.Delete(blockKey(number))
+}
@@ -0,0 +1,140 @@
+// Copyright 2019 The Elastos.ELA.SideChain.ETH Authors
+// This file is part of the Elastos.ELA.SideChain.ETH library.
+//
+// The Elastos.ELA.SideChain.ETH library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Elastos.ELA.SideChain.ETH library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Elastos.ELA.SideChain.ETH library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/common/hexutil"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/signer/storage"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	daccount "github.com/elastos/Elastos.ELA/dpos/account"
+)
+
+// DposSignerAPI serves the producer key of an Elastos side chain node on the
+// dpos namespace, so the key never resides in the node. Every request is
+// approved by the UI or the rules like a SignData request.
+type DposSignerAPI struct {
+	account daccount.Account
+	ui      UIClientAPI
+}
+
+// NewDposSignerAPI opens the ELA keystore of the producer key. The password is
+// taken from the credentials storage or else asked from the user.
+func NewDposSignerAPI(keystore string, ui UIClientAPI, credentials storage.Storage) (*DposSignerAPI, error) {
+	password, err := credentials.Get("dpos:" + keystore)
+	if err != nil {
+		resp, err := ui.OnInputRequired(UserInputRequest{
+			Title:      "Producer keystore",
+			Prompt:     fmt.Sprintf("Please enter the password of the producer keystore %s", keystore),
+			IsPassword: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		password = resp.Text
+	}
+	account, err := dpos.GetDposAccount(keystore, []byte(password))
+	if err != nil {
+		return nil, err
+	}
+	return &DposSignerAPI{account: account, ui: ui}, nil
+}
+
+// PublicKey returns the public key of the producer.
+func (api *DposSignerAPI) PublicKey(ctx context.Context) (hexutil.Bytes, error) {
+	return api.account.PublicKeyBytes(), nil
+}
+
+// SignData signs the proposal, vote or other data of the given content type
+// with the producer key.
+func (api *DposSignerAPI) SignData(ctx context.Context, contentType string, data hexutil.Bytes) (hexutil.Bytes, error) {
+	messages, err := dposMessages(contentType, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.approve(ctx, contentType, data, messages); err != nil {
+		return nil, err
+	}
+	signature := api.account.Sign(data)
+	if signature == nil {
+		return nil, fmt.Errorf("failed to sign %s", contentType)
+	}
+	return signature, nil
+}
+
+// DecryptAddr decrypts the DAddr cipher sent to the producer.
+func (api *DposSignerAPI) DecryptAddr(ctx context.Context, cipher hexutil.Bytes) (string, error) {
+	messages := []*NameValueType{{Name: "cipher", Typ: "bytes", Value: cipher.String()}}
+	if err := api.approve(ctx, dpos.MimetypeDposData, cipher, messages); err != nil {
+		return "", err
+	}
+	return api.account.DecryptAddr(cipher)
+}
+
+func (api *DposSignerAPI) approve(ctx context.Context, contentType string, data []byte, messages []*NameValueType) error {
+	hash := sha256.Sum256(data)
+	req := &SignDataRequest{
+		ContentType: contentType,
+		Rawdata:     data,
+		Messages:    messages,
+		Hash:        hash[:],
+		Meta:        MetadataFromContext(ctx),
+	}
+	res, err := api.ui.ApproveSignData(req)
+	if err != nil {
+		return err
+	}
+	if !res.Approved {
+		return ErrRequestDenied
+	}
+	return nil
+}
+
+// dposMessages decodes the data of the content type for the UI.
+func dposMessages(contentType string, data []byte) ([]*NameValueType, error) {
+	switch contentType {
+	case dpos.MimetypeDposProposal:
+		var proposal payload.DPOSProposal
+		if err := proposal.DeserializeUnSigned(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		return []*NameValueType{
+			{Name: "sponsor", Typ: "bytes", Value: hexutil.Encode(proposal.Sponsor)},
+			{Name: "block", Typ: "hash", Value: proposal.BlockHash.String()},
+			{Name: "viewoffset", Typ: "uint32", Value: fmt.Sprintf("%d", proposal.ViewOffset)},
+		}, nil
+	case dpos.MimetypeDposVote:
+		var vote payload.DPOSProposalVote
+		if err := vote.DeserializeUnsigned(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		return []*NameValueType{
+			{Name: "proposal", Typ: "hash", Value: vote.ProposalHash.String()},
+			{Name: "signer", Typ: "bytes", Value: hexutil.Encode(vote.Signer)},
+			{Name: "accept", Typ: "bool", Value: fmt.Sprintf("%v", vote.Accept)},
+		}, nil
+	case dpos.MimetypeDposData:
+		return []*NameValueType{{Name: "data", Typ: "bytes", Value: hexutil.Encode(data)}}, nil
+	}
+	return nil, fmt.Errorf("unsupported content type %s", contentType)
+}
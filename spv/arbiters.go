@@ -0,0 +1,158 @@
+package spv
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/blocksigner"
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rlp"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+)
+
+//Arbiters history prefix, ArbitersPrefix + working main chain height -> RLP encoded public keys
+const ArbitersPrefix string = "Arb-"
+
+func arbitersKey(workingHeight uint32) []byte {
+	enc := make([]byte, 4)
+	binary.BigEndian.PutUint32(enc, workingHeight)
+	return append([]byte(ArbitersPrefix), enc...)
+}
+
+//WriteArbiters stores the public keys of the arbiters working from the main chain height.
+func WriteArbiters(db ethdb.KeyValueWriter, workingHeight uint32, arbiters [][]byte) error {
+	data, err := rlp.EncodeToBytes(arbiters)
+	if err != nil {
+		return err
+	}
+	return db.Put(arbitersKey(workingHeight), data)
+}
+
+//ReadAllArbiters returns the arbiters history, indexed by the main chain height they work from.
+func ReadAllArbiters(db ethdb.Iteratee) map[uint32][][]byte {
+	history := make(map[uint32][][]byte)
+	it := db.NewIteratorWithPrefix([]byte(ArbitersPrefix))
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()[len(ArbitersPrefix):]
+		if len(key) != 4 {
+			continue
+		}
+		var arbiters [][]byte
+		if err := rlp.DecodeBytes(it.Value(), &arbiters); err != nil {
+			log.Error("Invalid arbiters record", "key", it.Key(), "err", err)
+			continue
+		}
+		history[binary.BigEndian.Uint32(key)] = arbiters
+	}
+	return history
+}
+
+//newArbiterSigners returns the registry of the arbiter block signers indexed by the lower case hex
+//encoded public keys, the keys which are not public keys of the main chain are left out.
+func newArbiterSigners(registry map[string]ethCommon.Address) map[string]ethCommon.Address {
+	signers := make(map[string]ethCommon.Address, len(registry))
+	for key, signer := range registry {
+		publicKey, err := hex.DecodeString(strings.TrimPrefix(key, "0x"))
+		if err == nil {
+			_, err = crypto.DecodePoint(publicKey)
+		}
+		if err != nil {
+			log.Warn("Invalid arbiter public key", "key", key, "err", err)
+			continue
+		}
+		signers[hex.EncodeToString(publicKey)] = signer
+	}
+	return signers
+}
+
+//ArbiterSigner returns the side chain block signer registered for the arbiter public key, false if
+//the arbiter has none.
+func (s *Service) ArbiterSigner(publicKey []byte) (ethCommon.Address, bool) {
+	if s == nil {
+		return ethCommon.Address{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	signer, ok := s.arbiterSigners[hex.EncodeToString(publicKey)]
+	return signer, ok
+}
+
+//ArbiterPublicKey returns the public key of the arbiter the side chain block signer is registered
+//for, false if the signer is not registered.
+func (s *Service) ArbiterPublicKey(signer ethCommon.Address) ([]byte, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for key, address := range s.arbiterSigners {
+		if address == signer {
+			publicKey, _ := hex.DecodeString(key)
+			return publicKey, true
+		}
+	}
+	return nil, false
+}
+
+//arbiterKeys returns the public keys of the arbiters of the next turn.
+func arbiterKeys(info *payload.NextTurnDPOSInfo) [][]byte {
+	keys := make([][]byte, 0, len(info.CRPublicKeys)+len(info.DPOSPublicKeys))
+	for _, key := range info.CRPublicKeys {
+		if len(key) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range info.DPOSPublicKeys {
+		if len(key) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+//setBlockSigners sets the block signers of the arbiters working from the main chain height, the
+//arbiters without a registered signer are left out, nothing is set if none of them has one.
+func (s *Service) setBlockSigners(workingHeight uint32, arbiters [][]byte) {
+	signers := make([]ethCommon.Address, 0, len(arbiters))
+	for _, key := range arbiters {
+		if signer, ok := s.ArbiterSigner(key); ok {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) == 0 {
+		log.Warn("No block signer in arbiters", "workingHeight", workingHeight, "count", len(arbiters))
+		return
+	}
+	blocksigner.SetBlockSigners(uint64(workingHeight), signers)
+}
+
+//recordArbiters stores the arbiters of the next turn in the history and sets their block signers.
+func (s *Service) recordArbiters(info *payload.NextTurnDPOSInfo) {
+	arbiters := arbiterKeys(info)
+	if len(arbiters) == 0 {
+		return
+	}
+	if s != nil && s.db != nil {
+		if err := WriteArbiters(s.db, info.WorkingHeight, arbiters); err != nil {
+			log.Error("Write arbiters failed", "workingHeight", info.WorkingHeight, "err", err)
+		}
+	}
+	s.setBlockSigners(info.WorkingHeight, arbiters)
+}
+
+//loadArbiters sets the block signers of the arbiters history.
+func (s *Service) loadArbiters() {
+	if s == nil || s.db == nil {
+		return
+	}
+	for workingHeight, arbiters := range ReadAllArbiters(s.db) {
+		s.setBlockSigners(workingHeight, arbiters)
+	}
+}
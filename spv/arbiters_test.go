@@ -0,0 +1,92 @@
+package spv
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/blocksigner"
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb/memorydb"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	elacrypto "github.com/elastos/Elastos.ELA/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArbiterSigners(t *testing.T) {
+	genesis := ethCommon.Address{0xff}
+	blocksigner.Signers = map[ethCommon.Address]struct{}{genesis: {}}
+	defer func() {
+		blocksigner.Signers = nil
+		blocksigner.ResetBlockSigners()
+	}()
+
+	var (
+		keys     [][]byte
+		signers  []ethCommon.Address
+		registry = make(map[string]ethCommon.Address)
+	)
+	for i := 0; i < 3; i++ {
+		_, pub, err := elacrypto.GenerateKeyPair()
+		assert.NoError(t, err)
+		key, err := pub.EncodePoint(true)
+		assert.NoError(t, err)
+		keys = append(keys, key)
+		signers = append(signers, ethCommon.Address{byte(i + 1)})
+		registry[hex.EncodeToString(key)] = signers[i]
+	}
+	// a registered key is looked up whatever its case and prefix, an invalid key is ignored
+	registry["0x"+strings.ToUpper(hex.EncodeToString(keys[0]))] = signers[0]
+	registry["01"] = ethCommon.Address{0xee}
+	cfg := &Config{ArbiterSigners: registry}
+
+	chain := NewFakeMainChain()
+	db := memorydb.New()
+	s := New(cfg, db, chain, nil)
+	assert.NoError(t, s.Start())
+	defer s.Stop()
+
+	chain.RotateArbiters(&payload.NextTurnDPOSInfo{WorkingHeight: 100, CRPublicKeys: keys[:1], DPOSPublicKeys: keys[1:2]})
+	chain.RotateArbiters(&payload.NextTurnDPOSInfo{WorkingHeight: 200, DPOSPublicKeys: [][]byte{keys[2], {0x01}}})
+
+	assert.Equal(t, 1, blocksigner.GetBlockSignersCount(99))
+	assert.True(t, blocksigner.ValidateSigner(99, genesis))
+	assert.Equal(t, 2, blocksigner.GetBlockSignersCount(100))
+	assert.True(t, blocksigner.ValidateSigner(150, signers[1]))
+	assert.False(t, blocksigner.ValidateSigner(150, genesis))
+	assert.Equal(t, 1, blocksigner.GetBlockSignersCount(200))
+	assert.True(t, blocksigner.ValidateSigner(300, signers[2]))
+	assert.False(t, blocksigner.ValidateSigner(300, signers[0]))
+
+	// the history is restored from the database
+	assert.Equal(t, map[uint32][][]byte{100: keys[:2], 200: {keys[2], {0x01}}}, ReadAllArbiters(db))
+	blocksigner.ResetBlockSigners()
+	New(cfg, db, nil, nil).loadArbiters()
+	assert.True(t, blocksigner.ValidateSigner(150, signers[0]))
+	assert.True(t, blocksigner.ValidateSigner(200, signers[2]))
+
+	// arbiters without a registered signer are not block signers
+	blocksigner.ResetBlockSigners()
+	New(&Config{}, db, nil, nil).loadArbiters()
+	assert.Equal(t, 1, blocksigner.GetBlockSignersCount(150))
+	assert.True(t, blocksigner.ValidateSigner(150, genesis))
+}
+
+func TestArbiterPublicKey(t *testing.T) {
+	_, pub, err := elacrypto.GenerateKeyPair()
+	assert.NoError(t, err)
+	key, err := pub.EncodePoint(true)
+	assert.NoError(t, err)
+	signer := ethCommon.Address{0x01}
+	s := New(&Config{ArbiterSigners: map[string]ethCommon.Address{hex.EncodeToString(key): signer}}, nil, nil, nil)
+
+	publicKey, ok := s.ArbiterPublicKey(signer)
+	assert.True(t, ok)
+	assert.Equal(t, key, publicKey)
+	_, ok = s.ArbiterPublicKey(ethCommon.Address{0x02})
+	assert.False(t, ok)
+	address, ok := s.ArbiterSigner(key)
+	assert.True(t, ok)
+	assert.Equal(t, signer, address)
+}
@@ -0,0 +1,159 @@
+package spv
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync/atomic"
+
+	ethereum "github.com/elastos/Elastos.ELA.SideChain.ETH"
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"golang.org/x/net/context"
+)
+
+//MaxBatchRecharge is the maximum number of main chain transactions recharged by a batch recharge transaction
+const MaxBatchRecharge = 100
+
+//batchRechargePrefix marks the data of a batch recharge transaction, followed by the 32 byte main chain transaction hashes
+var batchRechargePrefix = []byte("RCHB")
+
+var errEmptyBatch = errors.New("no recharge to send")
+
+//EncodeBatchRecharge returns the data of the transaction recharging the main chain transactions.
+func EncodeBatchRecharge(hashes []ethCommon.Hash) []byte {
+	data := make([]byte, 0, len(batchRechargePrefix)+len(hashes)*ethCommon.HashLength)
+	data = append(data, batchRechargePrefix...)
+	for _, hash := range hashes {
+		data = append(data, hash.Bytes()...)
+	}
+	return data
+}
+
+//DecodeBatchRecharge returns the main chain transactions recharged by the transaction data, false if
+//the data is not a batch recharge.
+func DecodeBatchRecharge(data []byte) ([]ethCommon.Hash, bool) {
+	if !bytes.HasPrefix(data, batchRechargePrefix) {
+		return nil, false
+	}
+	data = data[len(batchRechargePrefix):]
+	count := len(data) / ethCommon.HashLength
+	if count == 0 || count > MaxBatchRecharge || len(data)%ethCommon.HashLength != 0 {
+		return nil, false
+	}
+	hashes := make([]ethCommon.Hash, count)
+	for i := range hashes {
+		hashes[i] = ethCommon.BytesToHash(data[i*ethCommon.HashLength : (i+1)*ethCommon.HashLength])
+	}
+	return hashes, true
+}
+
+//IsBatchRecharge returns whether the data is the data of a batch recharge transaction.
+func IsBatchRecharge(data []byte) bool {
+	_, ok := DecodeBatchRecharge(data)
+	return ok
+}
+
+//FindRechargeFee returns the fee of the outputs of the deposit which can be credited.
+func (s *Service) FindRechargeFee(transactionHash string) *big.Int {
+	fee := new(big.Int)
+	for _, o := range s.FindRechargeOutputs(transactionHash) {
+		if o.Output.Cmp(o.Fee) > 0 {
+			fee.Add(fee, o.Fee)
+		}
+	}
+	return fee
+}
+
+//sendRechargeBatch sends the queued recharges from the seek in a single batch recharge transaction, it
+//returns false if nothing is left to send now.
+func (s *Service) sendRechargeBatch(from ethCommon.Address, seek, index uint64) bool {
+	var (
+		hashes []ethCommon.Hash
+		seeks  []uint64
+		fee    = new(big.Int)
+	)
+	client := s.sideChain()
+	if client == nil {
+		return false
+	}
+	for ; seek < index && len(hashes) < MaxBatchRecharge; seek++ {
+		txHash, ok := ReadQueuedDeposit(s.db, seek)
+		if !ok {
+			// the entry has been removed by a main chain rollback
+			log.Error("get queued deposit: not found", "seek", seek)
+			seeks = append(seeks, seek)
+			continue
+		}
+		elaTx := ethCommon.Bytes2Hex(txHash.Bytes())
+		outputFee := s.FindRechargeFee(elaTx)
+		if outputFee.Sign() <= 0 {
+			break
+		}
+		ethTx, err := client.StorageAt(context.Background(), ethCommon.Address{}, ethCommon.HexToHash("0x"+elaTx), nil)
+		if err != nil {
+			log.Error("IpcClient StorageAt: ", "err", err, "elaHash", elaTx)
+			break
+		}
+		seeks = append(seeks, seek)
+		if ethCommon.BytesToHash(ethTx) != (ethCommon.Hash{}) {
+			continue
+		}
+		hashes = append(hashes, ethCommon.HexToHash(elaTx))
+		fee.Add(fee, outputFee)
+	}
+	if len(seeks) == 0 {
+		return false
+	}
+	hash, err := s.sendBatchTransaction(client, from, hashes, fee)
+	if err == errEmptyBatch {
+		for _, seek := range seeks {
+			s.setNextSeek(seek)
+		}
+		return true
+	}
+	if atomic.LoadInt32(&s.canSend) == 0 {
+		return false
+	}
+	for _, seek := range seeks {
+		s.setNextSeek(seek)
+	}
+	for _, elaTx := range hashes {
+		if err != nil {
+			s.UpTransactionIndex(elaTx.String())
+			continue
+		}
+		s.setDepositStatus(elaTx.String(), DepositSent, hash)
+	}
+	if err != nil {
+		log.Info("Send batch recharge failed", "error", err.Error(), "count", len(hashes))
+		return false
+	}
+	log.Info("Cross chain batch Transaction", "count", len(hashes), "ethTh", hash.String())
+	return true
+}
+
+//sendBatchTransaction sends the batch recharge transaction of the main chain transactions to the txpool.
+func (s *Service) sendBatchTransaction(client SideChain, from ethCommon.Address, hashes []ethCommon.Hash, fee *big.Int) (ethCommon.Hash, error) {
+	if len(hashes) == 0 {
+		return ethCommon.Hash{}, errEmptyBatch
+	}
+	msg := ethereum.CallMsg{From: from, To: &ethCommon.Address{}, Data: []byte{}}
+	gasLimit, err := client.EstimateGas(context.Background(), msg)
+	if err != nil {
+		log.Error("IpcClient EstimateGas:", "err", err, "count", len(hashes))
+		return ethCommon.Hash{}, err
+	}
+	if gasLimit == 0 {
+		return ethCommon.Hash{}, errors.New("gasLimit is zero")
+	}
+	data := EncodeBatchRecharge(hashes)
+	gasLimit = gasLimit*GASLimtScale + uint64(len(data))*params.TxDataNonZeroGasFrontier
+	if atomic.LoadInt32(&s.canSend) == 0 {
+		return ethCommon.Hash{}, errors.New("canSend is 0")
+	}
+	price := new(big.Int).Quo(fee, new(big.Int).SetUint64(gasLimit))
+	callmsg := ethereum.TXMsg{From: from, To: &ethCommon.Address{}, Gas: gasLimit, Data: data, GasPrice: price}
+	return client.SendPublicTransaction(context.Background(), callmsg)
+}
@@ -0,0 +1,65 @@
+package spv
+
+import (
+	"math/big"
+	"testing"
+
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb/memorydb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchRechargeData(t *testing.T) {
+	hashes := []ethCommon.Hash{{1}, {2}, {1}}
+	data := EncodeBatchRecharge(hashes)
+	decoded, ok := DecodeBatchRecharge(data)
+	assert.True(t, ok)
+	assert.Equal(t, hashes, decoded)
+
+	// the single recharge data is not a batch
+	assert.False(t, IsBatchRecharge(hashes[0].Bytes()))
+	assert.False(t, IsBatchRecharge(EncodeBatchRecharge(nil)))
+	assert.False(t, IsBatchRecharge(data[:len(data)-1]))
+	assert.False(t, IsBatchRecharge(EncodeBatchRecharge(make([]ethCommon.Hash, MaxBatchRecharge+1))))
+	assert.True(t, IsBatchRecharge(EncodeBatchRecharge(make([]ethCommon.Hash, MaxBatchRecharge))))
+}
+
+func TestSendRechargeBatch(t *testing.T) {
+	db := memorydb.New()
+	minted := ethCommon.HexToHash("03")
+	client := &fakeSideChain{minted: map[ethCommon.Hash]ethCommon.Hash{minted: {3}}}
+	s := New(&Config{BatchRecharge: func() bool { return true }}, db, nil, client)
+	s.canSend = 1
+
+	for _, elaTx := range []string{"01", "02", "03"} {
+		assert.NoError(t, WriteDeposit(db, &DepositRecord{
+			MainTxHash: ethCommon.HexToHash(elaTx),
+			Outputs:    []DepositOutput{{Address: ethCommon.Address{1}.String(), Amount: 100000000, Fee: 10000}},
+			Fee:        10000,
+		}))
+		s.UpTransactionIndex(elaTx)
+	}
+	index := GetUnTransactionNum(db, QueueIndexKey)
+	assert.True(t, s.sendRechargeBatch(ethCommon.Address{9}, 1, index))
+
+	// the minted deposit is skipped and the others are sent at once
+	assert.Equal(t, 1, len(client.sent))
+	msg := client.sent[0]
+	pending := []ethCommon.Hash{ethCommon.HexToHash("01"), ethCommon.HexToHash("02")}
+	assert.Equal(t, EncodeBatchRecharge(pending), msg.Data)
+	assert.Equal(t, params.TxGas*GASLimtScale+uint64(len(msg.Data))*params.TxDataNonZeroGasFrontier, msg.Gas)
+	assert.Equal(t, new(big.Int).Quo(SelaToWei(20000), new(big.Int).SetUint64(msg.Gas)), msg.GasPrice)
+	for _, hash := range pending {
+		record := s.GetDeposit(hash)
+		assert.Equal(t, DepositSent, record.Status)
+		assert.NotEqual(t, ethCommon.Hash{}, record.SideTxHash)
+	}
+	assert.Equal(t, 0, len(s.QueuedDeposits()))
+	assert.Equal(t, index, GetUnTransactionNum(db, QueueSeekKey))
+
+	// nothing is left to send
+	assert.False(t, s.sendRechargeBatch(ethCommon.Address{9}, index, index))
+	assert.Equal(t, 1, len(client.sent))
+}
@@ -20,6 +20,7 @@ func (param *auxParam) clean() {
 }
 
 type BlockListener struct {
+	chain       *spvMainChain
 	blockNumber uint32
 	param       auxParam
 	handle      func(block interface{}) error
@@ -27,6 +28,7 @@ type BlockListener struct {
 
 func (l *BlockListener) NotifyBlock(block *util.Block) {
 	l.blockNumber = block.Height
+	l.chain.handler.OnBlock(block.Height)
 	if l.blockNumber >= l.param.height {
 		l.StoreAuxBlock(block)
 		log.Info("BlockListener handle block ", "height", block.Height)
@@ -87,4 +89,5 @@ func (l *BlockListener) onBlockHandled(block interface{}) {
 			log.Info(common.Bytes2Hex(arbiter) + "\n")
 		}
 		log.Info("work height", "height", payloadData.WorkingHeight)
+		l.chain.handler.OnArbiters(payloadData)
 }
\ No newline at end of file
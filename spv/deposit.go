@@ -0,0 +1,396 @@
+package spv
+
+import (
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"time"
+
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/event"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rlp"
+
+	"github.com/elastos/Elastos.ELA/common"
+)
+
+const (
+	//Schema version of the spv recharge database key
+	SchemaVersionKey string = "SchemaVersion"
+
+	//Current schema version of the spv recharge database
+	SchemaVersion uint64 = 2
+
+	//Deposit record prefix, DepositPrefix + main chain transaction hash -> RLP encoded DepositRecord
+	DepositPrefix string = "Dep-"
+
+	//Recharge queue prefix, QueuePrefix + queue index -> main chain transaction hash
+	QueuePrefix string = "Que-"
+
+	//Recharge queue index key, the queue index of the next deposit to queue
+	QueueIndexKey string = "QueueIndex"
+
+	//Recharge queue seek key, the queue index of the next deposit to recharge
+	QueueSeekKey string = "QueueSeek"
+
+	// the recharge queue keys of the schema versions before 2, the queued main chain
+	// transaction hashes are hex strings
+	legacyQueuePrefix   = "UnT-"
+	legacyQueueIndexKey = "UnTI"
+	legacyQueueSeekKey  = "UnTS"
+)
+
+// DepositStatus is the recharge state of a cross chain deposit.
+type DepositStatus uint8
+
+const (
+	//DepositPending is waiting in the recharge queue
+	DepositPending DepositStatus = iota
+
+	//DepositSent has its recharge transaction sent to the transaction pool
+	DepositSent
+
+	//DepositRolledBack is no longer in the canonical main chain
+	DepositRolledBack
+)
+
+func (s DepositStatus) String() string {
+	switch s {
+	case DepositPending:
+		return "pending"
+	case DepositSent:
+		return "sent"
+	case DepositRolledBack:
+		return "rolledback"
+	}
+	return "unknown"
+}
+
+// DepositOutput is an output of a cross chain deposit, the amounts are in sela.
+type DepositOutput struct {
+	Address string
+	Amount  uint64
+	Fee     uint64
+}
+
+// DepositRecord is the recharge record of a cross chain deposit of the main chain.
+type DepositRecord struct {
+	MainTxHash ethCommon.Hash
+	MainHeight uint32
+	Outputs    []DepositOutput
+	Fee        uint64
+	Status     DepositStatus
+	SideTxHash ethCommon.Hash
+	CreatedAt  uint64
+	UpdatedAt  uint64
+}
+
+func depositKey(hash ethCommon.Hash) []byte {
+	return append([]byte(DepositPrefix), hash.Bytes()...)
+}
+
+// ReadDeposit retrieves the deposit record of the main chain transaction, nil if not found.
+func ReadDeposit(db ethdb.KeyValueReader, hash ethCommon.Hash) *DepositRecord {
+	data, err := db.Get(depositKey(hash))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	record := new(DepositRecord)
+	if err := rlp.DecodeBytes(data, record); err != nil {
+		log.Error("Invalid deposit record", "hash", hash.String(), "err", err)
+		return nil
+	}
+	return record
+}
+
+// WriteDeposit stores the deposit record.
+func WriteDeposit(db ethdb.KeyValueWriter, record *DepositRecord) error {
+	data, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		return err
+	}
+	return db.Put(depositKey(record.MainTxHash), data)
+}
+
+// ReadAllDeposits retrieves every deposit record.
+func ReadAllDeposits(db ethdb.Iteratee) []*DepositRecord {
+	var records []*DepositRecord
+	it := db.NewIteratorWithPrefix([]byte(DepositPrefix))
+	defer it.Release()
+	for it.Next() {
+		record := new(DepositRecord)
+		if err := rlp.DecodeBytes(it.Value(), record); err != nil {
+			log.Error("Invalid deposit record", "key", it.Key(), "err", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func queueKey(index uint64) []byte {
+	return append([]byte(QueuePrefix), encodeUnTransactionNumber(index)...)
+}
+
+// ReadQueuedDeposit retrieves the main chain transaction at the index of the recharge queue, false
+// if the entry has been removed.
+func ReadQueuedDeposit(db ethdb.KeyValueReader, index uint64) (ethCommon.Hash, bool) {
+	data, err := db.Get(queueKey(index))
+	if err != nil || len(data) != ethCommon.HashLength {
+		return ethCommon.Hash{}, false
+	}
+	return ethCommon.BytesToHash(data), true
+}
+
+// WriteQueuedDeposit stores the main chain transaction at the index of the recharge queue.
+func WriteQueuedDeposit(db ethdb.KeyValueWriter, index uint64, hash ethCommon.Hash) error {
+	return db.Put(queueKey(index), hash.Bytes())
+}
+
+// DeleteQueuedDeposit removes the entry at the index of the recharge queue.
+func DeleteQueuedDeposit(db ethdb.KeyValueWriter, index uint64) error {
+	return db.Delete(queueKey(index))
+}
+
+// findDeposit returns the deposit record of the main chain transaction which can be recharged.
+func (s *Service) findDeposit(transactionHash string) *DepositRecord {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	record := ReadDeposit(s.db, ethCommon.HexToHash(transactionHash))
+	if record == nil {
+		log.Error("SpvServicedb Get deposit: not found", "elaHash", transactionHash)
+		return nil
+	}
+	if record.Status == DepositRolledBack {
+		return nil
+	}
+	return record
+}
+
+// setDepositStatus updates the status of the deposit record, the side chain transaction is kept if hash is empty.
+func (s *Service) setDepositStatus(transactionHash string, status DepositStatus, sideTx ethCommon.Hash) {
+	if s.db == nil {
+		return
+	}
+	record := ReadDeposit(s.db, ethCommon.HexToHash(transactionHash))
+	if record == nil {
+		return
+	}
+	record.Status = status
+	if sideTx != (ethCommon.Hash{}) {
+		record.SideTxHash = sideTx
+	}
+	record.UpdatedAt = uint64(time.Now().Unix())
+	s.storeDeposit(record)
+}
+
+// storeDeposit writes the deposit record and notifies the subscribers of the change.
+func (s *Service) storeDeposit(record *DepositRecord) {
+	if err := WriteDeposit(s.db, record); err != nil {
+		log.Error("SpvServicedb Put deposit: ", "err", err, "elaHash", record.MainTxHash.String())
+		return
+	}
+	s.depositFeed.Send(record)
+}
+
+// GetDeposit returns the deposit record of the main chain transaction, nil if not found.
+func (s *Service) GetDeposit(hash ethCommon.Hash) *DepositRecord {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return ReadDeposit(s.db, hash)
+}
+
+// QueuedDeposits returns the main chain transactions waiting in the recharge queue in order.
+func (s *Service) QueuedDeposits() []ethCommon.Hash {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	var hashes []ethCommon.Hash
+	it := s.db.NewIteratorWithPrefix([]byte(QueuePrefix))
+	defer it.Release()
+	for it.Next() {
+		hashes = append(hashes, ethCommon.BytesToHash(it.Value()))
+	}
+	return hashes
+}
+
+// SubscribeDepositEvent registers a subscription of the changed deposit records,
+// nothing is sent if the service is nil.
+func (s *Service) SubscribeDepositEvent(ch chan<- *DepositRecord) event.Subscription {
+	if s == nil {
+		return event.NewSubscription(func(quit <-chan struct{}) error {
+			<-quit
+			return nil
+		})
+	}
+	return s.depositFeed.Subscribe(ch)
+}
+
+// SelaToWei converts the amount in sela to the side chain unit.
+func SelaToWei(sela uint64) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(sela), big.NewInt(rate))
+}
+
+// readSchemaVersion returns the schema version of the spv recharge database, 0 for the legacy layout.
+func readSchemaVersion(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get([]byte(SchemaVersionKey))
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// migrateDepositDB upgrades the spv recharge database to the current schema version.
+func migrateDepositDB(db ethdb.KeyValueStore) error {
+	version := readSchemaVersion(db)
+	if version == 0 {
+		if err := migrateLegacyDeposits(db); err != nil {
+			return err
+		}
+		version = 1
+	}
+	if version == 1 {
+		if err := migrateLegacyQueue(db); err != nil {
+			return err
+		}
+		version = 2
+	}
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, version)
+	return db.Put([]byte(SchemaVersionKey), enc)
+}
+
+// migrateLegacyDeposits converts the comma joined Fee, Address and Output entries of the legacy
+// layout into deposit records.
+func migrateLegacyDeposits(db ethdb.KeyValueStore) error {
+	// the queued deposits are pending and the others have been sent
+	queued := make(map[string]bool)
+	it := db.NewIteratorWithPrefix([]byte(legacyQueuePrefix))
+	for it.Next() {
+		queued[strings.ToLower(string(it.Value()))] = true
+	}
+	it.Release()
+	heights := make(map[string]uint32)
+	it = db.NewIteratorWithPrefix([]byte(RechargeHeight))
+	for it.Next() {
+		key := it.Key()[len(RechargeHeight):]
+		if len(key) > 4 {
+			heights[strings.ToLower(string(key[4:]))] = binary.BigEndian.Uint32(key[:4])
+		}
+	}
+	it.Release()
+
+	var hashes []string
+	it = db.NewIterator()
+	for it.Next() {
+		key := string(it.Key())
+		if !strings.HasSuffix(key, "Fee") {
+			continue
+		}
+		hash := strings.TrimSuffix(key, "Fee")
+		if _, err := common.HexStringToBytes(hash); err != nil || len(hash) != 64 {
+			continue
+		}
+		hashes = append(hashes, hash)
+	}
+	it.Release()
+
+	batch := db.NewBatch()
+	now := uint64(time.Now().Unix())
+	kept := 0
+	for _, hash := range hashes {
+		record, err := readLegacyDeposit(db, hash)
+		if err != nil {
+			// keep the entries for a manual recovery rather than losing the deposit
+			log.Error("Keep unparseable legacy deposit", "elaHash", hash, "err", err)
+			kept++
+			continue
+		}
+		record.MainHeight = heights[strings.ToLower(hash)]
+		record.Status = DepositSent
+		if queued[strings.ToLower(hash)] {
+			record.Status = DepositPending
+		}
+		record.CreatedAt, record.UpdatedAt = now, now
+		data, err := rlp.EncodeToBytes(record)
+		if err != nil {
+			return err
+		}
+		batch.Put(depositKey(record.MainTxHash), data)
+		for _, suffix := range []string{"Fee", "Address", "Output"} {
+			batch.Delete([]byte(hash + suffix))
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Migrated spv recharge database", "deposits", len(hashes)-kept, "kept", kept)
+	return nil
+}
+
+// migrateLegacyQueue moves the recharge queue from the hex string entries to the typed keys.
+func migrateLegacyQueue(db ethdb.KeyValueStore) error {
+	batch := db.NewBatch()
+	it := db.NewIteratorWithPrefix([]byte(legacyQueuePrefix))
+	count := 0
+	for it.Next() {
+		key := it.Key()[len(legacyQueuePrefix):]
+		if len(key) != 8 {
+			continue
+		}
+		hash := ethCommon.HexToHash(string(it.Value()))
+		batch.Put(queueKey(binary.BigEndian.Uint64(key)), hash.Bytes())
+		batch.Delete(ethCommon.CopyBytes(it.Key()))
+		count++
+	}
+	it.Release()
+	for legacy, key := range map[string]string{legacyQueueIndexKey: QueueIndexKey, legacyQueueSeekKey: QueueSeekKey} {
+		data, err := db.Get([]byte(legacy))
+		if err != nil {
+			continue
+		}
+		batch.Put([]byte(key), data)
+		batch.Delete([]byte(legacy))
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Migrated spv recharge queue", "deposits", count)
+	return nil
+}
+
+func readLegacyDeposit(db ethdb.KeyValueReader, hash string) (*DepositRecord, error) {
+	var fields [3][]string
+	for i, suffix := range []string{"Fee", "Address", "Output"} {
+		v, err := db.Get([]byte(hash + suffix))
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = strings.Split(string(v), ",")
+	}
+	fees, addrs, outputs := fields[0], fields[1], fields[2]
+	if len(fees) != len(addrs) || len(outputs) != len(addrs) {
+		return nil, errDepositOutputs
+	}
+	record := &DepositRecord{MainTxHash: ethCommon.HexToHash(hash)}
+	for i, addr := range addrs {
+		fee, err := common.StringToFixed64(fees[i])
+		if err != nil {
+			return nil, err
+		}
+		output, err := common.StringToFixed64(outputs[i])
+		if err != nil {
+			return nil, err
+		}
+		record.Outputs = append(record.Outputs, DepositOutput{
+			Address: addr,
+			Amount:  uint64(*output),
+			Fee:     uint64(*fee),
+		})
+		record.Fee += uint64(*fee)
+	}
+	return record, nil
+}
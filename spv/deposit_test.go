@@ -0,0 +1,88 @@
+package spv
+
+import (
+	"testing"
+
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb/memorydb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateDepositDB(t *testing.T) {
+	db := memorydb.New()
+	pending := "aa00000000000000000000000000000000000000000000000000000000000001"
+	sent := "aa00000000000000000000000000000000000000000000000000000000000002"
+	addr := ethCommon.Address{1}.String()
+	for _, hash := range []string{pending, sent} {
+		assert.NoError(t, db.Put([]byte(hash+"Fee"), []byte("0.0001,0.0002")))
+		assert.NoError(t, db.Put([]byte(hash+"Address"), []byte(addr+","+addr)))
+		assert.NoError(t, db.Put([]byte(hash+"Output"), []byte("1,2.5")))
+	}
+	assert.NoError(t, db.Put(append([]byte(legacyQueuePrefix), encodeUnTransactionNumber(1)...), []byte(pending)))
+	assert.NoError(t, db.Put([]byte(legacyQueueIndexKey), encodeUnTransactionNumber(2)))
+	assert.NoError(t, db.Put([]byte(legacyQueueSeekKey), encodeUnTransactionNumber(1)))
+	assert.NoError(t, db.Put(rechargeHeightKey(7, sent), []byte{}))
+	assert.NoError(t, db.Put([]byte(EvidencePending+"Fee"), []byte("1")))
+	// a deposit the migration can't parse is left untouched
+	invalid := "aa00000000000000000000000000000000000000000000000000000000000003"
+	assert.NoError(t, db.Put([]byte(invalid+"Fee"), []byte("0.0001,0.0002")))
+	assert.NoError(t, db.Put([]byte(invalid+"Address"), []byte(addr)))
+	assert.NoError(t, db.Put([]byte(invalid+"Output"), []byte("1")))
+
+	assert.NoError(t, migrateDepositDB(db))
+	assert.Equal(t, SchemaVersion, readSchemaVersion(db))
+	for _, suffix := range []string{"Fee", "Address", "Output"} {
+		has, _ := db.Has([]byte(pending + suffix))
+		assert.False(t, has)
+	}
+	has, _ := db.Has([]byte(EvidencePending + "Fee"))
+	assert.True(t, has)
+	for _, suffix := range []string{"Fee", "Address", "Output"} {
+		has, _ := db.Has([]byte(invalid + suffix))
+		assert.True(t, has)
+	}
+	assert.Nil(t, ReadDeposit(db, ethCommon.HexToHash(invalid)))
+
+	record := ReadDeposit(db, ethCommon.HexToHash(pending))
+	assert.NotNil(t, record)
+	assert.Equal(t, DepositPending, record.Status)
+	assert.Equal(t, []DepositOutput{
+		{Address: addr, Amount: 100000000, Fee: 10000},
+		{Address: addr, Amount: 250000000, Fee: 20000},
+	}, record.Outputs)
+	assert.Equal(t, uint64(30000), record.Fee)
+
+	record = ReadDeposit(db, ethCommon.HexToHash(sent))
+	assert.Equal(t, DepositSent, record.Status)
+	assert.Equal(t, uint32(7), record.MainHeight)
+	assert.Equal(t, 2, len(ReadAllDeposits(db)))
+
+	// the recharge queue is moved to the typed keys
+	hash, ok := ReadQueuedDeposit(db, 1)
+	assert.True(t, ok)
+	assert.Equal(t, ethCommon.HexToHash(pending), hash)
+	assert.Equal(t, uint64(2), GetUnTransactionNum(db, QueueIndexKey))
+	assert.Equal(t, uint64(1), GetUnTransactionNum(db, QueueSeekKey))
+	for _, key := range [][]byte{append([]byte(legacyQueuePrefix), encodeUnTransactionNumber(1)...),
+		[]byte(legacyQueueIndexKey), []byte(legacyQueueSeekKey)} {
+		has, _ := db.Has(key)
+		assert.False(t, has)
+	}
+
+	// a database of the first schema version only has its recharge queue migrated
+	v1 := memorydb.New()
+	assert.NoError(t, v1.Put([]byte(SchemaVersionKey), []byte{0, 0, 0, 0, 0, 0, 0, 1}))
+	assert.NoError(t, v1.Put(append([]byte(legacyQueuePrefix), encodeUnTransactionNumber(3)...), []byte(sent)))
+	assert.NoError(t, migrateDepositDB(v1))
+	assert.Equal(t, SchemaVersion, readSchemaVersion(v1))
+	hash, ok = ReadQueuedDeposit(v1, 3)
+	assert.True(t, ok)
+	assert.Equal(t, ethCommon.HexToHash(sent), hash)
+
+	// the migration runs only once
+	assert.NoError(t, db.Put([]byte(pending+"Fee"), []byte("0.0001")))
+	assert.NoError(t, migrateDepositDB(db))
+	has, _ = db.Has([]byte(pending + "Fee"))
+	assert.True(t, has)
+}
@@ -0,0 +1,266 @@
+package spv
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/contract/program"
+	core "github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+)
+
+const (
+	//Illegal evidence waiting to be submitted to the main chain index prefix
+	EvidencePending string = "EvP-"
+
+	//Illegal evidence submitted to the main chain index prefix
+	EvidenceSubmitted string = "EvS-"
+)
+
+var (
+	errEvidenceDBNotInit = errors.New("evidence database is not initialized")
+	errEvidenceNotSigned = errors.New("evidence can not be signed by this node")
+	errEvidenceNoArbiter = errors.New("signer is not registered for any arbiter")
+)
+
+//newEvidenceTransaction creates the IllegalSidechainEvidence transaction carrying the evidence to the main chain.
+func newEvidenceTransaction(evidence *payload.SidechainIllegalData) core.Transaction {
+	return core.Transaction{
+		Version:        core.TxVersion09,
+		TxType:         core.IllegalSidechainEvidence,
+		PayloadVersion: payload.SidechainIllegalDataVersion,
+		Payload:        evidence,
+		Attributes:     []*core.Attribute{},
+		Programs:       []*program.Program{},
+		Outputs:        []*core.Output{},
+		Inputs:         []*core.Input{},
+	}
+}
+
+//SetEvidenceHandlers sets the handlers signing the evidence found by this node with its arbiter
+//key and sending the evidence with the signs collected by this node to the other arbiters.
+func (s *Service) SetEvidenceHandlers(sign func(data []byte) []byte, broadcast func(evidence *payload.SidechainIllegalData)) {
+	s.muEvidence.Lock()
+	defer s.muEvidence.Unlock()
+	s.signEvidence, s.broadcastEvidence = sign, broadcast
+}
+
+//NewIllegalData creates the main chain evidence of a signer who signed two different data at the same height.
+func (s *Service) NewIllegalData(illegalType payload.IllegalDataType, height uint64, signer []byte,
+	first, second common.Uint256) *payload.SidechainIllegalData {
+	var genesisAddress string
+	if s != nil {
+		s.mu.RLock()
+		genesisAddress = s.genesisAddress
+		s.mu.RUnlock()
+	}
+	if first.Compare(second) > 0 {
+		first, second = second, first
+	}
+	return &payload.SidechainIllegalData{
+		IllegalType:         illegalType,
+		Height:              uint32(height),
+		IllegalSigner:       signer,
+		Evidence:            payload.SidechainIllegalEvidence{DataHash: first},
+		CompareEvidence:     payload.SidechainIllegalEvidence{DataHash: second},
+		GenesisBlockAddress: genesisAddress,
+	}
+}
+
+//IsEvidenceSubmitted returns whether the evidence has been submitted to the main chain.
+func (s *Service) IsEvidenceSubmitted(hash common.Uint256) bool {
+	if s == nil || s.db == nil {
+		return false
+	}
+	s.muEvidence.Lock()
+	defer s.muEvidence.Unlock()
+	has, _ := s.db.Has(append([]byte(EvidenceSubmitted), hash.Bytes()...))
+	return has
+}
+
+//SubmitEvidence signs the evidence found by this node and broadcasts the sign to the other
+//arbiters, the evidence is stored until it is signed by the majority of the arbiters and
+//submitted to the main chain, the evidence failed to submit will be retried by RetryPendingEvidences.
+func (s *Service) SubmitEvidence(evidence *payload.SidechainIllegalData) error {
+	if s == nil || s.db == nil {
+		return errEvidenceDBNotInit
+	}
+	s.muEvidence.Lock()
+	defer s.muEvidence.Unlock()
+	hash := evidence.Hash()
+	if has, _ := s.db.Has(append([]byte(EvidenceSubmitted), hash.Bytes()...)); has {
+		return nil
+	}
+	if has, _ := s.db.Has(append([]byte(EvidencePending), hash.Bytes()...)); has {
+		return nil
+	}
+	if s.signEvidence == nil {
+		return errEvidenceNotSigned
+	}
+	buf := new(bytes.Buffer)
+	if err := evidence.SerializeUnsigned(buf, payload.SidechainIllegalDataVersion); err != nil {
+		return err
+	}
+	evidence.Signs = [][]byte{s.signEvidence(buf.Bytes())}
+	return s.collectEvidence(hash, evidence)
+}
+
+//AddEvidenceSigns adds the signs of the other arbiters to the evidence found by this node,
+//signer returns the arbiter who made the sign of the unsigned evidence data, nil if the sign is
+//not made by an arbiter. The signs of the evidence this node has not found are ignored.
+func (s *Service) AddEvidenceSigns(hash common.Uint256, signs [][]byte, signer func(data, sign []byte) []byte) error {
+	if s == nil || s.db == nil {
+		return errEvidenceDBNotInit
+	}
+	s.muEvidence.Lock()
+	defer s.muEvidence.Unlock()
+	data, err := s.db.Get(append([]byte(EvidencePending), hash.Bytes()...))
+	if err != nil {
+		return nil
+	}
+	evidence := &payload.SidechainIllegalData{}
+	if err := evidence.Deserialize(bytes.NewReader(data), payload.SidechainIllegalDataVersion); err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	if err := evidence.SerializeUnsigned(buf, payload.SidechainIllegalDataVersion); err != nil {
+		return err
+	}
+	signers := make(map[string]struct{}, len(evidence.Signs))
+	for _, sign := range evidence.Signs {
+		if arbiter := signer(buf.Bytes(), sign); arbiter != nil {
+			signers[string(arbiter)] = struct{}{}
+		}
+	}
+	count := len(evidence.Signs)
+	for _, sign := range signs {
+		arbiter := signer(buf.Bytes(), sign)
+		if arbiter == nil {
+			continue
+		}
+		if _, ok := signers[string(arbiter)]; ok {
+			continue
+		}
+		signers[string(arbiter)] = struct{}{}
+		evidence.Signs = append(evidence.Signs, sign)
+	}
+	if len(evidence.Signs) == count {
+		return nil
+	}
+	return s.collectEvidence(hash, evidence)
+}
+
+//collectEvidence stores the evidence with its signs, broadcasts them to the other arbiters and
+//submits the evidence once it is signed by the majority of the arbiters.
+func (s *Service) collectEvidence(hash common.Uint256, evidence *payload.SidechainIllegalData) error {
+	pendingKey := append([]byte(EvidencePending), hash.Bytes()...)
+	if err := s.db.Put(pendingKey, evidence.Data(payload.SidechainIllegalDataVersion)); err != nil {
+		return err
+	}
+	if s.broadcastEvidence != nil {
+		s.broadcastEvidence(evidence)
+	}
+	if !s.hasMajoritySigns(evidence) {
+		log.Info("Collect illegal evidence signs", "hash", hash.String(), "signs", len(evidence.Signs))
+		return nil
+	}
+	return s.submitEvidence(hash, evidence)
+}
+
+//hasMajoritySigns returns whether the evidence is signed by more than the majority of the
+//latest arbiters, which the main chain requires.
+func (s *Service) hasMajoritySigns(evidence *payload.SidechainIllegalData) bool {
+	var (
+		latest   uint32
+		arbiters int
+	)
+	for workingHeight, keys := range ReadAllArbiters(s.db) {
+		if workingHeight >= latest {
+			latest, arbiters = workingHeight, len(keys)
+		}
+	}
+	return len(evidence.Signs) > arbiters*2/3
+}
+
+func (s *Service) submitEvidence(hash common.Uint256, evidence *payload.SidechainIllegalData) error {
+	chain := s.GetMainChain()
+	if chain == nil {
+		log.Warn("Submit illegal evidence failed", "hash", hash.String(), "err", errSpvNotStarted)
+		return errSpvNotStarted
+	}
+	if err := chain.SubmitTransaction(newEvidenceTransaction(evidence)); err != nil {
+		log.Warn("Submit illegal evidence failed", "hash", hash.String(), "err", err)
+		return err
+	}
+	if err := s.db.Put(append([]byte(EvidenceSubmitted), hash.Bytes()...), []byte{1}); err != nil {
+		return err
+	}
+	log.Info("Submit illegal evidence", "hash", hash.String(), "type", evidence.IllegalType,
+		"height", evidence.Height, "signer", ethCommon.Bytes2Hex(evidence.IllegalSigner))
+	return s.db.Delete(append([]byte(EvidencePending), hash.Bytes()...))
+}
+
+//RetryPendingEvidences submits the evidence which failed to submit before.
+func (s *Service) RetryPendingEvidences() {
+	if s == nil || s.db == nil {
+		return
+	}
+	s.muEvidence.Lock()
+	defer s.muEvidence.Unlock()
+	pending := make(map[common.Uint256]*payload.SidechainIllegalData)
+	it := s.db.NewIteratorWithPrefix([]byte(EvidencePending))
+	for it.Next() {
+		evidence := &payload.SidechainIllegalData{}
+		if err := evidence.Deserialize(bytes.NewReader(it.Value()), payload.SidechainIllegalDataVersion); err != nil {
+			log.Error("Invalid pending illegal evidence", "key", it.Key(), "err", err)
+			continue
+		}
+		pending[evidence.Hash()] = evidence
+	}
+	it.Release()
+
+	for hash, evidence := range pending {
+		if !s.hasMajoritySigns(evidence) {
+			continue
+		}
+		if s.submitEvidence(hash, evidence) != nil {
+			return
+		}
+	}
+}
+
+//SendEvilProof submits the evidence of a signer who signed different blocks at the same height,
+//the arbiter registered for the signer is reported to the main chain.
+func (s *Service) SendEvilProof(addr ethCommon.Address, height *big.Int, blocks map[ethCommon.Hash]uint64) {
+	log.Info("Send evil Proof", "signer", addr.String())
+	if len(blocks) < 2 {
+		return
+	}
+	publicKey, ok := s.ArbiterPublicKey(addr)
+	if !ok {
+		log.Warn("Send evil Proof failed", "signer", addr.String(), "err", errEvidenceNoArbiter)
+		return
+	}
+	hashes := make([]ethCommon.Hash, 0, len(blocks))
+	for hash := range blocks {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+	var first, second common.Uint256
+	copy(first[:], hashes[0][:])
+	copy(second[:], hashes[1][:])
+	evidence := s.NewIllegalData(payload.SidechainIllegalProposal, height.Uint64(), publicKey, first, second)
+	go func() {
+		if err := s.SubmitEvidence(evidence); err != nil {
+			log.Warn("Send evil Proof failed", "signer", addr.String(), "err", err)
+		}
+	}()
+}
@@ -0,0 +1,125 @@
+package spv
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb/memorydb"
+
+	"github.com/elastos/Elastos.ELA/common"
+	core "github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	elacrypto "github.com/elastos/Elastos.ELA/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitEvidence(t *testing.T) {
+	chain := NewFakeMainChain()
+	db := memorydb.New()
+	s := New(&Config{}, db, chain, nil)
+	s.SetEvidenceHandlers(func(data []byte) []byte { return []byte{0} }, nil)
+
+	evidence := s.NewIllegalData(payload.SidechainIllegalProposal, 10, []byte{1},
+		common.Uint256{2}, common.Uint256{1})
+	assert.Equal(t, common.Uint256{1}, evidence.Evidence.DataHash)
+	assert.NoError(t, s.SubmitEvidence(evidence))
+	assert.True(t, s.IsEvidenceSubmitted(evidence.Hash()))
+	submitted := chain.Submitted()
+	assert.Equal(t, 1, len(submitted))
+	assert.Equal(t, core.IllegalSidechainEvidence, submitted[0].TxType)
+
+	// the same evidence is never submitted twice
+	evidence = s.NewIllegalData(payload.SidechainIllegalProposal, 10, []byte{1},
+		common.Uint256{1}, common.Uint256{2})
+	assert.NoError(t, s.SubmitEvidence(evidence))
+	assert.Equal(t, 1, len(chain.Submitted()))
+
+	// failed evidence is kept and retried
+	chain.SetSubmitError(errors.New("main chain is not reachable"))
+	evidence = s.NewIllegalData(payload.SidechainIllegalVote, 11, []byte{1},
+		common.Uint256{3}, common.Uint256{4})
+	assert.Error(t, s.SubmitEvidence(evidence))
+	assert.False(t, s.IsEvidenceSubmitted(evidence.Hash()))
+
+	chain.SetSubmitError(nil)
+	s.RetryPendingEvidences()
+	assert.True(t, s.IsEvidenceSubmitted(evidence.Hash()))
+	submitted = chain.Submitted()
+	assert.Equal(t, 2, len(submitted))
+	assert.Equal(t, payload.SidechainIllegalVote, submitted[1].Payload.(*payload.SidechainIllegalData).IllegalType)
+
+	s.RetryPendingEvidences()
+	assert.Equal(t, 2, len(chain.Submitted()))
+}
+
+func TestCollectEvidenceSigns(t *testing.T) {
+	chain := NewFakeMainChain()
+	db := memorydb.New()
+	s := New(&Config{}, db, chain, nil)
+	var broadcast [][][]byte
+	s.SetEvidenceHandlers(func(data []byte) []byte { return []byte{0} }, func(evidence *payload.SidechainIllegalData) {
+		broadcast = append(broadcast, evidence.Signs)
+	})
+	// the main chain requires the signs of more than 2/3 of the 4 arbiters
+	assert.NoError(t, WriteArbiters(db, 100, [][]byte{{0}, {1}, {2}, {3}}))
+	// the sign is made by the arbiter of its first byte
+	signer := func(data, sign []byte) []byte {
+		if len(sign) == 0 || sign[0] > 3 {
+			return nil
+		}
+		return sign[:1]
+	}
+
+	evidence := s.NewIllegalData(payload.SidechainIllegalProposal, 10, []byte{1},
+		common.Uint256{1}, common.Uint256{2})
+	hash := evidence.Hash()
+
+	// the signs of the evidence this node has not found are ignored
+	assert.NoError(t, s.AddEvidenceSigns(hash, [][]byte{{1}}, signer))
+	assert.Equal(t, 0, len(broadcast))
+
+	assert.NoError(t, s.SubmitEvidence(evidence))
+	assert.Equal(t, [][][]byte{{{0}}}, broadcast)
+	assert.False(t, s.IsEvidenceSubmitted(hash))
+
+	// the signs of the same arbiter and of the others are not counted
+	assert.NoError(t, s.AddEvidenceSigns(hash, [][]byte{{1}, {1, 1}, {9}}, signer))
+	assert.Equal(t, [][]byte{{0}, {1}}, broadcast[1])
+	assert.NoError(t, s.AddEvidenceSigns(hash, [][]byte{{0, 1}, {1, 2}}, signer))
+	assert.Equal(t, 2, len(broadcast))
+	assert.False(t, s.IsEvidenceSubmitted(hash))
+	assert.Equal(t, 0, len(chain.Submitted()))
+
+	// the evidence is submitted once signed by the majority
+	assert.NoError(t, s.AddEvidenceSigns(hash, [][]byte{{2}}, signer))
+	assert.True(t, s.IsEvidenceSubmitted(hash))
+	submitted := chain.Submitted()
+	assert.Equal(t, 1, len(submitted))
+	assert.Equal(t, [][]byte{{0}, {1}, {2}}, submitted[0].Payload.(*payload.SidechainIllegalData).Signs)
+}
+
+func TestSendEvilProof(t *testing.T) {
+	_, pub, err := elacrypto.GenerateKeyPair()
+	assert.NoError(t, err)
+	key, err := pub.EncodePoint(true)
+	assert.NoError(t, err)
+	signer := ethCommon.Address{0x01}
+	chain := NewFakeMainChain()
+	cfg := &Config{ArbiterSigners: map[string]ethCommon.Address{hex.EncodeToString(key): signer}}
+	s := New(cfg, memorydb.New(), chain, nil)
+	submitted := make(chan *payload.SidechainIllegalData, 1)
+	s.SetEvidenceHandlers(func(data []byte) []byte { return []byte{0} },
+		func(evidence *payload.SidechainIllegalData) { submitted <- evidence })
+
+	blocks := map[ethCommon.Hash]uint64{{1}: 1, {2}: 1}
+	s.SendEvilProof(ethCommon.Address{0x02}, big.NewInt(10), blocks)
+	s.SendEvilProof(signer, big.NewInt(10), blocks)
+	evidence := <-submitted
+	assert.Equal(t, key, evidence.IllegalSigner)
+	assert.Equal(t, uint32(10), evidence.Height)
+	assert.Equal(t, common.Uint256{1}, evidence.Evidence.DataHash)
+	assert.Equal(t, 0, len(submitted))
+}
@@ -0,0 +1,180 @@
+package spv
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+
+	"github.com/elastos/Elastos.ELA/common"
+	core "github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+)
+
+var (
+	errUnknownHeader      = errors.New("main chain header not found")
+	errUnknownTransaction = errors.New("main chain transaction not found")
+)
+
+//FakeMainChain is an in-memory main chain driven by the caller, the events are delivered
+//to the handler synchronously so the recharge paths can be tested without an ELA network.
+type FakeMainChain struct {
+	mu        sync.Mutex
+	handler   MainChainHandler
+	headers   []common.Uint256
+	txs       map[common.Uint256]*core.Transaction
+	submitted []core.Transaction
+	submitErr error
+}
+
+//NewFakeMainChain creates an in-memory main chain with the genesis block only.
+func NewFakeMainChain() *FakeMainChain {
+	return &FakeMainChain{
+		headers: []common.Uint256{fakeHeaderHash(0)},
+		txs:     make(map[common.Uint256]*core.Transaction),
+	}
+}
+
+func fakeHeaderHash(height uint32) common.Uint256 {
+	var hash common.Uint256
+	binary.BigEndian.PutUint32(hash[:], height)
+	hash[len(hash)-1] = 0xff
+	return hash
+}
+
+func (c *FakeMainChain) Start(handler MainChainHandler) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.handler != nil {
+		return errMainChainStarted
+	}
+	c.handler = handler
+	return nil
+}
+
+func (c *FakeMainChain) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = nil
+}
+
+func (c *FakeMainChain) BestHeight() (uint32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return uint32(len(c.headers) - 1), nil
+}
+
+func (c *FakeMainChain) VerifyHeader(hash *common.Uint256) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, header := range c.headers {
+		if header == *hash {
+			return nil
+		}
+	}
+	return errUnknownHeader
+}
+
+func (c *FakeMainChain) VerifyTransaction(proof bloom.MerkleProof, tx core.Transaction) error {
+	hash := tx.Hash()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.txs[hash]; !ok {
+		return errUnknownTransaction
+	}
+	return nil
+}
+
+func (c *FakeMainChain) GetTransaction(hash *common.Uint256) (*core.Transaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx, ok := c.txs[*hash]
+	if !ok {
+		return nil, errUnknownTransaction
+	}
+	return tx, nil
+}
+
+func (c *FakeMainChain) SubmitTransaction(tx core.Transaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.submitErr != nil {
+		return c.submitErr
+	}
+	c.submitted = append(c.submitted, tx)
+	return nil
+}
+
+//AddBlock appends a main chain block with the transactions and notifies the block and
+//its cross chain transfers, returning the height and the header hash of the block.
+func (c *FakeMainChain) AddBlock(txs ...core.Transaction) (uint32, common.Uint256) {
+	hashes := make([]common.Uint256, len(txs))
+	for i := range txs {
+		hashes[i] = txs[i].Hash()
+	}
+	c.mu.Lock()
+	height := uint32(len(c.headers))
+	hash := fakeHeaderHash(height)
+	c.headers = append(c.headers, hash)
+	for i := range txs {
+		c.txs[hashes[i]] = &txs[i]
+	}
+	handler := c.handler
+	c.mu.Unlock()
+
+	if handler != nil {
+		handler.OnBlock(height)
+		for _, tx := range txs {
+			if tx.TxType == core.TransferCrossChainAsset {
+				handler.OnDeposit(height, tx)
+			}
+		}
+	}
+	return height, hash
+}
+
+//Rollback removes the main chain blocks from the best one down to the height, notifying
+//each rolled back height.
+func (c *FakeMainChain) Rollback(height uint32) {
+	c.mu.Lock()
+	best := uint32(len(c.headers) - 1)
+	if height == 0 || height > best {
+		c.mu.Unlock()
+		return
+	}
+	c.headers = c.headers[:height]
+	handler := c.handler
+	c.mu.Unlock()
+
+	if handler != nil {
+		for h := best; h >= height; h-- {
+			handler.OnRollback(h)
+		}
+	}
+}
+
+//RotateArbiters notifies the arbiters of the next turn.
+func (c *FakeMainChain) RotateArbiters(info *payload.NextTurnDPOSInfo) {
+	c.mu.Lock()
+	handler := c.handler
+	c.mu.Unlock()
+
+	if handler != nil {
+		handler.OnArbiters(info)
+	}
+}
+
+//Submitted returns the transactions submitted to the main chain.
+func (c *FakeMainChain) Submitted() []core.Transaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]core.Transaction{}, c.submitted...)
+}
+
+//SetSubmitError makes the following submissions fail with the error, nil to accept them again.
+func (c *FakeMainChain) SetSubmitError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.submitErr = err
+}
@@ -0,0 +1,192 @@
+package spv
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	spv "github.com/elastos/Elastos.ELA.SPV/interface"
+	ethereum "github.com/elastos/Elastos.ELA.SideChain.ETH"
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"golang.org/x/net/context"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/common/config"
+	core "github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/elanet/filter"
+)
+
+var errMainChainStarted = errors.New("main chain is already started")
+
+//MainChainHandler receives the events of the ELA main chain.
+type MainChainHandler interface {
+	//OnDeposit is called with a cross chain transfer to the side chain confirmed at the main chain height
+	OnDeposit(height uint32, tx core.Transaction)
+
+	//OnBlock is called with the height of each main chain block
+	OnBlock(height uint32)
+
+	//OnArbiters is called with the arbiters rotated in by the main chain
+	OnArbiters(info *payload.NextTurnDPOSInfo)
+
+	//OnRollback is called when the main chain block at the height is rolled back
+	OnRollback(height uint32)
+}
+
+//MainChain is the ELA main chain seen by the side chain.
+type MainChain interface {
+	//Start connects to the main chain and delivers its events to the handler
+	Start(handler MainChainHandler) error
+
+	//Stop disconnects from the main chain
+	Stop()
+
+	//BestHeight returns the height of the best main chain header
+	BestHeight() (uint32, error)
+
+	//VerifyHeader checks the main chain header is known
+	VerifyHeader(hash *common.Uint256) error
+
+	//VerifyTransaction checks the main chain transaction against its merkle proof
+	VerifyTransaction(proof bloom.MerkleProof, tx core.Transaction) error
+
+	//GetTransaction returns a main chain transaction notified to the side chain
+	GetTransaction(hash *common.Uint256) (*core.Transaction, error)
+
+	//SubmitTransaction sends a transaction, such as an illegal evidence proof, to the main chain
+	SubmitTransaction(tx core.Transaction) error
+}
+
+//SideChain is the client of the side chain node the recharge transactions are sent to.
+type SideChain interface {
+	StorageAt(ctx context.Context, account ethCommon.Address, key ethCommon.Hash, blockNumber *big.Int) ([]byte, error)
+	EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error)
+	SendPublicTransaction(ctx context.Context, msg ethereum.TXMsg) (ethCommon.Hash, error)
+}
+
+//spvMainChain is the main chain followed by the ELA spv service.
+type spvMainChain struct {
+	service spv.SPVService
+	address string
+	handler MainChainHandler
+}
+
+//NewSPVMainChain creates the main chain followed by the ELA spv service, monitoring the genesis address of the config.
+func NewSPVMainChain(cfg *Config) (MainChain, error) {
+	var chainParams *config.Params
+	switch strings.ToLower(cfg.ActiveNet) {
+	case "testnet", "test", "t":
+		chainParams = config.DefaultParams.TestNet()
+		chainParams.Magic = 2018111
+	case "regnet", "reg", "r":
+		chainParams = config.DefaultParams.RegNet()
+	case "goreli", "g":
+		chainParams = config.DefaultParams.RegNet()
+		chainParams.Magic = 2018211
+	default:
+		chainParams = &config.DefaultParams
+
+	}
+	chain := &spvMainChain{address: cfg.GenesisAddress}
+	spvCfg := &spv.Config{
+		DataDir:    cfg.DataDir,
+		FilterType: filter.FTNexTTurnDPOSInfo,
+		OnRollback: chain.onRollback,
+	}
+	//chainParams, spvCfg = ResetConfig(chainParams, spvCfg)
+	ResetConfigWithReflect(chainParams, spvCfg)
+	spvCfg.ChainParams = chainParams
+	spvCfg.PermanentPeers = chainParams.PermanentPeers
+	initLog(cfg.DataDir)
+
+	service, err := spv.NewSPVService(spvCfg)
+	if err != nil {
+		log.Error("Spv New DPOS SPVService: ", "err", err)
+		return nil, err
+	}
+	chain.service = service
+	return chain, nil
+}
+
+func (c *spvMainChain) Start(handler MainChainHandler) error {
+	if c.handler != nil {
+		return errMainChainStarted
+	}
+	c.handler = handler
+	err := c.service.RegisterTransactionListener(&listener{chain: c})
+	if err != nil {
+		log.Error("Spv Register Transaction Listener: ", "err", err)
+		return err
+	}
+	err = c.service.RegisterBlockListener(&BlockListener{chain: c})
+	if err != nil {
+		return err
+	}
+	c.service.Start()
+	return nil
+}
+
+func (c *spvMainChain) Stop() {
+	c.service.Stop()
+}
+
+func (c *spvMainChain) BestHeight() (uint32, error) {
+	best, err := c.service.HeaderStore().GetBest()
+	if err != nil {
+		return 0, err
+	}
+	return best.Height, nil
+}
+
+func (c *spvMainChain) VerifyHeader(hash *common.Uint256) error {
+	_, err := c.service.HeaderStore().Get(hash)
+	return err
+}
+
+func (c *spvMainChain) VerifyTransaction(proof bloom.MerkleProof, tx core.Transaction) error {
+	return c.service.VerifyTransaction(proof, tx)
+}
+
+func (c *spvMainChain) GetTransaction(hash *common.Uint256) (*core.Transaction, error) {
+	return c.service.GetTransaction(hash)
+}
+
+func (c *spvMainChain) SubmitTransaction(tx core.Transaction) error {
+	return c.service.SendTransaction(tx)
+}
+
+func (c *spvMainChain) onRollback(height uint32) {
+	if c.handler != nil {
+		c.handler.OnRollback(height)
+	}
+}
+
+type listener struct {
+	chain *spvMainChain
+}
+
+func (l *listener) Address() string {
+	return l.chain.address
+}
+
+func (l *listener) Type() core.TxType {
+	return core.TransferCrossChainAsset
+}
+
+func (l *listener) Flags() uint64 {
+	return spv.FlagNotifyInSyncing | spv.FlagNotifyConfirmed
+}
+
+func (l *listener) Notify(id common.Uint256, proof bloom.MerkleProof, tx core.Transaction) {
+	// Submit transaction receipt
+	log.Info("========================================================================================")
+	log.Info("mainchain transaction info")
+	log.Info("----------------------------------------------------------------------------------------")
+	log.Info(string(tx.String()))
+	log.Info("----------------------------------------------------------------------------------------")
+	l.chain.handler.OnDeposit(proof.Height, tx)
+	l.chain.service.SubmitTransactionReceipt(id, tx.Hash()) // give spv service a receipt, Indicates receipt of notice
+}
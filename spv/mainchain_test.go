@@ -0,0 +1,79 @@
+package spv
+
+import (
+	"testing"
+
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb/memorydb"
+
+	"github.com/elastos/Elastos.ELA/common"
+	core "github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDepositTx(nonce byte, addr ethCommon.Address, amount, fee common.Fixed64) core.Transaction {
+	return core.Transaction{
+		TxType: core.TransferCrossChainAsset,
+		Payload: &payload.TransferCrossChainAsset{
+			CrossChainAddresses: []string{addr.String()},
+			OutputIndexes:       []uint64{0},
+			CrossChainAmounts:   []common.Fixed64{amount},
+		},
+		Attributes: []*core.Attribute{{Usage: core.Nonce, Data: []byte{nonce}}},
+		Outputs:    []*core.Output{{Value: amount + fee}},
+	}
+}
+
+func TestFakeMainChainRecharge(t *testing.T) {
+	chain := NewFakeMainChain()
+	db := memorydb.New()
+	s := New(&Config{}, db, chain, nil)
+	assert.NoError(t, s.Start())
+	defer s.Stop()
+	assert.Equal(t, errMainChainStarted, chain.Start(s))
+
+	addr := ethCommon.Address{1}
+	first := newDepositTx(1, addr, 100000000, 10000)
+	second := newDepositTx(2, addr, 200000000, 20000)
+	height, header := chain.AddBlock(first)
+	chain.AddBlock(second)
+
+	best, _ := s.BestHeight()
+	assert.Equal(t, uint64(height+1), best)
+	assert.NoError(t, s.VerifyElaHeader(&header))
+
+	// the deposits are stored and queued for recharge
+	firstHash := ethCommon.HexToHash(first.Hash().String())
+	secondHash := ethCommon.HexToHash(second.Hash().String())
+	record := s.GetDeposit(firstHash)
+	assert.NotNil(t, record)
+	assert.Equal(t, DepositPending, record.Status)
+	assert.Equal(t, height, record.MainHeight)
+	assert.Equal(t, uint64(10000), record.Fee)
+	assert.Equal(t, []ethCommon.Hash{firstHash, secondHash}, s.QueuedDeposits())
+	fee, to, _ := s.FindOutputFeeAndaddressByTxHash(first.Hash().String())
+	assert.Equal(t, addr, to)
+	assert.Equal(t, SelaToWei(10000), fee)
+
+	// the rolled back deposit is dropped from the queue
+	chain.Rollback(height + 1)
+	assert.Equal(t, DepositPending, s.GetDeposit(firstHash).Status)
+	assert.Equal(t, DepositRolledBack, s.GetDeposit(secondHash).Status)
+	assert.Equal(t, []ethCommon.Hash{firstHash}, s.QueuedDeposits())
+	best, _ = s.BestHeight()
+	assert.Equal(t, uint64(height), best)
+
+	// the arbiters of the next turn are notified to the producers
+	var rotated *payload.NextTurnDPOSInfo
+	events.Subscribe(func(e *events.Event) {
+		if e.Type == dpos.ETNextProducers {
+			rotated = e.Data.(*payload.NextTurnDPOSInfo)
+		}
+	})
+	info := &payload.NextTurnDPOSInfo{WorkingHeight: 100}
+	chain.RotateArbiters(info)
+	assert.Equal(t, info, rotated)
+}
@@ -0,0 +1,176 @@
+package spv
+
+import (
+	"encoding/binary"
+	"strings"
+	"time"
+
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/metrics"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/rlp"
+	"golang.org/x/net/context"
+)
+
+const (
+	//Recharge transactions by main chain height index prefix
+	RechargeHeight string = "RcH-"
+
+	//Audit record of minted recharges whose deposit was rolled back prefix
+	RollbackMinted string = "RbM-"
+)
+
+var (
+	rollbackDroppedMeter = metrics.NewRegisteredMeter("spv/rollback/dropped", nil)
+	rollbackMintedMeter  = metrics.NewRegisteredMeter("spv/rollback/minted", nil)
+)
+
+// RollbackRecord is the audit record of a recharge minted on the side chain whose deposit
+// is no longer in the canonical main chain.
+type RollbackRecord struct {
+	ElaTx  string
+	Height uint32
+	EthTx  ethCommon.Hash
+	Time   uint64
+}
+
+func rechargeHeightKey(height uint32, elaTx string) []byte {
+	key := make([]byte, len(RechargeHeight)+4, len(RechargeHeight)+4+len(elaTx))
+	copy(key, RechargeHeight)
+	binary.BigEndian.PutUint32(key[len(RechargeHeight):], height)
+	return append(key, elaTx...)
+}
+
+// putRechargeHeight records the main chain height of the deposit, so it can be found on rollback.
+func (s *Service) putRechargeHeight(height uint32, elaTx string) {
+	if s.db == nil {
+		return
+	}
+	elaTx = strings.TrimPrefix(elaTx, "0x")
+	if err := s.db.Put(rechargeHeightKey(height, elaTx), []byte{}); err != nil {
+		log.Error("SpvServicedb Put RechargeHeight: ", "err", err, "elaHash", elaTx)
+	}
+}
+
+// getRechargeTx returns the side chain transaction which minted the recharge of the main chain transaction.
+func (s *Service) getRechargeTx(elaTx string) ethCommon.Hash {
+	client := s.sideChain()
+	if client == nil {
+		return ethCommon.Hash{}
+	}
+	ethTx, err := client.StorageAt(context.Background(), ethCommon.Address{}, ethCommon.HexToHash("0x"+elaTx), nil)
+	if err != nil {
+		log.Error("IpcClient StorageAt: ", "err", err, "elaHash", elaTx)
+		return ethCommon.Hash{}
+	}
+	return ethCommon.BytesToHash(ethTx)
+}
+
+// OnRollback implements MainChainHandler, invalidating the deposits of the main chain block at the
+// height which has been rolled back.
+func (s *Service) OnRollback(height uint32) {
+	s.muRollback.Lock()
+	defer s.muRollback.Unlock()
+	if s.db == nil {
+		return
+	}
+	prefix := rechargeHeightKey(height, "")
+	var deposits []string
+	it := s.db.NewIteratorWithPrefix(prefix)
+	for it.Next() {
+		deposits = append(deposits, string(it.Key()[len(prefix):]))
+	}
+	it.Release()
+	if len(deposits) == 0 {
+		return
+	}
+	log.Warn("Main chain rollback", "height", height, "deposits", len(deposits))
+
+	// the rolled back transaction may be notified again on the new main chain
+	s.notifiedTx = ""
+	minted := make(map[string]ethCommon.Hash, len(deposits))
+	var dropped []string
+	for _, elaTx := range deposits {
+		if ethTx := s.getRechargeTx(elaTx); ethTx != (ethCommon.Hash{}) {
+			minted[elaTx] = ethTx
+			continue
+		}
+		dropped = append(dropped, elaTx)
+	}
+	s.removeUnTransactions(dropped)
+	for _, elaTx := range deposits {
+		s.invalidateRecharge(height, elaTx, minted[elaTx])
+	}
+}
+
+// removeUnTransactions removes the unprocessed deposits from the recharge queue.
+func (s *Service) removeUnTransactions(deposits []string) {
+	if len(deposits) == 0 {
+		return
+	}
+	s.muIndex.Lock()
+	defer s.muIndex.Unlock()
+
+	hashes := make(map[ethCommon.Hash]bool, len(deposits))
+	for _, elaTx := range deposits {
+		hashes[ethCommon.HexToHash(elaTx)] = true
+	}
+	it := s.db.NewIteratorWithPrefix([]byte(QueuePrefix))
+	var keys [][]byte
+	for it.Next() {
+		if hashes[ethCommon.BytesToHash(it.Value())] {
+			keys = append(keys, ethCommon.CopyBytes(it.Key()))
+		}
+	}
+	it.Release()
+	for _, key := range keys {
+		if err := s.db.Delete(key); err != nil {
+			log.Error("SpvServicedb Delete queued deposit: ", "err", err)
+		}
+	}
+}
+
+// invalidateRecharge marks the deposit record of a recharge which has not been minted as
+// rolled back. A minted recharge is kept in an audit record only, its deposit record is left
+// untouched as the blocks minting it are re-executed from it.
+func (s *Service) invalidateRecharge(height uint32, elaTx string, ethTx ethCommon.Hash) {
+	if err := s.db.Delete(rechargeHeightKey(height, elaTx)); err != nil {
+		log.Error("SpvServicedb Delete RechargeHeight: ", "err", err, "elaHash", elaTx)
+	}
+	if ethTx == (ethCommon.Hash{}) {
+		s.setDepositStatus(elaTx, DepositRolledBack, ethCommon.Hash{})
+		rollbackDroppedMeter.Mark(1)
+		log.Info("Drop rolled back recharge", "elaHash", elaTx, "height", height)
+		return
+	}
+	rollbackMintedMeter.Mark(1)
+	log.Error("Minted recharge has been rolled back", "elaHash", elaTx, "height", height, "ethTx", ethTx.String())
+	record := &RollbackRecord{ElaTx: elaTx, Height: height, EthTx: ethTx, Time: uint64(time.Now().Unix())}
+	data, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		log.Error("RollbackRecord encode: ", "err", err, "elaHash", elaTx)
+		return
+	}
+	if err := s.db.Put([]byte(RollbackMinted+elaTx), data); err != nil {
+		log.Error("SpvServicedb Put RollbackMinted: ", "err", err, "elaHash", elaTx)
+	}
+}
+
+// GetRollbackRecords returns the audit records of the minted recharges which have been rolled back.
+func (s *Service) GetRollbackRecords() []*RollbackRecord {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	var records []*RollbackRecord
+	it := s.db.NewIteratorWithPrefix([]byte(RollbackMinted))
+	defer it.Release()
+	for it.Next() {
+		record := new(RollbackRecord)
+		if err := rlp.DecodeBytes(it.Value(), record); err != nil {
+			log.Error("Invalid rollback record", "key", string(it.Key()), "err", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,98 @@
+package spv
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	ethereum "github.com/elastos/Elastos.ELA.SideChain.ETH"
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb/leveldb"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/params"
+	"golang.org/x/net/context"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSideChain keeps the minted recharges of the side chain state and the
+// recharge transactions sent to it
+type fakeSideChain struct {
+	minted map[ethCommon.Hash]ethCommon.Hash
+	sent   []ethereum.TXMsg
+}
+
+func (c *fakeSideChain) StorageAt(ctx context.Context, account ethCommon.Address, key ethCommon.Hash, blockNumber *big.Int) ([]byte, error) {
+	tx := c.minted[key]
+	return tx[:], nil
+}
+
+func (c *fakeSideChain) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	return params.TxGas, nil
+}
+
+func (c *fakeSideChain) SendPublicTransaction(ctx context.Context, msg ethereum.TXMsg) (ethCommon.Hash, error) {
+	c.sent = append(c.sent, msg)
+	return crypto.Keccak256Hash(msg.Data), nil
+}
+
+func TestOnRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spv-rollback")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := leveldb.New(dir, 16, 16, "")
+	assert.NoError(t, err)
+	defer db.Close()
+	client := &fakeSideChain{minted: map[ethCommon.Hash]ethCommon.Hash{ethCommon.HexToHash("03"): {3}}}
+	s := New(&Config{}, db, nil, client)
+
+	for _, elaTx := range []string{"01", "02", "03"} {
+		height := uint32(10)
+		if elaTx == "02" {
+			height = 11
+		}
+		s.putRechargeHeight(height, elaTx)
+		assert.NoError(t, WriteDeposit(db, &DepositRecord{
+			MainTxHash: ethCommon.HexToHash(elaTx),
+			MainHeight: height,
+			Outputs:    []DepositOutput{{Address: ethCommon.Address{1}.String(), Amount: 100000000, Fee: 10000}},
+			Fee:        10000,
+		}))
+	}
+	s.UpTransactionIndex("01")
+	s.UpTransactionIndex("02")
+	s.UpTransactionIndex("03")
+
+	s.OnRollback(10)
+	assert.Equal(t, DepositRolledBack, ReadDeposit(db, ethCommon.HexToHash("01")).Status)
+	assert.Equal(t, DepositPending, ReadDeposit(db, ethCommon.HexToHash("02")).Status)
+	fee, _, _ := s.FindOutputFeeAndaddressByTxHash("01")
+	assert.Equal(t, 0, fee.Sign())
+	fee, _, _ = s.FindOutputFeeAndaddressByTxHash("02")
+	assert.Equal(t, 1, fee.Sign())
+
+	// the unprocessed deposit is removed from the queue
+	_, ok := ReadQueuedDeposit(db, 1)
+	assert.False(t, ok)
+	txHash, ok := ReadQueuedDeposit(db, 2)
+	assert.True(t, ok)
+	assert.Equal(t, ethCommon.HexToHash("02"), txHash)
+
+	// the minted deposit is audited, it is left queued and can still be read by
+	// the blocks minting it
+	_, ok = ReadQueuedDeposit(db, 3)
+	assert.True(t, ok)
+	assert.Equal(t, DepositPending, ReadDeposit(db, ethCommon.HexToHash("03")).Status)
+	fee, _, _ = s.FindOutputFeeAndaddressByTxHash("03")
+	assert.Equal(t, 1, fee.Sign())
+	records := s.GetRollbackRecords()
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, "03", records[0].ElaTx)
+	assert.Equal(t, uint32(10), records[0].Height)
+	assert.Equal(t, ethCommon.Hash{3}, records[0].EthTx)
+
+	// rolling back the same height again changes nothing
+	s.OnRollback(10)
+	assert.Equal(t, 1, len(s.GetRollbackRecords()))
+}
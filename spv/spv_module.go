@@ -10,13 +10,16 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/elastos/Elastos.ELA.SPV/bloom"
-	spv "github.com/elastos/Elastos.ELA.SPV/interface"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/blocksigner"
 	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/crypto"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/dpos"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethclient"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb/leveldb"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/event"
 	"github.com/elastos/Elastos.ELA.SideChain.ETH/log"
@@ -25,25 +28,14 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/elastos/Elastos.ELA/common"
-	"github.com/elastos/Elastos.ELA/common/config"
 	core "github.com/elastos/Elastos.ELA/core/types"
 	"github.com/elastos/Elastos.ELA/core/types/payload"
-	"github.com/elastos/Elastos.ELA/elanet/filter"
+	"github.com/elastos/Elastos.ELA/events"
 )
 
 var (
-	dataDir          = "./"
-	ipcClient        *ethclient.Client
-	SpvService       *Service
-	spvTxhash        string //Spv notification main chain hash
-	spvTransactiondb *leveldb.Database
-	muiterator       sync.RWMutex
-	muupti           sync.RWMutex
-	candSend         int32     //1 can send recharge transactions, 0 can not send recharge transactions
-	candIterator     int32 = 0 //0 Iteratively send recharge transactions, 1 can't iteratively send recharge transactions
-	MinedBlockSub    *event.TypeMuxSubscription
-
-	GetDefaultSingerAddr func() ethCommon.Address
+	errDepositOutputs = errors.New("deposit outputs mismatch")
+	errSpvNotStarted  = errors.New("spv service is not started")
 )
 
 const (
@@ -51,9 +43,6 @@ const (
 
 	handles = 16
 
-	//Unprocessed refill transaction index prefix
-	UnTransaction string = "UnT-"
-
 	// missingNumber is returned by GetBlockNumber if no header with the
 	// given block hash has been stored in the database
 	missingNumber = uint64(0xffffffffffffffff)
@@ -61,12 +50,6 @@ const (
 	//Cross-chain exchange rate
 	rate int64 = 10000000000
 
-	//Cross-chain recharge unprocessed transaction index
-	UnTransactionIndex = "UnTI"
-
-	//Cross-chain recharge unprocessed transaction seek
-	UnTransactionSeek = "UnTS"
-
 	// Fixed number of extra-data prefix bytes reserved for signer vanity
 	ExtraVanity = 32
 
@@ -90,71 +73,82 @@ type Config struct {
 
 	// GenesisAddress is the address generated by the side chain genesis block.
 	GenesisAddress string
+
+	// Signer returns the account sending the recharge transactions.
+	Signer func() ethCommon.Address
+
+	// BatchRecharge returns whether the recharges of the next block can be batched.
+	BatchRecharge func() bool
+
+	// ArbiterSigners maps the hex encoded public keys of the arbiters to their block signers.
+	ArbiterSigners map[string]ethCommon.Address
 }
 
+//Service keeps the recharge state of the main chain deposits and sends their recharge
+//transactions to the side chain.
 type Service struct {
-	spv.SPVService
+	db ethdb.KeyValueStore
+
+	// the fields set by Init, mu guards those read before the service is initialized
+	chain          MainChain
+	client         SideChain
+	genesisAddress string
+	signer         func() ethCommon.Address
+	batchRecharge  func() bool
+	arbiterSigners map[string]ethCommon.Address
+	mu             sync.RWMutex
+
+	depositFeed       event.Feed
+	signEvidence      func(data []byte) []byte                      //signs the unsigned evidence data with the arbiter key of this node
+	broadcastEvidence func(evidence *payload.SidechainIllegalData) //sends the evidence with the signs collected by this node to the other arbiters
+
+	notifiedTx string //Spv notification main chain hash
+	canSend    int32  //1 can send recharge transactions, 0 can not send recharge transactions
+	iterating  int32  //0 Iteratively send recharge transactions, 1 can't iteratively send recharge transactions
+
+	muIterator sync.RWMutex
+	muIndex    sync.RWMutex
+	muRollback sync.Mutex
+	muEvidence sync.Mutex
 }
 
-//Spv database initialization
-func SpvDbInit(spvdataDir string) {
-	db, err := leveldb.New(filepath.Join(spvdataDir, "spv_transaction_info.db"), databaseCache, handles, "eth/db/ela/")
-	if err != nil {
-		log.Error("spv Open db", "err", err)
-		return
+//New creates the recharge service on the spv database, the main chain and the side chain client
+//may be nil before the node starts.
+func New(cfg *Config, db ethdb.KeyValueStore, chain MainChain, client SideChain) *Service {
+	return &Service{
+		chain:          chain,
+		client:         client,
+		db:             db,
+		genesisAddress: cfg.GenesisAddress,
+		signer:         cfg.Signer,
+		batchRecharge:  cfg.BatchRecharge,
+		arbiterSigners: newArbiterSigners(cfg.ArbiterSigners),
 	}
-	spvTransactiondb = db
 }
 
-//Spv service initialization
-func NewService(cfg *Config, client *rpc.Client) (*Service, error) {
-	var chainParams *config.Params
-	switch strings.ToLower(cfg.ActiveNet) {
-	case "testnet", "test", "t":
-		chainParams = config.DefaultParams.TestNet()
-		chainParams.Magic = 2018111
-	case "regnet", "reg", "r":
-		chainParams = config.DefaultParams.RegNet()
-	case "goreli", "g":
-		chainParams = config.DefaultParams.RegNet()
-		chainParams.Magic = 2018211
-	default:
-		chainParams = &config.DefaultParams
-
-	}
-	spvCfg := &spv.Config{
-		DataDir:    cfg.DataDir,
-		FilterType: filter.FTNexTTurnDPOSInfo,
-		OnRollback: nil, // Not implemented yet
-	}
-	//chainParams, spvCfg = ResetConfig(chainParams, spvCfg)
-	ResetConfigWithReflect(chainParams, spvCfg)
-	spvCfg.ChainParams = chainParams
-	spvCfg.PermanentPeers = chainParams.PermanentPeers
-	dataDir = cfg.DataDir
-	initLog(cfg.DataDir)
-
-	service, err := spv.NewSPVService(spvCfg)
+//NewService opens the spv database, upgraded to the current schema version, and creates the
+//service on it. The deposits can be read once it is created, the service follows the main
+//chain after it is initialized by Init and started.
+func NewService(spvdataDir string) (*Service, error) {
+	db, err := leveldb.New(filepath.Join(spvdataDir, "spv_transaction_info.db"), databaseCache, handles, "eth/db/ela/")
 	if err != nil {
-		log.Error("Spv New DPOS SPVService: ", "err", err)
 		return nil, err
 	}
-
-	SpvService = &Service{service}
-	err = service.RegisterTransactionListener(&listener{
-		address: cfg.GenesisAddress,
-		service: service,
-	})
-	if err != nil {
-		log.Error("Spv Register Transaction Listener: ", "err", err)
-		return nil, err
+	if err := migrateDepositDB(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate spv database: %v", err)
 	}
-	err = service.RegisterBlockListener(&BlockListener{})
+	return New(&Config{}, db, nil, nil), nil
+}
+
+//Init connects the service to the main chain and the side chain client of the running node.
+func (s *Service) Init(cfg *Config, client *rpc.Client) error {
+	chain, err := NewSPVMainChain(cfg)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	ipcClient = ethclient.NewClient(client)
+	ipcClient := ethclient.NewClient(client)
 	genesis, err := ipcClient.HeaderByNumber(context.Background(), new(big.Int).SetInt64(0))
 	if err != nil {
 		log.Error("IpcClient: ", "err", err)
@@ -176,36 +170,109 @@ func NewService(cfg *Config, client *rpc.Client) (*Service, error) {
 		}
 	}
 
-	return SpvService, nil
+	s.mu.Lock()
+	s.chain, s.client = chain, ipcClient
+	s.genesisAddress, s.signer, s.batchRecharge = cfg.GenesisAddress, cfg.Signer, cfg.BatchRecharge
+	s.arbiterSigners = newArbiterSigners(cfg.ArbiterSigners)
+	s.mu.Unlock()
+	s.loadArbiters()
+	return nil
+}
+
+//Start starts following the main chain.
+func (s *Service) Start() error {
+	chain := s.GetMainChain()
+	if chain == nil {
+		return errSpvNotStarted
+	}
+	return chain.Start(s)
+}
+
+//Stop stops following the main chain.
+func (s *Service) Stop() {
+	if chain := s.GetMainChain(); chain != nil {
+		chain.Stop()
+	}
+}
+
+//Close closes the spv database.
+func (s *Service) Close() {
+	if s.db != nil {
+		s.db.Close()
+	}
 }
 
 //minedBroadcastLoop Mining awareness, eth can initiate a recharge transaction after the block
-func MinedBroadcastLoop(minedBlockSub *event.TypeMuxSubscription, ondutySub *event.TypeMuxSubscription) {
+func (s *Service) MinedBroadcastLoop(minedBlockSub *event.TypeMuxSubscription, ondutySub *event.TypeMuxSubscription) {
 	var i = 0
 
 	for {
 		select {
-		case <-minedBlockSub.Chan():
+		case _, ok := <-minedBlockSub.Chan():
+			if !ok {
+				return
+			}
 			i++
 			if i >= 2 {
-				atomic.StoreInt32(&candSend, 1)
-				IteratorUnTransaction(GetDefaultSingerAddr())
+				atomic.StoreInt32(&s.canSend, 1)
+				s.IteratorUnTransaction(s.signer())
+			}
+		case _, ok := <-ondutySub.Chan():
+			if !ok {
+				return
 			}
-		case <-ondutySub.Chan():
 			if i >= 2 {
 				i = 0
 				log.Info("receive onduty event")
-				atomic.StoreInt32(&candSend, 0)
+				atomic.StoreInt32(&s.canSend, 0)
 			}
 		}
 	}
 }
 
-func (s *Service) GetDatabase() *leveldb.Database {
-	return spvTransactiondb
+func (s *Service) GetDatabase() ethdb.KeyValueStore {
+	if s == nil {
+		return nil
+	}
+	return s.db
+}
+
+//GetMainChain returns the main chain followed by the service, nil before it is initialized.
+func (s *Service) GetMainChain() MainChain {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.chain
+}
+
+//sideChain returns the client of the side chain, nil before the service is initialized.
+func (s *Service) sideChain() SideChain {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.client
+}
+
+// BestHeight returns the best main chain height synced by the service, false
+// if the service doesn't follow the main chain.
+func (s *Service) BestHeight() (uint64, bool) {
+	chain := s.GetMainChain()
+	if chain == nil {
+		return 0, false
+	}
+	height, err := chain.BestHeight()
+	if err != nil {
+		return 0, true
+	}
+	return uint64(height), true
 }
 
 func (s *Service) VerifyTransaction(tx *types.Transaction) error {
+	chain := s.GetMainChain()
+	if chain == nil {
+		return errSpvNotStarted
+	}
 	payload, ok := tx.Payload.(*types.PayloadRechargeToSideChain)
 	if !ok {
 		return errors.New("[VerifyTransaction] Invalid payload core.PayloadRechargeToSideChain")
@@ -227,13 +294,13 @@ func (s *Service) VerifyTransaction(tx *types.Transaction) error {
 			return errors.New("[VerifyTransaction] RechargeToSideChain mainChainTransaction deserialize failed")
 		}
 
-		if err := s.SPVService.VerifyTransaction(*proof, *mainChainTransaction); err != nil {
+		if err := chain.VerifyTransaction(*proof, *mainChainTransaction); err != nil {
 			return errors.New("[VerifyTransaction] SPV module verify transaction failed.")
 		}
 
 	case types.RechargeToSideChainPayloadVersion1:
 
-		_, err := s.GetTransaction(&payload.MainChainTransactionHash)
+		_, err := chain.GetTransaction(&payload.MainChainTransactionHash)
 		if err != nil {
 			return errors.New("[VerifyTransaction] Main chain transaction not found")
 		}
@@ -246,151 +313,129 @@ func (s *Service) VerifyTransaction(tx *types.Transaction) error {
 }
 
 func (s *Service) VerifyElaHeader(hash *common.Uint256) error {
-	blockChain := s.HeaderStore()
-	_, err := blockChain.Get(hash)
-	if err != nil {
+	chain := s.GetMainChain()
+	if chain == nil {
+		return errSpvNotStarted
+	}
+	if err := chain.VerifyHeader(hash); err != nil {
 		return errors.New("[VerifyElaHeader] Verify ela header failed.")
 	}
 	return nil
 }
 
-type listener struct {
-	address string
-	service spv.SPVService
-}
-
-func (l *listener) Address() string {
-	return l.address
+//OnDeposit implements MainChainHandler, storing the deposit and sending its recharge.
+func (s *Service) OnDeposit(height uint32, tx core.Transaction) {
+	s.putRechargeHeight(height, tx.Hash().String())
+	s.savePayloadInfo(tx, height)
 }
 
-func (l *listener) Type() core.TxType {
-	return core.TransferCrossChainAsset
+//OnBlock implements MainChainHandler, retrying the evidence failed to submit.
+func (s *Service) OnBlock(height uint32) {
+	go s.RetryPendingEvidences()
 }
 
-func (l *listener) Flags() uint64 {
-	return spv.FlagNotifyInSyncing | spv.FlagNotifyConfirmed
-}
-
-func (l *listener) Notify(id common.Uint256, proof bloom.MerkleProof, tx core.Transaction) {
-	// Submit transaction receipt
-	log.Info("========================================================================================")
-	log.Info("mainchain transaction info")
-	log.Info("----------------------------------------------------------------------------------------")
-	log.Info(string(tx.String()))
-	log.Info("----------------------------------------------------------------------------------------")
-	savePayloadInfo(tx, l)
-	l.service.SubmitTransactionReceipt(id, tx.Hash())// give spv service a receipt, Indicates receipt of notice
+//OnArbiters implements MainChainHandler, recording the arbiters and notifying the producers of the next turn.
+func (s *Service) OnArbiters(info *payload.NextTurnDPOSInfo) {
+	s.recordArbiters(info)
+	events.Notify(dpos.ETNextProducers, info)
 }
 
 //savePayloadInfo save and send spv perception
-func savePayloadInfo(elaTx core.Transaction, l *listener) {
+func (s *Service) savePayloadInfo(elaTx core.Transaction, height uint32) {
 	nr := bytes.NewReader(elaTx.Payload.Data(elaTx.PayloadVersion))
 	p := new(payload.TransferCrossChainAsset)
 	p.Deserialize(nr, elaTx.PayloadVersion)
-	var fees []string
-	var address []string
-	var outputs []string
+	now := uint64(time.Now().Unix())
+	record := &DepositRecord{
+		MainTxHash: ethCommon.HexToHash(elaTx.Hash().String()),
+		MainHeight: height,
+		Status:     DepositPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
 	for i, amount := range p.CrossChainAmounts {
-		fees = append(fees, (elaTx.Outputs[i].Value - amount).String())
-		outputs = append(outputs, elaTx.Outputs[i].Value.String())
-		address = append(address, p.CrossChainAddresses[i])
-	}
-	addr := strings.Join(address, ",")
-	fee := strings.Join(fees, ",")
-	output := strings.Join(outputs, ",")
-	if spvTxhash == elaTx.Hash().String() {
+		fee := uint64(elaTx.Outputs[i].Value - amount)
+		record.Outputs = append(record.Outputs, DepositOutput{
+			Address: p.CrossChainAddresses[i],
+			Amount:  uint64(elaTx.Outputs[i].Value),
+			Fee:     fee,
+		})
+		record.Fee += fee
+	}
+	if s.notifiedTx == elaTx.Hash().String() {
 		return
 	}
-	spvTxhash = elaTx.Hash().String()
-	err := spvTransactiondb.Put([]byte(elaTx.Hash().String()+"Fee"), []byte(fee))
-
-	if err != nil {
-		log.Error("SpvServicedb Put Fee: ", "err", err, "elaHash", elaTx.Hash().String())
-	}
-
-	err = spvTransactiondb.Put([]byte(elaTx.Hash().String()+"Address"), []byte(addr))
-
-	if err != nil {
-		log.Error("SpvServicedb Put Address: ", "err", err, "elaHash", elaTx.Hash().String())
-	}
-	err = spvTransactiondb.Put([]byte(elaTx.Hash().String()+"Output"), []byte(output))
-
-	if err != nil {
-		log.Error("SpvServicedb Put Output: ", "err", err, "elaHash", elaTx.Hash().String())
-	}
-	if atomic.LoadInt32(&candSend) == 1 {
-		from := GetDefaultSingerAddr()
-		IteratorUnTransaction(from)
-		f, err := common.StringToFixed64(fees[0])
-		if err != nil {
-			log.Error("SpvSendTransaction Fee StringToFixed64: ", "err", err, "elaHash", elaTx)
-			return
-
-		}
-		fe := new(big.Int).SetInt64(f.IntValue())
-		y := new(big.Int).SetInt64(rate)
-		fee := new(big.Int).Mul(fe, y)
-		SendTransaction(from, elaTx.Hash().String(), fee)
+	s.notifiedTx = elaTx.Hash().String()
+	s.storeDeposit(record)
+	if atomic.LoadInt32(&s.canSend) == 1 && len(record.Outputs) > 0 {
+		from := s.signer()
+		s.IteratorUnTransaction(from)
+		s.SendTransaction(from, elaTx.Hash().String(), SelaToWei(record.Outputs[0].Fee))
 
 	} else {
-		UpTransactionIndex(elaTx.Hash().String())
+		s.UpTransactionIndex(elaTx.Hash().String())
 	}
 	return
 }
 
 //UpTransactionIndex records spv-aware refill transaction index
-func UpTransactionIndex(elaTx string) {
-	muupti.Lock()
-	defer muupti.Unlock()
+func (s *Service) UpTransactionIndex(elaTx string) {
+	if s == nil || s.db == nil {
+		return
+	}
+	s.muIndex.Lock()
+	defer s.muIndex.Unlock()
 	if strings.HasPrefix(elaTx, "0x") {
 		elaTx = elaTx[2:]
 	}
-	index := GetUnTransactionNum(spvTransactiondb, UnTransactionIndex)
+	index := GetUnTransactionNum(s.db, QueueIndexKey)
 	if index == missingNumber {
 		index = 1
 	}
-	err := spvTransactiondb.Put(append([]byte(UnTransaction), encodeUnTransactionNumber(index)...), []byte(elaTx))
+	err := WriteQueuedDeposit(s.db, index, ethCommon.HexToHash(elaTx))
 	if err != nil {
-		log.Error(fmt.Sprintf("SpvServicedb Put UnTransaction: %v", err), "elaHash", elaTx)
+		log.Error(fmt.Sprintf("SpvServicedb Put queued deposit: %v", err), "elaHash", elaTx)
 	}
-	log.Trace(UnTransaction+"put", "index", index, "elaTx", elaTx)
-	err = spvTransactiondb.Put([]byte(UnTransactionIndex), encodeUnTransactionNumber(index+1))
+	log.Trace("Queue deposit", "index", index, "elaTx", elaTx)
+	err = s.db.Put([]byte(QueueIndexKey), encodeUnTransactionNumber(index+1))
 	if err != nil {
-		log.Error("UnTransactionIndexPut", err, index+1)
+		log.Error("SpvServicedb Put queue index", "err", err, "index", index+1)
 		return
 	}
-	log.Trace(UnTransactionIndex+"put", "index", index+1)
-
+	s.setDepositStatus(elaTx, DepositPending, ethCommon.Hash{})
 }
 
 //IteratorUnTransaction iterates before mining and processes existing spv refill transactions
-func IteratorUnTransaction(from ethCommon.Address) {
-	muiterator.Lock()
-	defer muiterator.Unlock()
+func (s *Service) IteratorUnTransaction(from ethCommon.Address) {
+	if s.db == nil {
+		return
+	}
+	s.muIterator.Lock()
+	defer s.muIterator.Unlock()
 
-	_, ok := blocksigner.Signers[from]
-	if !ok && !blocksigner.SelfIsProducer {
+	elaHeight, _ := s.BestHeight()
+	if !blocksigner.ValidateSigner(elaHeight, from) && !blocksigner.SelfIsProducer {
 		log.Error("error signers", "signer", from.String())
 		return
 	}
 
-	if atomic.LoadInt32(&candIterator) == 1 {
+	if atomic.LoadInt32(&s.iterating) == 1 {
 		return
 	}
-	atomic.StoreInt32(&candIterator, 1)
+	atomic.StoreInt32(&s.iterating, 1)
 	go func(addr ethCommon.Address) {
-		defer atomic.StoreInt32(&candIterator, 0)
+		defer atomic.StoreInt32(&s.iterating, 0)
 		for {
-			// stop send tx if candSend == 0
-			if atomic.LoadInt32(&candSend) == 0 {
+			// stop send tx if canSend == 0
+			if atomic.LoadInt32(&s.canSend) == 0 {
 				log.Info("stop send tx, canSend is 0")
 				break
 			}
-			index := GetUnTransactionNum(spvTransactiondb, UnTransactionIndex)
+			index := GetUnTransactionNum(s.db, QueueIndexKey)
 			if index == missingNumber {
 				break
 			}
-			seek := GetUnTransactionNum(spvTransactiondb, UnTransactionSeek)
+			seek := GetUnTransactionNum(s.db, QueueSeekKey)
 			if seek == missingNumber {
 				seek = 1
 			}
@@ -399,46 +444,57 @@ func IteratorUnTransaction(from ethCommon.Address) {
 				log.Info("send over recharge", "seek", seek, "index", index)
 				break
 			}
-			txHash, err := spvTransactiondb.Get(append([]byte(UnTransaction), encodeUnTransactionNumber(seek)...))
-			if err != nil {
-				log.Error("get UnTransaction ", "err", err, "seek", seek)
-				setNextSeek(seek)
-				break
+			if s.batchRecharge != nil && s.batchRecharge() {
+				if !s.sendRechargeBatch(addr, seek, index) {
+					break
+				}
+				continue
+			}
+			txHash, ok := ReadQueuedDeposit(s.db, seek)
+			if !ok {
+				// the entry has been removed by a main chain rollback
+				log.Error("get queued deposit: not found", "seek", seek)
+				s.setNextSeek(seek)
+				continue
 			}
-			fee, _, _ := FindOutputFeeAndaddressByTxHash(string(txHash))
+			elaTx := ethCommon.Bytes2Hex(txHash.Bytes())
+			fee, _, _ := s.FindOutputFeeAndaddressByTxHash(elaTx)
 			if fee.Uint64() <= 0 {
 				break
 			}
-			err, finished := SendTransaction(from, string(txHash), fee)
+			err, finished := s.SendTransaction(from, elaTx, fee)
 			if err != nil {
 				log.Info("SendTransaction failed", "error", err.Error())
 			}
 			if finished {
-				setNextSeek(seek)
+				s.setNextSeek(seek)
 			}
 		}
 
 	}(from)
 }
 
-func setNextSeek(seek uint64) {
-	err := spvTransactiondb.Delete(append([]byte(UnTransaction), encodeUnTransactionNumber(seek)...))
-	log.Trace(UnTransaction+"delete", "seek", seek)
+func (s *Service) setNextSeek(seek uint64) {
+	err := DeleteQueuedDeposit(s.db, seek)
+	log.Trace("Dequeue deposit", "seek", seek)
 	if err != nil {
-		log.Error("UnTransactionIndexDeleteSeek ", "err", err, "seek", seek)
+		log.Error("SpvServicedb Delete queued deposit", "err", err, "seek", seek)
 	}
 
-	err = spvTransactiondb.Put([]byte(UnTransactionSeek), encodeUnTransactionNumber(seek+1))
-	log.Trace(UnTransactionSeek+"put", "seek", seek+1)
+	err = s.db.Put([]byte(QueueSeekKey), encodeUnTransactionNumber(seek+1))
 	if err != nil {
-		log.Error("UnTransactionIndexPutSeek ", err, seek+1)
+		log.Error("SpvServicedb Put queue seek", "err", err, "seek", seek+1)
 		return
 	}
 }
 
 //SendTransaction sends a reload transaction to txpool
-func SendTransaction(from ethCommon.Address, elaTx string, fee *big.Int)(err error, finished bool){
-	ethTx, err := ipcClient.StorageAt(context.Background(), ethCommon.Address{}, ethCommon.HexToHash("0x"+elaTx), nil)
+func (s *Service) SendTransaction(from ethCommon.Address, elaTx string, fee *big.Int)(err error, finished bool){
+	client := s.sideChain()
+	if client == nil {
+		return errSpvNotStarted, false
+	}
+	ethTx, err := client.StorageAt(context.Background(), ethCommon.Address{}, ethCommon.HexToHash("0x"+elaTx), nil)
 	if err != nil {
 		log.Error(fmt.Sprintf("IpcClient StorageAt: %v", err))
 		return err, true
@@ -454,31 +510,32 @@ func SendTransaction(from ethCommon.Address, elaTx string, fee *big.Int)(err err
 		return err, true
 	}
 	msg := ethereum.CallMsg{From: from, To: &ethCommon.Address{}, Data: []byte{}}
-	gasLimit, err := ipcClient.EstimateGas(context.Background(), msg)
+	gasLimit, err := client.EstimateGas(context.Background(), msg)
 	if err != nil {
 		log.Error("IpcClient EstimateGas:", "err", err, "main txhash", elaTx)
-		UpTransactionIndex(elaTx)
+		s.UpTransactionIndex(elaTx)
 		return err, true
 	}
 
 	if gasLimit == 0 {
 		log.Error("gasLimit is zero:","main txhash", elaTx)
-		UpTransactionIndex(elaTx)
+		s.UpTransactionIndex(elaTx)
 		return err, true
 	}
 	gasLimit = gasLimit * GASLimtScale
 
-	if atomic.LoadInt32(&candSend) == 0 {
+	if atomic.LoadInt32(&s.canSend) == 0 {
 		err = errors.New("canSend is 0")
 		return err, false
 	}
 	price := new(big.Int).Quo(fee, new(big.Int).SetUint64(gasLimit))
 	callmsg := ethereum.TXMsg{From: from, To: &ethCommon.Address{}, Gas: gasLimit, Data: data, GasPrice: price}
-	hash, err := ipcClient.SendPublicTransaction(context.Background(), callmsg)
+	hash, err := client.SendPublicTransaction(context.Background(), callmsg)
 	if err != nil {
 		return err, true
 	}
 	log.Info("Cross chain Transaction", "elaTx", elaTx, "ethTh", hash.String())
+	s.setDepositStatus(elaTx, DepositSent, hash)
 	return nil, true
 }
 
@@ -502,61 +559,51 @@ type DatabaseReader interface {
 }
 
 //FindOutputFeeAndaddressByTxHash Finds the eth recharge address, recharge amount, and transaction fee based on the main chain hash.
-func FindOutputFeeAndaddressByTxHash(transactionHash string) (*big.Int, ethCommon.Address, *big.Int) {
+func (s *Service) FindOutputFeeAndaddressByTxHash(transactionHash string) (*big.Int, ethCommon.Address, *big.Int) {
 	var emptyaddr ethCommon.Address
-	transactionHash = strings.Replace(transactionHash, "0x", "", 1)
-	if spvTransactiondb == nil {
+	record := s.findDeposit(transactionHash)
+	if record == nil || len(record.Outputs) == 0 {
 		return new(big.Int), emptyaddr, new(big.Int)
 	}
-	v, err := spvTransactiondb.Get([]byte(transactionHash + "Fee"))
-	if err != nil {
-		log.Error("SpvServicedb Get Fee: ", "err", err, "elaHash", transactionHash)
-		return new(big.Int), emptyaddr, new(big.Int)
-	}
-	fees := strings.Split(string(v), ",")
-	f, err := common.StringToFixed64(fees[0])
-	if err != nil {
-		log.Error("SpvServicedb Get Fee StringToFixed64: ", "err", err, "elaHash", transactionHash)
-		return new(big.Int), emptyaddr, new(big.Int)
-
-	}
-	fe := new(big.Int).SetInt64(f.IntValue())
-	y := new(big.Int).SetInt64(rate)
-
-	addrss, err := spvTransactiondb.Get([]byte(transactionHash + "Address"))
-	if err != nil {
-		log.Error("SpvServicedb Get Address: ", "err", err, "elaHash", transactionHash)
-		return new(big.Int), emptyaddr, new(big.Int)
-
-	}
-	addrs := strings.Split(string(addrss), ",")
-	if !ethCommon.IsHexAddress(addrs[0]) {
+	output := record.Outputs[0]
+	if !ethCommon.IsHexAddress(output.Address) {
 		return new(big.Int), emptyaddr, new(big.Int)
 	}
-	outputs, err := spvTransactiondb.Get([]byte(transactionHash + "Output"))
-	if err != nil {
-		log.Error("SpvServicedb Get elaHash: ", "err", err, "elaHash", transactionHash)
-		return new(big.Int), emptyaddr, new(big.Int)
-
-	}
-	output := strings.Split(string(outputs), ",")
-	o, err := common.StringToFixed64(output[0])
-	if err != nil {
-		log.Error("SpvServicedb Get elaHash StringToFixed64: ", "err", err, "elaHash", transactionHash)
-		return new(big.Int), emptyaddr, new(big.Int)
-
-	}
-	op := new(big.Int).SetInt64(o.IntValue())
-	return new(big.Int).Mul(fe, y), ethCommon.HexToAddress(addrs[0]), new(big.Int).Mul(op, y)
+	return SelaToWei(output.Fee), ethCommon.HexToAddress(output.Address), SelaToWei(output.Amount)
 }
 
-func SendEvilProof(addr ethCommon.Address, info interface{}) {
-	log.Info("Send evil Proof", "signer", addr.String())
-	//ToDO connect ela chain
+//RechargeOutput is an output of a cross chain deposit to a side chain address.
+type RechargeOutput struct {
+	Index   int
+	Address ethCommon.Address
+	Output  *big.Int
+	Fee     *big.Int
+}
 
+//FindRechargeOutputs returns every output of the deposit with a valid side chain address.
+func (s *Service) FindRechargeOutputs(transactionHash string) []*RechargeOutput {
+	record := s.findDeposit(transactionHash)
+	if record == nil {
+		return nil
+	}
+	var result []*RechargeOutput
+	for i, output := range record.Outputs {
+		if !ethCommon.IsHexAddress(output.Address) {
+			continue
+		}
+		result = append(result, &RechargeOutput{
+			Index:   i,
+			Address: ethCommon.HexToAddress(output.Address),
+			Output:  SelaToWei(output.Amount),
+			Fee:     SelaToWei(output.Fee),
+		})
+	}
+	return result
 }
 
-func GetElaHeight() uint64 {
-	//ToDO
-	return 0
+//RechargeOutputKey returns the state key which records the completion of an output of the deposit.
+func RechargeOutputKey(transactionHash string, index int) ethCommon.Hash {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, uint64(index))
+	return crypto.Keccak256Hash(ethCommon.HexToHash(transactionHash).Bytes(), enc)
 }
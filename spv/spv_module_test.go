@@ -0,0 +1,59 @@
+package spv
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	ethCommon "github.com/elastos/Elastos.ELA.SideChain.ETH/common"
+	"github.com/elastos/Elastos.ELA.SideChain.ETH/ethdb/leveldb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindRechargeOutputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spv-recharge")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := leveldb.New(dir, 16, 16, "")
+	assert.NoError(t, err)
+	defer db.Close()
+	s := New(&Config{}, db, nil, nil)
+
+	first := ethCommon.HexToAddress("0x0000000000000000000000000000000000000001")
+	second := ethCommon.HexToAddress("0x0000000000000000000000000000000000000002")
+	elaTx := "ab"
+	assert.NoError(t, WriteDeposit(db, &DepositRecord{
+		MainTxHash: ethCommon.HexToHash(elaTx),
+		Outputs: []DepositOutput{
+			{Address: first.String(), Amount: 100000000, Fee: 10000},
+			{Address: "invalid", Amount: 200000000, Fee: 10000},
+			{Address: second.String(), Amount: 300000000, Fee: 20000},
+			{Address: first.String(), Amount: 10000, Fee: 10000},
+		},
+		Fee: 50000,
+	}))
+
+	outputs := s.FindRechargeOutputs("0x" + elaTx)
+	assert.Equal(t, 3, len(outputs))
+	assert.Equal(t, 0, outputs[0].Index)
+	assert.Equal(t, first, outputs[0].Address)
+	assert.Equal(t, 2, outputs[1].Index)
+	assert.Equal(t, second, outputs[1].Address)
+	assert.Equal(t, new(big.Int).Mul(big.NewInt(300000000), big.NewInt(rate)), outputs[1].Output)
+	assert.Equal(t, new(big.Int).Mul(big.NewInt(20000), big.NewInt(rate)), outputs[1].Fee)
+
+	// the single output lookup only sees the first output
+	fee, addr, output := s.FindOutputFeeAndaddressByTxHash(elaTx)
+	assert.Equal(t, first, addr)
+	assert.Equal(t, outputs[0].Fee, fee)
+	assert.Equal(t, outputs[0].Output, output)
+
+	// the output not covering its fee is left out of the fee
+	assert.Equal(t, new(big.Int).Add(outputs[0].Fee, outputs[1].Fee), s.FindRechargeFee(elaTx))
+
+	assert.NotEqual(t, RechargeOutputKey(elaTx, 0), RechargeOutputKey(elaTx, 2))
+	assert.Equal(t, RechargeOutputKey(elaTx, 2), RechargeOutputKey("0x"+elaTx, 2))
+	assert.Nil(t, s.FindRechargeOutputs("cd"))
+}